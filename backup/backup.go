@@ -0,0 +1,348 @@
+// Package backup snapshots the collections of the service to an archive and
+// restores them. An archive is a gzipped tar of one file per collection, in BSON
+// or in canonical Extended JSON lines, followed by a manifest listing their
+// document counts and checksums. The documents are copied as they are stored,
+// ObjectIDs and timestamps included.
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/snehil-sinha/goBookStore/db"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// FormatVersion is the version of the archives written by this build, which
+// restores the archives of this version and of the earlier ones
+const FormatVersion = 1
+
+// App marks the archives of the service
+const App = "goBookStore"
+
+// ManifestFile is the name of the manifest in the archive, its last file
+const ManifestFile = "manifest.json"
+
+// Collections are the collections backed up, in order. The lock of the
+// migrations is left out.
+var Collections = []string{
+	db.CollectionGoBookStore,
+	db.CollectionBookAudit,
+	db.CollectionBookRevisions,
+	db.CollectionAPIKeys,
+	db.CollectionMigrations,
+}
+
+// batchSize is the number of documents restored at once
+const batchSize = 1000
+
+// Manifest describes an archive
+type Manifest struct {
+	FormatVersion int       `json:"format_version"`
+	App           string    `json:"app"`
+	CreatedAt     time.Time `json:"created_at"`
+	// SchemaVersion is the latest migration applied to the backed up DB
+	SchemaVersion int                  `json:"schema_version"`
+	Encoding      string               `json:"encoding"`
+	Collections   []CollectionManifest `json:"collections"`
+}
+
+// CollectionManifest describes the file of a collection in an archive
+type CollectionManifest struct {
+	Name      string `json:"name"`
+	File      string `json:"file"`
+	Documents int64  `json:"documents"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+}
+
+// ErrCorrupt wraps the errors of the archives which do not match their manifest
+// or cannot be read
+var ErrCorrupt = errors.New("corrupt archive")
+
+func corruptf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrCorrupt, fmt.Sprintf(format, args...))
+}
+
+// BackupOptions configure a backup
+type BackupOptions struct {
+	// Encoding of the documents, BSON (the default) or JSONL
+	Encoding string
+}
+
+// Backup writes the archive of the collections of the store to w, and returns
+// its manifest. The collections are read one after the other, not as a
+// snapshot: changes made meanwhile may be left out.
+func Backup(ctx context.Context, store Store, w io.Writer, opts BackupOptions) (*Manifest, error) {
+	if opts.Encoding == "" {
+		opts.Encoding = BSON
+	}
+	if _, ok := extensions[opts.Encoding]; !ok {
+		return nil, fmt.Errorf("unsupported encoding %q, must be one of %s or %s", opts.Encoding, BSON, JSONL)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	m := &Manifest{
+		FormatVersion: FormatVersion,
+		App:           App,
+		CreatedAt:     time.Now().UTC().Truncate(time.Second),
+		Encoding:      opts.Encoding,
+		Collections:   []CollectionManifest{},
+	}
+	for _, name := range Collections {
+		c, err := backupCollection(ctx, store, tw, m, name)
+		if err != nil {
+			return nil, fmt.Errorf("backing up %s: %w", name, err)
+		}
+		m.Collections = append(m.Collections, *c)
+	}
+
+	raw, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err = writeFile(tw, ManifestFile, m.CreatedAt, int64(len(raw)), bytes.NewReader(raw)); err != nil {
+		return nil, err
+	}
+	if err = tw.Close(); err != nil {
+		return nil, err
+	}
+	return m, gz.Close()
+}
+
+// backupCollection writes the file of a collection to the archive. The file is
+// spooled to a temporary file first, its size being needed ahead of it.
+func backupCollection(ctx context.Context, store Store, tw *tar.Writer, m *Manifest, name string) (*CollectionManifest, error) {
+	tmp, err := os.CreateTemp("", "gbs-backup-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	c := &CollectionManifest{Name: name, File: name + extensions[m.Encoding]}
+	h := sha256.New()
+	enc := newEncoder(m.Encoding, io.MultiWriter(tmp, h))
+	err = store.Each(ctx, name, func(doc bson.Raw) error {
+		if !isRecord(name, doc) {
+			return nil
+		}
+		if name == db.CollectionMigrations {
+			v := doc.Lookup("_id").AsInt64()
+			if int(v) > m.SchemaVersion {
+				m.SchemaVersion = int(v)
+			}
+		}
+		c.Documents++
+		return enc.Encode(doc)
+	})
+	if err == nil {
+		err = enc.Flush()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.Size, err = tmp.Seek(0, io.SeekCurrent); err != nil {
+		return nil, err
+	}
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	c.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return c, writeFile(tw, c.File, m.CreatedAt, c.Size, tmp)
+}
+
+func writeFile(tw *tar.Writer, name string, modTime time.Time, size int64, r io.Reader) error {
+	err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: size, ModTime: modTime, Typeflag: tar.TypeReg})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, r)
+	return err
+}
+
+// RestoreOptions configure a restore
+type RestoreOptions struct {
+	// Drop removes the documents of the collections first, so that they hold
+	// those of the archive only. Otherwise the documents of the archive replace
+	// the stored ones with the same _id, and the others are kept.
+	Drop bool
+	// DryRun verifies the archive without writing anything
+	DryRun bool
+	// SchemaVersion is the latest migration known to the build; archives of a
+	// newer schema are refused. Zero skips the check.
+	SchemaVersion int
+}
+
+// Restore restores the collections of the archive read from r into the store,
+// and returns its manifest. The whole archive is verified against its manifest
+// before anything is written, which is why r is read twice.
+func Restore(ctx context.Context, store Store, r io.ReadSeeker, opts RestoreOptions) (*Manifest, error) {
+	m, err := Verify(r)
+	if err != nil {
+		return nil, err
+	}
+	if opts.SchemaVersion > 0 && m.SchemaVersion > opts.SchemaVersion {
+		return m, fmt.Errorf("the archive has the schema version %d, newer than the version %d of this build", m.SchemaVersion, opts.SchemaVersion)
+	}
+	if opts.DryRun {
+		return m, nil
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]CollectionManifest, len(m.Collections))
+	for _, c := range m.Collections {
+		files[c.File] = c
+	}
+	err = eachFile(r, func(name string, f io.Reader) error {
+		c, ok := files[name]
+		if !ok {
+			return nil
+		}
+		if opts.Drop {
+			if err := store.Clear(ctx, c.Name); err != nil {
+				return err
+			}
+		}
+		return restoreCollection(ctx, store, c.Name, newDecoder(m.Encoding, f))
+	})
+	return m, err
+}
+
+// restoreCollection writes the documents of a collection in batches
+func restoreCollection(ctx context.Context, store Store, name string, dec decoder) error {
+	batch := make([]bson.Raw, 0, batchSize)
+	for {
+		doc, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return corruptf("%s: %s", name, err)
+		}
+		if batch = append(batch, doc); len(batch) == batchSize {
+			if err = store.Put(ctx, name, batch); err != nil {
+				return fmt.Errorf("restoring %s: %w", name, err)
+			}
+			batch = batch[:0]
+		}
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	if err := store.Put(ctx, name, batch); err != nil {
+		return fmt.Errorf("restoring %s: %w", name, err)
+	}
+	return nil
+}
+
+// Verify reads the archive and checks every file against the manifest: its
+// checksum, size and number of documents. It returns the manifest.
+func Verify(r io.Reader) (*Manifest, error) {
+	type stat struct {
+		sum  string
+		size int64
+		docs int64
+	}
+	stats := map[string]stat{}
+	var m *Manifest
+	err := eachFile(r, func(name string, f io.Reader) error {
+		if name == ManifestFile {
+			m = &Manifest{}
+			if err := json.NewDecoder(f).Decode(m); err != nil {
+				return corruptf("invalid manifest: %s", err)
+			}
+			return nil
+		}
+		if m != nil {
+			return corruptf("%s follows the manifest", name)
+		}
+		h := sha256.New()
+		counter := &countingReader{r: io.TeeReader(f, h)}
+		// the manifest comes last, the encoding is told by the extension
+		s := stat{}
+		var err error
+		if s.docs, err = countDocuments(counter, name); err != nil {
+			return corruptf("%s: %s", name, err)
+		}
+		s.sum, s.size = hex.EncodeToString(h.Sum(nil)), counter.n
+		stats[name] = s
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, corruptf("no %s", ManifestFile)
+	}
+	if m.App != App {
+		return nil, corruptf("not an archive of %s", App)
+	}
+	if m.FormatVersion < 1 || m.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("unsupported archive format version %d, this build reads up to %d", m.FormatVersion, FormatVersion)
+	}
+	if _, ok := extensions[m.Encoding]; !ok {
+		return nil, corruptf("unsupported encoding %q", m.Encoding)
+	}
+	for _, c := range m.Collections {
+		s, ok := stats[c.File]
+		switch {
+		case !ok:
+			return nil, corruptf("%s is missing", c.File)
+		case s.sum != c.SHA256 || s.size != c.Size:
+			return nil, corruptf("%s does not match its checksum", c.File)
+		case s.docs != c.Documents:
+			return nil, corruptf("%s has %d documents, %d expected", c.File, s.docs, c.Documents)
+		}
+	}
+	return m, nil
+}
+
+// eachFile calls fn with every regular file of the archive
+func eachFile(r io.Reader, fn func(name string, f io.Reader) error) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return corruptf("%s", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return corruptf("%s", err)
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err = fn(h.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,217 @@
+package backup_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/backup"
+	"github.com/snehil-sinha/goBookStore/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func raw(doc bson.D) bson.Raw {
+	b, err := bson.Marshal(doc)
+	So(err, ShouldBeNil)
+	return b
+}
+
+// rewrite returns the archive with its files changed by fn
+func rewrite(archive []byte, fn func(name string, content []byte) []byte) []byte {
+	gr, err := gzip.NewReader(bytes.NewReader(archive))
+	So(err, ShouldBeNil)
+	tr := tar.NewReader(gr)
+	var out bytes.Buffer
+	gw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(gw)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		So(err, ShouldBeNil)
+		content, err := io.ReadAll(tr)
+		So(err, ShouldBeNil)
+		content = fn(h.Name, content)
+		h.Size = int64(len(content))
+		So(tw.WriteHeader(h), ShouldBeNil)
+		_, err = tw.Write(content)
+		So(err, ShouldBeNil)
+	}
+	So(tw.Close(), ShouldBeNil)
+	So(gw.Close(), ShouldBeNil)
+	return out.Bytes()
+}
+
+func TestBackupRestore(t *testing.T) {
+	ctx := context.Background()
+
+	Convey("Given a store with books, an audit record and migrations", t, func() {
+		created := primitive.NewDateTimeFromTime(time.Date(2024, 3, 1, 10, 30, 0, 123e6, time.UTC))
+		dune := raw(bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "created_at", Value: created},
+			{Key: "title", Value: "Dune"},
+			{Key: "pages", Value: int32(412)},
+			{Key: "prices", Value: bson.A{bson.D{{Key: "amount", Value: 10.99}, {Key: "currency", Value: "USD"}}}},
+		})
+		emma := raw(bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "title", Value: "Emma"}, {Key: "pages", Value: int64(474)}})
+
+		source := backup.NewMemoryStore()
+		So(source.Put(ctx, db.CollectionGoBookStore, []bson.Raw{dune, emma}), ShouldBeNil)
+		So(source.Put(ctx, db.CollectionBookAudit, []bson.Raw{raw(bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "operation", Value: "create"}})}), ShouldBeNil)
+		So(source.Put(ctx, db.CollectionMigrations, []bson.Raw{
+			raw(bson.D{{Key: "_id", Value: int32(1)}}),
+			raw(bson.D{{Key: "_id", Value: int32(4)}}),
+			raw(bson.D{{Key: "_id", Value: "lock"}, {Key: "owner", Value: "host/1"}}),
+		}), ShouldBeNil)
+
+		for _, encoding := range []string{backup.BSON, backup.JSONL} {
+			encoding := encoding
+
+			Convey("When backing it up in "+encoding, func() {
+				var archive bytes.Buffer
+				m, err := backup.Backup(ctx, source, &archive, backup.BackupOptions{Encoding: encoding})
+				So(err, ShouldBeNil)
+
+				Convey("Then the manifest should describe the collections", func() {
+					So(m.FormatVersion, ShouldEqual, backup.FormatVersion)
+					So(m.Encoding, ShouldEqual, encoding)
+					So(m.SchemaVersion, ShouldEqual, 4)
+					So(m.Collections, ShouldHaveLength, len(backup.Collections))
+					So(m.Collections[0].Name, ShouldEqual, db.CollectionGoBookStore)
+					So(m.Collections[0].File, ShouldEqual, db.CollectionGoBookStore+"."+encoding)
+					So(m.Collections[0].Documents, ShouldEqual, 2)
+					So(m.Collections[0].SHA256, ShouldHaveLength, 64)
+
+					verified, err := backup.Verify(bytes.NewReader(archive.Bytes()))
+					So(err, ShouldBeNil)
+					So(verified.Collections, ShouldResemble, m.Collections)
+				})
+
+				Convey("Then the lock of the migrations should be left out", func() {
+					So(m.Collections[4].Name, ShouldEqual, db.CollectionMigrations)
+					So(m.Collections[4].Documents, ShouldEqual, 2)
+				})
+
+				Convey("Then restoring it to an empty store should copy the documents as they are", func() {
+					target := backup.NewMemoryStore()
+					_, err := backup.Restore(ctx, target, bytes.NewReader(archive.Bytes()), backup.RestoreOptions{})
+					So(err, ShouldBeNil)
+					So(target.Documents(db.CollectionGoBookStore), ShouldResemble, []bson.Raw{dune, emma})
+					So(target.Documents(db.CollectionBookAudit), ShouldResemble, source.Documents(db.CollectionBookAudit))
+					So(target.Documents(db.CollectionMigrations), ShouldHaveLength, 2)
+				})
+			})
+		}
+
+		Convey("When restoring its archive to a store with other books", func() {
+			var archive bytes.Buffer
+			_, err := backup.Backup(ctx, source, &archive, backup.BackupOptions{})
+			So(err, ShouldBeNil)
+
+			changed := raw(bson.D{{Key: "_id", Value: dune.Lookup("_id").ObjectID()}, {Key: "title", Value: "Dune Messiah"}})
+			other := raw(bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "title", Value: "Solaris"}})
+			target := backup.NewMemoryStore()
+			So(target.Put(ctx, db.CollectionGoBookStore, []bson.Raw{changed, other}), ShouldBeNil)
+
+			Convey("Then merging should replace the books of the archive and keep the others", func() {
+				_, err := backup.Restore(ctx, target, bytes.NewReader(archive.Bytes()), backup.RestoreOptions{})
+				So(err, ShouldBeNil)
+				So(target.Documents(db.CollectionGoBookStore), ShouldResemble, []bson.Raw{dune, other, emma})
+			})
+
+			Convey("Then dropping first should leave the books of the archive only", func() {
+				_, err := backup.Restore(ctx, target, bytes.NewReader(archive.Bytes()), backup.RestoreOptions{Drop: true})
+				So(err, ShouldBeNil)
+				So(target.Documents(db.CollectionGoBookStore), ShouldResemble, []bson.Raw{dune, emma})
+			})
+
+			Convey("Then dropping first should keep the lock of the migrations", func() {
+				lock := raw(bson.D{{Key: "_id", Value: "lock"}, {Key: "owner", Value: "host/2"}})
+				So(target.Put(ctx, db.CollectionMigrations, []bson.Raw{raw(bson.D{{Key: "_id", Value: int32(9)}}), lock}), ShouldBeNil)
+
+				_, err := backup.Restore(ctx, target, bytes.NewReader(archive.Bytes()), backup.RestoreOptions{Drop: true})
+				So(err, ShouldBeNil)
+				So(target.Documents(db.CollectionMigrations), ShouldResemble, []bson.Raw{
+					lock,
+					raw(bson.D{{Key: "_id", Value: int32(1)}}),
+					raw(bson.D{{Key: "_id", Value: int32(4)}}),
+				})
+			})
+
+			Convey("Then a dry run should write nothing", func() {
+				m, err := backup.Restore(ctx, target, bytes.NewReader(archive.Bytes()), backup.RestoreOptions{Drop: true, DryRun: true})
+				So(err, ShouldBeNil)
+				So(m.Collections[0].Documents, ShouldEqual, 2)
+				So(target.Documents(db.CollectionGoBookStore), ShouldResemble, []bson.Raw{changed, other})
+			})
+
+			Convey("Then an archive of a newer schema should be refused", func() {
+				_, err := backup.Restore(ctx, target, bytes.NewReader(archive.Bytes()), backup.RestoreOptions{SchemaVersion: 3})
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "schema version 4")
+				So(target.Documents(db.CollectionGoBookStore), ShouldResemble, []bson.Raw{changed, other})
+			})
+		})
+	})
+
+	Convey("Given a damaged archive", t, func() {
+		source := backup.NewMemoryStore()
+		So(source.Put(ctx, db.CollectionGoBookStore, []bson.Raw{raw(bson.D{{Key: "_id", Value: int32(1)}, {Key: "title", Value: "Dune"}})}), ShouldBeNil)
+		var archive bytes.Buffer
+		_, err := backup.Backup(ctx, source, &archive, backup.BackupOptions{Encoding: backup.JSONL})
+		So(err, ShouldBeNil)
+		target := backup.NewMemoryStore()
+
+		Convey("When a file does not match its checksum", func() {
+			damaged := rewrite(archive.Bytes(), func(name string, content []byte) []byte {
+				return bytes.Replace(content, []byte("Dune"), []byte("Emma"), 1)
+			})
+			_, err := backup.Restore(ctx, target, bytes.NewReader(damaged), backup.RestoreOptions{Drop: true})
+
+			Convey("Then nothing should be restored", func() {
+				So(errors.Is(err, backup.ErrCorrupt), ShouldBeTrue)
+				So(err.Error(), ShouldContainSubstring, "does not match its checksum")
+				So(target.Documents(db.CollectionGoBookStore), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When the archive is of a newer format", func() {
+			newer := rewrite(archive.Bytes(), func(name string, content []byte) []byte {
+				if name != backup.ManifestFile {
+					return content
+				}
+				var m map[string]any
+				So(json.Unmarshal(content, &m), ShouldBeNil)
+				m["format_version"] = backup.FormatVersion + 1
+				content, err := json.Marshal(m)
+				So(err, ShouldBeNil)
+				return content
+			})
+			_, err := backup.Restore(ctx, target, bytes.NewReader(newer), backup.RestoreOptions{})
+
+			Convey("Then it should be refused", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "unsupported archive format version 2")
+			})
+		})
+
+		Convey("When the file is not an archive", func() {
+			_, err := backup.Verify(strings.NewReader("books.csv"))
+
+			Convey("Then it should be reported as corrupt", func() {
+				So(errors.Is(err, backup.ErrCorrupt), ShouldBeTrue)
+			})
+		})
+	})
+}
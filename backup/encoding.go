@@ -0,0 +1,162 @@
+package backup
+
+// Encodings of the documents in the files of the archives.
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Encodings of the documents
+const (
+	// BSON files are the concatenated documents, like the files of mongodump
+	BSON = "bson"
+	// JSONL files have a document per line in canonical Extended JSON, which
+	// keeps the BSON types
+	JSONL = "jsonl"
+)
+
+// extensions maps the encodings to the extension of their files
+var extensions = map[string]string{
+	BSON:  ".bson",
+	JSONL: ".jsonl",
+}
+
+// maxDocumentSize bounds the size of the documents read, above the 16 MB limit
+// of MongoDB
+const maxDocumentSize = 32 << 20
+
+type encoder interface {
+	Encode(bson.Raw) error
+	Flush() error
+}
+
+type decoder interface {
+	// Decode returns the next document, or io.EOF
+	Decode() (bson.Raw, error)
+}
+
+func newEncoder(encoding string, w io.Writer) encoder {
+	bw := bufio.NewWriter(w)
+	if encoding == JSONL {
+		return &jsonlEncoder{w: bw}
+	}
+	return &bsonEncoder{w: bw}
+}
+
+func newDecoder(encoding string, r io.Reader) decoder {
+	if encoding == JSONL {
+		s := bufio.NewScanner(r)
+		s.Buffer(make([]byte, 64<<10), maxDocumentSize)
+		return &jsonlDecoder{s: s}
+	}
+	return &bsonDecoder{r: bufio.NewReader(r)}
+}
+
+// countDocuments decodes the documents of a file, its encoding told by its
+// extension, and returns their number
+func countDocuments(r io.Reader, name string) (n int64, err error) {
+	var dec decoder
+	for encoding, ext := range extensions {
+		if path.Ext(name) == ext {
+			dec = newDecoder(encoding, r)
+		}
+	}
+	if dec == nil {
+		_, err = io.Copy(io.Discard, r)
+		return 0, err
+	}
+	for {
+		if _, err = dec.Decode(); err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+type bsonEncoder struct {
+	w *bufio.Writer
+}
+
+func (e *bsonEncoder) Encode(doc bson.Raw) error {
+	_, err := e.w.Write(doc)
+	return err
+}
+
+func (e *bsonEncoder) Flush() error {
+	return e.w.Flush()
+}
+
+type bsonDecoder struct {
+	r *bufio.Reader
+}
+
+func (d *bsonDecoder) Decode() (bson.Raw, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(d.r, size[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("truncated document")
+		}
+		return nil, err
+	}
+	n := binary.LittleEndian.Uint32(size[:])
+	if n < 5 || n > maxDocumentSize {
+		return nil, fmt.Errorf("invalid document size %d", n)
+	}
+	doc := make([]byte, n)
+	copy(doc, size[:])
+	if _, err := io.ReadFull(d.r, doc[4:]); err != nil {
+		return nil, fmt.Errorf("truncated document")
+	}
+	if err := bson.Raw(doc).Validate(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+type jsonlEncoder struct {
+	w *bufio.Writer
+}
+
+func (e *jsonlEncoder) Encode(doc bson.Raw) error {
+	line, err := bson.MarshalExtJSON(doc, true, false)
+	if err != nil {
+		return err
+	}
+	if _, err = e.w.Write(line); err != nil {
+		return err
+	}
+	return e.w.WriteByte('\n')
+}
+
+func (e *jsonlEncoder) Flush() error {
+	return e.w.Flush()
+}
+
+type jsonlDecoder struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func (d *jsonlDecoder) Decode() (bson.Raw, error) {
+	if !d.s.Scan() {
+		if err := d.s.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	d.line++
+	var doc bson.D
+	if err := bson.UnmarshalExtJSON(d.s.Bytes(), true, &doc); err != nil {
+		return nil, fmt.Errorf("line %d: %s", d.line, err)
+	}
+	return bson.Marshal(doc)
+}
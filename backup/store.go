@@ -0,0 +1,150 @@
+package backup
+
+// Backends the collections are backed up from and restored to.
+
+import (
+	"context"
+	"sync"
+
+	"github.com/snehil-sinha/goBookStore/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Store is a backend holding the collections
+type Store interface {
+	// Each calls fn with every document of the collection, in a stable order
+	Each(ctx context.Context, collection string, fn func(bson.Raw) error) error
+	// Clear removes every document of the collection, keeping its indexes, but
+	// the lock of the migrations (see isRecord), held by the instance migrating
+	Clear(ctx context.Context, collection string) error
+	// Put stores the documents, replacing those with the same _id
+	Put(ctx context.Context, collection string, docs []bson.Raw) error
+}
+
+// isRecord tells if a document of the collection is backed up and restored:
+// all of them are but the lock of the migrations, whose records have a numeric
+// _id unlike the lock
+func isRecord(collection string, doc bson.Raw) bool {
+	if collection != db.CollectionMigrations {
+		return true
+	}
+	_, ok := doc.Lookup("_id").AsInt64OK()
+	return ok
+}
+
+type mongoStore struct {
+	database *mongo.Database
+}
+
+// NewMongoStore returns the store of a MongoDB database
+func NewMongoStore(database *mongo.Database) Store {
+	return &mongoStore{database: database}
+}
+
+func (ms *mongoStore) Each(ctx context.Context, collection string, fn func(bson.Raw) error) error {
+	cur, err := ms.database.Collection(collection).Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		if err = fn(cur.Current); err != nil {
+			return err
+		}
+	}
+	return cur.Err()
+}
+
+func (ms *mongoStore) Clear(ctx context.Context, collection string) error {
+	filter := bson.M{}
+	if collection == db.CollectionMigrations {
+		filter = bson.M{"_id": bson.M{"$type": "number"}}
+	}
+	_, err := ms.database.Collection(collection).DeleteMany(ctx, filter)
+	return err
+}
+
+func (ms *mongoStore) Put(ctx context.Context, collection string, docs []bson.Raw) error {
+	models := make([]mongo.WriteModel, len(docs))
+	for i, doc := range docs {
+		models[i] = mongo.NewReplaceOneModel().
+			SetFilter(bson.D{{Key: "_id", Value: doc.Lookup("_id")}}).
+			SetReplacement(doc).
+			SetUpsert(true)
+	}
+	_, err := ms.database.Collection(collection).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// MemoryStore is a store held in memory, for the tests. Its documents are kept
+// in the order they were first put.
+type MemoryStore struct {
+	mu          sync.Mutex
+	collections map[string][]bson.Raw
+	// indexes maps the _id of the documents to their index, by collection
+	indexes map[string]map[string]int
+}
+
+// NewMemoryStore returns an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{collections: map[string][]bson.Raw{}, indexes: map[string]map[string]int{}}
+}
+
+func (ms *MemoryStore) Each(ctx context.Context, collection string, fn func(bson.Raw) error) error {
+	ms.mu.Lock()
+	docs := append([]bson.Raw{}, ms.collections[collection]...)
+	ms.mu.Unlock()
+	for _, doc := range docs {
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ms *MemoryStore) Clear(ctx context.Context, collection string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	var kept []bson.Raw
+	index := map[string]int{}
+	for _, doc := range ms.collections[collection] {
+		if !isRecord(collection, doc) {
+			id := doc.Lookup("_id")
+			index[string(id.Type)+string(id.Value)] = len(kept)
+			kept = append(kept, doc)
+		}
+	}
+	ms.collections[collection], ms.indexes[collection] = kept, index
+	return nil
+}
+
+func (ms *MemoryStore) Put(ctx context.Context, collection string, docs []bson.Raw) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	index := ms.indexes[collection]
+	if index == nil {
+		index = map[string]int{}
+		ms.indexes[collection] = index
+	}
+	for _, doc := range docs {
+		doc = append(bson.Raw{}, doc...)
+		id := doc.Lookup("_id")
+		key := string(id.Type) + string(id.Value)
+		if i, ok := index[key]; ok {
+			ms.collections[collection][i] = doc
+			continue
+		}
+		index[key] = len(ms.collections[collection])
+		ms.collections[collection] = append(ms.collections[collection], doc)
+	}
+	return nil
+}
+
+// Documents returns the documents of a collection
+func (ms *MemoryStore) Documents(collection string) []bson.Raw {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return append([]bson.Raw{}, ms.collections[collection]...)
+}
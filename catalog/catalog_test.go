@@ -0,0 +1,263 @@
+package catalog_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/catalog"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/models/book/mocks"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+func newBook(title string, pages int) *book.Book {
+	b := book.NewBook(title, pages)
+	b.ID = primitive.NewObjectID()
+	return b
+}
+
+func readAll(r catalog.Reader) (records []*catalog.Record, errs []string) {
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			So(err, ShouldHaveSameTypeAs, &catalog.RecordError{})
+			errs = append(errs, err.Error())
+			continue
+		}
+		records = append(records, rec)
+	}
+}
+
+func TestFormats(t *testing.T) {
+	Convey("Given a book", t, func() {
+		b := newBook("Dune, Part One", 412)
+		b.CreatedAt = time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+		b.UpdatedAt = b.CreatedAt
+		b.ISBN = "9780441013593"
+		b.Contributors = []book.Contributor{{Name: "Frank Herbert", Role: book.RoleAuthor}, {Name: "Brian Herbert", Role: book.RoleEditor}}
+		b.Prices = []book.Price{{Amount: 9.99, Currency: "EUR"}, {Amount: 12, Currency: "USD"}}
+		b.Availability = book.OutOfStock
+		b.Reference = "dune-1965"
+		record := func(line int) []*catalog.Record {
+			return []*catalog.Record{{
+				Line: line, ID: b.ID, Title: "Dune, Part One", Pages: 412,
+				ISBN: b.ISBN, Contributors: b.Contributors, Prices: b.Prices, Availability: b.Availability, Reference: b.Reference,
+			}}
+		}
+
+		Convey("When it is exported as CSV and read back", func() {
+			var buf bytes.Buffer
+			w, err := catalog.NewWriter(catalog.CSV, &buf)
+			So(err, ShouldBeNil)
+			So(w.Write(b), ShouldBeNil)
+			So(w.Flush(), ShouldBeNil)
+
+			r, err := catalog.NewReader(catalog.CSV, &buf, nil)
+			So(err, ShouldBeNil)
+			records, errs := readAll(r)
+
+			Convey("Then the record should hold its fields, metadata included", func() {
+				So(errs, ShouldBeEmpty)
+				So(records, ShouldResemble, record(2))
+				So(records[0].Book().ISBN, ShouldEqual, b.ISBN)
+			})
+		})
+
+		Convey("When it is exported as JSON Lines and read back", func() {
+			var buf bytes.Buffer
+			w, err := catalog.NewWriter(catalog.JSONL, &buf)
+			So(err, ShouldBeNil)
+			So(w.Write(b), ShouldBeNil)
+			So(w.Flush(), ShouldBeNil)
+
+			r, err := catalog.NewReader(catalog.JSONL, &buf, nil)
+			So(err, ShouldBeNil)
+			records, errs := readAll(r)
+
+			Convey("Then the record should hold its fields, metadata included", func() {
+				So(errs, ShouldBeEmpty)
+				So(records, ShouldResemble, record(1))
+			})
+		})
+	})
+
+	Convey("Given a CSV file with custom headers", t, func() {
+		file := "Book Title,Page Count,Shelf\nDune,412,A1\nEmma,many,B2\n"
+
+		Convey("When the headers are mapped to the fields", func() {
+			r, err := catalog.NewReader(catalog.CSV, strings.NewReader(file), map[string]string{
+				"Book Title": "title",
+				"Page Count": "pages",
+			})
+			So(err, ShouldBeNil)
+			records, errs := readAll(r)
+
+			Convey("Then the valid records should be read and the others reported", func() {
+				So(records, ShouldResemble, []*catalog.Record{{Line: 2, Title: "Dune", Pages: 412}})
+				So(errs, ShouldResemble, []string{`line 3: pages must be an integer, got "many"`})
+			})
+		})
+
+		Convey("When a mapped header is not in the file", func() {
+			_, err := catalog.NewReader(catalog.CSV, strings.NewReader(file), map[string]string{"Name": "title"})
+
+			Convey("Then it should be rejected", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When the contributors or the prices are malformed", func() {
+			r, err := catalog.NewReader(catalog.CSV, strings.NewReader("title,pages,contributors,prices\nDune,412,Frank Herbert,\nEmma,474,,many EUR\n"), nil)
+			So(err, ShouldBeNil)
+			records, errs := readAll(r)
+
+			Convey("Then the records should be reported", func() {
+				So(records, ShouldBeEmpty)
+				So(errs, ShouldHaveLength, 2)
+			})
+		})
+
+		Convey("When a header is mapped to an unknown field", func() {
+			_, err := catalog.NewReader(catalog.CSV, strings.NewReader(file), map[string]string{"Shelf": "shelf"})
+
+			Convey("Then it should be rejected", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+
+	Convey("Given an unknown format", t, func() {
+		_, rerr := catalog.NewReader("xml", strings.NewReader(""), nil)
+		_, werr := catalog.NewWriter("xml", io.Discard)
+
+		Convey("Then no reader or writer should be returned", func() {
+			So(rerr, ShouldNotBeNil)
+			So(werr, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestImport(t *testing.T) {
+	Convey("Given a book service", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		m := mocks.NewMockBookService(ctrl)
+		log := &common.Logger{Logger: zap.NewNop()}
+
+		jsonl := func(lines ...string) catalog.Reader {
+			r, err := catalog.NewReader(catalog.JSONL, strings.NewReader(strings.Join(lines, "\n")), nil)
+			So(err, ShouldBeNil)
+			return r
+		}
+		byTitle := func(title string, books ...*book.Book) *gomock.Call {
+			return m.EXPECT().List(gomock.Any(), book.ListOptions{Limit: 2, Filter: book.Filter{Title: title}}).
+				Return(books, int64(len(books)), nil)
+		}
+
+		Convey("When records are matched by title", func() {
+			dune, emma := newBook("Dune", 412), newBook("Emma", 474)
+			byTitle("Dune", dune)
+			byTitle("Emma", emma)
+			byTitle("Persuasion")
+			m.EXPECT().Update(gomock.Any(), gomock.Any(), dune.ID.Hex(), book.NewBook("Dune", 500)).Return(newBook("Dune", 500), nil)
+			m.EXPECT().Create(gomock.Any(), gomock.Any(), book.NewBook("Persuasion", 249)).Return(newBook("Persuasion", 249), nil)
+
+			report, err := catalog.Import(context.Background(), log, m, jsonl(
+				`{"title": "Dune", "pages": 500}`,
+				`{"title": "Emma", "pages": 474}`,
+				`{"title": "Persuasion", "pages": 249}`,
+				`{"title": "Persuasion", "pages": 249`,
+			), catalog.ImportOptions{})
+
+			Convey("Then the stored books should be updated and the others created", func() {
+				So(err, ShouldBeNil)
+				So(report.Updated, ShouldEqual, 1)
+				So(report.Unchanged, ShouldEqual, 1)
+				So(report.Created, ShouldEqual, 1)
+				So(report.Invalid, ShouldEqual, 1)
+				So(report.Records[1].ID, ShouldEqual, emma.ID.Hex())
+				So(report.Records[3].Line, ShouldEqual, 4)
+			})
+		})
+
+		Convey("When a record sets the metadata of a stored book", func() {
+			dune := newBook("Dune", 412)
+			byTitle("Dune", dune)
+			data := book.NewBook("Dune", 0)
+			data.ISBN = "9780441013593"
+			data.Availability = book.OutOfStock
+			m.EXPECT().Update(gomock.Any(), gomock.Any(), dune.ID.Hex(), data).Return(dune, nil)
+
+			report, err := catalog.Import(context.Background(), log, m, jsonl(`{"title": "Dune", "isbn": "9780441013593", "availability": "out_of_stock"}`), catalog.ImportOptions{})
+
+			Convey("Then the book should be updated with the metadata", func() {
+				So(err, ShouldBeNil)
+				So(report.Updated, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When a file holds the same book twice", func() {
+			byTitle("Dune").Times(2)
+			m.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(newBook("Dune", 412), nil)
+
+			report, err := catalog.Import(context.Background(), log, m, jsonl(
+				`{"title": "Dune", "pages": 412}`,
+				`{"title": "Dune", "pages": 412}`,
+			), catalog.ImportOptions{})
+
+			Convey("Then the second record should be reported as a duplicate", func() {
+				So(err, ShouldBeNil)
+				So(report.Created, ShouldEqual, 1)
+				So(report.Records[1].Status, ShouldEqual, catalog.Invalid)
+				So(report.Records[1].Error, ShouldEqual, "validation error: duplicate of line 1")
+			})
+		})
+
+		Convey("When records are matched by an id which is not stored", func() {
+			id := primitive.NewObjectID()
+			m.EXPECT().ReadById(gomock.Any(), id.Hex()).Return(nil, book.ErrNotFound)
+
+			report, err := catalog.Import(context.Background(), log, m, jsonl(`{"id": "`+id.Hex()+`", "title": "", "pages": 412}`),
+				catalog.ImportOptions{Key: catalog.KeyID, DryRun: true})
+
+			Convey("Then a dry run should report the record as invalid without writing", func() {
+				So(err, ShouldBeNil)
+				So(report.DryRun, ShouldBeTrue)
+				So(report.Invalid, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When the service fails unexpectedly", func() {
+			m.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, int64(0), errors.New("connection reset"))
+
+			report, err := catalog.Import(context.Background(), log, m, jsonl(`{"title": "Dune", "pages": 412}`), catalog.ImportOptions{})
+
+			Convey("Then the import should stop", func() {
+				So(err, ShouldNotBeNil)
+				So(report.Records, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When the key is unknown", func() {
+			report, err := catalog.Import(context.Background(), log, m, jsonl(), catalog.ImportOptions{Key: "isbn"})
+
+			Convey("Then nothing should be imported", func() {
+				So(err, ShouldNotBeNil)
+				So(report, ShouldBeNil)
+			})
+		})
+	})
+}
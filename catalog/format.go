@@ -0,0 +1,361 @@
+package catalog
+
+// Exchange formats of the catalog: CSV and JSON Lines.
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Formats of the catalog files
+const (
+	CSV   = "csv"
+	JSONL = "jsonl"
+)
+
+// Formats lists the supported formats
+var Formats = []string{CSV, JSONL}
+
+// ContentTypes maps the formats to their media type
+var ContentTypes = map[string]string{
+	CSV:   "text/csv",
+	JSONL: "application/x-ndjson",
+}
+
+// Columns of the exported CSV files
+var Columns = []string{"id", "title", "pages", "isbn", "contributors", "prices", "availability", "reference", "created_at", "updated_at"}
+
+// Fields of a record, which the CSV headers may be mapped to
+var Fields = []string{"id", "title", "pages", "isbn", "contributors", "prices", "availability", "reference"}
+
+// Writer encodes books in a catalog format
+type Writer interface {
+	Write(*book.Book) error
+	// Flush writes the buffered books to the underlying writer
+	Flush() error
+	// Close ends the file and flushes it, without closing the underlying writer
+	Close() error
+}
+
+// NewWriter returns a writer of the format, which writes the CSV header right away
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case CSV:
+		cw := &csvWriter{w: csv.NewWriter(w)}
+		return cw, cw.w.Write(Columns)
+	case JSONL:
+		bw := bufio.NewWriter(w)
+		return &jsonlWriter{w: bw, enc: json.NewEncoder(bw)}, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q, must be one of %s", format, strings.Join(Formats, ", "))
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (cw *csvWriter) Write(b *book.Book) error {
+	return cw.w.Write([]string{
+		b.ID.Hex(),
+		b.Title,
+		strconv.Itoa(b.Pages),
+		b.ISBN,
+		formatContributors(b.Contributors),
+		formatPrices(b.Prices),
+		b.Availability,
+		b.Reference,
+		b.CreatedAt.UTC().Format(time.RFC3339),
+		b.UpdatedAt.UTC().Format(time.RFC3339),
+	})
+}
+
+func (cw *csvWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func (cw *csvWriter) Close() error {
+	return cw.Flush()
+}
+
+type jsonlWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (jw *jsonlWriter) Write(b *book.Book) error {
+	return jw.enc.Encode(b)
+}
+
+func (jw *jsonlWriter) Flush() error {
+	return jw.w.Flush()
+}
+
+func (jw *jsonlWriter) Close() error {
+	return jw.Flush()
+}
+
+// Record is a book read from a catalog file. Only the fields present in the
+// file are set, ID is the zero ObjectID if absent.
+type Record struct {
+	// Line of the record in the file
+	Line         int
+	ID           primitive.ObjectID
+	Title        string
+	Pages        int
+	ISBN         string
+	Contributors []book.Contributor
+	Prices       []book.Price
+	Availability string
+	Reference    string
+}
+
+// Book returns the fields of the record as a book
+func (r *Record) Book() *book.Book {
+	b := book.NewBook(r.Title, r.Pages)
+	b.ISBN = r.ISBN
+	b.Contributors = r.Contributors
+	b.Prices = r.Prices
+	b.Availability = r.Availability
+	b.Reference = r.Reference
+	return b
+}
+
+// Reader decodes the records of a catalog file
+type Reader interface {
+	// Read returns the next record, or io.EOF at the end of the file. A *RecordError
+	// reports an invalid record and reading may go on; other errors are fatal.
+	Read() (*Record, error)
+}
+
+// RecordError reports a record which could not be decoded
+type RecordError struct {
+	Line int
+	Err  error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+// NewReader returns a reader of the format. For CSV, mapping maps the column
+// headers of the file to record fields (e.g. "Book Title" to "title"); headers
+// which are not mapped are matched case insensitively to the fields, and the
+// remaining columns are ignored.
+func NewReader(format string, r io.Reader, mapping map[string]string) (Reader, error) {
+	switch format {
+	case CSV:
+		return newCSVReader(r, mapping)
+	case JSONL:
+		return &jsonlReader{s: bufio.NewScanner(r)}, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q, must be one of %s", format, strings.Join(Formats, ", "))
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int // field to column index
+}
+
+func newCSVReader(r io.Reader, mapping map[string]string) (*csvReader, error) {
+	cr := &csvReader{r: csv.NewReader(r), columns: map[string]int{}}
+	cr.r.FieldsPerRecord = -1
+	cr.r.TrimLeadingSpace = true
+
+	header, err := cr.r.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty, a header is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]bool{}
+	for i, h := range header {
+		headers[h] = true
+		field, ok := mapping[h]
+		if !ok {
+			field = strings.ToLower(strings.TrimSpace(h))
+		}
+		cr.columns[field] = i
+	}
+	for h, field := range mapping {
+		if !headers[h] {
+			return nil, fmt.Errorf("mapped column %q is not in the header", h)
+		}
+		if !isField(field) {
+			return nil, fmt.Errorf("column %q is mapped to %q, must be one of %s", h, field, strings.Join(Fields, ", "))
+		}
+	}
+	return cr, nil
+}
+
+func (cr *csvReader) Read() (*Record, error) {
+	row, err := cr.r.Read()
+	if err != nil {
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			return nil, &RecordError{Line: perr.Line, Err: perr.Err}
+		}
+		return nil, err
+	}
+	line, _ := cr.r.FieldPos(0)
+
+	get := func(field string) string {
+		if i, ok := cr.columns[field]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	rec := &Record{
+		Line:         line,
+		Title:        get("title"),
+		ISBN:         get("isbn"),
+		Availability: get("availability"),
+		Reference:    get("reference"),
+	}
+	if id := get("id"); id != "" {
+		if rec.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+			return nil, &RecordError{Line: line, Err: fmt.Errorf("id: %s", err)}
+		}
+	}
+	if pages := get("pages"); pages != "" {
+		if rec.Pages, err = strconv.Atoi(pages); err != nil {
+			return nil, &RecordError{Line: line, Err: fmt.Errorf("pages must be an integer, got %q", pages)}
+		}
+	}
+	if rec.Contributors, err = parseContributors(get("contributors")); err != nil {
+		return nil, &RecordError{Line: line, Err: err}
+	}
+	if rec.Prices, err = parsePrices(get("prices")); err != nil {
+		return nil, &RecordError{Line: line, Err: err}
+	}
+	return rec, nil
+}
+
+func isField(field string) bool {
+	for _, f := range Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// The contributors and the prices of the CSV files are separated by listSeparator,
+// e.g. "Frank Herbert (author); Brian Herbert (editor)" and "9.99 EUR; 11.99 USD"
+const listSeparator = "; "
+
+func formatContributors(contributors []book.Contributor) string {
+	items := make([]string, len(contributors))
+	for i, c := range contributors {
+		items[i] = c.Name + " (" + c.Role + ")"
+	}
+	return strings.Join(items, listSeparator)
+}
+
+func parseContributors(s string) (contributors []book.Contributor, err error) {
+	if s == "" {
+		return nil, nil
+	}
+	for _, item := range strings.Split(s, strings.TrimSpace(listSeparator)) {
+		item = strings.TrimSpace(item)
+		name, role, ok := strings.Cut(item, " (")
+		if !ok || !strings.HasSuffix(role, ")") {
+			return nil, fmt.Errorf("contributors: %q must be a name followed by a role in parentheses", item)
+		}
+		contributors = append(contributors, book.Contributor{Name: strings.TrimSpace(name), Role: strings.TrimSuffix(role, ")")})
+	}
+	return contributors, nil
+}
+
+func formatPrices(prices []book.Price) string {
+	items := make([]string, len(prices))
+	for i, p := range prices {
+		items[i] = strconv.FormatFloat(p.Amount, 'f', -1, 64) + " " + p.Currency
+	}
+	return strings.Join(items, listSeparator)
+}
+
+func parsePrices(s string) (prices []book.Price, err error) {
+	if s == "" {
+		return nil, nil
+	}
+	for _, item := range strings.Split(s, strings.TrimSpace(listSeparator)) {
+		item = strings.TrimSpace(item)
+		amount, currency, ok := strings.Cut(item, " ")
+		p := book.Price{Currency: strings.TrimSpace(currency)}
+		if ok {
+			p.Amount, err = strconv.ParseFloat(amount, 64)
+		}
+		if !ok || err != nil {
+			return nil, fmt.Errorf("prices: %q must be an amount followed by a currency", item)
+		}
+		prices = append(prices, p)
+	}
+	return prices, nil
+}
+
+type jsonlReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func (jr *jsonlReader) Read() (*Record, error) {
+	for jr.s.Scan() {
+		jr.line++
+		data := strings.TrimSpace(jr.s.Text())
+		if data == "" {
+			continue
+		}
+
+		// the fields of the exported books
+		var fields struct {
+			ID           string             `json:"id"`
+			Title        string             `json:"title"`
+			Pages        int                `json:"pages"`
+			ISBN         string             `json:"isbn"`
+			Contributors []book.Contributor `json:"contributors"`
+			Prices       []book.Price       `json:"prices"`
+			Availability string             `json:"availability"`
+			Reference    string             `json:"reference"`
+		}
+		if err := json.Unmarshal([]byte(data), &fields); err != nil {
+			return nil, &RecordError{Line: jr.line, Err: err}
+		}
+
+		rec := &Record{
+			Line:         jr.line,
+			Title:        fields.Title,
+			Pages:        fields.Pages,
+			ISBN:         fields.ISBN,
+			Contributors: fields.Contributors,
+			Prices:       fields.Prices,
+			Availability: fields.Availability,
+			Reference:    fields.Reference,
+		}
+		if fields.ID != "" {
+			id, err := primitive.ObjectIDFromHex(fields.ID)
+			if err != nil {
+				return nil, &RecordError{Line: jr.line, Err: fmt.Errorf("id: %s", err)}
+			}
+			rec.ID = id
+		}
+		return rec, nil
+	}
+	if err := jr.s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
@@ -0,0 +1,259 @@
+package catalog
+
+// Import of catalog files, upserting the books by key.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Keys identifying the stored book a record updates
+const (
+	KeyID    = "id"
+	KeyTitle = "title"
+)
+
+// Statuses of the imported records. Duplicate is only used by the imports
+// skipping the books already in the catalog, and Deleted by the ones deleting
+// books, see the marc and onix packages.
+const (
+	Created   = "created"
+	Updated   = "updated"
+	Unchanged = "unchanged"
+	Duplicate = "duplicate"
+	Deleted   = "deleted"
+	Invalid   = "invalid"
+)
+
+// ImportOptions configure an import
+type ImportOptions struct {
+	// Key is the field matching the records to the stored books, id or title. It
+	// only applies to the CSV and JSON Lines files.
+	Key string
+	// DryRun validates the records without writing anything
+	DryRun bool
+}
+
+// FieldError reports a field of a record whose value could not be mapped to the
+// book, and was left out
+type FieldError struct {
+	Field string `json:"field"`
+	Error string `json:"error"`
+}
+
+// RecordReport is the outcome of the import of a record. A record is identified
+// by its line in a CSV or JSON Lines file, by its index and control number in a
+// MARC file, or by its reference in an ONIX message.
+type RecordReport struct {
+	Line          int          `json:"line,omitempty"`
+	Index         int          `json:"index,omitempty"`
+	ControlNumber string       `json:"control_number,omitempty"`
+	Reference     string       `json:"reference,omitempty"`
+	Status        string       `json:"status"`
+	ID            string       `json:"id,omitempty"`
+	Error         string       `json:"error,omitempty"`
+	Unmapped      []string     `json:"unmapped,omitempty"`
+	Invalid       []FieldError `json:"invalid,omitempty"`
+}
+
+// Report summarizes an import. In a dry run, the statuses are the ones the
+// records would have.
+type Report struct {
+	DryRun     bool           `json:"dry_run"`
+	Created    int            `json:"created"`
+	Updated    int            `json:"updated"`
+	Unchanged  int            `json:"unchanged"`
+	Duplicates int            `json:"duplicates"`
+	Deleted    int            `json:"deleted"`
+	Invalid    int            `json:"invalid"`
+	Records    []RecordReport `json:"records"`
+}
+
+// NewReport returns an empty report
+func NewReport(opts ImportOptions) *Report {
+	return &Report{DryRun: opts.DryRun, Records: []RecordReport{}}
+}
+
+// Add adds the outcome of a record to the report
+func (r *Report) Add(rec RecordReport) {
+	switch rec.Status {
+	case Created:
+		r.Created++
+	case Updated:
+		r.Updated++
+	case Unchanged:
+		r.Unchanged++
+	case Duplicate:
+		r.Duplicates++
+	case Deleted:
+		r.Deleted++
+	case Invalid:
+		r.Invalid++
+	}
+	r.Records = append(r.Records, rec)
+}
+
+// Import upserts the records read from r: a record matching a stored book by key
+// updates the fields it sets (see Book.Apply), other records create a book. Every book is checked
+// with Book.Validate, and the invalid records are reported without stopping the
+// import. The returned error is only set if the import could not go on, in which
+// case the report covers the records imported so far.
+func Import(ctx context.Context, log *common.Logger, svc book.BookService, r Reader, opts ImportOptions) (*Report, error) {
+	if opts.Key == "" {
+		opts.Key = KeyTitle
+	}
+	if opts.Key != KeyID && opts.Key != KeyTitle {
+		return nil, fmt.Errorf("unsupported key %q, must be one of id, title", opts.Key)
+	}
+
+	im := &importer{
+		ctx:   ctx,
+		log:   log,
+		svc:   svc,
+		opts:  opts,
+		books: map[string]int{},
+		ids:   map[string]int{},
+	}
+	report := NewReport(opts)
+
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return report, nil
+		}
+		var recErr *RecordError
+		if errors.As(err, &recErr) {
+			report.Add(RecordReport{Line: recErr.Line, Status: Invalid, Error: recErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+
+		out, err := im.upsert(rec)
+		if err != nil {
+			return report, err
+		}
+		report.Add(out)
+	}
+}
+
+type importer struct {
+	ctx  context.Context
+	log  *common.Logger
+	svc  book.BookService
+	opts ImportOptions
+	// lines of the books and of the stored ids written by the import, to catch
+	// the duplicates within the file
+	books map[string]int
+	ids   map[string]int
+}
+
+// upsert imports a record, returning an error only if the import cannot go on
+func (im *importer) upsert(rec *Record) (RecordReport, error) {
+	out := RecordReport{Line: rec.Line}
+	invalid := func(format string, args ...any) (RecordReport, error) {
+		out.Status, out.Error = Invalid, fmt.Sprintf(format, args...)
+		return out, nil
+	}
+
+	current, err := im.find(rec)
+	if err != nil {
+		if IsRecordError(err) {
+			return invalid("%s", err)
+		}
+		return out, err
+	}
+
+	data := rec.Book()
+	b := data
+	out.Status = Created
+	if current != nil {
+		out.ID = current.ID.Hex()
+		if line, ok := im.ids[out.ID]; ok {
+			return invalid("book %s is already imported from line %d", out.ID, line)
+		}
+		im.ids[out.ID] = rec.Line
+
+		changed := *current
+		changed.Apply(data)
+		if reflect.DeepEqual(&changed, current) {
+			out.Status = Unchanged
+			return out, nil
+		}
+		b = &changed
+		out.Status = Updated
+	} else if !rec.ID.IsZero() {
+		b.ID = rec.ID
+	}
+
+	key := b.Title + "\x00" + strconv.Itoa(b.Pages)
+	if line, ok := im.books[key]; ok {
+		return invalid("validation error: duplicate of line %d", line)
+	}
+
+	if im.opts.DryRun {
+		if err := b.Validate(); err != nil {
+			return invalid("validation error: %s", err)
+		}
+	} else {
+		switch out.Status {
+		case Created:
+			b, err = im.svc.Create(im.ctx, im.log, b)
+		case Updated:
+			b, err = im.svc.Update(im.ctx, im.log, out.ID, data)
+		}
+		if err != nil {
+			if IsRecordError(err) {
+				return invalid("%s", err)
+			}
+			return out, err
+		}
+		out.ID = b.ID.Hex()
+	}
+
+	im.books[key] = rec.Line
+	return out, nil
+}
+
+// find returns the stored book matching the key of the record, nil if none
+func (im *importer) find(rec *Record) (*book.Book, error) {
+	switch im.opts.Key {
+	case KeyID:
+		if rec.ID.IsZero() {
+			return nil, nil
+		}
+		b, err := im.svc.ReadById(im.log, rec.ID.Hex())
+		if errors.Is(err, book.ErrNotFound) {
+			return nil, nil
+		}
+		return b, err
+
+	default:
+		if rec.Title == "" {
+			return nil, fmt.Errorf("%w: title is required to match the books by title", book.ErrValidation)
+		}
+		books, _, err := im.svc.List(im.log, book.ListOptions{Limit: 2, Filter: book.Filter{Title: rec.Title}})
+		if err != nil || len(books) == 0 {
+			return nil, err
+		}
+		if len(books) > 1 {
+			return nil, fmt.Errorf("%w: several books have the title %q", book.ErrValidation, rec.Title)
+		}
+		return books[0], nil
+	}
+}
+
+// IsRecordError reports whether an error of the book service is due to the record
+func IsRecordError(err error) bool {
+	return errors.Is(err, book.ErrNotFound) || errors.Is(err, primitive.ErrInvalidHex) ||
+		errors.Is(err, book.ErrValidation)
+}
@@ -0,0 +1,173 @@
+package marc
+
+// MARC 21 records in the ISO 2709 exchange format.
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/snehil-sinha/goBookStore/models/book"
+)
+
+// Delimiters of the ISO 2709 format
+const (
+	subfieldDelimiter = 0x1F
+	fieldTerminator   = 0x1E
+	recordTerminator  = 0x1D
+)
+
+const (
+	leaderLength         = 24
+	directoryEntryLength = 12
+	// maxRecordLength is the largest record length the leader can hold
+	maxRecordLength = 99999
+)
+
+// Encode returns the ISO 2709 encoding of the record
+func (r *Record) Encode() ([]byte, error) {
+	var dir, data bytes.Buffer
+	for _, f := range r.Fields {
+		start := data.Len()
+		if isControl(f.Tag) {
+			data.WriteString(f.Value)
+		} else {
+			data.WriteByte(indicator(f.Ind1))
+			data.WriteByte(indicator(f.Ind2))
+			for _, sf := range f.Subfields {
+				data.WriteByte(subfieldDelimiter)
+				data.WriteByte(sf.Code)
+				data.WriteString(sf.Value)
+			}
+		}
+		data.WriteByte(fieldTerminator)
+		fmt.Fprintf(&dir, "%3s%04d%05d", f.Tag, data.Len()-start, start)
+	}
+	dir.WriteByte(fieldTerminator)
+
+	base := leaderLength + dir.Len()
+	length := base + data.Len() + 1
+	if length > maxRecordLength {
+		return nil, fmt.Errorf("record of %d bytes exceeds the maximum of %d", length, maxRecordLength)
+	}
+
+	leader := []byte(newLeader)
+	if len(r.Leader) == leaderLength {
+		leader = []byte(r.Leader)
+	}
+	copy(leader[0:5], fmt.Sprintf("%05d", length))
+	copy(leader[12:17], fmt.Sprintf("%05d", base))
+
+	out := make([]byte, 0, length)
+	out = append(out, leader...)
+	out = append(out, dir.Bytes()...)
+	out = append(out, data.Bytes()...)
+	return append(out, recordTerminator), nil
+}
+
+func indicator(b byte) byte {
+	if b == 0 {
+		return ' '
+	}
+	return b
+}
+
+// Decode parses a record in the ISO 2709 format, with or without its terminator
+func Decode(raw []byte) (*Record, error) {
+	raw = bytes.TrimSuffix(raw, []byte{recordTerminator})
+	if len(raw) < leaderLength {
+		return nil, errors.New("record shorter than its leader")
+	}
+	r := &Record{Leader: string(raw[:leaderLength])}
+
+	base, err := strconv.Atoi(string(raw[12:17]))
+	if err != nil || base <= leaderLength || base > len(raw) {
+		return nil, fmt.Errorf("invalid base address of data %q", raw[12:17])
+	}
+	dir := bytes.TrimSuffix(raw[leaderLength:base], []byte{fieldTerminator})
+	if len(dir)%directoryEntryLength != 0 {
+		return nil, errors.New("invalid directory length")
+	}
+	data := raw[base:]
+
+	for i := 0; i < len(dir); i += directoryEntryLength {
+		entry := dir[i : i+directoryEntryLength]
+		tag := string(entry[:3])
+		length, err1 := strconv.Atoi(string(entry[3:7]))
+		start, err2 := strconv.Atoi(string(entry[7:12]))
+		if err1 != nil || err2 != nil || start+length > len(data) {
+			return nil, fmt.Errorf("invalid directory entry %q", entry)
+		}
+		value := bytes.TrimSuffix(data[start:start+length], []byte{fieldTerminator})
+
+		if isControl(tag) {
+			r.Fields = append(r.Fields, Field{Tag: tag, Value: string(value)})
+			continue
+		}
+		if len(value) < 2 {
+			return nil, fmt.Errorf("field %s has no indicators", tag)
+		}
+		f := Field{Tag: tag, Ind1: value[0], Ind2: value[1]}
+		for _, sf := range bytes.Split(value[2:], []byte{subfieldDelimiter}) {
+			if len(sf) == 0 {
+				continue
+			}
+			f.Subfields = append(f.Subfields, Subfield{Code: sf[0], Value: string(sf[1:])})
+		}
+		r.Fields = append(r.Fields, f)
+	}
+	return r, nil
+}
+
+type binaryWriter struct {
+	w *bufio.Writer
+}
+
+func (bw *binaryWriter) Write(b *book.Book) error {
+	raw, err := FromBook(b).Encode()
+	if err != nil {
+		return err
+	}
+	_, err = bw.w.Write(raw)
+	return err
+}
+
+func (bw *binaryWriter) Flush() error {
+	return bw.w.Flush()
+}
+
+func (bw *binaryWriter) Close() error {
+	return bw.Flush()
+}
+
+type binaryReader struct {
+	r     *bufio.Reader
+	index int
+}
+
+func (br *binaryReader) Read() (*Record, error) {
+	for {
+		raw, err := br.r.ReadBytes(recordTerminator)
+		// the line breaks some tools add between the records
+		raw = bytes.TrimLeft(raw, "\r\n")
+		if len(raw) == 0 {
+			if err == nil {
+				continue
+			}
+			return nil, err
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		br.index++
+		r, err := Decode(raw)
+		if err != nil {
+			return nil, &RecordError{Index: br.index, Err: err}
+		}
+		return r, nil
+	}
+}
@@ -0,0 +1,218 @@
+package marc
+
+// Readers and writers of MARC 21 files, in ISO 2709 and MARCXML.
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/snehil-sinha/goBookStore/models/book"
+)
+
+// Formats of the MARC files
+const (
+	Binary = "marc"
+	XML    = "marcxml"
+)
+
+// Formats lists the supported formats
+var Formats = []string{Binary, XML}
+
+// ContentTypes maps the formats to their media type
+var ContentTypes = map[string]string{
+	Binary: "application/marc",
+	XML:    "application/marcxml+xml",
+}
+
+// Extensions maps the formats to the extension of their files
+var Extensions = map[string]string{
+	Binary: "mrc",
+	XML:    "xml",
+}
+
+// Namespace is the XML namespace of MARCXML
+const Namespace = "http://www.loc.gov/MARC21/slim"
+
+// Writer encodes books as MARC records; it implements catalog.Writer
+type Writer interface {
+	Write(*book.Book) error
+	// Flush writes the buffered records to the underlying writer
+	Flush() error
+	// Close ends the file and flushes it, without closing the underlying writer
+	Close() error
+}
+
+// NewWriter returns a writer of the format
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case Binary:
+		return &binaryWriter{w: bufio.NewWriter(w)}, nil
+	case XML:
+		return &xmlWriter{w: bufio.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q, must be one of %s", format, strings.Join(Formats, ", "))
+}
+
+// Reader decodes the records of a MARC file
+type Reader interface {
+	// Read returns the next record, or io.EOF at the end of the file. A *RecordError
+	// reports an invalid record and reading may go on; other errors are fatal.
+	Read() (*Record, error)
+}
+
+// RecordError reports a record which could not be decoded
+type RecordError struct {
+	// Index of the record in the file, from 1
+	Index int
+	Err   error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("record %d: %s", e.Index, e.Err)
+}
+
+// NewReader returns a reader of the format
+func NewReader(format string, r io.Reader) (Reader, error) {
+	switch format {
+	case Binary:
+		return &binaryReader{r: bufio.NewReader(r)}, nil
+	case XML:
+		return &xmlReader{d: xml.NewDecoder(r)}, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q, must be one of %s", format, strings.Join(Formats, ", "))
+}
+
+// MARCXML elements of a record
+type (
+	xmlRecord struct {
+		XMLName       xml.Name          `xml:"record"`
+		Leader        string            `xml:"leader"`
+		ControlFields []xmlControlField `xml:"controlfield"`
+		DataFields    []xmlDataField    `xml:"datafield"`
+	}
+	xmlControlField struct {
+		Tag   string `xml:"tag,attr"`
+		Value string `xml:",chardata"`
+	}
+	xmlDataField struct {
+		Tag       string        `xml:"tag,attr"`
+		Ind1      string        `xml:"ind1,attr"`
+		Ind2      string        `xml:"ind2,attr"`
+		Subfields []xmlSubfield `xml:"subfield"`
+	}
+	xmlSubfield struct {
+		Code  string `xml:"code,attr"`
+		Value string `xml:",chardata"`
+	}
+)
+
+type xmlWriter struct {
+	w       *bufio.Writer
+	started bool
+}
+
+func (xw *xmlWriter) start() error {
+	if xw.started {
+		return nil
+	}
+	xw.started = true
+	_, err := fmt.Fprintf(xw.w, "%s<collection xmlns=\"%s\">\n", xml.Header, Namespace)
+	return err
+}
+
+func (xw *xmlWriter) Write(b *book.Book) error {
+	if err := xw.start(); err != nil {
+		return err
+	}
+
+	r := FromBook(b)
+	// the record length and base address only make sense in ISO 2709
+	x := xmlRecord{Leader: r.Leader[:12] + "00000" + r.Leader[17:]}
+	for _, f := range r.Fields {
+		if isControl(f.Tag) {
+			x.ControlFields = append(x.ControlFields, xmlControlField{Tag: f.Tag, Value: f.Value})
+			continue
+		}
+		df := xmlDataField{Tag: f.Tag, Ind1: string(indicator(f.Ind1)), Ind2: string(indicator(f.Ind2))}
+		for _, sf := range f.Subfields {
+			df.Subfields = append(df.Subfields, xmlSubfield{Code: string(sf.Code), Value: sf.Value})
+		}
+		x.DataFields = append(x.DataFields, df)
+	}
+
+	enc := xml.NewEncoder(xw.w)
+	enc.Indent("  ", "  ")
+	if err := enc.Encode(x); err != nil {
+		return err
+	}
+	return xw.w.WriteByte('\n')
+}
+
+func (xw *xmlWriter) Flush() error {
+	return xw.w.Flush()
+}
+
+func (xw *xmlWriter) Close() error {
+	if err := xw.start(); err != nil {
+		return err
+	}
+	if _, err := xw.w.WriteString("</collection>\n"); err != nil {
+		return err
+	}
+	return xw.Flush()
+}
+
+type xmlReader struct {
+	d     *xml.Decoder
+	index int
+}
+
+// Read returns the records of a collection, or the record of a file holding a
+// single one
+func (xr *xmlReader) Read() (*Record, error) {
+	for {
+		tok, err := xr.d.Token()
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+
+		xr.index++
+		var x xmlRecord
+		if err := xr.d.DecodeElement(&x, &start); err != nil {
+			return nil, err
+		}
+
+		r := &Record{Leader: x.Leader}
+		for _, cf := range x.ControlFields {
+			r.Fields = append(r.Fields, Field{Tag: cf.Tag, Value: cf.Value})
+		}
+		for _, df := range x.DataFields {
+			if len(df.Tag) != 3 || len(df.Ind1) > 1 || len(df.Ind2) > 1 {
+				return nil, &RecordError{Index: xr.index, Err: fmt.Errorf("invalid data field %q", df.Tag)}
+			}
+			f := Field{Tag: df.Tag, Ind1: firstByte(df.Ind1), Ind2: firstByte(df.Ind2)}
+			for _, sf := range df.Subfields {
+				if len(sf.Code) != 1 {
+					return nil, &RecordError{Index: xr.index, Err: fmt.Errorf("invalid subfield code %q in field %s", sf.Code, df.Tag)}
+				}
+				f.Subfields = append(f.Subfields, Subfield{Code: sf.Code[0], Value: sf.Value})
+			}
+			r.Fields = append(r.Fields, f)
+		}
+		return r, nil
+	}
+}
+
+func firstByte(s string) byte {
+	if s == "" {
+		return ' '
+	}
+	return s[0]
+}
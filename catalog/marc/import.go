@@ -0,0 +1,203 @@
+package marc
+
+// Import of MARC files, skipping the books already in the catalog.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/snehil-sinha/goBookStore/catalog"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+)
+
+// ErrInvalidFile wraps the errors of the files which cannot be read
+var ErrInvalidFile = errors.New("invalid MARC file")
+
+// batchSize is the number of records checked and written at once
+const batchSize = 500
+
+// Import creates the books of the records read from r. A record is a duplicate,
+// and skipped, if a stored book, in the trash or not, or an earlier record has
+// its ISBN, or its title and page count. Every book is checked like with
+// Book.Validate, and the invalid records are reported without stopping the
+// import. The records are checked and created in batches of batchSize, see
+// BookService.FindDuplicates and BookService.Batch. The returned error is only
+// set if the import could not go on, in which case the report covers the records
+// imported so far; it wraps ErrInvalidFile if the file could not be read.
+func Import(ctx context.Context, log *common.Logger, svc book.BookService, r Reader, opts catalog.ImportOptions) (*catalog.Report, error) {
+	im := &importer{
+		ctx:    ctx,
+		log:    log,
+		svc:    svc,
+		opts:   opts,
+		seen:   map[string]int{},
+		report: catalog.NewReport(opts),
+	}
+
+	for index := 1; ; index++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return im.report, im.flush()
+		}
+		var recErr *RecordError
+		if errors.As(err, &recErr) {
+			im.add(catalog.RecordReport{Index: recErr.Index, Status: catalog.Invalid, Error: recErr.Err.Error()}, nil)
+			continue
+		}
+		if err != nil {
+			if flushErr := im.flush(); flushErr != nil {
+				return im.report, flushErr
+			}
+			return im.report, fmt.Errorf("%w: %w", ErrInvalidFile, err)
+		}
+
+		im.check(index, rec)
+		if im.pending == batchSize {
+			if err = im.flush(); err != nil {
+				return im.report, err
+			}
+		}
+	}
+}
+
+type importer struct {
+	ctx  context.Context
+	log  *common.Logger
+	svc  book.BookService
+	opts catalog.ImportOptions
+	// indexes of the records imported, by ISBN and by title and page count, to
+	// catch the duplicates within the file
+	seen map[string]int
+
+	// records of the batch, and their books, nil for the records already reported
+	// as invalid or duplicate
+	records []catalog.RecordReport
+	books   []*book.Book
+	pending int
+	report  *catalog.Report
+}
+
+// add adds a record to the batch, with its book if it is to be created
+func (im *importer) add(out catalog.RecordReport, b *book.Book) {
+	im.records = append(im.records, out)
+	im.books = append(im.books, b)
+	if b != nil {
+		im.pending++
+	}
+}
+
+// check maps a record to a book and adds it to the batch, reporting the invalid
+// books and the duplicates within the file
+func (im *importer) check(index int, rec *Record) {
+	out := catalog.RecordReport{Index: index, ControlNumber: rec.ControlNumber()}
+	b, invalid := ToBook(rec)
+	out.Invalid = invalid
+	if err := b.ValidateFields(); err != nil {
+		out.Status, out.Error = catalog.Invalid, err.Error()
+		im.add(out, nil)
+		return
+	}
+
+	var keys []string
+	if b.Title != "" && b.Pages > 0 {
+		keys = append(keys, "title:"+b.Title+"\x00"+strconv.Itoa(b.Pages))
+	}
+	if b.ISBN != "" {
+		keys = append(keys, "isbn:"+b.ISBN)
+	}
+	for _, key := range keys {
+		if i, ok := im.seen[key]; ok {
+			out.Status, out.Error = catalog.Duplicate, fmt.Sprintf("duplicate of record %d", i)
+			im.add(out, nil)
+			return
+		}
+	}
+	for _, key := range keys {
+		im.seen[key] = index
+	}
+	im.add(out, b)
+}
+
+// flush skips the duplicates of stored books among the records of the batch,
+// creates the others and adds the batch to the report. It returns an error only
+// if the import cannot go on.
+func (im *importer) flush() error {
+	defer func() {
+		// the records of a batch which failed are left out
+		for _, out := range im.records {
+			if out.Status != "" {
+				im.report.Add(out)
+			}
+		}
+		im.records, im.books, im.pending = nil, nil, 0
+	}()
+	if im.pending == 0 {
+		return nil
+	}
+
+	var (
+		books   []*book.Book
+		indexes []int
+	)
+	for i, b := range im.books {
+		if b != nil {
+			books = append(books, b)
+			indexes = append(indexes, i)
+		}
+	}
+	existing, err := im.svc.FindDuplicates(im.log, books)
+	if err != nil {
+		return err
+	}
+
+	var (
+		ops    []book.Operation
+		opRecs []int
+	)
+	for j, i := range indexes {
+		out := &im.records[i]
+		if e := existing[j]; e != nil {
+			out.Status, out.ID = catalog.Duplicate, e.ID.Hex()
+			if e.DeletedAt != nil {
+				out.Error = "duplicate of a book in the trash"
+			}
+			continue
+		}
+		if im.opts.DryRun {
+			out.Status = catalog.Created
+			continue
+		}
+		ops = append(ops, book.Operation{Op: book.OpCreate, Book: books[j]})
+		opRecs = append(opRecs, i)
+	}
+
+	if len(ops) == 0 {
+		return nil
+	}
+	results, err := im.svc.Batch(im.ctx, im.log, ops, false)
+	if results == nil {
+		return err
+	}
+	// err is set if the outcome of the batch could not be told, and the first
+	// write error stops the import as well, once the batch is reported
+	for k, r := range results {
+		out := &im.records[opRecs[k]]
+		switch {
+		case r.Err == nil:
+			out.Status, out.ID = catalog.Created, r.ID
+		case errors.Is(r.Err, book.ErrDuplicate):
+			// stored by another request since FindDuplicates
+			out.Status, out.Error = catalog.Duplicate, r.Err.Error()
+		default:
+			out.Status, out.Error = catalog.Invalid, r.Err.Error()
+			if !errors.Is(r.Err, book.ErrValidation) && err == nil {
+				err = r.Err
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,261 @@
+package marc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/catalog"
+	"github.com/snehil-sinha/goBookStore/catalog/marc"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/models/book/mocks"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+func open(format, name string) marc.Reader {
+	f, err := os.Open("testdata/" + name)
+	So(err, ShouldBeNil)
+	Reset(func() { f.Close() })
+	r, err := marc.NewReader(format, f)
+	So(err, ShouldBeNil)
+	return r
+}
+
+func readAll(r marc.Reader) []*marc.Record {
+	var records []*marc.Record
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return records
+		}
+		So(err, ShouldBeNil)
+		records = append(records, rec)
+	}
+}
+
+func newBook() *book.Book {
+	b := book.NewBook("Dune: Deluxe Edition", 412)
+	b.ID = primitive.NewObjectID()
+	b.UpdatedAt = time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	b.ISBN = "9780441013593"
+	b.Contributors = []book.Contributor{
+		{Name: "Frank Herbert", Role: book.RoleAuthor},
+		{Name: "John Schoenherr", Role: book.RoleIllustrator},
+	}
+	return b
+}
+
+func TestRecords(t *testing.T) {
+	Convey("Given a MARCXML file of a library", t, func() {
+		records := readAll(open(marc.XML, "records.xml"))
+		So(records, ShouldHaveLength, 2)
+
+		Convey("Then its records should be mapped to books", func() {
+			b, invalid := marc.ToBook(records[0])
+			So(invalid, ShouldBeEmpty)
+			So(records[0].ControlNumber(), ShouldEqual, "65012345")
+			So(b, ShouldResemble, &book.Book{
+				Title: "Dune",
+				Pages: 412,
+				ISBN:  "9780441013593",
+				Contributors: []book.Contributor{
+					{Name: "Frank Herbert", Role: book.RoleAuthor},
+					{Name: "John Schoenherr", Role: book.RoleIllustrator},
+				},
+			})
+		})
+
+		Convey("Then the invalid fields should be reported and left out", func() {
+			b, invalid := marc.ToBook(records[1])
+			So(b.Title, ShouldEqual, "Emma: a novel")
+			So(b.ISBN, ShouldBeEmpty)
+			So(b.Pages, ShouldEqual, 0)
+			So(invalid, ShouldHaveLength, 2)
+		})
+	})
+
+	Convey("Given a book", t, func() {
+		b := newBook()
+
+		for _, format := range marc.Formats {
+			Convey("When it is written in "+format+" and read back", func() {
+				var buf bytes.Buffer
+				w, err := marc.NewWriter(format, &buf)
+				So(err, ShouldBeNil)
+				So(w.Write(b), ShouldBeNil)
+				So(w.Write(b), ShouldBeNil)
+				So(w.Close(), ShouldBeNil)
+
+				r, err := marc.NewReader(format, &buf)
+				So(err, ShouldBeNil)
+				records := readAll(r)
+
+				Convey("Then the records should hold the fields of the book", func() {
+					So(records, ShouldHaveLength, 2)
+					So(records[0].ControlNumber(), ShouldEqual, b.ID.Hex())
+					got, invalid := marc.ToBook(records[1])
+					So(invalid, ShouldBeEmpty)
+					So(got, ShouldResemble, &book.Book{
+						Title:        b.Title,
+						Pages:        b.Pages,
+						ISBN:         b.ISBN,
+						Contributors: b.Contributors,
+					})
+				})
+			})
+		}
+
+		Convey("When it is encoded in ISO 2709", func() {
+			raw, err := marc.FromBook(b).Encode()
+			So(err, ShouldBeNil)
+
+			Convey("Then the leader should hold the record length and base address", func() {
+				So(string(raw[:5]), ShouldEqual, fmt.Sprintf("%05d", len(raw)))
+				base, err := strconv.Atoi(string(raw[12:17]))
+				So(err, ShouldBeNil)
+				So(raw[base-1], ShouldEqual, 0x1E)
+				So(string(raw[base:base+3]), ShouldEqual, b.ID.Hex()[:3])
+				So(string(raw[5:12]), ShouldEqual, "nam a22")
+				So(raw[len(raw)-1], ShouldEqual, 0x1D)
+			})
+		})
+	})
+
+	Convey("Given a corrupt ISO 2709 record between valid ones", t, func() {
+		raw, err := marc.FromBook(newBook()).Encode()
+		So(err, ShouldBeNil)
+		file := append(append(append([]byte{}, raw...), "00042nam a2200abc i 4500\x1d"...), raw...)
+		r, err := marc.NewReader(marc.Binary, bytes.NewReader(file))
+		So(err, ShouldBeNil)
+
+		Convey("Then it should be reported and reading go on", func() {
+			_, err = r.Read()
+			So(err, ShouldBeNil)
+			_, err = r.Read()
+			var recErr *marc.RecordError
+			So(errors.As(err, &recErr), ShouldBeTrue)
+			So(recErr.Index, ShouldEqual, 2)
+			_, err = r.Read()
+			So(err, ShouldBeNil)
+		})
+	})
+}
+
+func TestImport(t *testing.T) {
+	Convey("Given a book service", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		m := mocks.NewMockBookService(ctrl)
+		log := &common.Logger{Logger: zap.NewNop()}
+
+		Convey("When a file holds a book already in the catalog", func() {
+			stored := newBook()
+			m.EXPECT().FindDuplicates(gomock.Any(), gomock.Len(1)).Return([]*book.Book{stored}, nil)
+
+			report, err := marc.Import(context.Background(), log, m, open(marc.XML, "records.xml"), catalog.ImportOptions{})
+
+			Convey("Then its record should be skipped as a duplicate", func() {
+				So(err, ShouldBeNil)
+				So(report.Duplicates, ShouldEqual, 1)
+				So(report.Records[0].ID, ShouldEqual, stored.ID.Hex())
+				So(report.Records[0].ControlNumber, ShouldEqual, "65012345")
+				So(report.Invalid, ShouldEqual, 1)
+				So(report.Records[1].Invalid, ShouldHaveLength, 2)
+			})
+		})
+
+		Convey("When a file holds a book in the trash", func() {
+			stored := newBook()
+			deletedAt := time.Now()
+			stored.DeletedAt = &deletedAt
+			m.EXPECT().FindDuplicates(gomock.Any(), gomock.Any()).Return([]*book.Book{stored}, nil)
+
+			report, err := marc.Import(context.Background(), log, m, open(marc.XML, "records.xml"), catalog.ImportOptions{})
+
+			Convey("Then its record should be skipped as a duplicate as well", func() {
+				So(err, ShouldBeNil)
+				So(report.Records[0].Status, ShouldEqual, catalog.Duplicate)
+				So(report.Records[0].Error, ShouldEqual, "duplicate of a book in the trash")
+			})
+		})
+
+		Convey("When a file holds the same book twice", func() {
+			var buf bytes.Buffer
+			w, _ := marc.NewWriter(marc.Binary, &buf)
+			So(w.Write(newBook()), ShouldBeNil)
+			So(w.Write(newBook()), ShouldBeNil)
+			So(w.Close(), ShouldBeNil)
+			r, _ := marc.NewReader(marc.Binary, &buf)
+
+			created := newBook()
+			m.EXPECT().FindDuplicates(gomock.Any(), gomock.Len(1)).Return([]*book.Book{nil}, nil)
+			m.EXPECT().Batch(gomock.Any(), gomock.Any(), gomock.Len(1), false).
+				Return([]book.Result{{Op: book.OpCreate, ID: created.ID.Hex(), Book: created}}, nil)
+
+			report, err := marc.Import(context.Background(), log, m, r, catalog.ImportOptions{})
+
+			Convey("Then the second record should be skipped", func() {
+				So(err, ShouldBeNil)
+				So(report.Created, ShouldEqual, 1)
+				So(report.Records[0].Status, ShouldEqual, catalog.Created)
+				So(report.Records[0].ID, ShouldEqual, created.ID.Hex())
+				So(report.Records[1].Status, ShouldEqual, catalog.Duplicate)
+				So(report.Records[1].Error, ShouldEqual, "duplicate of record 1")
+			})
+		})
+
+		Convey("When a book is stored by another request during the import", func() {
+			var buf bytes.Buffer
+			w, _ := marc.NewWriter(marc.Binary, &buf)
+			So(w.Write(newBook()), ShouldBeNil)
+			So(w.Close(), ShouldBeNil)
+			r, _ := marc.NewReader(marc.Binary, &buf)
+
+			m.EXPECT().FindDuplicates(gomock.Any(), gomock.Any()).Return([]*book.Book{nil}, nil)
+			m.EXPECT().Batch(gomock.Any(), gomock.Any(), gomock.Any(), false).
+				Return([]book.Result{{Op: book.OpCreate, Err: book.ErrDuplicate}}, nil)
+
+			report, err := marc.Import(context.Background(), log, m, r, catalog.ImportOptions{})
+
+			Convey("Then its record should be reported as a duplicate", func() {
+				So(err, ShouldBeNil)
+				So(report.Duplicates, ShouldEqual, 1)
+				So(report.Invalid, ShouldEqual, 0)
+			})
+		})
+
+		Convey("When the import is a dry run", func() {
+			m.EXPECT().FindDuplicates(gomock.Any(), gomock.Any()).Return([]*book.Book{nil}, nil)
+
+			report, err := marc.Import(context.Background(), log, m, open(marc.XML, "records.xml"), catalog.ImportOptions{DryRun: true})
+
+			Convey("Then the books should be reported without being created", func() {
+				So(err, ShouldBeNil)
+				So(report.DryRun, ShouldBeTrue)
+				So(report.Created, ShouldEqual, 1)
+				So(report.Invalid, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When the file is not well formed", func() {
+			r, _ := marc.NewReader(marc.XML, strings.NewReader("<collection><record>"))
+			_, err := marc.Import(context.Background(), log, m, r, catalog.ImportOptions{})
+
+			Convey("Then the import should fail with ErrInvalidFile", func() {
+				So(errors.Is(err, marc.ErrInvalidFile), ShouldBeTrue)
+			})
+		})
+	})
+}
@@ -0,0 +1,272 @@
+package marc
+
+// MARC 21 bibliographic records and their mapping to books.
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/snehil-sinha/goBookStore/catalog"
+	"github.com/snehil-sinha/goBookStore/models/book"
+)
+
+// Record is a MARC 21 bibliographic record
+type Record struct {
+	Leader string
+	Fields []Field
+}
+
+// Field is a control field (tags 001 to 009), which only has a value, or a
+// data field, which has indicators and subfields
+type Field struct {
+	Tag       string
+	Value     string
+	Ind1      byte
+	Ind2      byte
+	Subfields []Subfield
+}
+
+// Subfield is a subfield of a data field
+type Subfield struct {
+	Code  byte
+	Value string
+}
+
+// isControl reports whether the tag is the one of a control field
+func isControl(tag string) bool {
+	return strings.HasPrefix(tag, "00")
+}
+
+// fields returns the fields with the tag
+func (r *Record) fields(tag string) (out []Field) {
+	for _, f := range r.Fields {
+		if f.Tag == tag {
+			out = append(out, f)
+		}
+	}
+	return
+}
+
+// ControlNumber returns the value of the 001 field
+func (r *Record) ControlNumber() string {
+	for _, f := range r.fields("001") {
+		return f.Value
+	}
+	return ""
+}
+
+// subfield returns the first value of the subfield with the code
+func (f Field) subfield(code byte) string {
+	for _, sf := range f.Subfields {
+		if sf.Code == code {
+			return strings.TrimSpace(sf.Value)
+		}
+	}
+	return ""
+}
+
+// newLeader returns the leader of a new record of a printed book in Unicode;
+// the record length and base address are set when encoding it
+const newLeader = "00000nam a2200000 i 4500"
+
+// relators maps the roles of the contributors to MARC relator terms, and back
+var relators = map[string]string{
+	book.RoleAuthor:      "author",
+	book.RoleEditor:      "editor",
+	book.RoleTranslator:  "translator",
+	book.RoleIllustrator: "illustrator",
+}
+
+// FromBook returns the record of a book: 001 control number (the id), 005
+// latest transaction, 020 ISBN, 100 main author, 245 title, 300 extent and 700
+// the other contributors
+func FromBook(b *book.Book) *Record {
+	r := &Record{Leader: newLeader}
+	if !b.ID.IsZero() {
+		r.Fields = append(r.Fields, Field{Tag: "001", Value: b.ID.Hex()})
+	}
+	if !b.UpdatedAt.IsZero() {
+		r.Fields = append(r.Fields, Field{Tag: "005", Value: b.UpdatedAt.UTC().Format("20060102150405.0")})
+	}
+	if b.ISBN != "" {
+		r.Fields = append(r.Fields, dataField("020", ' ', ' ', 'a', b.ISBN))
+	}
+
+	var added []Field
+	mainEntry := false
+	for _, c := range b.Contributors {
+		f := dataField("700", '1', ' ', 'a', invertName(c.Name))
+		if term, ok := relators[c.Role]; ok {
+			f.Subfields = append(f.Subfields, Subfield{Code: 'e', Value: term})
+		}
+		if c.Role == book.RoleAuthor && !mainEntry {
+			f.Tag, mainEntry = "100", true
+			r.Fields = append(r.Fields, f)
+			continue
+		}
+		added = append(added, f)
+	}
+
+	// the first indicator tells whether the title is an added entry, i.e. if
+	// the record has a main entry
+	titleInd1 := byte('0')
+	if mainEntry {
+		titleInd1 = '1'
+	}
+	title := dataField("245", titleInd1, '0', 'a', b.Title)
+	if main, sub, ok := strings.Cut(b.Title, ": "); ok {
+		title.Subfields = []Subfield{{Code: 'a', Value: main + " :"}, {Code: 'b', Value: sub}}
+	}
+	r.Fields = append(r.Fields, title)
+	if b.Pages > 0 {
+		r.Fields = append(r.Fields, dataField("300", ' ', ' ', 'a', strconv.Itoa(b.Pages)+" pages"))
+	}
+	r.Fields = append(r.Fields, added...)
+	return r
+}
+
+func dataField(tag string, ind1, ind2, code byte, value string) Field {
+	return Field{Tag: tag, Ind1: ind1, Ind2: ind2, Subfields: []Subfield{{Code: code, Value: value}}}
+}
+
+// pagesPattern matches the page count of an extent, e.g. "xii, 412 p. :"
+var pagesPattern = regexp.MustCompile(`(\d+)\s*(?:p\b|p\.|pages)`)
+
+// ToBook returns the book of a record. Fields whose value is invalid are left
+// out and reported in the returned errors; the book is validated on creation.
+func ToBook(r *Record) (b *book.Book, invalid []catalog.FieldError) {
+	b = &book.Book{}
+
+	for _, f := range r.fields("020") {
+		isbn, err := normalizeISBN(f.subfield('a'))
+		if err != nil {
+			invalid = append(invalid, catalog.FieldError{Field: "020 $a", Error: err.Error()})
+			continue
+		}
+		if isbn != "" {
+			b.ISBN = isbn
+			break
+		}
+	}
+
+	if fields := r.fields("245"); len(fields) > 0 {
+		title := trimPunctuation(fields[0].subfield('a'))
+		if sub := trimPunctuation(fields[0].subfield('b')); sub != "" {
+			title += ": " + sub
+		}
+		b.Title = title
+	}
+
+	if fields := r.fields("300"); len(fields) > 0 {
+		extent := fields[0].subfield('a')
+		if m := pagesPattern.FindStringSubmatch(extent); m != nil {
+			b.Pages, _ = strconv.Atoi(m[1])
+		} else if extent != "" {
+			invalid = append(invalid, catalog.FieldError{Field: "300 $a", Error: fmt.Sprintf("no page count in %q", extent)})
+		}
+	}
+
+	for _, tag := range []string{"100", "700"} {
+		for _, f := range r.fields(tag) {
+			name := trimPunctuation(f.subfield('a'))
+			if name == "" {
+				invalid = append(invalid, catalog.FieldError{Field: tag + " $a", Error: "is required"})
+				continue
+			}
+			role := book.RoleContributor
+			if tag == "100" {
+				role = book.RoleAuthor
+			}
+			term := strings.ToLower(trimPunctuation(f.subfield('e')))
+			for rl, t := range relators {
+				if t == term {
+					role = rl
+				}
+			}
+			b.Contributors = append(b.Contributors, book.Contributor{Name: uninvertName(name), Role: role})
+		}
+	}
+	return
+}
+
+// invertName returns a personal name in the inverted form of the headings,
+// e.g. "Herbert, Frank" for "Frank Herbert"
+func invertName(name string) string {
+	i := strings.LastIndex(name, " ")
+	if i < 0 || strings.Contains(name, ",") {
+		return name
+	}
+	return name[i+1:] + ", " + name[:i]
+}
+
+// uninvertName returns a personal name in direct order
+func uninvertName(name string) string {
+	surname, forenames, ok := strings.Cut(name, ", ")
+	if !ok {
+		return name
+	}
+	return forenames + " " + surname
+}
+
+// trimPunctuation removes the ISBD punctuation ending the values of the subfields
+func trimPunctuation(s string) string {
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(s), " /:;,."))
+}
+
+// normalizeISBN returns the ISBN-13 of the value of a 020 $a, e.g.
+// "0-441-01359-7 (pbk.)", converting ISBN-10 to ISBN-13
+func normalizeISBN(s string) (string, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return "", nil
+	}
+	isbn := strings.ToUpper(strings.ReplaceAll(fields[0], "-", ""))
+
+	switch len(isbn) {
+	case 10:
+		if !validISBN10(isbn) {
+			return "", fmt.Errorf("invalid ISBN-10 %q", fields[0])
+		}
+		isbn = "978" + isbn[:9]
+		return isbn + strconv.Itoa(isbn13CheckDigit(isbn)), nil
+	case 13:
+		if _, err := strconv.Atoi(isbn); err != nil || isbn13CheckDigit(isbn[:12]) != int(isbn[12]-'0') {
+			return "", fmt.Errorf("invalid ISBN-13 %q", fields[0])
+		}
+		return isbn, nil
+	}
+	return "", errors.New("an ISBN has 10 or 13 digits, got " + strconv.Quote(fields[0]))
+}
+
+// isbn13CheckDigit returns the check digit of the first 12 digits of an ISBN-13
+func isbn13CheckDigit(digits string) int {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		d := int(digits[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return (10 - sum%10) % 10
+}
+
+func validISBN10(isbn string) bool {
+	sum := 0
+	for i, r := range isbn {
+		var d int
+		switch {
+		case r >= '0' && r <= '9':
+			d = int(r - '0')
+		case r == 'X' && i == 9:
+			d = 10
+		default:
+			return false
+		}
+		sum += (10 - i) * d
+	}
+	return sum%11 == 0
+}
@@ -0,0 +1,147 @@
+package onix
+
+// Import of ONIX messages, upserting the books by record reference.
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/snehil-sinha/goBookStore/catalog"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+)
+
+// Import upserts the books of the products read from r, keyed on their record
+// reference, so that importing a message again changes nothing. A product
+// matching a stored book updates the fields it maps, and products notified as
+// deleted delete their book. Products which cannot be mapped to a valid book
+// are reported without stopping the import; their invalid and unmapped fields
+// are reported as well. The returned error is only set if the import could not
+// go on, in which case the report covers the products imported so far.
+func Import(ctx context.Context, log *common.Logger, svc book.BookService, r *Reader, opts catalog.ImportOptions) (*catalog.Report, error) {
+	im := &importer{
+		ctx:    ctx,
+		log:    log,
+		svc:    svc,
+		opts:   opts,
+		states: map[string]*book.Book{},
+	}
+	report := catalog.NewReport(opts)
+
+	for {
+		p, err := r.Read()
+		if err == io.EOF {
+			return report, nil
+		}
+		if err != nil {
+			return report, err
+		}
+
+		out, err := im.upsert(p)
+		if err != nil {
+			return report, err
+		}
+		report.Add(out)
+	}
+}
+
+type importer struct {
+	ctx  context.Context
+	log  *common.Logger
+	svc  book.BookService
+	opts catalog.ImportOptions
+	// books of the references imported so far, nil once deleted, so that a dry
+	// run reports the products of a reference after the first one accurately
+	states map[string]*book.Book
+}
+
+// upsert imports a product, returning an error only if the import cannot go on
+func (im *importer) upsert(p *Product) (catalog.RecordReport, error) {
+	out := catalog.RecordReport{Reference: p.Reference, Unmapped: p.Unmapped, Invalid: p.Invalid}
+	invalid := func(err error) (catalog.RecordReport, error) {
+		out.Status, out.Error = catalog.Invalid, err.Error()
+		return out, nil
+	}
+	if p.Reference == "" {
+		return invalid(fmt.Errorf("%w: RecordReference is required", book.ErrValidation))
+	}
+
+	current, err := im.find(p.Reference)
+	if err != nil {
+		if catalog.IsRecordError(err) {
+			return invalid(err)
+		}
+		return out, err
+	}
+	if current != nil && !current.ID.IsZero() {
+		out.ID = current.ID.Hex()
+	}
+
+	if p.Delete {
+		out.Status = catalog.Deleted
+		if current == nil {
+			out.Status = catalog.Unchanged
+			return out, nil
+		}
+		if !im.opts.DryRun {
+			if err := im.svc.Delete(im.ctx, im.log, out.ID); err != nil {
+				return out, err
+			}
+		}
+		im.states[p.Reference] = nil
+		return out, nil
+	}
+
+	b := p.Book
+	if current == nil {
+		out.Status = catalog.Created
+	} else {
+		changed := *current
+		changed.Apply(p.Book)
+		if reflect.DeepEqual(&changed, current) {
+			out.Status = catalog.Unchanged
+			return out, nil
+		}
+		b, out.Status = &changed, catalog.Updated
+	}
+
+	if im.opts.DryRun {
+		if err := b.Validate(); err != nil {
+			return invalid(fmt.Errorf("%w: %s", book.ErrValidation, err))
+		}
+	} else {
+		switch out.Status {
+		case catalog.Created:
+			b, err = im.svc.Create(im.ctx, im.log, b)
+		case catalog.Updated:
+			b, err = im.svc.Update(im.ctx, im.log, out.ID, p.Book)
+		}
+		if err != nil {
+			if catalog.IsRecordError(err) {
+				return invalid(err)
+			}
+			return out, err
+		}
+		out.ID = b.ID.Hex()
+	}
+
+	im.states[p.Reference] = b
+	return out, nil
+}
+
+// find returns the book of the reference, nil if none
+func (im *importer) find(ref string) (*book.Book, error) {
+	if b, ok := im.states[ref]; ok {
+		return b, nil
+	}
+	books, _, err := im.svc.List(im.log, book.ListOptions{Limit: 2, Filter: book.Filter{Reference: ref}})
+	if err != nil || len(books) == 0 {
+		return nil, err
+	}
+	if len(books) > 1 {
+		return nil, fmt.Errorf("%w: several books have the reference %q", book.ErrValidation, ref)
+	}
+	return books[0], nil
+}
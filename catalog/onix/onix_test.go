@@ -0,0 +1,245 @@
+package onix_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/catalog"
+	"github.com/snehil-sinha/goBookStore/catalog/onix"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/models/book/mocks"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+func open(name string) *onix.Reader {
+	f, err := os.Open("testdata/" + name)
+	So(err, ShouldBeNil)
+	Reset(func() { f.Close() })
+	return onix.NewReader(f)
+}
+
+func readAll(r *onix.Reader) []*onix.Product {
+	var products []*onix.Product
+	for {
+		p, err := r.Read()
+		if err == io.EOF {
+			return products
+		}
+		So(err, ShouldBeNil)
+		products = append(products, p)
+	}
+}
+
+func TestReader(t *testing.T) {
+	Convey("Given an ONIX message in reference tags", t, func() {
+		products := readAll(open("products.xml"))
+		So(products, ShouldHaveLength, 3)
+
+		Convey("Then a complete product should be mapped to a book", func() {
+			p := products[0]
+			So(p.Reference, ShouldEqual, "com.chilton.dune")
+			So(p.Delete, ShouldBeFalse)
+			So(p.Invalid, ShouldBeEmpty)
+			So(p.Book, ShouldResemble, &book.Book{
+				Title: "Dune",
+				Pages: 412,
+				ISBN:  "9780441013593",
+				Contributors: []book.Contributor{
+					{Name: "Frank Herbert", Role: book.RoleAuthor},
+					{Name: "John Schoenherr", Role: book.RoleIllustrator},
+				},
+				Prices: []book.Price{
+					{Amount: 9.99, Currency: "USD"},
+					{Amount: 8.99, Currency: "GBP"},
+				},
+				Availability: book.Available,
+				Reference:    "com.chilton.dune",
+			})
+		})
+
+		Convey("Then the elements without a book field should be reported", func() {
+			So(products[0].Unmapped, ShouldResemble, []string{
+				"ProductIdentifier",
+				"DescriptiveDetail/ProductComposition",
+				"DescriptiveDetail/ProductForm",
+				"DescriptiveDetail/Contributor/BiographicalNote",
+				"DescriptiveDetail/Subject",
+				"PublishingDetail",
+				"ProductSupply/SupplyDetail/Supplier",
+			})
+		})
+
+		Convey("Then the invalid fields should be reported and left out", func() {
+			p := products[1]
+			So(p.Book.ISBN, ShouldBeEmpty)
+			So(p.Book.Pages, ShouldEqual, 0)
+			So(p.Invalid, ShouldResemble, []catalog.FieldError{
+				{Field: "ProductIdentifier/IDValue", Error: `invalid ISBN-13 "9780141439588"`},
+				{Field: "DescriptiveDetail/Contributor", Error: "contributor 1 has no name"},
+				{Field: "DescriptiveDetail/Extent/ExtentValue", Error: `page count must be a positive integer, got "many"`},
+			})
+		})
+
+		Convey("Then a deletion should be flagged", func() {
+			So(products[2].Reference, ShouldEqual, "com.chilton.persuasion")
+			So(products[2].Delete, ShouldBeTrue)
+		})
+	})
+
+	Convey("Given an ONIX message in short tags", t, func() {
+		products := readAll(open("short_tags.xml"))
+
+		Convey("Then it should be mapped like one in reference tags", func() {
+			So(products, ShouldHaveLength, 1)
+			p := products[0]
+			So(p.Unmapped, ShouldBeEmpty)
+			So(p.Invalid, ShouldBeEmpty)
+			So(p.Book.Title, ShouldEqual, "The Dune Chronicles: Book One")
+			So(p.Book.Pages, ShouldEqual, 412)
+			So(p.Book.ISBN, ShouldEqual, "9780441013593")
+			So(p.Book.Availability, ShouldEqual, book.NotYetAvailable)
+			So(p.Book.Prices, ShouldResemble, []book.Price{{Amount: 8.99, Currency: "GBP"}})
+		})
+	})
+
+	Convey("Given a message of another release", t, func() {
+		_, err := onix.NewReader(strings.NewReader(`<ONIXMessage release="2.1"></ONIXMessage>`)).Read()
+
+		Convey("Then it should be rejected", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestImport(t *testing.T) {
+	Convey("Given a book service", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		m := mocks.NewMockBookService(ctrl)
+		log := &common.Logger{Logger: zap.NewNop()}
+
+		byReference := func(ref string, books ...*book.Book) {
+			m.EXPECT().List(gomock.Any(), book.ListOptions{Limit: 2, Filter: book.Filter{Reference: ref}}).
+				Return(books, int64(len(books)), nil)
+		}
+		stored := func(p *onix.Product) *book.Book {
+			b := *p.Book
+			b.ID = primitive.NewObjectID()
+			return &b
+		}
+		products := readAll(open("products.xml"))
+
+		Convey("When a message is imported", func() {
+			persuasion := book.NewBook("Persuasion", 249)
+			persuasion.ID = primitive.NewObjectID()
+
+			byReference("com.chilton.dune")
+			byReference("com.chilton.emma")
+			byReference("com.chilton.persuasion", persuasion)
+			m.EXPECT().Create(gomock.Any(), gomock.Any(), products[0].Book).Return(stored(products[0]), nil)
+			m.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("%w: book validation failed", book.ErrValidation))
+			m.EXPECT().Delete(gomock.Any(), gomock.Any(), persuasion.ID.Hex()).Return(nil)
+
+			report, err := onix.Import(context.Background(), log, m, open("products.xml"), catalog.ImportOptions{})
+
+			Convey("Then every product should be reported", func() {
+				So(err, ShouldBeNil)
+				So(report.Created, ShouldEqual, 1)
+				So(report.Invalid, ShouldEqual, 1)
+				So(report.Deleted, ShouldEqual, 1)
+				So(report.Records[0].Unmapped, ShouldNotBeEmpty)
+				So(report.Records[1].Status, ShouldEqual, catalog.Invalid)
+				So(report.Records[1].Invalid, ShouldHaveLength, 3)
+			})
+		})
+
+		Convey("When a message is imported again", func() {
+			byReference("com.chilton.dune", stored(products[0]))
+			emma := stored(products[1])
+			emma.Pages = 474
+			byReference("com.chilton.emma", emma)
+			byReference("com.chilton.persuasion")
+
+			report, err := onix.Import(context.Background(), log, m, open("products.xml"), catalog.ImportOptions{})
+
+			Convey("Then nothing should change", func() {
+				So(err, ShouldBeNil)
+				So(report.Unchanged, ShouldEqual, 3)
+			})
+		})
+
+		Convey("When a stored book is changed by a product", func() {
+			dune := stored(products[0])
+			dune.Prices = []book.Price{{Amount: 12, Currency: "USD"}}
+			byReference("com.chilton.dune", dune)
+			updated := *products[0].Book
+			updated.ID = dune.ID
+			m.EXPECT().Update(gomock.Any(), gomock.Any(), dune.ID.Hex(), products[0].Book).Return(&updated, nil)
+
+			r := onix.NewReader(strings.NewReader(`<ONIXMessage release="3.0">` + products0XML + `</ONIXMessage>`))
+			report, err := onix.Import(context.Background(), log, m, r, catalog.ImportOptions{})
+
+			Convey("Then the book should be updated", func() {
+				So(err, ShouldBeNil)
+				So(report.Updated, ShouldEqual, 1)
+				So(report.Records[0].ID, ShouldEqual, dune.ID.Hex())
+			})
+		})
+
+		Convey("When a deletion is imported in a dry run", func() {
+			persuasion := book.NewBook("Persuasion", 249)
+			persuasion.ID = primitive.NewObjectID()
+			byReference("com.chilton.persuasion", persuasion)
+
+			r := onix.NewReader(strings.NewReader(`<ONIXMessage release="3.0"><Product>
+				<RecordReference>com.chilton.persuasion</RecordReference>
+				<NotificationType>05</NotificationType>
+			</Product></ONIXMessage>`))
+			report, err := onix.Import(context.Background(), log, m, r, catalog.ImportOptions{DryRun: true})
+
+			Convey("Then the deletion should be reported without deleting the book", func() {
+				So(err, ShouldBeNil)
+				So(report.DryRun, ShouldBeTrue)
+				So(report.Deleted, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When the service fails unexpectedly", func() {
+			m.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, int64(0), errors.New("connection reset"))
+
+			report, err := onix.Import(context.Background(), log, m, open("products.xml"), catalog.ImportOptions{})
+
+			Convey("Then the import should stop", func() {
+				So(err, ShouldNotBeNil)
+				So(report.Records, ShouldBeEmpty)
+			})
+		})
+	})
+}
+
+// products0XML is the first product of testdata/products.xml
+const products0XML = `<Product>
+	<RecordReference>com.chilton.dune</RecordReference>
+	<ProductIdentifier><ProductIDType>15</ProductIDType><IDValue>9780441013593</IDValue></ProductIdentifier>
+	<DescriptiveDetail>
+		<TitleDetail><TitleType>01</TitleType><TitleElement><TitleElementLevel>01</TitleElementLevel><TitleText>Dune</TitleText></TitleElement></TitleDetail>
+		<Contributor><SequenceNumber>1</SequenceNumber><ContributorRole>A01</ContributorRole><PersonName>Frank Herbert</PersonName></Contributor>
+		<Contributor><SequenceNumber>2</SequenceNumber><ContributorRole>A12</ContributorRole><PersonName>John Schoenherr</PersonName></Contributor>
+		<Extent><ExtentType>00</ExtentType><ExtentValue>412</ExtentValue><ExtentUnit>03</ExtentUnit></Extent>
+	</DescriptiveDetail>
+	<ProductSupply><SupplyDetail>
+		<ProductAvailability>21</ProductAvailability>
+		<Price><PriceAmount>9.99</PriceAmount><CurrencyCode>USD</CurrencyCode></Price>
+		<Price><PriceAmount>8.99</PriceAmount><CurrencyCode>GBP</CurrencyCode></Price>
+	</SupplyDetail></ProductSupply>
+</Product>`
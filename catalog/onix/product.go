@@ -0,0 +1,278 @@
+package onix
+
+// Mapping of the ONIX products to books.
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/snehil-sinha/goBookStore/catalog"
+	"github.com/snehil-sinha/goBookStore/models/book"
+)
+
+// Product is an ONIX product record mapped to a book
+type Product struct {
+	Reference string
+	// Delete is set for the products notified as deleted (NotificationType 05)
+	Delete bool
+	Book   *book.Book
+	// Unmapped lists the paths of the elements which have no field in the book
+	Unmapped []string
+	// Invalid lists the elements whose value is invalid, which are left out
+	Invalid []catalog.FieldError
+}
+
+func (p *Product) invalid(field, format string, args ...any) {
+	p.Invalid = append(p.Invalid, catalog.FieldError{Field: field, Error: fmt.Sprintf(format, args...)})
+}
+
+// Codes of the ONIX code lists mapped to the books
+const (
+	notificationDelete = "05" // list 1
+	idTypeGTIN13       = "03" // list 5
+	idTypeISBN13       = "15"
+	titleTypeTitle     = "01" // list 15
+	titleLevelProduct  = "01" // list 149
+	extentUnitPages    = "03" // list 24
+)
+
+// pageExtentTypes are the extent types (list 23) giving the page count, most
+// relevant first: main content, content and total numbered pages
+var pageExtentTypes = []string{"00", "11", "05"}
+
+// contributorRoles maps the contributor roles (list 17) to the book roles, the
+// other roles being mapped to book.RoleContributor
+var contributorRoles = map[string]string{
+	"A01": book.RoleAuthor,
+	"A12": book.RoleIllustrator,
+	"B01": book.RoleEditor,
+	"B06": book.RoleTranslator,
+}
+
+// mapProduct maps a Product element to a book
+func mapProduct(n *node, defaultCurrency string) *Product {
+	p := &Product{
+		Reference: n.text("RecordReference"),
+		Delete:    n.text("NotificationType") == notificationDelete,
+		Book:      &book.Book{},
+	}
+	// details of the record itself rather than of the book
+	n.all("RecordSourceType")
+	n.all("RecordSourceName")
+
+	if p.Reference == "" {
+		p.invalid("RecordReference", "is required")
+	}
+	p.Book.Reference = p.Reference
+	if p.Delete {
+		return p
+	}
+
+	p.mapIdentifiers(n)
+	detail := n.child("DescriptiveDetail")
+	p.mapTitle(detail)
+	p.mapContributors(detail)
+	p.mapExtent(detail)
+	p.mapSupply(n, defaultCurrency)
+
+	p.Unmapped = n.unmapped("", []string{})
+	return p
+}
+
+func (p *Product) mapIdentifiers(n *node) {
+	var gtin string
+	for _, id := range n.all("ProductIdentifier") {
+		value := strings.ReplaceAll(id.text("IDValue"), "-", "")
+		switch id.text("ProductIDType") {
+		case idTypeISBN13:
+			if !validISBN13(value) {
+				p.invalid("ProductIdentifier/IDValue", "invalid ISBN-13 %q", value)
+				continue
+			}
+			p.Book.ISBN = value
+		case idTypeGTIN13:
+			// the GTIN-13 of a book is its ISBN-13
+			gtin = value
+		default:
+			id.used = false
+		}
+	}
+	if p.Book.ISBN == "" && (strings.HasPrefix(gtin, "978") || strings.HasPrefix(gtin, "979")) && validISBN13(gtin) {
+		p.Book.ISBN = gtin
+	}
+}
+
+func (p *Product) mapTitle(detail *node) {
+	for _, td := range detail.all("TitleDetail") {
+		if td.text("TitleType") != titleTypeTitle {
+			td.used = false
+			continue
+		}
+		for _, te := range td.all("TitleElement") {
+			if te.text("TitleElementLevel") != titleLevelProduct {
+				te.used = false
+				continue
+			}
+			title := te.text("TitleText")
+			if title == "" {
+				title = strings.TrimSpace(te.text("TitlePrefix") + " " + te.text("TitleWithoutPrefix"))
+			}
+			if sub := te.text("Subtitle"); sub != "" {
+				title += ": " + sub
+			}
+			p.Book.Title = title
+			return
+		}
+	}
+	p.invalid("DescriptiveDetail/TitleDetail", "no title of type %s at the product level", titleTypeTitle)
+}
+
+func (p *Product) mapContributors(detail *node) {
+	type ranked struct {
+		seq int
+		c   book.Contributor
+	}
+	var all []ranked
+	for i, c := range detail.all("Contributor") {
+		name := c.text("PersonName")
+		if name == "" {
+			name = strings.TrimSpace(c.text("NamesBeforeKey") + " " + c.text("KeyNames"))
+		}
+		if name == "" {
+			name = c.text("CorporateName")
+		}
+		if name == "" {
+			p.invalid("DescriptiveDetail/Contributor", "contributor %d has no name", i+1)
+			continue
+		}
+
+		role, ok := contributorRoles[c.text("ContributorRole")]
+		if !ok {
+			role = book.RoleContributor
+		}
+		seq, err := strconv.Atoi(c.text("SequenceNumber"))
+		if err != nil {
+			seq = i + 1
+		}
+		all = append(all, ranked{seq: seq, c: book.Contributor{Name: name, Role: role}})
+	}
+
+	sort.SliceStable(all, func(i, j int) bool { return all[i].seq < all[j].seq })
+	for _, r := range all {
+		p.Book.Contributors = append(p.Book.Contributors, r.c)
+	}
+}
+
+func (p *Product) mapExtent(detail *node) {
+	pages := map[string]string{}
+	for _, e := range detail.all("Extent") {
+		t := e.text("ExtentType")
+		if e.text("ExtentUnit") != extentUnitPages || !contains(pageExtentTypes, t) {
+			e.used = false
+			continue
+		}
+		pages[t] = e.text("ExtentValue")
+	}
+	if len(pages) == 0 {
+		p.invalid("DescriptiveDetail/Extent", "no page count")
+		return
+	}
+
+	for _, t := range pageExtentTypes {
+		value, ok := pages[t]
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			p.invalid("DescriptiveDetail/Extent/ExtentValue", "page count must be a positive integer, got %q", value)
+			continue
+		}
+		p.Book.Pages = n
+		return
+	}
+}
+
+func (p *Product) mapSupply(n *node, defaultCurrency string) {
+	currencies := map[string]bool{}
+	for _, supply := range n.all("ProductSupply") {
+		for _, sd := range supply.all("SupplyDetail") {
+			if code := sd.text("ProductAvailability"); code != "" && p.Book.Availability == "" {
+				if p.Book.Availability = availability(code); p.Book.Availability == "" {
+					p.invalid("ProductSupply/SupplyDetail/ProductAvailability", "unknown availability %q", code)
+				}
+			}
+
+			for _, price := range sd.all("Price") {
+				price.all("PriceType")
+				currency := price.text("CurrencyCode")
+				if currency == "" {
+					currency = defaultCurrency
+				}
+				if currency == "" {
+					p.invalid("ProductSupply/SupplyDetail/Price/CurrencyCode", "is required without a default currency")
+					continue
+				}
+				amount, err := strconv.ParseFloat(price.text("PriceAmount"), 64)
+				if err != nil || amount < 0 {
+					p.invalid("ProductSupply/SupplyDetail/Price/PriceAmount", "invalid amount %q", price.text("PriceAmount"))
+					continue
+				}
+				// the first price of a currency is the one kept
+				if !currencies[currency] {
+					currencies[currency] = true
+					p.Book.Prices = append(p.Book.Prices, book.Price{Amount: amount, Currency: currency})
+				}
+			}
+		}
+	}
+}
+
+// availability maps a product availability (list 65) to the availability of a
+// book, "" if unknown
+func availability(code string) string {
+	if len(code) != 2 {
+		return ""
+	}
+	switch code[0] {
+	case '1':
+		return book.NotYetAvailable
+	case '2':
+		return book.Available
+	case '3':
+		return book.OutOfStock
+	case '4', '5':
+		return book.Unavailable
+	}
+	return ""
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// validISBN13 reports whether s is 13 digits with a valid check digit
+func validISBN13(s string) bool {
+	if len(s) != 13 {
+		return false
+	}
+	sum := 0
+	for i, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+		d := int(r - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
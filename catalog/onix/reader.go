@@ -0,0 +1,213 @@
+package onix
+
+// Streaming reader of ONIX 3.0 messages.
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrInvalidMessage wraps the errors of the messages which cannot be read
+var ErrInvalidMessage = errors.New("invalid ONIX message")
+
+// node is an element of a product record, decoded generically so that the
+// elements which are not mapped can be reported
+type node struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Text    string     `xml:",chardata"`
+	Nodes   []*node    `xml:",any"`
+
+	name string // reference name of the element
+	used bool   // whether the element was mapped
+}
+
+// shortTags maps the short tags of the elements read to their reference names
+var shortTags = map[string]string{
+	"ONIXmessage":       "ONIXMessage",
+	"header":            "Header",
+	"m186":              "DefaultCurrencyCode",
+	"product":           "Product",
+	"a001":              "RecordReference",
+	"a002":              "NotificationType",
+	"a194":              "RecordSourceType",
+	"a197":              "RecordSourceName",
+	"productidentifier": "ProductIdentifier",
+	"b221":              "ProductIDType",
+	"b244":              "IDValue",
+	"descriptivedetail": "DescriptiveDetail",
+	"titledetail":       "TitleDetail",
+	"b202":              "TitleType",
+	"titleelement":      "TitleElement",
+	"x409":              "TitleElementLevel",
+	"b203":              "TitleText",
+	"b030":              "TitlePrefix",
+	"b031":              "TitleWithoutPrefix",
+	"b029":              "Subtitle",
+	"contributor":       "Contributor",
+	"b034":              "SequenceNumber",
+	"b035":              "ContributorRole",
+	"b036":              "PersonName",
+	"b039":              "NamesBeforeKey",
+	"b040":              "KeyNames",
+	"b047":              "CorporateName",
+	"extent":            "Extent",
+	"b218":              "ExtentType",
+	"b219":              "ExtentValue",
+	"b220":              "ExtentUnit",
+	"productsupply":     "ProductSupply",
+	"supplydetail":      "SupplyDetail",
+	"j396":              "ProductAvailability",
+	"price":             "Price",
+	"x462":              "PriceType",
+	"j151":              "PriceAmount",
+	"j152":              "CurrencyCode",
+}
+
+func referenceName(local string) string {
+	if name, ok := shortTags[local]; ok {
+		return name
+	}
+	return local
+}
+
+// resolve sets the reference names of the element and its descendants
+func (n *node) resolve() {
+	n.name = referenceName(n.XMLName.Local)
+	for _, c := range n.Nodes {
+		c.resolve()
+	}
+}
+
+// all returns the child elements with the name, marking them as mapped
+func (n *node) all(name string) (out []*node) {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.Nodes {
+		if c.name == name {
+			c.used = true
+			out = append(out, c)
+		}
+	}
+	return
+}
+
+// child returns the first child element with the name, nil if none
+func (n *node) child(name string) *node {
+	if all := n.all(name); len(all) > 0 {
+		return all[0]
+	}
+	return nil
+}
+
+// text returns the trimmed text of the first child element with the name
+func (n *node) text(name string) string {
+	if c := n.child(name); c != nil {
+		return strings.TrimSpace(c.Text)
+	}
+	return ""
+}
+
+// unmapped appends the paths of the descendant elements which were not mapped,
+// without the descendants of those
+func (n *node) unmapped(path string, out []string) []string {
+	for _, c := range n.Nodes {
+		p := path + "/" + c.name
+		if path == "" {
+			p = c.name
+		}
+		if !c.used {
+			out = appendOnce(out, p)
+			continue
+		}
+		out = c.unmapped(p, out)
+	}
+	return out
+}
+
+func appendOnce(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
+
+// Reader reads the products of an ONIX 3.0 message one at a time, in reference
+// or short tag form, without loading the whole message
+type Reader struct {
+	d               *xml.Decoder
+	started         bool
+	defaultCurrency string
+}
+
+// NewReader returns a reader of the message read from r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{d: xml.NewDecoder(r)}
+}
+
+// Read returns the next product, or io.EOF at the end of the message. Other
+// errors wrap ErrInvalidMessage and are fatal: the message is not well formed,
+// or not an ONIX 3.0 message.
+func (r *Reader) Read() (*Product, error) {
+	p, err := r.read()
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("%w: %w", ErrInvalidMessage, err)
+	}
+	return p, err
+}
+
+func (r *Reader) read() (*Product, error) {
+	for {
+		tok, err := r.d.Token()
+		if err == io.EOF && !r.started {
+			return nil, fmt.Errorf("the message is empty")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if !r.started {
+			if err := r.checkRoot(start); err != nil {
+				return nil, err
+			}
+			r.started = true
+			continue
+		}
+
+		n := &node{}
+		if err := r.d.DecodeElement(n, &start); err != nil {
+			return nil, err
+		}
+		n.resolve()
+
+		switch n.name {
+		case "Header":
+			r.defaultCurrency = n.text("DefaultCurrencyCode")
+		case "Product":
+			return mapProduct(n, r.defaultCurrency), nil
+		}
+	}
+}
+
+// checkRoot checks the root element is an ONIX message of release 3
+func (r *Reader) checkRoot(root xml.StartElement) error {
+	if referenceName(root.Name.Local) != "ONIXMessage" {
+		return fmt.Errorf("not an ONIX message, the root element is %s", root.Name.Local)
+	}
+	for _, attr := range root.Attr {
+		if attr.Name.Local == "release" && !strings.HasPrefix(attr.Value, "3.") {
+			return fmt.Errorf("unsupported ONIX release %s, must be 3.x", attr.Value)
+		}
+	}
+	return nil
+}
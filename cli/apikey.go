@@ -0,0 +1,93 @@
+package cli
+
+// Management of the API keys.
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/snehil-sinha/goBookStore/models/apikey"
+)
+
+// apiKeyHeader is the header of the tables of API keys
+var apiKeyHeader = []string{"NAME", "PREFIX", "CREATED", "REVOKED"}
+
+func apiKeyRow(k *apikey.APIKey) []string {
+	revoked := ""
+	if k.RevokedAt != nil {
+		revoked = k.RevokedAt.UTC().Format(time.RFC3339)
+	}
+	return []string{k.Name, k.Prefix + "…", k.CreatedAt.UTC().Format(time.RFC3339), revoked}
+}
+
+// createdKey is the output of apikey create, the only one with the key itself
+type createdKey struct {
+	*apikey.APIKey
+	Key string `json:"key"`
+}
+
+func apikeyCreateFlags(fs *flag.FlagSet) action {
+	return func(e *env, args []string) error {
+		if len(args) != 1 {
+			return usagef("apikey create takes the name of the key")
+		}
+		log, err := e.connect()
+		if err != nil {
+			return err
+		}
+		k, key, err := apikey.NewAPIKeyService().Create(log, args[0])
+		if err != nil {
+			return err
+		}
+		if e.output == OutputTable {
+			fmt.Fprintln(e.stderr, "Store the key now, it cannot be shown again.")
+		}
+		return e.print(createdKey{APIKey: k, Key: key}, []string{"NAME", "KEY"}, [][]string{{k.Name, key}})
+	}
+}
+
+func apikeyRevokeFlags(fs *flag.FlagSet) action {
+	return func(e *env, args []string) error {
+		if len(args) != 1 {
+			return usagef("apikey revoke takes the name of the key")
+		}
+		log, err := e.connect()
+		if err != nil {
+			return err
+		}
+		err = apikey.NewAPIKeyService().Revoke(log, args[0])
+		if errors.Is(err, apikey.ErrNotFound) {
+			return errors.New("no active API key named " + args[0])
+		}
+		if err != nil {
+			return err
+		}
+		return e.message("revoked API key %s", args[0])
+	}
+}
+
+func apikeyListFlags(fs *flag.FlagSet) action {
+	return func(e *env, args []string) error {
+		if len(args) > 0 {
+			return usagef("apikey list takes no arguments, got %q", args)
+		}
+		log, err := e.connect()
+		if err != nil {
+			return err
+		}
+		keys, err := apikey.NewAPIKeyService().List(log)
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(keys))
+		for _, k := range keys {
+			rows = append(rows, apiKeyRow(k))
+		}
+		if keys == nil {
+			keys = []*apikey.APIKey{}
+		}
+		return e.print(keys, apiKeyHeader, rows)
+	}
+}
@@ -0,0 +1,104 @@
+package cli
+
+// Backup and restore of the DB.
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/snehil-sinha/goBookStore/backup"
+	"github.com/snehil-sinha/goBookStore/db"
+	"github.com/snehil-sinha/goBookStore/migrations"
+)
+
+// manifestRows returns the table of the collections of a manifest
+func manifestRows(m *backup.Manifest) [][]string {
+	rows := make([][]string, 0, len(m.Collections))
+	for _, c := range m.Collections {
+		rows = append(rows, []string{c.Name, strconv.FormatInt(c.Documents, 10), c.SHA256})
+	}
+	return rows
+}
+
+var manifestHeader = []string{"COLLECTION", "DOCUMENTS", "SHA256"}
+
+func backupFlags(fs *flag.FlagSet) action {
+	file := fs.String("file", "", "Path of the archive to write, - for stdout; by default goBookStore-<time>.tar.gz in the working directory.")
+	format := fs.String("format", backup.BSON, "Encoding of the documents, bson or jsonl.")
+
+	return func(e *env, args []string) error {
+		if len(args) > 0 {
+			return usagef("backup takes no arguments, got %q", args)
+		}
+		if *format != backup.BSON && *format != backup.JSONL {
+			return usagef("-format must be one of bson or jsonl, got %q", *format)
+		}
+		if _, err := e.connect(); err != nil {
+			return err
+		}
+		store := backup.NewMongoStore(db.Client.Database())
+
+		if *file == "-" {
+			_, err := backup.Backup(context.Background(), store, e.stdout, backup.BackupOptions{Encoding: *format})
+			return err
+		}
+		path := *file
+		if path == "" {
+			path = fmt.Sprintf("%s-%s.tar.gz", Name, time.Now().UTC().Format("20060102T150405Z"))
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		m, err := backup.Backup(context.Background(), store, f, backup.BackupOptions{Encoding: *format})
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			// an incomplete archive is of no use
+			os.Remove(path)
+			return err
+		}
+		if e.output == OutputTable {
+			fmt.Fprintf(e.stderr, "Backed up to %s\n", path)
+		}
+		return e.print(m, manifestHeader, manifestRows(m))
+	}
+}
+
+func restoreFlags(fs *flag.FlagSet) action {
+	drop := fs.Bool("drop", false, "Remove the documents of the collections first, rather than merging the archive into them.")
+	dryRun := fs.Bool("dry-run", false, "Verify the archive without restoring it.")
+
+	return func(e *env, args []string) error {
+		if len(args) != 1 {
+			return usagef("restore takes the path of an archive")
+		}
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		var store backup.Store
+		if !*dryRun {
+			if _, err = e.connect(); err != nil {
+				return err
+			}
+			store = backup.NewMongoStore(db.Client.Database())
+		}
+		m, err := backup.Restore(context.Background(), store, f, backup.RestoreOptions{
+			Drop:          *drop,
+			DryRun:        *dryRun,
+			SchemaVersion: migrations.LatestVersion(migrations.All),
+		})
+		if err != nil {
+			return err
+		}
+		return e.print(m, manifestHeader, manifestRows(m))
+	}
+}
@@ -0,0 +1,136 @@
+package cli
+
+// Management of the books.
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"strconv"
+	"time"
+
+	"github.com/snehil-sinha/goBookStore/models/book"
+)
+
+// bookHeader is the header of the tables of books
+var bookHeader = []string{"ID", "TITLE", "PAGES", "ISBN", "UPDATED"}
+
+func bookRow(b *book.Book) []string {
+	return []string{b.ID.Hex(), b.Title, strconv.Itoa(b.Pages), b.ISBN, b.UpdatedAt.UTC().Format(time.RFC3339)}
+}
+
+// errBookNotFound replaces book.ErrNotFound, the message of which is the one of
+// the driver
+var errBookNotFound = errors.New("no such book")
+
+func notFound(err error) error {
+	if errors.Is(err, book.ErrNotFound) {
+		return errBookNotFound
+	}
+	return err
+}
+
+func booksGetFlags(fs *flag.FlagSet) action {
+	return func(e *env, args []string) error {
+		if len(args) != 1 {
+			return usagef("books get takes the ID of a book")
+		}
+		log, err := e.connect()
+		if err != nil {
+			return err
+		}
+		b, err := book.NewBookService(nil).ReadById(log, args[0])
+		if err != nil {
+			return notFound(err)
+		}
+		return e.print(b, bookHeader, [][]string{bookRow(b)})
+	}
+}
+
+// bookList is the JSON output of books list
+type bookList struct {
+	Books []*book.Book `json:"books"`
+	Total int64        `json:"total"`
+}
+
+func booksListFlags(fs *flag.FlagSet) action {
+	limit := fs.Int64("limit", 20, "Maximum number of books to list, 0 for all of them.")
+	skip := fs.Int64("skip", 0, "Number of books to skip.")
+	filter := filterFlags(fs)
+	fs.BoolVar(&filter.Deleted, "deleted", false, "List the books in the trash rather than the live ones.")
+
+	return func(e *env, args []string) error {
+		if len(args) > 0 {
+			return usagef("books list takes no arguments, got %q", args)
+		}
+		if *limit < 0 || *skip < 0 {
+			return usagef("-limit and -skip must not be negative")
+		}
+		log, err := e.connect()
+		if err != nil {
+			return err
+		}
+		books, total, err := book.NewBookService(nil).List(log, book.ListOptions{Skip: *skip, Limit: *limit, Filter: *filter})
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(books))
+		for _, b := range books {
+			rows = append(rows, bookRow(b))
+		}
+		return e.print(bookList{Books: books, Total: total}, bookHeader, rows)
+	}
+}
+
+func booksCreateFlags(fs *flag.FlagSet) action {
+	b := &book.Book{}
+	fs.StringVar(&b.Title, "title", "", "Title of the book, required.")
+	fs.IntVar(&b.Pages, "pages", 0, "Page count of the book, required.")
+	fs.StringVar(&b.ISBN, "isbn", "", "ISBN-13 of the book.")
+	fs.StringVar(&b.Availability, "availability", "", "Availability of the book: available, not_yet_available, out_of_stock or unavailable.")
+
+	return func(e *env, args []string) error {
+		if len(args) > 0 {
+			return usagef("books create takes no arguments, got %q", args)
+		}
+		if b.Title == "" || b.Pages < 1 {
+			return usagef("-title and a positive -pages are required")
+		}
+		log, err := e.connect()
+		if err != nil {
+			return err
+		}
+		out, err := book.NewBookService(nil).Create(context.Background(), log, b)
+		if err != nil {
+			return err
+		}
+		return e.print(out, bookHeader, [][]string{bookRow(out)})
+	}
+}
+
+func booksDeleteFlags(fs *flag.FlagSet) action {
+	hard := fs.Bool("hard", false, "Delete the book permanently rather than moving it to the trash; it may be in the trash already.")
+
+	return func(e *env, args []string) error {
+		if len(args) != 1 {
+			return usagef("books delete takes the ID of a book")
+		}
+		log, err := e.connect()
+		if err != nil {
+			return err
+		}
+		svc := book.NewBookService(nil)
+		if *hard {
+			err = svc.Purge(context.Background(), log, args[0])
+		} else {
+			err = svc.Delete(context.Background(), log, args[0])
+		}
+		if err != nil {
+			return notFound(err)
+		}
+		if *hard {
+			return e.message("purged book %s", args[0])
+		}
+		return e.message("moved book %s to the trash", args[0])
+	}
+}
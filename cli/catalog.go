@@ -0,0 +1,258 @@
+package cli
+
+// Seeding, importing and exporting the books.
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/snehil-sinha/goBookStore/catalog"
+	"github.com/snehil-sinha/goBookStore/catalog/marc"
+	"github.com/snehil-sinha/goBookStore/catalog/onix"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/seed"
+)
+
+// ONIX is the format of the ONIX for Books 3.0 messages, besides the formats of
+// the catalog and marc packages
+const ONIX = "onix"
+
+// importFormats lists the formats of the import command
+var importFormats = append([]string{catalog.CSV, catalog.JSONL, ONIX}, marc.Formats...)
+
+// extensionFormats maps the file extensions to the format they imply
+var extensionFormats = map[string]string{
+	".csv":    catalog.CSV,
+	".jsonl":  catalog.JSONL,
+	".ndjson": catalog.JSONL,
+	".mrc":    marc.Binary,
+	".xml":    ONIX,
+}
+
+// seedFlags seeds the books of fixture files and fake books, see the seed
+// package; seeding twice is a no-op
+func seedFlags(fs *flag.FlagSet) action {
+	fake := fs.Int("fake", 0, "Number of fake books to generate, after those of the files.")
+	seedValue := fs.Int64("seed", 1, "Random seed of the fake books; the same seed generates the same books.")
+	dryRun := fs.Bool("dry-run", false, "Print the books without storing them.")
+
+	return func(e *env, args []string) error {
+		if len(args) == 0 && *fake == 0 {
+			return usagef("seed takes fixture files, or -fake")
+		}
+		if *fake < 0 {
+			return usagef("-fake must not be negative")
+		}
+		var books []*book.Book
+		for _, path := range args {
+			fixture, err := seed.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			books = append(books, fixture...)
+		}
+		books = append(books, seed.Fake(*fake, *seedValue)...)
+
+		if *dryRun {
+			rows := make([][]string, 0, len(books))
+			for _, b := range books {
+				rows = append(rows, []string{b.Title, strconv.Itoa(b.Pages), b.ISBN})
+			}
+			return e.print(books, []string{"TITLE", "PAGES", "ISBN"}, rows)
+		}
+
+		log, err := e.connect()
+		if err != nil {
+			return err
+		}
+		report, err := seed.Books(context.Background(), log, book.NewBookService(nil), books)
+		if report == nil {
+			return err
+		}
+		rows := make([][]string, 0, len(report.Books))
+		for _, b := range report.Books {
+			rows = append(rows, []string{b.Status, b.ID, b.Title, b.Error})
+		}
+		if printErr := e.print(report, []string{"STATUS", "ID", "TITLE", "ERROR"}, rows); err == nil {
+			err = printErr
+		}
+		if err == nil && report.Invalid > 0 {
+			err = fmt.Errorf("%d of the books are invalid", report.Invalid)
+		}
+		return err
+	}
+}
+
+// importFlags imports a catalog file with the import of its format
+func importFlags(fs *flag.FlagSet) action {
+	format := fs.String("format", "", "Format of the file, one of "+strings.Join(importFormats, ", ")+"; by default implied by its extension.")
+	key := fs.String("key", catalog.KeyTitle, "With csv and jsonl, the field matching the records to the stored books, id or title.")
+	mapping := fs.String("map", "", "With csv, the columns of the fields, as field=column pairs separated by commas.")
+	dryRun := fs.Bool("dry-run", false, "Validate the file without writing anything.")
+
+	return func(e *env, args []string) error {
+		if len(args) != 1 {
+			return usagef("import takes the path of a catalog file")
+		}
+		path := args[0]
+		if *format == "" {
+			*format = extensionFormats[strings.ToLower(filepath.Ext(path))]
+			if *format == "" {
+				return usagef("the format of %s cannot be implied from its extension, set -format", path)
+			}
+		}
+		m, err := parseMapping(*mapping)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		log, err := e.connect()
+		if err != nil {
+			return err
+		}
+		svc := book.NewBookService(nil)
+
+		// the report is printed even if the import could not go on, covering the
+		// records imported so far
+		var (
+			report    any
+			header    []string
+			rows      [][]string
+			importErr error
+		)
+		switch *format {
+		case catalog.CSV, catalog.JSONL:
+			r, err := catalog.NewReader(*format, f, m)
+			if err != nil {
+				return err
+			}
+			rep, err := catalog.Import(context.Background(), log, svc, r, catalog.ImportOptions{Key: *key, DryRun: *dryRun})
+			if rep == nil {
+				return err
+			}
+			report, header, importErr = rep, []string{"LINE", "STATUS", "ID", "ERROR"}, err
+			for _, rec := range rep.Records {
+				rows = append(rows, []string{strconv.Itoa(rec.Line), rec.Status, rec.ID, rec.Error})
+			}
+		case ONIX:
+			rep, err := onix.Import(context.Background(), log, svc, onix.NewReader(f), catalog.ImportOptions{DryRun: *dryRun})
+			if rep == nil {
+				return err
+			}
+			report, header, importErr = rep, []string{"REFERENCE", "STATUS", "ID", "ERROR"}, err
+			for _, rec := range rep.Records {
+				rows = append(rows, []string{rec.Reference, rec.Status, rec.ID, rec.Error})
+			}
+		case marc.Binary, marc.XML:
+			r, err := marc.NewReader(*format, f)
+			if err != nil {
+				return err
+			}
+			rep, err := marc.Import(context.Background(), log, svc, r, catalog.ImportOptions{DryRun: *dryRun})
+			if rep == nil {
+				return err
+			}
+			report, header, importErr = rep, []string{"INDEX", "STATUS", "ID", "ERROR"}, err
+			for _, rec := range rep.Records {
+				rows = append(rows, []string{strconv.Itoa(rec.Index), rec.Status, rec.ID, rec.Error})
+			}
+		default:
+			return usagef("-format must be one of %s, got %q", strings.Join(importFormats, ", "), *format)
+		}
+		if err = e.print(report, header, rows); importErr != nil {
+			err = importErr
+		}
+		return err
+	}
+}
+
+// parseMapping parses the field=column pairs of the -map flag
+func parseMapping(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	m := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		if !ok || field == "" || column == "" {
+			return nil, usagef("-map must be field=column pairs separated by commas, got %q", pair)
+		}
+		m[strings.TrimSpace(field)] = strings.TrimSpace(column)
+	}
+	return m, nil
+}
+
+// exportFlags writes the books matching the filter flags to a file or stdout
+func exportFlags(fs *flag.FlagSet) action {
+	format := fs.String("format", catalog.CSV, "Format of the export, one of "+strings.Join(exportFormats(), ", ")+".")
+	file := fs.String("file", "", "Path of the file to write, stdout by default.")
+	filter := filterFlags(fs)
+
+	return func(e *env, args []string) error {
+		if len(args) > 0 {
+			return usagef("export takes no arguments, got %q", args)
+		}
+		log, err := e.connect()
+		if err != nil {
+			return err
+		}
+
+		var out io.Writer = e.stdout
+		if *file != "" {
+			f, err := os.Create(*file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+		w, err := newExportWriter(*format, out)
+		if err != nil {
+			return usagef("%s", err)
+		}
+		err = book.NewBookService(nil).Each(log, *filter, w.Write)
+		if err == nil {
+			err = w.Close()
+		}
+		return err
+	}
+}
+
+// newExportWriter returns the writer of an export format
+func newExportWriter(format string, w io.Writer) (catalog.Writer, error) {
+	if _, ok := marc.ContentTypes[format]; ok {
+		return marc.NewWriter(format, w)
+	}
+	cw, err := catalog.NewWriter(format, w)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported format %q, must be one of %s", format, strings.Join(exportFormats(), ", "))
+	}
+	return cw, nil
+}
+
+// exportFormats lists the formats of the export command
+func exportFormats() []string {
+	return append(append([]string{}, catalog.Formats...), marc.Formats...)
+}
+
+// filterFlags declares the flags of the filter of the books
+func filterFlags(fs *flag.FlagSet) *book.Filter {
+	f := &book.Filter{}
+	fs.StringVar(&f.Title, "title", "", "Only the books with this exact title.")
+	fs.StringVar(&f.TitleContains, "title-contains", "", "Only the books whose title contains this, case insensitively.")
+	fs.IntVar(&f.MinPages, "min-pages", 0, "Only the books with at least this many pages.")
+	fs.IntVar(&f.MaxPages, "max-pages", 0, "Only the books with at most this many pages.")
+	fs.StringVar(&f.ISBN, "isbn", "", "Only the book with this ISBN-13.")
+	return f
+}
@@ -0,0 +1,277 @@
+// Package cli is the command line of the service: serving the API and running
+// the administration tasks against the configured DB. Every command shares the
+// loading of the config and the output flags.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/db"
+)
+
+// Exit codes of the commands
+const (
+	ExitOK     = 0
+	ExitError  = 1 // the command failed
+	ExitUsage  = 2 // the command line is invalid
+	ExitConfig = 3 // the config cannot be loaded or is invalid
+)
+
+// Output formats of the commands
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+)
+
+// Name is the name of the program in the usages and the completion scripts
+const Name = "goBookStore"
+
+// action runs a command with its positional arguments
+type action func(e *env, args []string) error
+
+// command is a node of the command tree. A command with subcommands runs its
+// action, if any, when none is given.
+type command struct {
+	name    string
+	args    string // synopsis of the positional arguments
+	summary string
+	// flags declares the flags of the command and returns its action
+	flags    func(fs *flag.FlagSet) action
+	commands []*command
+}
+
+func (c *command) find(name string) *command {
+	for _, sub := range c.commands {
+		if sub.name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// usageError is an invalid command line
+type usageError struct{ msg string }
+
+func (e *usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...any) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// configError is a config which cannot be loaded or is invalid
+type configError struct{ err error }
+
+func (e *configError) Error() string { return e.err.Error() }
+func (e *configError) Unwrap() error { return e.err }
+
+// env is shared by the commands: the global flags, and the config, logger and
+// DB connection set up on demand
+type env struct {
+	stdout, stderr io.Writer
+
+	configFile string
+	envFile    string
+	output     string
+	// overrides maps yaml key paths to values set by flags, see common.Loader
+	overrides map[string]string
+
+	// root is the command tree, for the completion scripts
+	root *command
+
+	loader *common.Loader
+	cfg    *common.Config
+	log    *common.Logger
+}
+
+// globalFlags declares the flags every command accepts
+func (e *env) globalFlags(fs *flag.FlagSet) {
+	fs.StringVar(&e.configFile, "c", e.configFile, "Configuration file path.")
+	fs.StringVar(&e.configFile, "config", e.configFile, "Configuration file path.")
+	fs.StringVar(&e.envFile, "e", e.envFile, "Path of the .env file, ignored if missing.")
+	fs.StringVar(&e.envFile, "env-file", e.envFile, "Path of the .env file, ignored if missing.")
+	fs.StringVar(&e.output, "o", e.output, "Output format, json or table.")
+	fs.StringVar(&e.output, "output", e.output, "Output format, json or table.")
+}
+
+// config loads the config from the defaults, the config file, the .env file,
+// the environment and the flags
+func (e *env) config() (*common.Config, error) {
+	if e.cfg != nil {
+		return e.cfg, nil
+	}
+	e.loader = &common.Loader{
+		File:    e.configFile,
+		EnvFile: e.envFile,
+		Flags:   e.overrides,
+	}
+	cfg, err := e.loader.Load()
+	if err != nil {
+		return nil, &configError{err: err}
+	}
+	e.cfg = cfg
+	return cfg, nil
+}
+
+// logger returns the logger of the config
+func (e *env) logger() (*common.Logger, error) {
+	if e.log != nil {
+		return e.log, nil
+	}
+	cfg, err := e.config()
+	if err != nil {
+		return nil, err
+	}
+	if e.log, err = common.NewLogger(cfg); err != nil {
+		return nil, fmt.Errorf("error instantiating the logger: %s", err)
+	}
+	return e.log, nil
+}
+
+// connect connects to the DB of the config, and returns the logger
+func (e *env) connect() (*common.Logger, error) {
+	log, err := e.logger()
+	if err != nil {
+		return nil, err
+	}
+	if db.Client == nil {
+		if err = db.New(log, e.cfg.GoBookStore.DB, e.cfg.GoBookStore.URI); err != nil {
+			return nil, err
+		}
+	}
+	return log, nil
+}
+
+// close closes the DB connection and the logger, if they were set up
+func (e *env) close() {
+	if e.log == nil {
+		return
+	}
+	if db.Client != nil {
+		db.Client.Close(context.TODO(), e.log)
+		db.Client = nil
+	}
+	e.log.Close()
+}
+
+// Run runs the command line args (without the program name) and returns the exit
+// code. Without a command, the service is served.
+func Run(args []string, stdout, stderr io.Writer) int {
+	e := &env{stdout: stdout, stderr: stderr, envFile: ".env", output: OutputTable, root: root}
+	defer e.close()
+
+	cmd, path := root, []string{Name}
+	for {
+		fs := flag.NewFlagSet(strings.Join(path, " "), flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		e.globalFlags(fs)
+		var run action
+		if cmd.flags != nil {
+			run = cmd.flags(fs)
+		}
+		current := cmd
+		fs.Usage = func() { printUsage(stderr, fs, current, current == root) }
+
+		var err error
+		if len(cmd.commands) > 0 {
+			err = fs.Parse(args)
+			args = fs.Args()
+		} else {
+			args, err = parseInterleaved(fs, args)
+		}
+		if err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return ExitOK
+			}
+			return ExitUsage
+		}
+
+		if len(cmd.commands) > 0 && (len(args) > 0 || run == nil) {
+			if len(args) == 0 {
+				fs.Usage()
+				return ExitUsage
+			}
+			sub := cmd.find(args[0])
+			if sub == nil {
+				fmt.Fprintf(stderr, "unknown command %q\n", args[0])
+				fs.Usage()
+				return ExitUsage
+			}
+			cmd, path, args = sub, append(path, sub.name), args[1:]
+			continue
+		}
+
+		if e.output != OutputTable && e.output != OutputJSON {
+			fmt.Fprintf(stderr, "-output must be one of json or table, got %q\n", e.output)
+			return ExitUsage
+		}
+		return e.exit(run(e, args), fs)
+	}
+}
+
+// parseInterleaved parses the flags of a command given before, between or after
+// its positional arguments, and returns the latter
+func parseInterleaved(fs *flag.FlagSet, args []string) (positional []string, err error) {
+	for {
+		if err = fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional, args = append(positional, args[0]), args[1:]
+	}
+}
+
+// exit reports the error of a command and returns its exit code
+func (e *env) exit(err error, fs *flag.FlagSet) int {
+	var (
+		usage *usageError
+		cfg   *configError
+	)
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &usage):
+		fmt.Fprintln(e.stderr, err)
+		fs.Usage()
+		return ExitUsage
+	case errors.As(err, &cfg):
+		fmt.Fprintln(e.stderr, "error loading config:", err)
+		return ExitConfig
+	}
+	fmt.Fprintln(e.stderr, "error:", err)
+	return ExitError
+}
+
+// printUsage prints the synopsis, subcommands and flags of a command
+func printUsage(w io.Writer, fs *flag.FlagSet, c *command, isRoot bool) {
+	synopsis := fs.Name()
+	if len(c.commands) > 0 {
+		synopsis += " <command>"
+	}
+	if c.args != "" {
+		synopsis += " " + c.args
+	}
+	fmt.Fprintf(w, "Usage:\n  %s [flags]\n", synopsis)
+	if c.summary != "" {
+		fmt.Fprintf(w, "\n%s\n", c.summary)
+	}
+	if len(c.commands) > 0 {
+		fmt.Fprintln(w, "\nCommands:")
+		for _, sub := range c.commands {
+			fmt.Fprintf(w, "  %-12s %s\n", sub.name, sub.summary)
+		}
+	}
+	fmt.Fprintln(w, "\nFlags:")
+	fs.PrintDefaults()
+	if isRoot {
+		fmt.Fprintln(w, "\nConfiguration precedence (highest first): flags, environment variables, .env file, config file, defaults.")
+	}
+}
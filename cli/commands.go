@@ -0,0 +1,41 @@
+package cli
+
+// The command tree.
+
+import "github.com/snehil-sinha/goBookStore/migrations"
+
+// root is the program itself, serving the API unless a command is given
+var root = &command{
+	name:    Name,
+	summary: "RESTful API for managing the books of a bookstore. Without a command, the API is served.",
+	flags:   serveFlags,
+	commands: []*command{
+		{name: "serve", summary: "Serve the API", flags: serveFlags},
+		{name: "migrate", summary: "Run the migrations of the DB", commands: []*command{
+			{name: "up", summary: "Run the pending migrations", flags: migrateFlags(migrations.Up)},
+			{name: "down", summary: "Roll back the latest migration, or down to a version", flags: migrateFlags(migrations.Down)},
+			{name: "status", summary: "List the migrations and whether they are applied", flags: migrateStatusFlags},
+		}},
+		{name: "seed", args: "[<file>...]", summary: "Store the books of YAML or JSON fixture files and fake books, unless stored already", flags: seedFlags},
+		{name: "import", args: "<file>", summary: "Import a catalog file: CSV, JSON Lines, ONIX or MARC", flags: importFlags},
+		{name: "export", summary: "Export the books: CSV, JSON Lines or MARC", flags: exportFlags},
+		{name: "backup", summary: "Back up the collections of the DB to a compressed archive", flags: backupFlags},
+		{name: "restore", args: "<archive>", summary: "Restore the collections of the DB from an archive", flags: restoreFlags},
+		{name: "books", summary: "Manage the books", commands: []*command{
+			{name: "get", args: "<id>", summary: "Get a book by ID", flags: booksGetFlags},
+			{name: "list", summary: "List the books", flags: booksListFlags},
+			{name: "create", summary: "Create a book", flags: booksCreateFlags},
+			{name: "delete", args: "<id>", summary: "Delete a book by ID, moving it to the trash", flags: booksDeleteFlags},
+		}},
+		{name: "apikey", summary: "Manage the API keys of the clients", commands: []*command{
+			{name: "create", args: "<name>", summary: "Create an API key, printed only once", flags: apikeyCreateFlags},
+			{name: "revoke", args: "<name>", summary: "Revoke the active API key by name", flags: apikeyRevokeFlags},
+			{name: "list", summary: "List the API keys", flags: apikeyListFlags},
+		}},
+		{name: "config", summary: "Check the configuration", commands: []*command{
+			{name: "validate", summary: "Validate the effective configuration", flags: configValidateFlags},
+			{name: "print", summary: "Print the effective configuration, secrets redacted", flags: configPrintFlags},
+		}},
+		{name: "completion", args: "bash|zsh|fish", summary: "Print the shell completion script", flags: completionFlags},
+	},
+}
@@ -0,0 +1,132 @@
+package cli
+
+// Shell completion scripts, generated from the command tree.
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Shells with a completion script
+var shells = []string{"bash", "zsh", "fish"}
+
+// completionNode is a command of the tree with its path and flags
+type completionNode struct {
+	path  string // names of the commands from the root, separated by spaces
+	cmd   *command
+	flags []*flag.Flag
+}
+
+// completionNodes lists the commands of the tree, depth first
+func completionNodes(c *command, path string) []completionNode {
+	fs := flag.NewFlagSet(path, flag.ContinueOnError)
+	(&env{}).globalFlags(fs)
+	if c.flags != nil {
+		c.flags(fs)
+	}
+	n := completionNode{path: path, cmd: c}
+	fs.VisitAll(func(f *flag.Flag) { n.flags = append(n.flags, f) })
+
+	out := []completionNode{n}
+	for _, sub := range c.commands {
+		out = append(out, completionNodes(sub, strings.TrimSpace(path+" "+sub.name))...)
+	}
+	return out
+}
+
+func completionFlags(fs *flag.FlagSet) action {
+	return func(e *env, args []string) error {
+		if len(args) != 1 {
+			return usagef("completion takes the shell, one of %s", strings.Join(shells, ", "))
+		}
+		nodes := completionNodes(e.root, "")
+		switch args[0] {
+		case "bash":
+			writeBash(e.stdout, nodes)
+		case "zsh":
+			fmt.Fprintln(e.stdout, "#compdef "+Name)
+			fmt.Fprintln(e.stdout, "autoload -U +X bashcompinit && bashcompinit")
+			writeBash(e.stdout, nodes)
+		case "fish":
+			writeFish(e.stdout, nodes)
+		default:
+			return usagef("the shell must be one of %s, got %q", strings.Join(shells, ", "), args[0])
+		}
+		return nil
+	}
+}
+
+// words returns the subcommands and flags completing a command
+func (n completionNode) words() []string {
+	var out []string
+	for _, sub := range n.cmd.commands {
+		out = append(out, sub.name)
+	}
+	for _, f := range n.flags {
+		out = append(out, "-"+f.Name)
+	}
+	sort.Strings(out[len(n.cmd.commands):])
+	return out
+}
+
+// function is the name of the shell function of the scripts
+var function = "_" + Name
+
+// writeBash writes the bash script, which finds the command being completed
+// among the words typed so far and offers its subcommands and flags
+func writeBash(w io.Writer, nodes []completionNode) {
+	paths := make([]string, 0, len(nodes))
+	for _, n := range nodes[1:] {
+		paths = append(paths, n.path)
+	}
+
+	fmt.Fprintf(w, "%s() {\n", function)
+	fmt.Fprintf(w, "    local paths=\"|%s|\"\n", strings.Join(paths, "|"))
+	fmt.Fprintln(w, `    local cur="${COMP_WORDS[COMP_CWORD]}" path="" word words`)
+	fmt.Fprintln(w, `    for word in "${COMP_WORDS[@]:1:COMP_CWORD-1}"; do`)
+	fmt.Fprintln(w, `        if [[ "$paths" == *"|${path:+$path }$word|"* ]]; then`)
+	fmt.Fprintln(w, `            path="${path:+$path }$word"`)
+	fmt.Fprintln(w, `        fi`)
+	fmt.Fprintln(w, `    done`)
+	fmt.Fprintln(w, `    case "$path" in`)
+	for _, n := range nodes {
+		fmt.Fprintf(w, "    %q) words=%q ;;\n", n.path, strings.Join(n.words(), " "))
+	}
+	fmt.Fprintln(w, `    esac`)
+	fmt.Fprintln(w, `    COMPREPLY=($(compgen -W "$words" -- "$cur"))`)
+	fmt.Fprintln(w, "}")
+	fmt.Fprintf(w, "complete -o default -F %s %s\n", function, Name)
+}
+
+// writeFish writes the fish script, completing the subcommands and flags of
+// the command found among the words typed so far
+func writeFish(w io.Writer, nodes []completionNode) {
+	paths := make([]string, 0, len(nodes))
+	for _, n := range nodes[1:] {
+		paths = append(paths, fmt.Sprintf("%q", n.path))
+	}
+
+	fmt.Fprintf(w, "function %s_at\n", function)
+	fmt.Fprintf(w, "    set -l paths %s\n", strings.Join(paths, " "))
+	fmt.Fprintln(w, `    set -l path ""`)
+	fmt.Fprintln(w, `    for word in (commandline -opc)[2..-1]`)
+	fmt.Fprintln(w, `        set -l next (string trim -- "$path $word")`)
+	fmt.Fprintln(w, `        if contains -- $next $paths`)
+	fmt.Fprintln(w, `            set path $next`)
+	fmt.Fprintln(w, `        end`)
+	fmt.Fprintln(w, `    end`)
+	fmt.Fprintln(w, `    test "$path" = "$argv[1]"`)
+	fmt.Fprintln(w, "end")
+	for _, n := range nodes {
+		cond := fmt.Sprintf("%s_at %q", function, n.path)
+		for _, sub := range n.cmd.commands {
+			fmt.Fprintf(w, "complete -c %s -n '%s' -a %s -d %q\n", Name, cond, sub.name, sub.summary)
+		}
+		for _, f := range n.flags {
+			fmt.Fprintf(w, "complete -c %s -n '%s' -o %s -d %q\n", Name, cond, f.Name, strings.TrimSuffix(f.Usage, "."))
+		}
+	}
+}
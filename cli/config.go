@@ -0,0 +1,48 @@
+package cli
+
+// Checking the configuration.
+
+import (
+	"bytes"
+	"flag"
+
+	"gopkg.in/yaml.v3"
+)
+
+func configValidateFlags(fs *flag.FlagSet) action {
+	return func(e *env, args []string) error {
+		if len(args) > 0 {
+			return usagef("config validate takes no arguments, got %q", args)
+		}
+		if _, err := e.config(); err != nil {
+			return err
+		}
+		return e.message("the configuration is valid")
+	}
+}
+
+func configPrintFlags(fs *flag.FlagSet) action {
+	return func(e *env, args []string) error {
+		if len(args) > 0 {
+			return usagef("config print takes no arguments, got %q", args)
+		}
+		cfg, err := e.config()
+		if err != nil {
+			return err
+		}
+		if e.output == OutputTable {
+			return cfg.Print(e.stdout)
+		}
+
+		// the JSON is converted from the YAML, to share its keys and redaction
+		var buf bytes.Buffer
+		if err = cfg.Print(&buf); err != nil {
+			return err
+		}
+		var v map[string]any
+		if err = yaml.Unmarshal(buf.Bytes(), &v); err != nil {
+			return err
+		}
+		return e.print(v, nil, nil)
+	}
+}
@@ -0,0 +1,99 @@
+package cli
+
+// Migrations of the DB.
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/snehil-sinha/goBookStore/db"
+	"github.com/snehil-sinha/goBookStore/migrations"
+)
+
+// migrator returns the migrator of the configured DB
+func (e *env) migrator() (*migrations.Migrator, error) {
+	log, err := e.connect()
+	if err != nil {
+		return nil, err
+	}
+	m, err := migrations.New(log, db.Client.Database(), migrations.All)
+	if err != nil {
+		return nil, err
+	}
+	m.LockTimeout = e.cfg.Migrations.LockTimeout
+	return m, nil
+}
+
+// migrateFlags runs the migrations in the direction. By default, up runs the
+// pending ones and down rolls back the latest one; -to 0 rolls back every one.
+func migrateFlags(dir migrations.Direction) func(fs *flag.FlagSet) action {
+	return func(fs *flag.FlagSet) action {
+		to := fs.Int("to", -1, "Version to migrate to: by default the latest one up, the one before the latest down; 0 rolls every migration back.")
+		dryRun := fs.Bool("dry-run", false, "List the migrations to run without running them.")
+
+		return func(e *env, args []string) error {
+			if len(args) > 0 {
+				return usagef("migrate %s takes no arguments, got %q", dir, args)
+			}
+			m, err := e.migrator()
+			if err != nil {
+				return err
+			}
+			target := *to
+			if dir == migrations.Up && target < 0 {
+				target = 0
+			}
+			ran, err := m.Run(context.Background(), dir, target, *dryRun)
+
+			verb := "migrated " + string(dir)
+			if *dryRun {
+				verb = "would migrate " + string(dir)
+			}
+			out := make([]migrations.Status, 0, len(ran))
+			rows := make([][]string, 0, len(ran))
+			for _, mig := range ran {
+				out = append(out, migrations.Status{Version: mig.Version, Description: mig.Description})
+				rows = append(rows, []string{verb, strconv.Itoa(mig.Version), mig.Description})
+			}
+			if len(ran) == 0 && err == nil {
+				return e.message("nothing to migrate")
+			}
+			if printErr := e.print(out, []string{"", "VERSION", "DESCRIPTION"}, rows); err == nil {
+				err = printErr
+			}
+			return err
+		}
+	}
+}
+
+// migrateStatusFlags lists the migrations and when they were applied
+func migrateStatusFlags(fs *flag.FlagSet) action {
+	return func(e *env, args []string) error {
+		if len(args) > 0 {
+			return usagef("migrate status takes no arguments, got %q", args)
+		}
+		m, err := e.migrator()
+		if err != nil {
+			return err
+		}
+		status, err := m.Status(context.Background())
+		if err != nil {
+			return err
+		}
+		rows := make([][]string, 0, len(status))
+		for _, s := range status {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			if s.Unknown {
+				applied += " (unknown to this build)"
+			}
+			rows = append(rows, []string{fmt.Sprint(s.Version), applied, s.Description})
+		}
+		return e.print(status, []string{"VERSION", "APPLIED", "DESCRIPTION"}, rows)
+	}
+}
@@ -0,0 +1,36 @@
+package cli
+
+// Output of the commands, as JSON or as a table.
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// print writes v as indented JSON, or else the table of the header and rows
+func (e *env) print(v any, header []string, rows [][]string) error {
+	if e.output == OutputJSON {
+		enc := json.NewEncoder(e.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	w := tabwriter.NewWriter(e.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// message writes a line of text, or the object {"message": ...} as JSON
+func (e *env) message(format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	if e.output == OutputJSON {
+		return e.print(map[string]string{"message": msg}, nil, nil)
+	}
+	_, err := fmt.Fprintln(e.stdout, msg)
+	return err
+}
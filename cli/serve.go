@@ -0,0 +1,73 @@
+package cli
+
+// Serving of the API.
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/events"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/service"
+)
+
+func serveFlags(fs *flag.FlagSet) action {
+	var bind, port string
+	fs.StringVar(&bind, "b", common.Bind, "IP address to bind")
+	fs.StringVar(&bind, "bind", common.Bind, "IP address to bind")
+	fs.StringVar(&port, "p", common.Port, "port number to listen")
+	fs.StringVar(&port, "port", common.Port, "port number to listen")
+
+	return func(e *env, args []string) error {
+		if len(args) > 0 {
+			return usagef("serve takes no arguments, got %q", args)
+		}
+		fmt.Fprintln(e.stdout, "GoLang Book Store")
+
+		// only flags set explicitly override the other config sources
+		e.overrides = map[string]string{}
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "b", "bind":
+				e.overrides["bind"] = bind
+			case "p", "port":
+				e.overrides["port"] = port
+			}
+		})
+		log, err := e.logger()
+		if err != nil {
+			return err
+		}
+
+		// reload the reloadable settings on SIGHUP or config file change
+		reloader := common.NewReloader(e.loader, e.cfg, log)
+		reloader.OnReload(func(c *common.Config) {
+			if err := log.SetLevels(c.Log.Console.Level, c.Log.File.Level); err != nil {
+				log.Sugar().Errorf("error setting the log level: %s", err)
+			}
+		})
+		ctx, stopBackground := context.WithCancel(context.Background())
+		go reloader.Watch(ctx)
+
+		s := &common.App{
+			Cfg:      e.cfg,
+			Log:      log,
+			Events:   events.NewBus(),
+			Reloader: reloader,
+		}
+
+		// start the service
+		server := service.Start(s)
+		grpcServer := service.StartGRPC(s)
+		go service.PurgeTrash(ctx, s, book.NewBookService(s.Events))
+		// wait for a signal to shutdown server
+		service.WaitForShutdown()
+		stopBackground()
+		// gracefully shutdown the server, the DB connection and the log file are
+		// closed once the command returns
+		service.GracefullyShutDownServer(s.Log, server, grpcServer)
+		return nil
+	}
+}
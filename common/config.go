@@ -1,36 +1,403 @@
 package common
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/joho/godotenv"
 	"gopkg.in/yaml.v3"
 )
 
+// Config holds the service configuration.
+//
+// Values are layered from the following sources, each overriding the previous:
+//  1. built-in defaults (see DefaultConfig)
+//  2. the YAML configuration file
+//  3. the .env file (never overrides variables already set in the environment)
+//  4. environment variables (see the env struct tags)
+//  5. command line flags
+//
+// Fields tagged with secret:"true" are redacted when the config is printed.
+// Sections tagged with reload:"true" can be changed while the service is running,
+// see Reloader.
 type Config struct {
-	Env  string `yaml:"env"`
-	Port string `yaml:"port"`
-	Bind string `yaml:"bind"`
+	Env     string `yaml:"env" env:"APP_ENV" validate:"required,oneof=development test production"`
+	Port    string `yaml:"port" env:"PORT" validate:"required,port"`
+	Bind    string `yaml:"bind" env:"BIND" validate:"required,ip"`
+	GinMode string `yaml:"gin_mode" env:"GIN_MODE" validate:"omitempty,oneof=debug release test"`
 
 	GoBookStore struct {
-		DB      string `yaml:"db"`
-		URI     string `yaml:"dsn"`
-		LOGPATH string `yaml:"logpath"`
+		DB      string `yaml:"db" env:"DB_NAME" validate:"required"`
+		URI     string `yaml:"dsn" env:"MONGODB_URI" validate:"required,uri" secret:"true"`
+		LOGPATH string `yaml:"logpath" env:"LOG_PATH" validate:"required"`
 	} `yaml:"gobookstore"`
+
+	Log struct {
+		Console struct {
+			Level    string `yaml:"level" env:"LOG_LEVEL" validate:"omitempty,oneof=debug info warn error" reload:"true"`
+			Encoding string `yaml:"encoding" validate:"omitempty,oneof=console json"`
+		} `yaml:"console"`
+
+		// File is the sink at gobookstore.logpath
+		File struct {
+			Level       string        `yaml:"level" env:"LOG_FILE_LEVEL" validate:"omitempty,oneof=debug info warn error" reload:"true"`
+			Encoding    string        `yaml:"encoding" validate:"omitempty,oneof=console json"`
+			MaxSizeMB   int           `yaml:"max_size_mb" validate:"gte=0"`
+			MaxBackups  int           `yaml:"max_backups" validate:"gte=0"`
+			MaxAgeDays  int           `yaml:"max_age_days" validate:"gte=0"`
+			Compress    bool          `yaml:"compress"`
+			RotateEvery time.Duration `yaml:"rotate_every" validate:"gte=0"`
+		} `yaml:"file"`
+
+		// Sampling of debug and info entries, per message and tick
+		Sampling struct {
+			Enabled    bool          `yaml:"enabled" env:"LOG_SAMPLING_ENABLED"`
+			Tick       time.Duration `yaml:"tick" validate:"required_if=Enabled true"`
+			Initial    int           `yaml:"initial" validate:"gte=0"`
+			Thereafter int           `yaml:"thereafter" validate:"gte=0"`
+		} `yaml:"sampling"`
+	} `yaml:"log"`
+
+	// Admin guards the /admin endpoints, which are disabled without a token
+	Admin struct {
+		Token string `yaml:"token" env:"ADMIN_TOKEN" secret:"true"`
+	} `yaml:"admin"`
+
+	// Auth of the clients of the API by their API key, sent in the X-API-Key
+	// header. Keys are only required for /api and /graphql if require_api_key is
+	// set, but they identify the actor of a change in the audit log either way.
+	Auth struct {
+		RequireAPIKey bool `yaml:"require_api_key" env:"REQUIRE_API_KEY"`
+	} `yaml:"auth" reload:"true"`
+
+	Cors CorsConfig `yaml:"cors"`
+
+	RateLimit struct {
+		Enabled           bool    `yaml:"enabled" env:"RATE_LIMIT_ENABLED"`
+		RequestsPerSecond float64 `yaml:"requests_per_second" env:"RATE_LIMIT_RPS" validate:"required_if=Enabled true,gte=0"`
+		Burst             int     `yaml:"burst" env:"RATE_LIMIT_BURST" validate:"required_if=Enabled true,gte=0"`
+	} `yaml:"rate_limit" reload:"true"`
+
+	// Validation of requests and responses against the OpenAPI document.
+	// Requests are always validated, responses only outside of production.
+	Validation struct {
+		Responses bool `yaml:"responses" env:"VALIDATE_RESPONSES"`
+	} `yaml:"validation"`
+
+	// GRPC serves the book catalog over gRPC next to the HTTP API
+	GRPC struct {
+		Enabled bool   `yaml:"enabled" env:"GRPC_ENABLED"`
+		Port    string `yaml:"port" env:"GRPC_PORT" validate:"required_if=Enabled true,omitempty,port"`
+	} `yaml:"grpc"`
+
+	// Batch bounds the size of the batches of book operations
+	Batch struct {
+		MaxOperations int `yaml:"max_operations" env:"BATCH_MAX_OPERATIONS" validate:"gte=1"`
+	} `yaml:"batch"`
+
+	// Idempotency keeps the responses of the POST requests sent with an
+	// Idempotency-Key, to replay them for the retries. They are kept in memory,
+	// so the retries must reach the same instance of the service.
+	Idempotency struct {
+		Enabled bool          `yaml:"enabled" env:"IDEMPOTENCY_ENABLED"`
+		TTL     time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL" validate:"required_if=Enabled true,gte=0"`
+	} `yaml:"idempotency"`
+
+	// Import bounds the size of the catalog files uploaded for import
+	Import struct {
+		MaxSizeMB int `yaml:"max_size_mb" env:"IMPORT_MAX_SIZE_MB" validate:"gte=1"`
+	} `yaml:"import"`
+
+	// Trash keeps the deleted books for retention before they are purged, which is
+	// checked every purge_interval; a zero retention keeps them until purged by hand
+	Trash struct {
+		Retention     time.Duration `yaml:"retention" env:"TRASH_RETENTION" validate:"gte=0"`
+		PurgeInterval time.Duration `yaml:"purge_interval" env:"TRASH_PURGE_INTERVAL" validate:"required_with=Retention,gte=0"`
+	} `yaml:"trash"`
+
+	// Migrations of the DB, run at startup if auto is set. Concurrent instances wait
+	// up to lock_timeout for the one running them.
+	Migrations struct {
+		Auto        bool          `yaml:"auto" env:"MIGRATIONS_AUTO"`
+		LockTimeout time.Duration `yaml:"lock_timeout" env:"MIGRATIONS_LOCK_TIMEOUT" validate:"gte=0"`
+	} `yaml:"migrations"`
+
+	// GraphQL bounds the cost of the queries served at /graphql; 0 disables a limit
+	GraphQL struct {
+		MaxDepth      int `yaml:"max_depth" env:"GRAPHQL_MAX_DEPTH" validate:"gte=0"`
+		MaxComplexity int `yaml:"max_complexity" env:"GRAPHQL_MAX_COMPLEXITY" validate:"gte=0"`
+	} `yaml:"graphql"`
+
+	// Events tunes the feeds of the catalog changes, which send a heartbeat every
+	// heartbeat to keep the idle connections open
+	Events struct {
+		Heartbeat time.Duration `yaml:"heartbeat" env:"EVENTS_HEARTBEAT" validate:"gt=0"`
+	} `yaml:"events"`
+
+	// Features toggles optional behaviour by name
+	Features map[string]bool `yaml:"features" reload:"true"`
+
+	// Versions holds the deprecation policies of the API versions, by name (e.g. v1)
+	Versions map[string]VersionPolicy `yaml:"versions" validate:"dive"`
 }
 
-// Load the yaml file into the Config struct
-func LoadConfig(cfgFile string) (conf *Config, err error) {
+// VersionPolicy announces the deprecation of an API version. The responses of a
+// deprecated version carry Deprecation and Sunset headers, and requests past its
+// sunset are answered with a 410.
+type VersionPolicy struct {
+	Deprecation time.Time `yaml:"deprecation"`
+	Sunset      time.Time `yaml:"sunset" validate:"omitempty,gtfield=Deprecation"`
+}
+
+// Deprecated reports whether a deprecation is announced
+func (p VersionPolicy) Deprecated() bool {
+	return !p.Deprecation.IsZero() || !p.Sunset.IsZero()
+}
+
+// CorsConfig configures cross-origin requests. An origin is allowed if it is
+// listed in AllowedOrigins, matches one of its wildcard entries
+// (e.g. "https://*.example.com") or matches AllowedOriginsRegex.
+// The entry "*" allows any origin.
+type CorsConfig struct {
+	AllowedOrigins      []string      `yaml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS" validate:"dive,origin" reload:"true"`
+	AllowedOriginsRegex string        `yaml:"allowed_origins_regex" env:"ALLOWED_ORIGINS_REGEX" validate:"omitempty,regexp" reload:"true"`
+	AllowMethods        []string      `yaml:"allow_methods" validate:"dive,required"`
+	AllowHeaders        []string      `yaml:"allow_headers" validate:"dive,required"`
+	ExposeHeaders       []string      `yaml:"expose_headers" validate:"dive,required"`
+	AllowCredentials    bool          `yaml:"allow_credentials"`
+	MaxAge              time.Duration `yaml:"max_age" validate:"gte=0"`
+}
+
+// FeatureEnabled reports whether the named feature flag is switched on
+func (c *Config) FeatureEnabled(name string) bool {
+	return c.Features[name]
+}
+
+// DefaultConfig returns the configuration used when no other source sets a value
+func DefaultConfig() *Config {
+	conf := &Config{
+		Env:  "development",
+		Port: Port,
+		Bind: Bind,
+	}
+	conf.GRPC.Enabled = true
+	conf.GRPC.Port = GRPCPort
+	conf.Batch.MaxOperations = 1000
+	conf.Import.MaxSizeMB = 32
+	conf.Idempotency.Enabled = true
+	conf.Idempotency.TTL = 24 * time.Hour
+	conf.Trash.Retention = 30 * 24 * time.Hour
+	conf.Trash.PurgeInterval = time.Hour
+	conf.Migrations.Auto = true
+	conf.Migrations.LockTimeout = time.Minute
+	conf.GraphQL.MaxDepth = 10
+	conf.GraphQL.MaxComplexity = 1000
+	conf.Events.Heartbeat = 15 * time.Second
+	conf.GoBookStore.DB = "book_store"
+	conf.GoBookStore.LOGPATH = "log/gobookstore.log"
+	conf.Cors.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "HEAD"}
+	conf.Cors.AllowHeaders = []string{"Authorization", "Content-Type", "X-Requested-With", "Idempotency-Key", "X-Request-ID", "X-API-Key"}
+	conf.Cors.ExposeHeaders = []string{"Authorization", "Idempotent-Replayed", "X-Request-ID"}
+	conf.Cors.AllowCredentials = true
+	conf.Cors.MaxAge = 12 * time.Hour
+	conf.Log.File.Level = "debug"
+	conf.Log.File.Encoding = "json"
+	conf.Log.File.MaxSizeMB = 100
+	conf.Log.File.MaxBackups = 7
+	conf.Log.File.MaxAgeDays = 30
+	conf.Log.File.Compress = true
+	conf.Log.Sampling.Tick = time.Second
+	conf.Log.Sampling.Initial = 100
+	conf.Log.Sampling.Thereafter = 100
+	return conf
+}
+
+// Loader assembles a Config from its layered sources
+type Loader struct {
+	// File is the path of the YAML config file; optional
+	File string
+	// EnvFile is the path of the dotenv file; ignored if it does not exist
+	EnvFile string
+	// Flags maps yaml key paths (e.g. "gobookstore.db") to values set on the command line
+	Flags map[string]string
+}
+
+// Load the config from all sources and validate the result
+func (l *Loader) Load() (conf *Config, err error) {
+
+	conf = DefaultConfig()
+
+	if l.File != "" {
+		var data []byte
+		data, err = os.ReadFile(l.File)
+		if err != nil {
+			return nil, err
+		}
+		if err = yaml.Unmarshal(data, conf); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %s", l.File, err)
+		}
+	}
 
-	conf = &Config{}
+	if l.EnvFile != "" {
+		// godotenv does not override variables already present in the environment
+		if err = godotenv.Load(l.EnvFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("error loading %s: %s", l.EnvFile, err)
+		}
+	}
 
-	data, err := os.ReadFile(cfgFile)
-	if err != nil {
-		return
+	if err = applyEnv(reflect.ValueOf(conf).Elem()); err != nil {
+		return nil, err
 	}
 
-	err = yaml.Unmarshal([]byte(data), &conf)
-	if err != nil {
-		return
+	for key, value := range l.Flags {
+		if err = conf.Set(key, value); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = conf.Validate(); err != nil {
+		return nil, err
 	}
 	return
 }
+
+// Load the yaml file into the Config struct, layering defaults and environment variables
+func LoadConfig(cfgFile string) (conf *Config, err error) {
+	return (&Loader{File: cfgFile}).Load()
+}
+
+// Set the field addressed by its yaml key path, e.g. "gobookstore.db"
+func (c *Config) Set(key, value string) error {
+	v := reflect.ValueOf(c).Elem()
+	for _, name := range strings.Split(key, ".") {
+		f, ok := fieldByYamlName(v, name)
+		if !ok {
+			return fmt.Errorf("unknown config key %q", key)
+		}
+		v = f
+	}
+	if err := setValue(v, value); err != nil {
+		return fmt.Errorf("invalid value for %s: %s", key, err)
+	}
+	return nil
+}
+
+// Redacted returns a copy of the config with all secrets masked
+func (c *Config) Redacted() *Config {
+	out := *c
+	redact(reflect.ValueOf(&out).Elem())
+	return &out
+}
+
+// Print writes the effective config as YAML with all secrets masked
+func (c *Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func fieldByYamlName(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if yamlName(t.Field(i)) == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func yamlName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+	if name == "" {
+		return strings.ToLower(f.Name)
+	}
+	return name
+}
+
+// Assign a string representation to a config field
+func setValue(v reflect.Value, value string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func redact(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := v.Field(i)
+		switch {
+		case f.Kind() == reflect.Struct && f.Type() != reflect.TypeOf(time.Time{}):
+			redact(f)
+		case t.Field(i).Tag.Get("secret") == "true" && f.Kind() == reflect.String:
+			f.SetString(redactSecret(f.String()))
+		}
+	}
+}
+
+// Mask a secret value. Credentials embedded in URIs are masked while keeping the
+// rest of the URI readable.
+func redactSecret(s string) string {
+	if s == "" {
+		return s
+	}
+	if u, err := url.Parse(s); err == nil && u.Scheme != "" && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "xxxxx")
+			return u.String()
+		}
+	}
+	return "[REDACTED]"
+}
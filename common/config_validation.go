@@ -0,0 +1,123 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ConfigError lists every invalid field of a Config
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks required fields and value ranges, reporting all problems at once
+func (c *Config) Validate() error {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		return yamlName(f)
+	})
+	_ = v.RegisterValidation("port", validatePort)
+	_ = v.RegisterValidation("regexp", validateRegexp)
+	_ = v.RegisterValidation("origin", validateOrigin)
+	v.RegisterStructValidation(validateCors, CorsConfig{})
+
+	err := v.Struct(c)
+	if err == nil {
+		return nil
+	}
+
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return err
+	}
+
+	cerr := &ConfigError{}
+	for _, fe := range verrs {
+		// strip the root struct name from the namespace: Config.gobookstore.db
+		key := fe.Namespace()
+		if i := strings.Index(key, "."); i >= 0 {
+			key = key[i+1:]
+		}
+		cerr.Problems = append(cerr.Problems, fmt.Sprintf("%s: %s", key, describe(fe)))
+	}
+	return cerr
+}
+
+// Human readable description of a failed validation
+func describe(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_with":
+		return fmt.Sprintf("is required when %s is set", strings.ToLower(fe.Param()))
+	case "required_if":
+		return fmt.Sprintf("is required when %s", strings.Replace(fe.Param(), " ", " is ", 1))
+	case "oneof":
+		return fmt.Sprintf("must be one of [%s], got %q", fe.Param(), fe.Value())
+	case "port":
+		return fmt.Sprintf("must be a port number between 1 and 65535, got %q", fe.Value())
+	case "ip":
+		return fmt.Sprintf("must be an IP address, got %q", fe.Value())
+	case "uri":
+		return "must be a valid URI"
+	case "origin":
+		return fmt.Sprintf("must be \"*\" or an origin like https://example.com or https://*.example.com, got %q", fe.Value())
+	case "nowildcardcredentials":
+		return "must not contain \"*\" when allow_credentials is set"
+	case "regexp":
+		return fmt.Sprintf("must be a valid regular expression, got %q", fe.Value())
+	case "gte", "min":
+		return fmt.Sprintf("must be at least %s, got %v", fe.Param(), fe.Value())
+	case "lte", "max":
+		return fmt.Sprintf("must be at most %s, got %v", fe.Param(), fe.Value())
+	case "gtfield":
+		return fmt.Sprintf("must be after %s", strings.ToLower(fe.Param()))
+	}
+	return fmt.Sprintf("failed the %q check", fe.Tag())
+}
+
+func validatePort(fl validator.FieldLevel) bool {
+	p, err := strconv.Atoi(fl.Field().String())
+	return err == nil && p >= 1 && p <= 65535
+}
+
+// An origin is scheme://host[:port], where host may start with a "*." wildcard
+func validateOrigin(fl validator.FieldLevel) bool {
+	origin := fl.Field().String()
+	if origin == "*" {
+		return true
+	}
+	u, err := url.Parse(strings.Replace(origin, "://*.", "://wildcard.", 1))
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+		u.Path == "" && u.RawQuery == "" && u.User == nil && !strings.Contains(u.Host, "*")
+}
+
+// Browsers refuse credentialed requests to a server allowing every origin
+func validateCors(sl validator.StructLevel) {
+	cors := sl.Current().Interface().(CorsConfig)
+	if !cors.AllowCredentials {
+		return
+	}
+	for _, origin := range cors.AllowedOrigins {
+		if origin == "*" {
+			sl.ReportError(cors.AllowedOrigins, "allowed_origins", "AllowedOrigins", "nowildcardcredentials", "")
+			return
+		}
+	}
+}
+
+func validateRegexp(fl validator.FieldLevel) bool {
+	_, err := regexp.Compile(fl.Field().String())
+	return err == nil
+}
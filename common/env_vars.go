@@ -1,55 +1,33 @@
 package common
 
 import (
-	"errors"
+	"fmt"
 	"os"
+	"reflect"
 )
 
-// Fetch the APP_ENV env variable
-func GetAppEnv() string {
-	return os.Getenv("APP_ENV")
-}
-
-// Fetch the MONGODB URI env variable
-func getEnvMongoDbUri() string {
-	return os.Getenv("MONGODB_URI")
-}
-
-// Fetch the MONGODB name env variable
-func getEnvMongoDbName() string {
-	return os.Getenv("DB_NAME")
-}
-
-// Fetch the LOGPATH env variable
-func getLogPath() string {
-	return os.Getenv("LOG_PATH")
-}
-
-// Fetch the allowed origins regex env variable
-func GetAllowedOriginsRegex() string {
-	return os.Getenv("ALLOWED_ORIGINS_REGEX")
-}
-
-// Load application environment specific variables
-func LoadEnvSpecificConfigVariables(cfg *Config) (err error) {
-	if cfg.Env != "development" && cfg.Env != "test" {
-		if mongoDBUri := getEnvMongoDbUri(); mongoDBUri != "" {
-			cfg.GoBookStore.URI = mongoDBUri
-		} else {
-			return errors.New("mongodb uri environment variable not set")
+// Override config fields tagged with env:"NAME" by the environment variable NAME.
+// Variables which are unset or empty leave the field untouched.
+func applyEnv(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := v.Field(i)
+		if f.Kind() == reflect.Struct {
+			if err := applyEnv(f); err != nil {
+				return err
+			}
+			continue
 		}
 
-		if mongoDbName := getEnvMongoDbName(); mongoDbName != "" {
-			cfg.GoBookStore.DB = mongoDbName
-		} else {
-			return errors.New("mongodb name env variable not set")
+		name := t.Field(i).Tag.Get("env")
+		if name == "" {
+			continue
 		}
-
-		if logPath := getLogPath(); logPath != "" {
-			cfg.GoBookStore.LOGPATH = logPath
-		} else {
-			return errors.New("logpath env variable not set")
+		if value := os.Getenv(name); value != "" {
+			if err := setValue(f, value); err != nil {
+				return fmt.Errorf("invalid value for %s env variable: %s", name, err)
+			}
 		}
 	}
-	return
+	return nil
 }
@@ -0,0 +1,23 @@
+package common
+
+import "context"
+
+// Identity identifies the request a change is made by: the services record it
+// along with the changes
+type Identity struct {
+	RequestID string
+	Actor     string
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a context carrying the identity of a request
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFrom returns the identity carried by ctx, empty outside of a request
+func IdentityFrom(ctx context.Context) Identity {
+	id, _ := ctx.Value(identityKey{}).(Identity)
+	return id
+}
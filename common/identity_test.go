@@ -0,0 +1,24 @@
+package common_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/common"
+)
+
+func TestIdentity(t *testing.T) {
+	Convey("Given a context carrying the identity of a request", t, func() {
+		id := common.Identity{RequestID: "checkout-42", Actor: "apikey:pos"}
+		ctx := common.WithIdentity(context.Background(), id)
+
+		Convey("Then the identity should be returned", func() {
+			So(common.IdentityFrom(ctx), ShouldResemble, id)
+		})
+
+		Convey("Then a context outside of a request should have an empty identity", func() {
+			So(common.IdentityFrom(context.Background()), ShouldResemble, common.Identity{})
+		})
+	})
+}
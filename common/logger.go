@@ -1,59 +1,191 @@
 package common
 
 import (
+	"context"
 	"os"
 	"strings"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 type Logger struct {
 	*zap.Logger
+	// Levels of the console and file outputs, adjustable at runtime
+	Level     zap.AtomicLevel
+	FileLevel zap.AtomicLevel
+
+	file *lumberjack.Logger
+	stop chan struct{}
 }
 
-// Return a new custom zap logger instance
-func NewLogger(env string, logPath string) (*Logger, error) {
-	if env == "test" {
-		return &Logger{zap.NewNop()}, nil
+// Return a new custom zap logger instance writing to the console and to the
+// rotated log file at cfg.GoBookStore.LOGPATH
+func NewLogger(cfg *Config) (*Logger, error) {
+	if cfg.Env == "test" {
+		return &Logger{
+			Logger:    zap.NewNop(),
+			Level:     zap.NewAtomicLevel(),
+			FileLevel: zap.NewAtomicLevel(),
+		}, nil
 	}
 
-	var (
-		l   *zap.Logger
-		err error
-	)
+	production := strings.EqualFold(cfg.Env, "production")
+
+	l := &Logger{
+		Level:     zap.NewAtomicLevelAt(zap.InfoLevel),
+		FileLevel: zap.NewAtomicLevelAt(zap.DebugLevel),
+		stop:      make(chan struct{}),
+	}
+	if !production {
+		l.Level.SetLevel(zap.DebugLevel)
+	}
+	if err := l.SetLevels(cfg.Log.Console.Level, cfg.Log.File.Level); err != nil {
+		return nil, err
+	}
 
-	if strings.EqualFold(env, "production") {
-		l, err = zap.NewProduction()
-		if err != nil {
-			return nil, err
+	consoleEncoding := cfg.Log.Console.Encoding
+	if consoleEncoding == "" {
+		consoleEncoding = "console"
+		if production {
+			consoleEncoding = "json"
 		}
-	} else {
-		config := zap.NewDevelopmentConfig()
-		config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	}
+	consoleCore := zapcore.NewCore(newEncoder(consoleEncoding, !production), zapcore.Lock(os.Stderr), l.Level)
+
+	// lumberjack appends to an existing log file and rotates it once it exceeds MaxSize
+	l.file = &lumberjack.Logger{
+		Filename:   cfg.GoBookStore.LOGPATH,
+		MaxSize:    cfg.Log.File.MaxSizeMB,
+		MaxBackups: cfg.Log.File.MaxBackups,
+		MaxAge:     cfg.Log.File.MaxAgeDays,
+		Compress:   cfg.Log.File.Compress,
+	}
+	fileCore := zapcore.NewCore(newEncoder(cfg.Log.File.Encoding, false), zapcore.AddSync(l.file), l.FileLevel)
 
-		l, err = config.Build()
-		if err != nil {
-			return nil, err
+	core := zapcore.NewTee(consoleCore, fileCore)
+	if s := cfg.Log.Sampling; s.Enabled {
+		core = &infoSampler{
+			sampled: zapcore.NewSamplerWithOptions(core, s.Tick, s.Initial, s.Thereafter),
+			Core:    core,
 		}
 	}
 
-	logFile, err := os.Create(logPath)
-	if err != nil {
-		return nil, err
+	opts := []zap.Option{
+		zap.AddCaller(),
+		zap.AddStacktrace(zapcore.PanicLevel),
+		zap.WithFatalHook(zapcore.WriteThenGoexit),
+	}
+	if !production {
+		opts = append(opts, zap.Development())
 	}
+	l.Logger = zap.New(core, opts...).Named(SvcName)
 
-	fileEncoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
-	fileCore := zapcore.NewCore(fileEncoder, zapcore.AddSync(logFile), zap.DebugLevel)
+	if every := cfg.Log.File.RotateEvery; every > 0 {
+		go l.rotateEvery(every)
+	}
 
-	l = l.Named(SvcName).
-		WithOptions(zap.AddStacktrace(zapcore.PanicLevel)).
-		WithOptions(zap.WithFatalHook(zapcore.WriteThenGoexit)).
-		WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
-			return zapcore.NewTee(c, fileCore)
-		}))
+	return l, nil
+}
 
-	return &Logger{
-		l,
-	}, nil
+// SetLevels changes the console and file log levels; empty levels are ignored
+func (l *Logger) SetLevels(console, file string) error {
+	if console != "" {
+		if err := l.Level.UnmarshalText([]byte(console)); err != nil {
+			return err
+		}
+	}
+	if file != "" {
+		if err := l.FileLevel.UnmarshalText([]byte(file)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForRequest returns a logger tagging its entries with the id and the actor of a
+// request. Closing it is a no-op.
+func (l *Logger) ForRequest(id Identity) *Logger {
+	out := *l
+	out.Logger = l.Logger.With(zap.String("request_id", id.RequestID), zap.String("actor", id.Actor))
+	out.file, out.stop = nil, nil
+	return &out
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a context carrying the logger of a request
+func WithLogger(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// LoggerFrom returns the logger carried by ctx, or fallback if there is none
+func LoggerFrom(ctx context.Context, fallback *Logger) *Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*Logger); ok {
+		return l
+	}
+	return fallback
+}
+
+// Close flushes the buffered logs and closes the log file
+func (l *Logger) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	close(l.stop)
+	_ = l.Sync()
+	return l.file.Close()
+}
+
+// Rotate the log file at a fixed interval, in addition to the size based rotation
+func (l *Logger) rotateEvery(every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			if err := l.file.Rotate(); err != nil {
+				l.Error("error rotating the log file: " + err.Error())
+			}
+		}
+	}
+}
+
+func newEncoder(encoding string, development bool) zapcore.Encoder {
+	config := zap.NewProductionEncoderConfig()
+	if development {
+		config = zap.NewDevelopmentEncoderConfig()
+	}
+	if encoding == "console" {
+		if development {
+			config.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		}
+		return zapcore.NewConsoleEncoder(config)
+	}
+	return zapcore.NewJSONEncoder(config)
+}
+
+// infoSampler samples debug and info entries only, so warnings and errors are
+// never dropped
+type infoSampler struct {
+	zapcore.Core
+	sampled zapcore.Core
+}
+
+func (s *infoSampler) With(fields []zapcore.Field) zapcore.Core {
+	return &infoSampler{
+		Core:    s.Core.With(fields),
+		sampled: s.sampled.With(fields),
+	}
+}
+
+func (s *infoSampler) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level <= zapcore.InfoLevel {
+		return s.sampled.Check(ent, ce)
+	}
+	return s.Core.Check(ent, ce)
 }
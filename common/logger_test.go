@@ -0,0 +1,41 @@
+package common_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/common"
+)
+
+func TestLoggerFileSink(t *testing.T) {
+	Convey("Given an existing log file", t, func() {
+		logPath := filepath.Join(t.TempDir(), "gbs.log")
+		So(os.WriteFile(logPath, []byte("previous run\n"), 0o600), ShouldBeNil)
+
+		cfg := common.DefaultConfig()
+		cfg.GoBookStore.LOGPATH = logPath
+		cfg.Log.File.Level = "warn"
+
+		Convey("When the logger writes entries above and below the file level", func() {
+			log, err := common.NewLogger(cfg)
+			So(err, ShouldBeNil)
+			log.Info("not for the file")
+			log.Warn("for the file")
+			So(log.Close(), ShouldBeNil)
+
+			data, err := os.ReadFile(logPath)
+			So(err, ShouldBeNil)
+
+			Convey("Then the previous log is kept", func() {
+				So(string(data), ShouldStartWith, "previous run\n")
+			})
+
+			Convey("Then only entries at or above the file level are written", func() {
+				So(string(data), ShouldContainSubstring, "for the file")
+				So(string(data), ShouldNotContainSubstring, "not for the file")
+			})
+		})
+	})
+}
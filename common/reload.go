@@ -0,0 +1,132 @@
+package common
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// How often the config file is checked for changes
+const reloadPollInterval = 2 * time.Second
+
+// Reloader keeps the config currently in effect and swaps it when the config
+// file changes or the process receives SIGHUP. Only sections tagged with
+// reload:"true" are applied; changes to any other setting are logged and ignored
+// until the next restart.
+type Reloader struct {
+	loader  *Loader
+	log     *Logger
+	current atomic.Pointer[Config]
+
+	mu        sync.Mutex
+	listeners []func(*Config)
+}
+
+// NewReloader returns a Reloader serving cfg until the next successful reload
+func NewReloader(loader *Loader, cfg *Config, log *Logger) *Reloader {
+	r := &Reloader{
+		loader: loader,
+		log:    log,
+	}
+	r.current.Store(cfg)
+	return r
+}
+
+// Config returns the config currently in effect
+func (r *Reloader) Config() *Config {
+	return r.current.Load()
+}
+
+// OnReload registers fn to be called with the new config after every successful reload
+func (r *Reloader) OnReload(fn func(*Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+// Reload the config from its sources. An invalid config is rejected as a whole
+// and the current one is kept.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := r.loader.Load()
+	if err != nil {
+		r.log.Sugar().Errorf("config reload rejected: %s", err)
+		return err
+	}
+
+	old := r.Config()
+	keepNonReloadable(reflect.ValueOf(next).Elem(), reflect.ValueOf(old).Elem(), "", r.log)
+
+	r.current.Store(next)
+	for _, fn := range r.listeners {
+		fn(next)
+	}
+	r.log.Info("config reloaded")
+	return nil
+}
+
+// Watch reloads the config on SIGHUP and whenever the config file changes, until ctx is done
+func (r *Reloader) Watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(reloadPollInterval)
+	defer ticker.Stop()
+
+	lastMod := r.fileModTime()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.log.Info("received SIGHUP, reloading config")
+			_ = r.Reload()
+		case <-ticker.C:
+			if mod := r.fileModTime(); !mod.Equal(lastMod) {
+				lastMod = mod
+				r.log.Sugar().Infof("config file %s changed, reloading config", r.loader.File)
+				_ = r.Reload()
+			}
+		}
+	}
+}
+
+func (r *Reloader) fileModTime() time.Time {
+	if r.loader.File == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(r.loader.File)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// Restore every field of next not tagged reload:"true" from old, warning about
+// each one which was changed
+func keepNonReloadable(next, old reflect.Value, prefix string, log *Logger) {
+	t := next.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Tag.Get("reload") == "true" {
+			continue
+		}
+		key := prefix + yamlName(f)
+		if f.Type.Kind() == reflect.Struct {
+			keepNonReloadable(next.Field(i), old.Field(i), key+".", log)
+			continue
+		}
+		if !reflect.DeepEqual(next.Field(i).Interface(), old.Field(i).Interface()) {
+			log.Sugar().Warnf("ignoring change to %s: setting cannot be reloaded, restart the service to apply it", key)
+			next.Field(i).Set(old.Field(i))
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package common_test
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/common"
+)
+
+func TestReloader(t *testing.T) {
+	Convey("Given a reloader serving a config file", t, func() {
+		cfgFile := writeFile(t, "config.yaml", testYaml+"log:\n  console:\n    level: info\n")
+		loader := &common.Loader{File: cfgFile}
+		cfg, err := loader.Load()
+		So(err, ShouldBeNil)
+
+		log, _ := common.NewLogger(&common.Config{Env: "test"})
+		r := common.NewReloader(loader, cfg, log)
+
+		var notified *common.Config
+		r.OnReload(func(c *common.Config) { notified = c })
+
+		Convey("When a reloadable setting changes", func() {
+			err := os.WriteFile(cfgFile, []byte(testYaml+"log:\n  console:\n    level: debug\n"), 0o600)
+			So(err, ShouldBeNil)
+			So(r.Reload(), ShouldBeNil)
+
+			Convey("Then the new value is in effect and listeners are notified", func() {
+				So(r.Config().Log.Console.Level, ShouldEqual, "debug")
+				So(notified, ShouldEqual, r.Config())
+			})
+		})
+
+		Convey("When a non-reloadable setting changes", func() {
+			yaml := "env: development\nport: 9999\ngobookstore:\n  dsn: mongodb://localhost\n"
+			So(os.WriteFile(cfgFile, []byte(yaml), 0o600), ShouldBeNil)
+			So(r.Reload(), ShouldBeNil)
+
+			Convey("Then the change is ignored", func() {
+				So(r.Config().Port, ShouldEqual, "9000")
+				So(r.Config().GoBookStore.URI, ShouldEqual, cfg.GoBookStore.URI)
+			})
+		})
+
+		Convey("When the new config is invalid", func() {
+			So(os.WriteFile(cfgFile, []byte(testYaml+"log:\n  console:\n    level: loud\n"), 0o600), ShouldBeNil)
+
+			Convey("Then the reload is rejected and the old config kept", func() {
+				So(r.Reload(), ShouldNotBeNil)
+				So(r.Config(), ShouldEqual, cfg)
+				So(notified, ShouldBeNil)
+			})
+		})
+	})
+}
@@ -1,8 +1,22 @@
 package common
 
+import "github.com/snehil-sinha/goBookStore/events"
+
 // App represents a container for all service related objects
 
 type App struct {
 	Cfg *Config
 	Log *Logger
+	// Events carries the changes made to the catalog
+	Events *events.Bus
+	// Reloader serves the reloadable settings; nil if hot reload is not set up
+	Reloader *Reloader
+}
+
+// Config returns the config currently in effect, including reloaded settings
+func (a *App) Config() *Config {
+	if a.Reloader != nil {
+		return a.Reloader.Config()
+	}
+	return a.Cfg
 }
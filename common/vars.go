@@ -8,4 +8,6 @@ const SvcName = "GBS"
 const (
 	Bind = "0.0.0.0"
 	Port = "8080"
+	// GRPCPort is the default port of the gRPC server
+	GRPCPort = "9090"
 )
@@ -51,6 +51,11 @@ func New(log *common.Logger, database, uri string) (err error) {
 	return
 }
 
+// Database returns the database of the connection
+func (d *db) Database() *mongo.Database {
+	return d.Client.Database(d.DB)
+}
+
 func (d *db) Close(ctx context.Context, log *common.Logger) (err error) {
 
 	if err = d.Client.Disconnect(ctx); err != nil {
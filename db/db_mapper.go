@@ -8,6 +8,12 @@ import (
 
 var (
 	GoBookStore *mgm.Collection
+	// BookAudit holds the audit log of the changes made to the books
+	BookAudit *mgm.Collection
+	// BookRevisions holds the successive versions of the books
+	BookRevisions *mgm.Collection
+	// APIKeys holds the API keys of the clients
+	APIKeys *mgm.Collection
 )
 
 // Initialise the ODM
@@ -24,4 +30,7 @@ func InitDbMapper(log *common.Logger, database, uri string) (err error) {
 // Intialise the collections within the DB
 func initCollections() {
 	GoBookStore = mgm.CollectionByName(CollectionGoBookStore)
+	BookAudit = mgm.CollectionByName(CollectionBookAudit)
+	BookRevisions = mgm.CollectionByName(CollectionBookRevisions)
+	APIKeys = mgm.CollectionByName(CollectionAPIKeys)
 }
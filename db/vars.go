@@ -2,5 +2,11 @@ package db
 
 // Collections in the DB
 const (
-	CollectionGoBookStore = "goBookStore"
+	CollectionGoBookStore   = "goBookStore"
+	CollectionBookAudit     = "bookAudit"
+	CollectionBookRevisions = "bookRevisions"
+	CollectionAPIKeys       = "apiKeys"
+	// CollectionMigrations records the applied migrations, see the migrations
+	// package
+	CollectionMigrations = "migrations"
 )
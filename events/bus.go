@@ -0,0 +1,170 @@
+package events
+
+// In-process publish/subscribe of catalog changes.
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Type of change an event describes
+type Type string
+
+const (
+	Created Type = "created"
+	Updated Type = "updated"
+	Deleted Type = "deleted"
+)
+
+// Event describes a change to a catalog entity
+type Event struct {
+	// ID increases monotonically with every event published on a bus
+	ID      uint64    `json:"id"`
+	Type    Type      `json:"type"`
+	Subject string    `json:"subject"` // ID of the changed entity
+	Data    any       `json:"data,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Size of the channel buffering the events of a subscriber
+const subscriberBuffer = 64
+
+// History is the number of latest events a bus keeps for the subscribers
+// resuming after a disconnection, see SubscribeSince
+const History = 1024
+
+// Bus fans out published events to all of its subscribers. Subscribers which
+// fall behind by more than their buffer are dropped, so a slow subscriber never
+// blocks the publishers.
+type Bus struct {
+	mu     sync.Mutex
+	epoch  string
+	lastID uint64
+	// history is a ring of the latest events, the event n at n % History
+	history []Event
+	subs    map[*Subscription]struct{}
+}
+
+// Subscription receives the events published after it was created
+type Subscription struct {
+	bus    *Bus
+	lastID uint64
+	ch     chan Event
+	once   sync.Once
+}
+
+// NewBus returns a bus without subscribers
+func NewBus() *Bus {
+	return &Bus{
+		epoch:   newEpoch(),
+		history: make([]Event, History),
+		subs:    make(map[*Subscription]struct{}),
+	}
+}
+
+// newEpoch returns a random epoch
+func newEpoch() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Epoch identifies the bus among the buses of the process and its restarts. The
+// IDs of the events are only comparable between events of the same epoch.
+func (b *Bus) Epoch() string {
+	return b.epoch
+}
+
+// Publish assigns the next ID to the event and delivers it to every subscriber.
+// A nil bus discards the event.
+func (b *Bus) Publish(typ Type, subject string, data any) Event {
+	if b == nil {
+		return Event{}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	e := Event{
+		ID:      b.lastID,
+		Type:    typ,
+		Subject: subject,
+		Data:    data,
+		Time:    time.Now().UTC(),
+	}
+	b.history[e.ID%History] = e
+
+	for sub := range b.subs {
+		select {
+		case sub.ch <- e:
+		default:
+			// the subscriber is too slow, drop it
+			delete(b.subs, sub)
+			sub.close()
+		}
+	}
+	return e
+}
+
+// Subscribe returns a subscription to the events published from now on
+func (b *Bus) Subscribe() *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.subscribe()
+}
+
+// SubscribeSince returns a subscription to the events published from now on, and
+// the events published after the one with the ID lastID, which the subscriber
+// missed. ok is false if they are no longer all in the history, or if lastID
+// was never published; the subscription is returned regardless.
+func (b *Bus) SubscribeSince(lastID uint64) (sub *Subscription, missed []Event, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub = b.subscribe()
+	if lastID > b.lastID || b.lastID-lastID > History {
+		return sub, nil, false
+	}
+	for id := lastID + 1; id <= b.lastID; id++ {
+		missed = append(missed, b.history[id%History])
+	}
+	return sub, missed, true
+}
+
+// subscribe adds a subscriber, with b.mu held
+func (b *Bus) subscribe() *Subscription {
+	sub := &Subscription{
+		bus:    b,
+		lastID: b.lastID,
+		ch:     make(chan Event, subscriberBuffer),
+	}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// Events returns the channel of events, closed once the subscription is cancelled
+// or dropped for being too slow
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// LastID returns the ID of the last event published before the subscription, 0
+// if none was
+func (s *Subscription) LastID() uint64 {
+	return s.lastID
+}
+
+// Cancel stops the delivery of events and closes the events channel
+func (s *Subscription) Cancel() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	delete(s.bus.subs, s)
+	s.close()
+}
+
+func (s *Subscription) close() {
+	s.once.Do(func() { close(s.ch) })
+}
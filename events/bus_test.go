@@ -0,0 +1,128 @@
+package events_test
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/events"
+)
+
+func TestBus(t *testing.T) {
+	Convey("Given a bus with a subscriber", t, func() {
+		bus := events.NewBus()
+		sub := bus.Subscribe()
+
+		Convey("When events are published", func() {
+			bus.Publish(events.Created, "1", nil)
+			bus.Publish(events.Deleted, "1", nil)
+
+			Convey("Then the subscriber should receive them in order with increasing ids", func() {
+				first, second := <-sub.Events(), <-sub.Events()
+				So(first.Type, ShouldEqual, events.Created)
+				So(second.Type, ShouldEqual, events.Deleted)
+				So(second.ID, ShouldEqual, first.ID+1)
+			})
+		})
+
+		Convey("When the subscriber falls behind", func() {
+			for i := 0; i < 100; i++ {
+				bus.Publish(events.Updated, "1", nil)
+			}
+
+			Convey("Then it should be dropped once its buffer is full", func() {
+				n := 0
+				for range sub.Events() {
+					n++
+				}
+				So(n, ShouldBeLessThan, 100)
+			})
+		})
+
+		Convey("When the subscription is cancelled", func() {
+			sub.Cancel()
+			bus.Publish(events.Created, "1", nil)
+
+			Convey("Then its channel should be closed", func() {
+				_, ok := <-sub.Events()
+				So(ok, ShouldBeFalse)
+			})
+		})
+	})
+
+	Convey("Given a bus with published events", t, func() {
+		bus := events.NewBus()
+		first := bus.Publish(events.Created, "1", nil)
+		bus.Publish(events.Updated, "1", nil)
+		last := bus.Publish(events.Deleted, "1", nil)
+
+		Convey("When subscribing since the first event", func() {
+			sub, missed, ok := bus.SubscribeSince(first.ID)
+			defer sub.Cancel()
+
+			Convey("Then the events published after it should be returned", func() {
+				So(ok, ShouldBeTrue)
+				So(missed, ShouldHaveLength, 2)
+				So(missed[0].Type, ShouldEqual, events.Updated)
+				So(missed[1].ID, ShouldEqual, last.ID)
+				So(sub.LastID(), ShouldEqual, last.ID)
+			})
+
+			Convey("Then the events published from now on should be received", func() {
+				bus.Publish(events.Created, "2", nil)
+				e := <-sub.Events()
+				So(e.ID, ShouldEqual, last.ID+1)
+			})
+		})
+
+		Convey("When subscribing since the last event", func() {
+			sub, missed, ok := bus.SubscribeSince(last.ID)
+			defer sub.Cancel()
+
+			Convey("Then no event should be missed", func() {
+				So(ok, ShouldBeTrue)
+				So(missed, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When subscribing since an event never published", func() {
+			sub, missed, ok := bus.SubscribeSince(last.ID + 1)
+			defer sub.Cancel()
+
+			Convey("Then the missed events should be unknown", func() {
+				So(ok, ShouldBeFalse)
+				So(missed, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When the event is no longer in the history", func() {
+			for i := 0; i < events.History; i++ {
+				bus.Publish(events.Updated, "1", nil)
+			}
+			sub, _, ok := bus.SubscribeSince(first.ID)
+			defer sub.Cancel()
+
+			Convey("Then the missed events should be unknown", func() {
+				So(ok, ShouldBeFalse)
+			})
+
+			Convey("Then the latest events should still be returned", func() {
+				_, missed, ok := bus.SubscribeSince(last.ID)
+				So(ok, ShouldBeTrue)
+				So(missed, ShouldHaveLength, events.History)
+			})
+		})
+
+		Convey("Then its epoch should differ from another bus", func() {
+			So(bus.Epoch(), ShouldNotBeEmpty)
+			So(bus.Epoch(), ShouldNotEqual, events.NewBus().Epoch())
+		})
+	})
+
+	Convey("Given a nil bus", t, func() {
+		var bus *events.Bus
+
+		Convey("Then publishing should be a no-op", func() {
+			So(func() { bus.Publish(events.Created, "1", nil) }, ShouldNotPanic)
+		})
+	})
+}
@@ -0,0 +1,167 @@
+package migrations
+
+// Migrations of the collections of the service. They work on raw documents
+// rather than on the models, which change after them.
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/snehil-sinha/goBookStore/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// All are the migrations of the service, run in order of version. A migration is
+// never changed once released; a new one is added instead.
+var All = []Migration{
+	{
+		Version:     1,
+		Description: "index the books by title and pages, reference, ISBN and deletion",
+		Up: createIndexes(db.CollectionGoBookStore,
+			mongo.IndexModel{Keys: bson.D{{Key: "title", Value: 1}, {Key: "pages", Value: 1}}, Options: options.Index().SetName("title_pages")},
+			mongo.IndexModel{Keys: bson.D{{Key: "reference", Value: 1}}, Options: options.Index().SetName("reference").SetSparse(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "isbn", Value: 1}}, Options: options.Index().SetName("isbn").SetSparse(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "deleted_at", Value: 1}}, Options: options.Index().SetName("deleted_at").SetSparse(true)},
+		),
+		Down: dropIndexes(db.CollectionGoBookStore, "title_pages", "reference", "isbn", "deleted_at"),
+	},
+	{
+		Version:     2,
+		Description: "index the audit log by book, time and request",
+		Up: createIndexes(db.CollectionBookAudit,
+			mongo.IndexModel{Keys: bson.D{{Key: "book_id", Value: 1}, {Key: "time", Value: -1}}, Options: options.Index().SetName("book_id_time")},
+			mongo.IndexModel{Keys: bson.D{{Key: "time", Value: -1}}, Options: options.Index().SetName("time")},
+			mongo.IndexModel{Keys: bson.D{{Key: "request_id", Value: 1}}, Options: options.Index().SetName("request_id").SetSparse(true)},
+		),
+		Down: dropIndexes(db.CollectionBookAudit, "book_id_time", "time", "request_id"),
+	},
+	{
+		Version:     3,
+		Description: "index the revisions by book and number, and by book and time",
+		Up: createIndexes(db.CollectionBookRevisions,
+			mongo.IndexModel{Keys: bson.D{{Key: "book_id", Value: 1}, {Key: "revision", Value: 1}}, Options: options.Index().SetName("book_id_revision").SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "book_id", Value: 1}, {Key: "time", Value: -1}}, Options: options.Index().SetName("book_id_time")},
+		),
+		Down: dropIndexes(db.CollectionBookRevisions, "book_id_revision", "book_id_time"),
+	},
+	{
+		Version:     4,
+		Description: "store the first revision of the books stored before the revisions were recorded",
+		Up:          backfillRevisions,
+		Down:        removeBackfilledRevisions,
+	},
+	{
+		Version:     5,
+		Description: "index the API keys by hash and name",
+		Up: createIndexes(db.CollectionAPIKeys,
+			mongo.IndexModel{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetName("hash").SetUnique(true)},
+			mongo.IndexModel{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetName("name")},
+		),
+		Down: dropIndexes(db.CollectionAPIKeys, "hash", "name"),
+	},
+}
+
+// createIndexes returns a migration step creating the indexes of a collection
+func createIndexes(collection string, indexes ...mongo.IndexModel) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, database *mongo.Database) error {
+		_, err := database.Collection(collection).Indexes().CreateMany(ctx, indexes)
+		return err
+	}
+}
+
+// dropIndexes returns a migration step dropping the indexes of a collection by
+// name
+func dropIndexes(collection string, names ...string) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, database *mongo.Database) error {
+		for _, name := range names {
+			if _, err := database.Collection(collection).Indexes().DropOne(ctx, name); err != nil {
+				return fmt.Errorf("dropping the index %s of %s: %w", name, collection, err)
+			}
+		}
+		return nil
+	}
+}
+
+// backfillRequestID marks the revisions stored by backfillRevisions
+const backfillRequestID = "migration-4"
+
+// backfillRevisions stores the revision 1 of the books without a revision. It
+// may be run again after it stopped midway.
+func backfillRevisions(ctx context.Context, database *mongo.Database) error {
+	books := database.Collection(db.CollectionGoBookStore)
+	cursor, err := books.Find(ctx, bson.M{"revision": bson.M{"$exists": false}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var b bson.M
+		if err = cursor.Decode(&b); err != nil {
+			return err
+		}
+		b["revision"] = 1
+		snapshot := bson.M{}
+		for k, v := range b {
+			if k != "deleted_at" {
+				snapshot[k] = v
+			}
+		}
+		// upserted rather than inserted, so that a run stopped before the book was
+		// set its revision can be run again
+		_, err = database.Collection(db.CollectionBookRevisions).UpdateOne(ctx,
+			bson.M{"book_id": b["_id"], "revision": 1},
+			bson.M{"$setOnInsert": bson.M{
+				"_id":        primitive.NewObjectID(),
+				"time":       b["updated_at"],
+				"actor":      "system",
+				"request_id": backfillRequestID,
+				"book":       snapshot,
+			}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+		if _, err = books.UpdateOne(ctx, bson.M{"_id": b["_id"]}, bson.M{"$set": bson.M{"revision": 1}}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// removeBackfilledRevisions removes the revisions stored by backfillRevisions,
+// except for the books updated since, whose later revisions follow them
+func removeBackfilledRevisions(ctx context.Context, database *mongo.Database) error {
+	revisions := database.Collection(db.CollectionBookRevisions)
+	cursor, err := revisions.Find(ctx, bson.M{"request_id": backfillRequestID})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var r struct {
+			ID     primitive.ObjectID `bson:"_id"`
+			BookID primitive.ObjectID `bson:"book_id"`
+		}
+		if err = cursor.Decode(&r); err != nil {
+			return err
+		}
+		res, err := database.Collection(db.CollectionGoBookStore).UpdateOne(ctx,
+			bson.M{"_id": r.BookID, "revision": 1},
+			bson.M{"$unset": bson.M{"revision": ""}})
+		if err != nil {
+			return err
+		}
+		if res.MatchedCount == 0 {
+			continue
+		}
+		if _, err = revisions.DeleteOne(ctx, bson.M{"_id": r.ID}); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
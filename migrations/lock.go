@@ -0,0 +1,133 @@
+package migrations
+
+// Lock of the migrations, a document of the migrations collection held by one
+// instance at a time until it expires.
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// lockID is the _id of the lock document, apart from the versions of the
+	// records
+	lockID = "lock"
+	// lockPoll is the interval between the attempts to take the lock
+	lockPoll = 500 * time.Millisecond
+)
+
+// newOwner returns an id of the instance holding the lock
+func newOwner() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s/%d/%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// tryLock takes or refreshes the lock, unless another instance holds it
+func (m *Migrator) tryLock(ctx context.Context) (bool, error) {
+	now := time.Now().UTC()
+	_, err := m.collection().UpdateOne(ctx,
+		bson.M{"_id": lockID, "$or": bson.A{bson.M{"owner": m.owner}, bson.M{"expires_at": bson.M{"$lt": now}}}},
+		bson.M{"$set": bson.M{"owner": m.owner, "expires_at": now.Add(m.LockTTL)}},
+		options.Update().SetUpsert(true))
+	// the lock held by another instance is not matched, and cannot be inserted again
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// lock waits for the lock for up to the lock timeout
+func (m *Migrator) lock(ctx context.Context) error {
+	deadline := time.Now().Add(m.LockTimeout)
+	for waiting := false; ; waiting = true {
+		ok, err := m.tryLock(ctx)
+		if err != nil || ok {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return ErrLocked
+		}
+		if !waiting {
+			m.log.Info("waiting for the migrations lock held by another instance")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPoll):
+		}
+	}
+}
+
+// refreshLock extends the lock before a migration, failing if it was lost
+func (m *Migrator) refreshLock(ctx context.Context) error {
+	ok, err := m.tryLock(ctx)
+	if err == nil && !ok {
+		err = ErrLockLost
+	}
+	return err
+}
+
+// heartbeat refreshes the lock every third of the lock TTL while a migration
+// runs, until ctx is done. If the lock is lost, or cannot be refreshed before it
+// expires, ctx is cancelled with ErrLockLost, stopping the migration before
+// another instance takes over.
+func (m *Migrator) heartbeat(ctx context.Context, cancel context.CancelCauseFunc) {
+	interval := m.LockTTL / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	refreshed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ok, err := m.tryLock(ctx)
+		switch {
+		case ok:
+			refreshed = time.Now()
+		case ctx.Err() != nil:
+			return
+		case err == nil:
+			cancel(ErrLockLost)
+			return
+		default:
+			m.log.Warn("error refreshing the migrations lock: " + err.Error())
+			// the lock would expire before the next attempt
+			if time.Since(refreshed)+interval >= m.LockTTL {
+				cancel(ErrLockLost)
+				return
+			}
+		}
+	}
+}
+
+// lockLost returns ErrLockLost in place of err if ctx was cancelled by the
+// heartbeat for losing the lock
+func lockLost(ctx context.Context, err error) error {
+	if errors.Is(context.Cause(ctx), ErrLockLost) {
+		return ErrLockLost
+	}
+	return err
+}
+
+// unlock releases the lock if it is still held
+func (m *Migrator) unlock() {
+	_, err := m.collection().DeleteOne(context.Background(), bson.M{"_id": lockID, "owner": m.owner})
+	if err != nil {
+		m.log.Error("error releasing the migrations lock: " + err.Error())
+	}
+}
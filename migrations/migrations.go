@@ -0,0 +1,280 @@
+// Package migrations evolves the collections of the DB with ordered, versioned
+// migrations. The applied ones are recorded in the migrations collection, along
+// with a lock held while they run so that concurrent instances do not race.
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Direction is the direction migrations are run in
+type Direction string
+
+const (
+	Up   Direction = "up"
+	Down Direction = "down"
+)
+
+// Migration is a versioned change of the DB. Down undoes Up; it is nil for a
+// migration which cannot be undone.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, database *mongo.Database) error
+	Down        func(ctx context.Context, database *mongo.Database) error
+}
+
+// Status is the state of a migration. A migration applied by a newer build is
+// listed with Unknown set.
+type Status struct {
+	Version     int        `json:"version"`
+	Description string     `json:"description"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+	Unknown     bool       `json:"unknown,omitempty"`
+}
+
+var (
+	// ErrLocked is returned when another instance held the lock for longer than
+	// the lock timeout
+	ErrLocked = errors.New("the migrations are locked by another instance")
+	// ErrLockLost is returned when the lock expired while the migrations ran,
+	// and may have been taken over by another instance
+	ErrLockLost = errors.New("the migrations lock was lost")
+	// ErrIrreversible is returned when rolling back a migration without Down
+	ErrIrreversible = errors.New("the migration cannot be rolled back")
+)
+
+// record is the document of an applied migration
+type record struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+// Migrator runs the migrations against a database
+type Migrator struct {
+	log        *common.Logger
+	database   *mongo.Database
+	migrations []Migration
+	owner      string
+	// LockTimeout bounds the wait for the lock held by another instance
+	LockTimeout time.Duration
+	// LockTTL is how long the lock is held for before it is refreshed, after
+	// which the lock of a crashed instance is taken over
+	LockTTL time.Duration
+}
+
+// New returns a migrator of the migrations, which are sorted by version
+func New(log *common.Logger, database *mongo.Database, migrations []Migration) (*Migrator, error) {
+	sorted := append([]Migration{}, migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, m := range sorted {
+		if m.Version < 1 || m.Up == nil {
+			return nil, fmt.Errorf("migration %d: the version must be positive and Up set", m.Version)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("migration %d: the version is used twice", m.Version)
+		}
+	}
+	return &Migrator{
+		log:         log,
+		database:    database,
+		migrations:  sorted,
+		owner:       newOwner(),
+		LockTimeout: time.Minute,
+		LockTTL:     10 * time.Minute,
+	}, nil
+}
+
+// LatestVersion returns the latest version of the migrations, 0 if there are none
+func LatestVersion(migrations []Migration) (latest int) {
+	for _, m := range migrations {
+		if m.Version > latest {
+			latest = m.Version
+		}
+	}
+	return
+}
+
+func (m *Migrator) collection() *mongo.Collection {
+	return m.database.Collection(db.CollectionMigrations)
+}
+
+// applied returns the records of the applied migrations, by version
+func (m *Migrator) applied(ctx context.Context) (map[int]record, error) {
+	cursor, err := m.collection().Find(ctx, bson.M{"_id": bson.M{"$type": "number"}})
+	if err != nil {
+		return nil, err
+	}
+	var records []record
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	out := make(map[int]record, len(records))
+	for _, r := range records {
+		out[r.Version] = r
+	}
+	return out, nil
+}
+
+// Status returns the state of every migration, ordered by version
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Description: mig.Description}
+		if r, ok := applied[mig.Version]; ok {
+			s.AppliedAt = &r.AppliedAt
+			delete(applied, mig.Version)
+		}
+		out = append(out, s)
+	}
+	for _, r := range applied {
+		r := r
+		out = append(out, Status{Version: r.Version, Description: r.Description, AppliedAt: &r.AppliedAt, Unknown: true})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// Plan returns the migrations to run, in order, to go in the direction up to the
+// version to: up, the pending migrations up to it (all of them if to is 0);
+// down, the applied migrations above it (only the latest one if to is
+// negative). applied are the versions of the applied migrations.
+func Plan(migrations []Migration, applied []int, dir Direction, to int) ([]Migration, error) {
+	isApplied := make(map[int]bool, len(applied))
+	for _, v := range applied {
+		isApplied[v] = true
+	}
+	known := make(map[int]bool, len(migrations))
+	for _, mig := range migrations {
+		known[mig.Version] = true
+	}
+
+	var out []Migration
+	switch dir {
+	case Up:
+		for _, mig := range migrations {
+			if !isApplied[mig.Version] && (to == 0 || mig.Version <= to) {
+				out = append(out, mig)
+			}
+		}
+	case Down:
+		versions := append([]int{}, applied...)
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+		for _, v := range versions {
+			if to >= 0 && v <= to {
+				break
+			}
+			if !known[v] {
+				return nil, fmt.Errorf("migration %d was applied by a newer build and cannot be rolled back by this one", v)
+			}
+			for _, mig := range migrations {
+				if mig.Version == v {
+					out = append(out, mig)
+				}
+			}
+			if to < 0 {
+				break
+			}
+		}
+	default:
+		return nil, fmt.Errorf("direction must be one of up or down, got %q", dir)
+	}
+	return out, nil
+}
+
+// plan returns the migrations to run in the direction up to the version to, see
+// Plan
+func (m *Migrator) plan(ctx context.Context, dir Direction, to int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	return Plan(m.migrations, versions, dir, to)
+}
+
+// Run runs the migrations in the direction up to the version to (see Plan) and
+// returns the ones it ran. In dry-run mode, it returns the ones it would run
+// without running them. A failed migration stops the run; the ones before it
+// stay applied. The lock is refreshed while the migrations run, and the one
+// running is cancelled if the lock is lost, failing with ErrLockLost.
+func (m *Migrator) Run(ctx context.Context, dir Direction, to int, dryRun bool) (ran []Migration, err error) {
+	if dryRun {
+		return m.plan(ctx, dir, to)
+	}
+
+	if err = m.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer m.unlock()
+
+	// the heartbeat is stopped before the lock is released, or it could take
+	// the lock again
+	ctx, cancel := context.WithCancelCause(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		m.heartbeat(ctx, cancel)
+	}()
+	defer func() {
+		cancel(nil)
+		<-stopped
+	}()
+
+	// planned once locked, to see the migrations run by another instance meanwhile
+	plan, err := m.plan(ctx, dir, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, mig := range plan {
+		if err = m.refreshLock(ctx); err != nil {
+			return ran, lockLost(ctx, err)
+		}
+		m.log.Sugar().Infof("migrating %s to %d: %s", dir, mig.Version, mig.Description)
+		if err = m.apply(ctx, dir, mig); err != nil {
+			return ran, fmt.Errorf("migration %d: %w", mig.Version, lockLost(ctx, err))
+		}
+		ran = append(ran, mig)
+	}
+	return
+}
+
+// apply runs a migration in the direction and records it
+func (m *Migrator) apply(ctx context.Context, dir Direction, mig Migration) error {
+	if dir == Down {
+		if mig.Down == nil {
+			return ErrIrreversible
+		}
+		if err := mig.Down(ctx, m.database); err != nil {
+			return err
+		}
+		_, err := m.collection().DeleteOne(ctx, bson.M{"_id": mig.Version})
+		return err
+	}
+
+	if err := mig.Up(ctx, m.database); err != nil {
+		return err
+	}
+	_, err := m.collection().ReplaceOne(ctx, bson.M{"_id": mig.Version},
+		record{Version: mig.Version, Description: mig.Description, AppliedAt: time.Now().UTC()},
+		options.Replace().SetUpsert(true))
+	return err
+}
@@ -0,0 +1,93 @@
+package migrations_test
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/migrations"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+func TestPlan(t *testing.T) {
+	Convey("Given three migrations, the first two applied", t, func() {
+		noop := func(context.Context, *mongo.Database) error { return nil }
+		all := []migrations.Migration{
+			{Version: 1, Up: noop, Down: noop},
+			{Version: 2, Up: noop, Down: noop},
+			{Version: 3, Up: noop, Down: noop},
+		}
+		versions := func(plan []migrations.Migration) []int {
+			out := []int{}
+			for _, m := range plan {
+				out = append(out, m.Version)
+			}
+			return out
+		}
+
+		Convey("When migrating up to the latest version", func() {
+			plan, err := migrations.Plan(all, []int{1, 2}, migrations.Up, 0)
+
+			Convey("Then only the pending migration should run", func() {
+				So(err, ShouldBeNil)
+				So(versions(plan), ShouldResemble, []int{3})
+			})
+		})
+
+		Convey("When migrating up to an applied version", func() {
+			plan, err := migrations.Plan(all, []int{1, 2}, migrations.Up, 2)
+
+			Convey("Then nothing should run", func() {
+				So(err, ShouldBeNil)
+				So(plan, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When migrating down without a version", func() {
+			plan, err := migrations.Plan(all, []int{1, 2}, migrations.Down, -1)
+
+			Convey("Then the latest applied migration only should be rolled back", func() {
+				So(err, ShouldBeNil)
+				So(versions(plan), ShouldResemble, []int{2})
+			})
+		})
+
+		Convey("When migrating down to version 0", func() {
+			plan, err := migrations.Plan(all, []int{1, 2}, migrations.Down, 0)
+
+			Convey("Then every applied migration should be rolled back, latest first", func() {
+				So(err, ShouldBeNil)
+				So(versions(plan), ShouldResemble, []int{2, 1})
+			})
+		})
+
+		Convey("When migrating down past a migration applied by a newer build", func() {
+			_, err := migrations.Plan(all, []int{1, 2, 4}, migrations.Down, 0)
+
+			Convey("Then it should fail", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "migration 4")
+			})
+		})
+	})
+}
+
+func TestNew(t *testing.T) {
+	Convey("Given a logger", t, func() {
+		log := &common.Logger{Logger: zap.NewNop()}
+		noop := func(context.Context, *mongo.Database) error { return nil }
+
+		Convey("Then migrations sharing a version should be rejected", func() {
+			_, err := migrations.New(log, nil, []migrations.Migration{{Version: 1, Up: noop}, {Version: 1, Up: noop}})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Then the migrations of the service should be valid", func() {
+			_, err := migrations.New(log, nil, migrations.All)
+			So(err, ShouldBeNil)
+			So(migrations.LatestVersion(migrations.All), ShouldEqual, migrations.All[len(migrations.All)-1].Version)
+		})
+	})
+}
@@ -0,0 +1,134 @@
+package apikey
+
+// API keys identify the clients of the API. Only a hash of a key is stored, the
+// key itself being shown once, when it is created.
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/kamva/mgm/v3"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//go:generate mockgen -destination=mocks/mock_api_key_service.go -package=mocks . APIKeyService
+type APIKeyService interface {
+	Create(*common.Logger, string) (*APIKey, string, error)
+	List(*common.Logger) ([]*APIKey, error)
+	Revoke(*common.Logger, string) error
+	Authenticate(*common.Logger, string) (*APIKey, error)
+}
+
+var (
+	// ErrNotFound is returned for an unknown or revoked key
+	ErrNotFound = errors.New("no such API key")
+	// ErrDuplicate is returned when creating a key with the name of an active one
+	ErrDuplicate = errors.New("an active API key with the same name already exists")
+)
+
+// keyPrefix starts every key, to recognize them e.g. in leaked files
+const keyPrefix = "gbs_"
+
+type APIKey struct {
+	mgm.DefaultModel `bson:",inline"`
+	Name             string `json:"name" bson:"name" validate:"required"`
+	// Prefix is the start of the key, to tell the keys apart
+	Prefix    string     `json:"prefix" bson:"prefix"`
+	Hash      string     `json:"-" bson:"hash"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+}
+
+type apiKeyService struct{}
+
+// NewAPIKeyService returns the API key service
+func NewAPIKeyService() APIKeyService {
+	return &apiKeyService{}
+}
+
+// active matches the keys which are not revoked
+var active = bson.M{"$exists": false}
+
+// hash returns the stored hash of a key. The keys are random, so a plain hash
+// is enough to protect them.
+func hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create an API key by name, and return it along with the key itself
+func (ks *apiKeyService) Create(log *common.Logger, name string) (out *APIKey, key string, err error) {
+	if name == "" {
+		return nil, "", errors.New("validation error: the name of an API key is required")
+	}
+	n, err := db.APIKeys.CountDocuments(mgm.Ctx(), bson.M{"name": name, "revoked_at": active})
+	if err != nil {
+		log.Error(err.Error())
+		return nil, "", err
+	}
+	if n > 0 {
+		return nil, "", ErrDuplicate
+	}
+
+	b := make([]byte, 24)
+	if _, err = rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	key = keyPrefix + hex.EncodeToString(b)
+
+	out = &APIKey{Name: name, Prefix: key[:len(keyPrefix)+6], Hash: hash(key)}
+	if err = db.APIKeys.CreateWithCtx(mgm.Ctx(), out); err != nil {
+		log.Error(err.Error())
+		return nil, "", err
+	}
+	return out, key, nil
+}
+
+// List the API keys, including the revoked ones, by creation
+func (ks *apiKeyService) List(log *common.Logger) (out []*APIKey, err error) {
+	keys := []APIKey{}
+	err = db.APIKeys.SimpleFindWithCtx(mgm.Ctx(), &keys, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	for i := range keys {
+		out = append(out, &keys[i])
+	}
+	return
+}
+
+// Revoke the active API key by name
+func (ks *apiKeyService) Revoke(log *common.Logger, name string) error {
+	res, err := db.APIKeys.UpdateOne(mgm.Ctx(),
+		bson.M{"name": name, "revoked_at": active},
+		bson.M{"$set": bson.M{"revoked_at": time.Now().UTC()}})
+	if err != nil {
+		log.Error(err.Error())
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Authenticate returns the active API key matching a key
+func (ks *apiKeyService) Authenticate(log *common.Logger, key string) (out *APIKey, err error) {
+	out = &APIKey{}
+	err = db.APIKeys.FirstWithCtx(mgm.Ctx(), bson.M{"hash": hash(key), "revoked_at": active}, out)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		log.Error(err.Error())
+		return nil, err
+	}
+	return
+}
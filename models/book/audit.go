@@ -0,0 +1,245 @@
+package book
+
+// Audit log of the changes made to the books, recording who changed which field
+// of a book and when.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/kamva/mgm/v3"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Operations recorded in the audit log besides the ones of the batches
+const (
+	OpRestore OpType = "restore"
+	OpPurge   OpType = "purge"
+)
+
+// ActorSystem is the actor of the changes made outside of a request, e.g. by the
+// purge of the trash or the imports run from the command line
+const ActorSystem = "system"
+
+// Change is the change of a field of a book; a field which is not set has a nil
+// value
+type Change struct {
+	Field  string `json:"field" bson:"field"`
+	Before any    `json:"before" bson:"before"`
+	After  any    `json:"after" bson:"after"`
+}
+
+// AuditRecord is an entry of the audit log, describing a change made to a book.
+// Records are only ever inserted.
+type AuditRecord struct {
+	mgm.IDField `bson:",inline"`
+	BookID      primitive.ObjectID `json:"book_id" bson:"book_id"`
+	Operation   OpType             `json:"operation" bson:"operation"`
+	Actor       string             `json:"actor" bson:"actor"`
+	RequestID   string             `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	Time        time.Time          `json:"time" bson:"time"`
+	Changes     []Change           `json:"changes" bson:"changes"`
+}
+
+// AuditFilter restricts the records returned by Audit; zero fields match any
+// record
+type AuditFilter struct {
+	BookID    string
+	Actor     string
+	RequestID string
+	Operation OpType
+	Field     string // the records changing the field
+	From      time.Time
+	To        time.Time // exclusive
+	Skip      int64
+	Limit     int64 // 0 means no limit
+}
+
+// query returns the Mongo filter matching the records selected by f
+func (f AuditFilter) query() (bson.M, error) {
+	q := bson.M{}
+	if f.BookID != "" {
+		id, err := primitive.ObjectIDFromHex(f.BookID)
+		if err != nil {
+			return nil, err
+		}
+		q["book_id"] = id
+	}
+	if f.Actor != "" {
+		q["actor"] = f.Actor
+	}
+	if f.RequestID != "" {
+		q["request_id"] = f.RequestID
+	}
+	if f.Operation != "" {
+		q["operation"] = f.Operation
+	}
+	if f.Field != "" {
+		q["changes.field"] = f.Field
+	}
+	period := bson.M{}
+	if !f.From.IsZero() {
+		period["$gte"] = f.From
+	}
+	if !f.To.IsZero() {
+		period["$lt"] = f.To
+	}
+	if len(period) > 0 {
+		q["time"] = period
+	}
+	return q, nil
+}
+
+// auditFields returns the fields of a book as they are encoded in JSON, the id,
+// the timestamps and the revision aside
+func auditFields(b *Book) map[string]any {
+	if b == nil {
+		return nil
+	}
+	raw, err := json.Marshal(b)
+	if err != nil {
+		return nil
+	}
+	var out map[string]any
+	if err = json.Unmarshal(raw, &out); err != nil {
+		return nil
+	}
+	delete(out, "id")
+	delete(out, "created_at")
+	delete(out, "updated_at")
+	delete(out, "revision")
+	return out
+}
+
+// Diff returns the changes of the fields of a book from before to after, either
+// of which may be nil, ordered by field
+func Diff(before, after *Book) []Change {
+	b, a := auditFields(before), auditFields(after)
+
+	fields := make([]string, 0, len(a))
+	for f := range a {
+		fields = append(fields, f)
+	}
+	for f := range b {
+		if _, ok := a[f]; !ok {
+			fields = append(fields, f)
+		}
+	}
+	sort.Strings(fields)
+
+	var changes []Change
+	for _, f := range fields {
+		if !reflect.DeepEqual(b[f], a[f]) {
+			changes = append(changes, Change{Field: f, Before: b[f], After: a[f]})
+		}
+	}
+	return changes
+}
+
+// newAuditRecord returns the record of a change made to a book by the request
+// of ctx, see common.IdentityFrom
+func newAuditRecord(ctx context.Context, op OpType, before, after *Book) *AuditRecord {
+	id := common.IdentityFrom(ctx)
+	r := &AuditRecord{
+		Operation: op,
+		Actor:     id.Actor,
+		RequestID: id.RequestID,
+		Time:      time.Now().UTC(),
+		Changes:   Diff(before, after),
+	}
+	r.ID = primitive.NewObjectID()
+	if r.Actor == "" {
+		r.Actor = ActorSystem
+	}
+	if after != nil {
+		r.BookID = after.ID
+	} else if before != nil {
+		r.BookID = before.ID
+	}
+	return r
+}
+
+// record stores the revisions and the audit records of a change. The change is
+// made already, so a failure cannot undo it, and is only logged: failing the
+// request would have the client retry a change which was made.
+func (bs *bookService) record(log *common.Logger, revisions []*Revision, records ...*AuditRecord) {
+	if err := errors.Join(bs.saveRevisions(revisions...), bs.audit(records...)); err != nil {
+		log.Error("the change was made but could not be recorded: " + err.Error())
+	}
+}
+
+// audit writes the records which change a field to the audit log
+func (bs *bookService) audit(records ...*AuditRecord) error {
+	docs := make([]any, 0, len(records))
+	for _, r := range records {
+		if len(r.Changes) > 0 {
+			docs = append(docs, r)
+		}
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+
+	if _, err := db.BookAudit.InsertMany(mgm.Ctx(), docs); err != nil {
+		return fmt.Errorf("error writing the audit log: %w", err)
+	}
+	return nil
+}
+
+// History returns the audit records of a book by id, oldest first, including
+// those of a purged book
+func (bs *bookService) History(log *common.Logger, id string) (out []*AuditRecord, err error) {
+	out, _, err = bs.Audit(log, AuditFilter{BookID: id})
+	if err != nil {
+		return
+	}
+	// Audit returns the latest records first
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return
+}
+
+// Audit returns a page of the audit records matching the filter, latest first,
+// along with their total number
+func (bs *bookService) Audit(log *common.Logger, filter AuditFilter) (out []*AuditRecord, total int64, err error) {
+
+	query, err := filter.query()
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	total, err = db.BookAudit.CountDocuments(mgm.Ctx(), query)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	findOpts := options.Find().SetSort(bson.D{{Key: "time", Value: -1}, {Key: "_id", Value: -1}}).SetSkip(filter.Skip)
+	if filter.Limit > 0 {
+		findOpts.SetLimit(filter.Limit)
+	}
+
+	results := []AuditRecord{}
+
+	err = db.BookAudit.SimpleFindWithCtx(mgm.Ctx(), &results, query, findOpts)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	for i := range results {
+		out = append(out, &results[i])
+	}
+	return
+}
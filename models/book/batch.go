@@ -0,0 +1,414 @@
+package book
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/kamva/mgm/v3"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/db"
+	"github.com/snehil-sinha/goBookStore/events"
+	"github.com/snehil-sinha/goBookStore/service/validators"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OpType is the kind of a batch operation
+type OpType string
+
+const (
+	OpCreate OpType = "create"
+	OpUpdate OpType = "update"
+	OpDelete OpType = "delete"
+)
+
+// Operation is an item of a batch. Create takes the Book, Update the ID and the
+// fields of the Book to change, Delete the ID.
+type Operation struct {
+	Op   OpType `json:"op"`
+	ID   string `json:"id,omitempty"`
+	Book *Book  `json:"book,omitempty"`
+}
+
+// Result is the outcome of an operation of a batch. Err is nil if it was applied.
+type Result struct {
+	Op   OpType
+	ID   string
+	Book *Book // the book created or updated
+	Err  error
+
+	stored *Book // the book before an update or a delete, for the audit log and the revisions
+}
+
+var (
+	// ErrDuplicate is returned for a book whose title and page count are already
+	// taken, by a stored book or by another operation of the batch
+	ErrDuplicate = fmt.Errorf("%w: a book with the same title and pages already exists", ErrValidation)
+	// ErrNotApplied is returned for the valid operations of an atomic batch which
+	// was rolled back because of other operations
+	ErrNotApplied = errors.New("not applied: another operation of the batch failed")
+)
+
+// Batch applies the operations. In atomic mode, either all of them are applied
+// in a transaction or none is. Otherwise the valid operations are applied with a
+// single unordered bulk write and the others reported as failed. The returned
+// error is only set if the batch could not be run at all, or if the outcome of
+// its operations could not be told once written.
+//
+// Duplicates are checked with one query for the whole batch rather than per book,
+// and include the duplicates between the operations of the batch. Like Update,
+// an update only applies to a book still at the revision read, and fails with
+// ErrConflict otherwise.
+func (bs *bookService) Batch(ctx context.Context, log *common.Logger, ops []Operation, atomic bool) (results []Result, err error) {
+
+	results = make([]Result, len(ops))
+	if err = bs.prepareBatch(log, ops, results); err != nil {
+		return nil, err
+	}
+
+	// the write models of the valid operations, and the index of their operation
+	var (
+		models  []mongo.WriteModel
+		indexes []int
+	)
+	for i, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		switch r.Op {
+		case OpCreate:
+			models = append(models, mongo.NewInsertOneModel().SetDocument(r.Book))
+		case OpUpdate:
+			// the whole book is written, every field Apply may have changed, if it
+			// is still at the revision read, like with saveRevised
+			models = append(models, mongo.NewReplaceOneModel().
+				SetFilter(revisedFilter(r.stored)).
+				SetReplacement(r.Book))
+		case OpDelete:
+			// deleted books are moved to the trash, see Delete
+			models = append(models, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": r.Book.ID, "deleted_at": notDeleted}).
+				SetUpdate(bson.M{"$set": bson.M{"deleted_at": r.Book.DeletedAt}}))
+		}
+		indexes = append(indexes, i)
+	}
+
+	if atomic {
+		if len(indexes) < len(results) {
+			for _, i := range indexes {
+				results[i].Err = ErrNotApplied
+			}
+			return
+		}
+		if err = bs.writeAtomic(models, len(models)-countOps(results, indexes, OpCreate)); err != nil {
+			log.Error(err.Error())
+			if errors.Is(err, errUnmatched) {
+				if err = failChanged(results, indexes, false); err != nil {
+					return nil, err
+				}
+				for _, i := range indexes {
+					if results[i].Err == nil {
+						results[i].Err = ErrNotApplied
+					}
+				}
+				return results, nil
+			}
+			for i := range results {
+				results[i].Err = err
+			}
+			return results, nil
+		}
+	} else if len(models) > 0 {
+		var res *mongo.BulkWriteResult
+		res, err = db.GoBookStore.BulkWrite(mgm.Ctx(), models, options.BulkWrite().SetOrdered(false))
+		var bulkErr mongo.BulkWriteException
+		switch {
+		case errors.As(err, &bulkErr):
+			for _, we := range bulkErr.WriteErrors {
+				log.Error(we.Message)
+				results[indexes[we.Index]].Err = errors.New(we.Message)
+			}
+		case err != nil:
+			log.Error(err.Error())
+			for _, i := range indexes {
+				results[i].Err = err
+			}
+		}
+		err = nil
+		// the updates and deletes of books changed by another request since they
+		// were read match nothing, and are told apart by reading the books again
+		if res != nil && res.MatchedCount < int64(countOps(results, indexes, OpUpdate, OpDelete)) {
+			if err = failChanged(results, indexes, true); err != nil {
+				log.Error(err.Error())
+				return results, err
+			}
+		}
+	}
+
+	var (
+		records   []*AuditRecord
+		revisions []*Revision
+	)
+	for _, i := range indexes {
+		r := results[i]
+		if r.Err != nil {
+			continue
+		}
+		records = append(records, newAuditRecord(ctx, r.Op, r.stored, r.Book))
+		switch r.Op {
+		case OpCreate:
+			revisions = append(revisions, newRevisions(ctx, nil, r.Book)...)
+			bs.events.Publish(events.Created, r.ID, r.Book)
+		case OpUpdate:
+			revisions = append(revisions, newRevisions(ctx, r.stored, r.Book)...)
+			bs.events.Publish(events.Updated, r.ID, r.Book)
+		case OpDelete:
+			bs.events.Publish(events.Deleted, r.ID, r.Book)
+			// a deleted book is not part of the result
+			results[i].Book = nil
+		}
+	}
+	bs.record(log, revisions, records...)
+	return
+}
+
+// prepareBatch checks every operation and fills in the results with the books
+// to write, or the error of the operation
+func (bs *bookService) prepareBatch(log *common.Logger, ops []Operation, results []Result) error {
+
+	// the stored books targeted by updates and deletes, loaded at once
+	var ids []string
+	for _, op := range ops {
+		if op.Op == OpUpdate || op.Op == OpDelete {
+			ids = append(ids, op.ID)
+		}
+	}
+	stored, err := bs.ReadByIds(log, ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]*Book, len(stored))
+	for _, b := range stored {
+		byID[b.ID.Hex()] = b
+	}
+
+	seenIDs := map[string]int{}
+	for i, op := range ops {
+		r := &results[i]
+		r.Op = op.Op
+		r.ID = op.ID
+
+		switch op.Op {
+		case OpCreate:
+			if op.Book == nil {
+				r.Err = fmt.Errorf("%w: book is required", ErrValidation)
+				continue
+			}
+			b := NewBook(op.Book.Title, op.Book.Pages)
+			b.Apply(op.Book)
+			b.ID = primitive.NewObjectID()
+			revise(nil, b)
+			_ = b.DefaultModel.Creating()
+			_ = b.DefaultModel.Saving()
+			r.ID, r.Book = b.ID.Hex(), b
+
+		case OpUpdate, OpDelete:
+			if _, err := primitive.ObjectIDFromHex(op.ID); err != nil {
+				r.Err = primitive.ErrInvalidHex
+				continue
+			}
+			if j, ok := seenIDs[op.ID]; ok {
+				r.Err = fmt.Errorf("%w: book %s is already changed by operation %d", ErrValidation, op.ID, j)
+				continue
+			}
+			seenIDs[op.ID] = i
+
+			current, ok := byID[op.ID]
+			if !ok {
+				r.Err = ErrNotFound
+				continue
+			}
+			b := *current
+			if op.Op == OpDelete {
+				now := time.Now().UTC()
+				b.DeletedAt = &now
+			}
+			if op.Op == OpUpdate {
+				if op.Book == nil {
+					r.Err = fmt.Errorf("%w: book is required", ErrValidation)
+					continue
+				}
+				b.Apply(op.Book)
+				revise(current, &b)
+				_ = b.DefaultModel.Saving()
+			}
+			r.Book, r.stored = &b, current
+
+		default:
+			r.Err = fmt.Errorf("%w: op must be one of create, update or delete, got %q", ErrValidation, op.Op)
+			continue
+		}
+
+		if op.Op != OpDelete {
+			r.Err = r.Book.ValidateFields()
+		}
+	}
+
+	return checkDuplicates(results)
+}
+
+// ValidateFields validates the book like Validate, without checking for
+// duplicates in the collection
+func (b *Book) ValidateFields() error {
+	v := validators.New()
+	if err := v.RegisterValidation("bookAlreadyPresent", func(validator.FieldLevel) bool { return true }); err != nil {
+		return fmt.Errorf("failed to register custom validator: %s", err)
+	}
+	if err := v.Struct(b); err != nil {
+		return fmt.Errorf("%w: book validation failed, err: %s", ErrValidation, err)
+	}
+	return nil
+}
+
+// checkDuplicates fails the creates and updates whose title and page count are
+// taken by a stored book or by an earlier operation of the batch
+func checkDuplicates(results []Result) error {
+
+	var (
+		or      bson.A
+		changed = map[primitive.ObjectID]bool{}
+	)
+	for _, r := range results {
+		if r.Err != nil || r.Op == OpDelete {
+			continue
+		}
+		or = append(or, bson.M{"title": r.Book.Title, "pages": r.Book.Pages})
+		changed[r.Book.ID] = true
+	}
+	if len(or) == 0 {
+		return nil
+	}
+
+	existing := []Book{}
+	if err := db.GoBookStore.SimpleFindWithCtx(mgm.Ctx(), &existing, bson.M{"$or": or, "deleted_at": notDeleted}); err != nil {
+		return err
+	}
+	taken := map[string]bool{}
+	for _, b := range existing {
+		// an updated book does not conflict with itself
+		if !changed[b.ID] {
+			taken[titleKey(&b)] = true
+		}
+	}
+
+	for i := range results {
+		r := &results[i]
+		if r.Err != nil || r.Op == OpDelete {
+			continue
+		}
+		k := titleKey(r.Book)
+		if taken[k] {
+			r.Err = ErrDuplicate
+			continue
+		}
+		taken[k] = true
+	}
+	return nil
+}
+
+// titleKey is the key of a book by title and page count, for the duplicate checks
+func titleKey(b *Book) string {
+	return b.Title + "\x00" + strconv.Itoa(b.Pages)
+}
+
+// errUnmatched is returned by writeAtomic when an update or a delete matched no
+// book, the book having been changed by another request
+var errUnmatched = errors.New("an operation of the batch matched no book")
+
+// writeAtomic runs the bulk write in a transaction, rolled back unless the
+// updates and deletes matched their books
+func (bs *bookService) writeAtomic(models []mongo.WriteModel, matches int) error {
+	return mgm.TransactionWithCtx(mgm.Ctx(), func(session mongo.Session, sc mongo.SessionContext) error {
+		res, err := db.GoBookStore.BulkWrite(sc, models, options.BulkWrite().SetOrdered(true))
+		if err == nil && res.MatchedCount < int64(matches) {
+			err = errUnmatched
+		}
+		if err != nil {
+			_ = session.AbortTransaction(sc)
+			return err
+		}
+		return session.CommitTransaction(sc)
+	})
+}
+
+// countOps returns the number of operations of the given types among the ones
+// of indexes which did not fail
+func countOps(results []Result, indexes []int, types ...OpType) (n int) {
+	for _, i := range indexes {
+		for _, t := range types {
+			if results[i].Op == t && results[i].Err == nil {
+				n++
+			}
+		}
+	}
+	return
+}
+
+// failChanged fails the updates and deletes of indexes whose book was changed by
+// another request, reading the books again: an update with ErrConflict, a delete
+// with ErrNotFound, like Update and Delete. The stored books are compared with
+// the ones written if the batch was written, and with the ones read otherwise.
+func failChanged(results []Result, indexes []int, written bool) error {
+	var ids []primitive.ObjectID
+	for _, i := range indexes {
+		if r := results[i]; r.Err == nil && r.Op != OpCreate {
+			ids = append(ids, r.Book.ID)
+		}
+	}
+	stored := []Book{}
+	if err := db.GoBookStore.SimpleFindWithCtx(mgm.Ctx(), &stored, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+		return err
+	}
+	byID := make(map[primitive.ObjectID]*Book, len(stored))
+	for i := range stored {
+		byID[stored[i].ID] = &stored[i]
+	}
+
+	for _, i := range indexes {
+		r := &results[i]
+		if r.Err != nil || r.Op == OpCreate {
+			continue
+		}
+		expected := r.stored
+		if written {
+			expected = r.Book
+		}
+		if b, ok := byID[r.Book.ID]; ok && sameState(b, expected) {
+			continue
+		}
+		r.Err = ErrConflict
+		if r.Op == OpDelete {
+			r.Err = ErrNotFound
+		}
+	}
+	return nil
+}
+
+// sameState reports whether a stored book is at the revision, update time and
+// deletion time of b, the times being stored to the millisecond
+func sameState(stored, b *Book) bool {
+	sameTime := func(t1, t2 *time.Time) bool {
+		if t1 == nil || t2 == nil {
+			return t1 == t2
+		}
+		return t1.Equal(t2.Truncate(time.Millisecond))
+	}
+	return stored.Revision == b.Revision && sameTime(&stored.UpdatedAt, &b.UpdatedAt) &&
+		sameTime(stored.DeletedAt, b.DeletedAt)
+}
@@ -5,13 +5,18 @@ import (
 	"encoding/json"
 	"net/http"
 	"os"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/catalog"
+	"github.com/snehil-sinha/goBookStore/catalog/marc"
 	"github.com/snehil-sinha/goBookStore/db"
 	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/seed"
 	"github.com/snehil-sinha/goBookStore/test"
 )
 
@@ -260,6 +265,27 @@ func TestCreateBook(t *testing.T) {
 			})
 		})
 
+		Convey("When called with a POST request to /api/v2/books with already existing book data", func() {
+			book := map[string]interface{}{
+				"title": "The Chronicles of Narnia",
+				"pages": 222,
+			}
+
+			_, err := resty.New().R().
+				SetBody(book).
+				Post(url)
+			So(err, ShouldBeNil)
+
+			resp, err := resty.New().R().
+				SetBody(book).
+				Post(baseUrl + "/api/v2/books")
+			So(err, ShouldBeNil)
+
+			Convey("Then the response code should have a 409 status code", func() {
+				So(resp.StatusCode(), ShouldEqual, http.StatusConflict)
+			})
+		})
+
 		Convey("When called with a POST request to /books with incomplete book data", func() {
 			book := map[string]interface{}{
 				"title": "test title",
@@ -281,7 +307,7 @@ func TestCreateBook(t *testing.T) {
 				err := json.Unmarshal(resp.Body(), &response)
 				So(err, ShouldBeNil)
 
-				So(response.Error, ShouldEqual, "validation error: book validation failed, err: Key: 'Book.Pages' Error:Field validation for 'Pages' failed on the 'required' tag")
+				So(response.Error, ShouldEqual, "invalid request: body pages: is required")
 			})
 		})
 
@@ -307,7 +333,7 @@ func TestCreateBook(t *testing.T) {
 				err := json.Unmarshal(resp.Body(), &response)
 				So(err, ShouldBeNil)
 
-				So(response.Error, ShouldEqual, "validation error: book validation failed, err: Key: 'Book.Pages' Error:Field validation for 'Pages' failed on the 'gte' tag")
+				So(response.Error, ShouldEqual, "invalid request: body pages: must be greater than or equal to 1")
 			})
 		})
 	})
@@ -435,7 +461,7 @@ func TestDeleteBook(t *testing.T) {
 
 				Convey("Then the response body should contain the appropriate error message", func() {
 
-					So(string(response.Error), ShouldEqual, "the provided hex string is not a valid ObjectID")
+					So(string(response.Error), ShouldEqual, "invalid request: path id: must be a hex encoded ObjectID")
 				})
 			})
 
@@ -465,3 +491,456 @@ func TestDeleteBook(t *testing.T) {
 		})
 	})
 }
+
+func TestTrash(t *testing.T) {
+	Convey("Given a deleted book", t, func() {
+		url := baseUrl + "/api/v1/books"
+
+		Reset(func() {
+			test.ClearDB(context.TODO())
+		})
+
+		var created struct {
+			ID string `json:"id"`
+		}
+		_, err := resty.New().R().
+			SetBody(map[string]interface{}{"title": "Dune", "pages": 412}).
+			SetResult(&created).
+			Post(url)
+		So(err, ShouldBeNil)
+		_, err = resty.New().R().Delete(url + "/" + created.ID)
+		So(err, ShouldBeNil)
+
+		Convey("When it is fetched", func() {
+			resp, err := resty.New().R().Get(url + "/" + created.ID)
+			So(err, ShouldBeNil)
+
+			Convey("Then the response should have a 404 status code", func() {
+				So(resp.StatusCode(), ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("When the trash is listed", func() {
+			resp, err := resty.New().R().Get(url + "/trash")
+			So(err, ShouldBeNil)
+
+			Convey("Then the book should be listed with its tombstone", func() {
+				So(resp.StatusCode(), ShouldEqual, http.StatusOK)
+				So(string(resp.Body()), ShouldContainSubstring, created.ID)
+				So(string(resp.Body()), ShouldContainSubstring, "deleted_at")
+			})
+		})
+
+		Convey("When it is restored", func() {
+			resp, err := resty.New().R().Post(url + "/" + created.ID + "/restore")
+			So(err, ShouldBeNil)
+
+			Convey("Then it should be found again", func() {
+				So(resp.StatusCode(), ShouldEqual, http.StatusOK)
+
+				resp, err := resty.New().R().Get(url + "/" + created.ID)
+				So(err, ShouldBeNil)
+				So(resp.StatusCode(), ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("When a MARC file of the book is imported", func() {
+			raw, err := marc.FromBook(book.NewBook("Dune", 412)).Encode()
+			So(err, ShouldBeNil)
+			var report struct {
+				Data catalog.Report `json:"data"`
+			}
+			resp, err := resty.New().R().
+				SetHeader("Content-Type", "application/marc").
+				SetBody(raw).
+				SetResult(&report).
+				Post(url + "/import/marc")
+			So(err, ShouldBeNil)
+
+			Convey("Then its record should be skipped as a duplicate of the deleted book", func() {
+				So(resp.StatusCode(), ShouldEqual, http.StatusOK)
+				So(report.Data.Duplicates, ShouldEqual, 1)
+				So(report.Data.Records[0].ID, ShouldEqual, created.ID)
+			})
+		})
+
+		Convey("When the same book is created again and the deleted one restored", func() {
+			_, err := resty.New().R().
+				SetBody(map[string]interface{}{"title": "Dune", "pages": 412}).
+				Post(url)
+			So(err, ShouldBeNil)
+			resp, err := resty.New().R().Post(url + "/" + created.ID + "/restore")
+			So(err, ShouldBeNil)
+
+			Convey("Then the response should have a 409 status code", func() {
+				So(resp.StatusCode(), ShouldEqual, http.StatusConflict)
+			})
+		})
+	})
+}
+
+func TestBookHistory(t *testing.T) {
+	Convey("Given a book created and then updated", t, func() {
+		url := baseUrl + "/api/v1/books"
+
+		Reset(func() {
+			test.ClearDB(context.TODO())
+		})
+
+		var created struct {
+			ID string `json:"id"`
+		}
+		_, err := resty.New().R().
+			SetHeader("X-Request-ID", "history-create").
+			SetBody(map[string]interface{}{"title": "Dune", "pages": 412}).
+			SetResult(&created).
+			Post(url)
+		So(err, ShouldBeNil)
+		_, err = resty.New().R().
+			SetHeader("X-Request-ID", "history-update").
+			SetBody(map[string]interface{}{"pages": 500}).
+			Put(url + "/" + created.ID)
+		So(err, ShouldBeNil)
+
+		Convey("When its history is fetched", func() {
+			var response struct {
+				Data []book.AuditRecord `json:"data"`
+			}
+			resp, err := resty.New().R().SetResult(&response).Get(url + "/" + created.ID + "/history")
+			So(err, ShouldBeNil)
+
+			Convey("Then both changes should be listed, oldest first", func() {
+				So(resp.StatusCode(), ShouldEqual, http.StatusOK)
+				So(response.Data, ShouldHaveLength, 2)
+				So(response.Data[0].Operation, ShouldEqual, book.OpCreate)
+				So(response.Data[0].RequestID, ShouldEqual, "history-create")
+				So(response.Data[1].Operation, ShouldEqual, book.OpUpdate)
+				So(response.Data[1].Actor, ShouldEqual, "anonymous")
+				So(response.Data[1].Changes, ShouldResemble, []book.Change{{Field: "pages", Before: 412.0, After: 500.0}})
+			})
+		})
+	})
+}
+
+func TestBookRevisions(t *testing.T) {
+	Convey("Given a book created and then updated", t, func() {
+		url := baseUrl + "/api/v1/books"
+
+		Reset(func() {
+			test.ClearDB(context.TODO())
+		})
+
+		var created book.Book
+		_, err := resty.New().R().
+			SetBody(map[string]interface{}{"title": "Dune", "pages": 412}).
+			SetResult(&created).
+			Post(url)
+		So(err, ShouldBeNil)
+		time.Sleep(10 * time.Millisecond)
+		_, err = resty.New().R().
+			SetBody(map[string]interface{}{"pages": 500}).
+			Put(url + "/" + created.ID.Hex())
+		So(err, ShouldBeNil)
+
+		Convey("When its first revision is fetched", func() {
+			var response struct {
+				Data book.Revision `json:"data"`
+			}
+			resp, err := resty.New().R().SetResult(&response).Get(url + "/" + created.ID.Hex() + "/revisions/1")
+			So(err, ShouldBeNil)
+
+			Convey("Then it should hold the book as it was created", func() {
+				So(resp.StatusCode(), ShouldEqual, http.StatusOK)
+				So(response.Data.Revision, ShouldEqual, 1)
+				So(response.Data.Book.Pages, ShouldEqual, 412)
+			})
+		})
+
+		Convey("When it is read as of its creation", func() {
+			var response struct {
+				Data book.Book `json:"data"`
+			}
+			resp, err := resty.New().R().
+				SetQueryParam("as_of", created.UpdatedAt.Format(time.RFC3339Nano)).
+				SetResult(&response).
+				Get(url + "/" + created.ID.Hex())
+			So(err, ShouldBeNil)
+
+			Convey("Then the book should be returned as it was then", func() {
+				So(resp.StatusCode(), ShouldEqual, http.StatusOK)
+				So(response.Data.Pages, ShouldEqual, 412)
+			})
+		})
+
+		Convey("When it is reverted to its first revision", func() {
+			var reverted book.Book
+			resp, err := resty.New().R().
+				SetBody(map[string]interface{}{"revision": 1}).
+				SetResult(&reverted).
+				Post(url + "/" + created.ID.Hex() + "/revert")
+			So(err, ShouldBeNil)
+
+			Convey("Then a third revision should be made with the fields of the first", func() {
+				So(resp.StatusCode(), ShouldEqual, http.StatusOK)
+				So(reverted.Pages, ShouldEqual, 412)
+				So(reverted.Revision, ShouldEqual, 3)
+			})
+		})
+
+		Convey("When it is given an ISBN and reverted to its first revision", func() {
+			_, err := resty.New().R().
+				SetBody(map[string]interface{}{"isbn": "9780441013593"}).
+				Put(url + "/" + created.ID.Hex())
+			So(err, ShouldBeNil)
+			resp, err := resty.New().R().
+				SetBody(map[string]interface{}{"revision": 1}).
+				Post(url + "/" + created.ID.Hex() + "/revert")
+			So(err, ShouldBeNil)
+
+			Convey("Then the stored book should have no ISBN", func() {
+				So(resp.StatusCode(), ShouldEqual, http.StatusOK)
+				var response struct {
+					Data book.Book `json:"data"`
+				}
+				_, err := resty.New().R().SetResult(&response).Get(url + "/" + created.ID.Hex())
+				So(err, ShouldBeNil)
+				So(response.Data.ISBN, ShouldBeEmpty)
+				So(response.Data.Pages, ShouldEqual, 412)
+				So(response.Data.Revision, ShouldEqual, 4)
+			})
+		})
+
+		Convey("When it is updated by concurrent requests", func() {
+			codes := make([]int, 8)
+			var wg sync.WaitGroup
+			for i := range codes {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					resp, err := resty.New().R().
+						SetBody(map[string]interface{}{"pages": 600 + i}).
+						Put(url + "/" + created.ID.Hex())
+					if err == nil {
+						codes[i] = resp.StatusCode()
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			var stored struct {
+				Data book.Book `json:"data"`
+			}
+			_, err := resty.New().R().SetResult(&stored).Get(url + "/" + created.ID.Hex())
+			So(err, ShouldBeNil)
+			current := stored.Data
+
+			Convey("Then the updates which were not applied should fail with a 409, and each applied one make a revision", func() {
+				applied := 0
+				for _, code := range codes {
+					So(code, ShouldBeIn, http.StatusOK, http.StatusConflict)
+					if code == http.StatusOK {
+						applied++
+					}
+				}
+				So(applied, ShouldBeGreaterThan, 0)
+				So(current.Revision, ShouldEqual, 2+applied)
+
+				var response struct {
+					Data book.Revision `json:"data"`
+				}
+				resp, err := resty.New().R().SetResult(&response).Get(url + "/" + created.ID.Hex() + "/revisions/" + strconv.Itoa(current.Revision))
+				So(err, ShouldBeNil)
+				So(resp.StatusCode(), ShouldEqual, http.StatusOK)
+				So(response.Data.Book.Pages, ShouldEqual, current.Pages)
+			})
+		})
+	})
+}
+
+func TestBatchBooks(t *testing.T) {
+	Convey("Given the /books:batch endpoint", t, func() {
+		url := baseUrl + "/api/v1/books:batch"
+
+		Reset(func() {
+			test.ClearDB(context.TODO())
+		})
+
+		type result struct {
+			Status int    `json:"status"`
+			ID     string `json:"id"`
+			Error  string `json:"error"`
+		}
+		batch := func(mode string, ops ...map[string]interface{}) (int, []result) {
+			var response struct {
+				Data []result `json:"data"`
+			}
+			resp, err := resty.New().R().
+				SetBody(map[string]interface{}{"mode": mode, "operations": ops}).
+				SetResult(&response).
+				Post(url)
+			So(err, ShouldBeNil)
+			return resp.StatusCode(), response.Data
+		}
+		create := func(title string, pages int) map[string]interface{} {
+			return map[string]interface{}{"op": "create", "book": map[string]interface{}{"title": title, "pages": pages}}
+		}
+
+		Convey("When a best effort batch creates the same book twice", func() {
+			status, results := batch("best_effort", create("Dune", 412), create("Emma", 474), create("Dune", 412))
+
+			Convey("Then the duplicate within the batch should be rejected and the others created", func() {
+				So(status, ShouldEqual, http.StatusMultiStatus)
+				So(results[0].Status, ShouldEqual, http.StatusCreated)
+				So(results[1].Status, ShouldEqual, http.StatusCreated)
+				So(results[2].Status, ShouldEqual, http.StatusConflict)
+			})
+		})
+
+		Convey("When an atomic batch has an invalid operation", func() {
+			status, results := batch("atomic", create("Dune", 412), map[string]interface{}{"op": "delete", "id": "61733b8e9c483c721f65b21d"})
+
+			Convey("Then no operation should be applied", func() {
+				So(status, ShouldEqual, http.StatusMultiStatus)
+				So(results[0].Status, ShouldEqual, http.StatusFailedDependency)
+				So(results[1].Status, ShouldEqual, http.StatusNotFound)
+
+				resp, err := resty.New().R().Get(baseUrl + "/api/v1/books")
+				So(err, ShouldBeNil)
+				So(string(resp.Body()), ShouldNotContainSubstring, "Dune")
+			})
+		})
+
+		Convey("When an atomic batch is valid", func() {
+			_, created := batch("best_effort", create("Dune", 412))
+			status, results := batch("atomic",
+				map[string]interface{}{"op": "update", "id": created[0].ID, "book": map[string]interface{}{"pages": 500}},
+				create("Emma", 474),
+			)
+
+			Convey("Then every operation should be applied", func() {
+				So(status, ShouldEqual, http.StatusOK)
+				So(results[0].Status, ShouldEqual, http.StatusOK)
+				So(results[1].Status, ShouldEqual, http.StatusCreated)
+			})
+		})
+
+		Convey("When a book is updated by concurrent batches", func() {
+			_, created := batch("best_effort", create("Dune", 412))
+			statuses := make([]int, 8)
+			var wg sync.WaitGroup
+			for i := range statuses {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					var response struct {
+						Data []result `json:"data"`
+					}
+					_, err := resty.New().R().
+						SetBody(map[string]interface{}{"operations": []map[string]interface{}{
+							{"op": "update", "id": created[0].ID, "book": map[string]interface{}{"pages": 600 + i}},
+						}}).
+						SetResult(&response).
+						Post(url)
+					if err == nil && len(response.Data) == 1 {
+						statuses[i] = response.Data[0].Status
+					}
+				}(i)
+			}
+			wg.Wait()
+
+			Convey("Then the updates made on a changed book should fail with a 409", func() {
+				applied := 0
+				for _, status := range statuses {
+					So(status, ShouldBeIn, http.StatusOK, http.StatusConflict)
+					if status == http.StatusOK {
+						applied++
+					}
+				}
+				So(applied, ShouldBeGreaterThan, 0)
+
+				var stored struct {
+					Data book.Book `json:"data"`
+				}
+				_, err := resty.New().R().SetResult(&stored).Get(baseUrl + "/api/v1/books/" + created[0].ID)
+				So(err, ShouldBeNil)
+				So(stored.Data.Revision, ShouldEqual, 1+applied)
+			})
+		})
+
+		Convey("When a batch updates a book in the trash", func() {
+			_, created := batch("best_effort", create("Dune", 412))
+			_, err := resty.New().R().Delete(baseUrl + "/api/v1/books/" + created[0].ID)
+			So(err, ShouldBeNil)
+			_, results := batch("best_effort", map[string]interface{}{"op": "update", "id": created[0].ID, "book": map[string]interface{}{"pages": 500}})
+
+			Convey("Then the book should stay in the trash", func() {
+				So(results[0].Status, ShouldEqual, http.StatusNotFound)
+
+				resp, err := resty.New().R().Get(baseUrl + "/api/v1/books/" + created[0].ID)
+				So(err, ShouldBeNil)
+				So(resp.StatusCode(), ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("When a batch updates the metadata of a book", func() {
+			_, created := batch("best_effort", create("Dune", 412))
+			_, results := batch("best_effort", map[string]interface{}{"op": "update", "id": created[0].ID, "book": map[string]interface{}{
+				"isbn":         "9780441013593",
+				"contributors": []map[string]interface{}{{"name": "Frank Herbert", "role": "author"}},
+				"prices":       []map[string]interface{}{{"amount": 9.99, "currency": "EUR"}},
+				"availability": "out_of_stock",
+				"reference":    "dune-1965",
+			}})
+
+			Convey("Then the metadata should be stored", func() {
+				So(results[0].Status, ShouldEqual, http.StatusOK)
+
+				var stored book.Book
+				resp, err := resty.New().R().SetResult(&stored).Get(baseUrl + "/api/v1/books/" + created[0].ID)
+				So(err, ShouldBeNil)
+				So(resp.StatusCode(), ShouldEqual, http.StatusOK)
+				So(stored.Title, ShouldEqual, "Dune")
+				So(stored.ISBN, ShouldEqual, "9780441013593")
+				So(stored.Contributors, ShouldResemble, []book.Contributor{{Name: "Frank Herbert", Role: book.RoleAuthor}})
+				So(stored.Prices, ShouldResemble, []book.Price{{Amount: 9.99, Currency: "EUR"}})
+				So(stored.Availability, ShouldEqual, book.OutOfStock)
+				So(stored.Reference, ShouldEqual, "dune-1965")
+			})
+		})
+	})
+}
+
+func TestSeed(t *testing.T) {
+	Convey("Given the books of a fixture and fake books seeded", t, func() {
+		Reset(func() {
+			test.ClearDB(context.TODO())
+		})
+
+		fixture, err := seed.ReadFile("../../seed/testdata/books.yaml")
+		So(err, ShouldBeNil)
+		report, err := test.SeedDB(append(fixture, seed.Fake(50, 1)...))
+		So(err, ShouldBeNil)
+
+		Convey("Then every book should be created with its metadata", func() {
+			So(report.Created, ShouldEqual, 53)
+
+			var response book.Book
+			resp, err := resty.New().R().SetResult(&response).Get(baseUrl + "/api/v1/books/" + fixture[0].ID.Hex())
+			So(err, ShouldBeNil)
+			So(resp.StatusCode(), ShouldEqual, http.StatusOK)
+			So(response.Title, ShouldEqual, "Dune")
+			So(response.ISBN, ShouldEqual, "9780441172719")
+			So(response.Contributors, ShouldHaveLength, 1)
+		})
+
+		Convey("When seeding them again", func() {
+			again, err := test.SeedDB(append(seed.Fake(50, 1), fixture...))
+
+			Convey("Then no book should be created twice", func() {
+				So(err, ShouldBeNil)
+				So(again.Created, ShouldEqual, 0)
+				So(again.Present, ShouldEqual, 53)
+			})
+		})
+	})
+}
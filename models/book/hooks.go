@@ -15,8 +15,12 @@ func (b *Book) Saving(ctx context.Context) (err error) {
 
 	// Validate the model fields
 	err = b.Validate()
+	if isDuplicate(err) {
+		err = ErrDuplicate
+		return
+	}
 	if err != nil {
-		err = fmt.Errorf("validation error: %s", err)
+		err = fmt.Errorf("%w: %s", ErrValidation, err)
 		return
 	}
 
@@ -0,0 +1,59 @@
+package book
+
+// Bibliographic metadata of the books, as received from publisher feeds.
+
+// Roles of the contributors
+const (
+	RoleAuthor      = "author"
+	RoleEditor      = "editor"
+	RoleTranslator  = "translator"
+	RoleIllustrator = "illustrator"
+	RoleContributor = "contributor"
+)
+
+// Availabilities of the books
+const (
+	Available       = "available"
+	NotYetAvailable = "not_yet_available"
+	OutOfStock      = "out_of_stock"
+	Unavailable     = "unavailable"
+)
+
+// Contributor is a person or an organisation credited for a book
+type Contributor struct {
+	Name string `json:"name" bson:"name" validate:"required"`
+	Role string `json:"role" bson:"role" validate:"required,oneof=author editor translator illustrator contributor"`
+}
+
+// Price is the price of a book in a currency
+type Price struct {
+	Amount   float64 `json:"amount" bson:"amount" validate:"gte=0"`
+	Currency string  `json:"currency" bson:"currency" validate:"required,iso4217"`
+}
+
+// Apply sets the fields of data which are not zero on the book. The updates of
+// every API go through it, so they cannot clear a field: an empty ISBN, price
+// list or reference leaves the stored one as it is.
+func (b *Book) Apply(data *Book) {
+	if data.Title != "" {
+		b.Title = data.Title
+	}
+	if data.Pages != 0 {
+		b.Pages = data.Pages
+	}
+	if data.ISBN != "" {
+		b.ISBN = data.ISBN
+	}
+	if data.Contributors != nil {
+		b.Contributors = data.Contributors
+	}
+	if data.Prices != nil {
+		b.Prices = data.Prices
+	}
+	if data.Availability != "" {
+		b.Availability = data.Availability
+	}
+	if data.Reference != "" {
+		b.Reference = data.Reference
+	}
+}
@@ -5,7 +5,9 @@
 package mocks
 
 import (
+	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	common "github.com/snehil-sinha/goBookStore/common"
@@ -35,6 +37,155 @@ func (m *MockBookService) EXPECT() *MockBookServiceMockRecorder {
 	return m.recorder
 }
 
+// Audit mocks base method.
+func (m *MockBookService) Audit(arg0 *common.Logger, arg1 book.AuditFilter) ([]*book.AuditRecord, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Audit", arg0, arg1)
+	ret0, _ := ret[0].([]*book.AuditRecord)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Audit indicates an expected call of Audit.
+func (mr *MockBookServiceMockRecorder) Audit(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Audit", reflect.TypeOf((*MockBookService)(nil).Audit), arg0, arg1)
+}
+
+// Batch mocks base method.
+func (m *MockBookService) Batch(arg0 context.Context, arg1 *common.Logger, arg2 []book.Operation, arg3 bool) ([]book.Result, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Batch", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]book.Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Batch indicates an expected call of Batch.
+func (mr *MockBookServiceMockRecorder) Batch(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Batch", reflect.TypeOf((*MockBookService)(nil).Batch), arg0, arg1, arg2, arg3)
+}
+
+// Create mocks base method.
+func (m *MockBookService) Create(arg0 context.Context, arg1 *common.Logger, arg2 *book.Book) (*book.Book, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*book.Book)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockBookServiceMockRecorder) Create(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockBookService)(nil).Create), arg0, arg1, arg2)
+}
+
+// Delete mocks base method.
+func (m *MockBookService) Delete(arg0 context.Context, arg1 *common.Logger, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockBookServiceMockRecorder) Delete(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockBookService)(nil).Delete), arg0, arg1, arg2)
+}
+
+// Each mocks base method.
+func (m *MockBookService) Each(arg0 *common.Logger, arg1 book.Filter, arg2 func(*book.Book) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Each", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Each indicates an expected call of Each.
+func (mr *MockBookServiceMockRecorder) Each(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Each", reflect.TypeOf((*MockBookService)(nil).Each), arg0, arg1, arg2)
+}
+
+// FindDuplicates mocks base method.
+func (m *MockBookService) FindDuplicates(arg0 *common.Logger, arg1 []*book.Book) ([]*book.Book, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindDuplicates", arg0, arg1)
+	ret0, _ := ret[0].([]*book.Book)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindDuplicates indicates an expected call of FindDuplicates.
+func (mr *MockBookServiceMockRecorder) FindDuplicates(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindDuplicates", reflect.TypeOf((*MockBookService)(nil).FindDuplicates), arg0, arg1)
+}
+
+// History mocks base method.
+func (m *MockBookService) History(arg0 *common.Logger, arg1 string) ([]*book.AuditRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "History", arg0, arg1)
+	ret0, _ := ret[0].([]*book.AuditRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// History indicates an expected call of History.
+func (mr *MockBookServiceMockRecorder) History(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "History", reflect.TypeOf((*MockBookService)(nil).History), arg0, arg1)
+}
+
+// List mocks base method.
+func (m *MockBookService) List(arg0 *common.Logger, arg1 book.ListOptions) ([]*book.Book, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", arg0, arg1)
+	ret0, _ := ret[0].([]*book.Book)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// List indicates an expected call of List.
+func (mr *MockBookServiceMockRecorder) List(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockBookService)(nil).List), arg0, arg1)
+}
+
+// Purge mocks base method.
+func (m *MockBookService) Purge(arg0 context.Context, arg1 *common.Logger, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Purge", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Purge indicates an expected call of Purge.
+func (mr *MockBookServiceMockRecorder) Purge(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Purge", reflect.TypeOf((*MockBookService)(nil).Purge), arg0, arg1, arg2)
+}
+
+// PurgeDeleted mocks base method.
+func (m *MockBookService) PurgeDeleted(arg0 context.Context, arg1 *common.Logger, arg2 time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeleted", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeleted indicates an expected call of PurgeDeleted.
+func (mr *MockBookServiceMockRecorder) PurgeDeleted(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeleted", reflect.TypeOf((*MockBookService)(nil).PurgeDeleted), arg0, arg1, arg2)
+}
+
 // ReadAll mocks base method.
 func (m *MockBookService) ReadAll(arg0 *common.Logger) ([]*book.Book, error) {
 	m.ctrl.T.Helper()
@@ -50,6 +201,21 @@ func (mr *MockBookServiceMockRecorder) ReadAll(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadAll", reflect.TypeOf((*MockBookService)(nil).ReadAll), arg0)
 }
 
+// ReadAsOf mocks base method.
+func (m *MockBookService) ReadAsOf(arg0 *common.Logger, arg1 string, arg2 time.Time) (*book.Book, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadAsOf", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*book.Book)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadAsOf indicates an expected call of ReadAsOf.
+func (mr *MockBookServiceMockRecorder) ReadAsOf(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadAsOf", reflect.TypeOf((*MockBookService)(nil).ReadAsOf), arg0, arg1, arg2)
+}
+
 // ReadById mocks base method.
 func (m *MockBookService) ReadById(arg0 *common.Logger, arg1 string) (*book.Book, error) {
 	m.ctrl.T.Helper()
@@ -64,3 +230,78 @@ func (mr *MockBookServiceMockRecorder) ReadById(arg0, arg1 interface{}) *gomock.
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadById", reflect.TypeOf((*MockBookService)(nil).ReadById), arg0, arg1)
 }
+
+// ReadByIds mocks base method.
+func (m *MockBookService) ReadByIds(arg0 *common.Logger, arg1 []string) ([]*book.Book, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadByIds", arg0, arg1)
+	ret0, _ := ret[0].([]*book.Book)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadByIds indicates an expected call of ReadByIds.
+func (mr *MockBookServiceMockRecorder) ReadByIds(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadByIds", reflect.TypeOf((*MockBookService)(nil).ReadByIds), arg0, arg1)
+}
+
+// Restore mocks base method.
+func (m *MockBookService) Restore(arg0 context.Context, arg1 *common.Logger, arg2 string) (*book.Book, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*book.Book)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockBookServiceMockRecorder) Restore(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockBookService)(nil).Restore), arg0, arg1, arg2)
+}
+
+// Revert mocks base method.
+func (m *MockBookService) Revert(arg0 context.Context, arg1 *common.Logger, arg2 string, arg3 int) (*book.Book, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revert", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*book.Book)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Revert indicates an expected call of Revert.
+func (mr *MockBookServiceMockRecorder) Revert(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revert", reflect.TypeOf((*MockBookService)(nil).Revert), arg0, arg1, arg2, arg3)
+}
+
+// Revision mocks base method.
+func (m *MockBookService) Revision(arg0 *common.Logger, arg1 string, arg2 int) (*book.Revision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revision", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*book.Revision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Revision indicates an expected call of Revision.
+func (mr *MockBookServiceMockRecorder) Revision(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revision", reflect.TypeOf((*MockBookService)(nil).Revision), arg0, arg1, arg2)
+}
+
+// Update mocks base method.
+func (m *MockBookService) Update(arg0 context.Context, arg1 *common.Logger, arg2 string, arg3 *book.Book) (*book.Book, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*book.Book)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockBookServiceMockRecorder) Update(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockBookService)(nil).Update), arg0, arg1, arg2, arg3)
+}
@@ -1,36 +1,159 @@
 package book
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/kamva/mgm/v3"
 	"github.com/snehil-sinha/goBookStore/common"
 	"github.com/snehil-sinha/goBookStore/db"
+	"github.com/snehil-sinha/goBookStore/events"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 //go:generate mockgen -destination=mocks/mock_book_service.go -package=mocks . BookService
 type BookService interface {
 	ReadById(*common.Logger, string) (*Book, error)
+	ReadByIds(*common.Logger, []string) ([]*Book, error)
+	FindDuplicates(*common.Logger, []*Book) ([]*Book, error)
 	ReadAll(*common.Logger) ([]*Book, error)
-	// Create(*common.Logger, *Book) (*Book, error)
-	// Update(*common.Logger, string, *Book) (*Book, error)
-	// Delete(*common.Logger, string) error
+	List(*common.Logger, ListOptions) ([]*Book, int64, error)
+	Each(*common.Logger, Filter, func(*Book) error) error
+	Create(context.Context, *common.Logger, *Book) (*Book, error)
+	Update(context.Context, *common.Logger, string, *Book) (*Book, error)
+	Delete(context.Context, *common.Logger, string) error
+	Restore(context.Context, *common.Logger, string) (*Book, error)
+	Purge(context.Context, *common.Logger, string) error
+	PurgeDeleted(context.Context, *common.Logger, time.Time) (int64, error)
+	History(*common.Logger, string) ([]*AuditRecord, error)
+	Audit(*common.Logger, AuditFilter) ([]*AuditRecord, int64, error)
+	Batch(context.Context, *common.Logger, []Operation, bool) ([]Result, error)
+	Revision(*common.Logger, string, int) (*Revision, error)
+	ReadAsOf(*common.Logger, string, time.Time) (*Book, error)
+	Revert(context.Context, *common.Logger, string, int) (*Book, error)
 }
 
+// ErrNotFound is returned when no book matches the given id
+var ErrNotFound = errors.New("mongo: no documents in result")
+
+// ErrValidation is wrapped by the errors of the books, or of the requests, which
+// are invalid
+var ErrValidation = errors.New("validation error")
+
 type bookService struct {
+	events *events.Bus
 }
 
-func NewBookService() BookService {
-	return &bookService{}
+// NewBookService returns the book service, publishing every change to bus (which may be nil)
+func NewBookService(bus *events.Bus) BookService {
+	return &bookService{events: bus}
+}
+
+// ListOptions selects a page of books, ordered by id
+type ListOptions struct {
+	Skip   int64
+	Limit  int64 // 0 means no limit
+	Filter Filter
+}
+
+// Filter restricts the books returned by List; zero fields match any book
+type Filter struct {
+	Title         string // exact title
+	TitleContains string // case insensitive part of the title
+	MinPages      int
+	MaxPages      int
+	Reference     string // exact record reference
+	ISBN          string // exact ISBN-13
+	Deleted       bool   // the deleted books, in the trash, rather than the live ones
+}
+
+// Conditions on the deleted_at field matching the live and the deleted books
+var (
+	notDeleted = bson.M{"$exists": false}
+	deleted    = bson.M{"$exists": true}
+)
+
+// query returns the Mongo filter matching the books selected by f
+func (f Filter) query() bson.M {
+	q := bson.M{"deleted_at": notDeleted}
+	if f.Deleted {
+		q["deleted_at"] = deleted
+	}
+	switch {
+	case f.Title != "":
+		q["title"] = f.Title
+	case f.TitleContains != "":
+		q["title"] = primitive.Regex{Pattern: regexp.QuoteMeta(f.TitleContains), Options: "i"}
+	}
+	if f.Reference != "" {
+		q["reference"] = f.Reference
+	}
+	if f.ISBN != "" {
+		q["isbn"] = f.ISBN
+	}
+	pages := bson.M{}
+	if f.MinPages > 0 {
+		pages["$gte"] = f.MinPages
+	}
+	if f.MaxPages > 0 {
+		pages["$lte"] = f.MaxPages
+	}
+	if len(pages) > 0 {
+		q["pages"] = pages
+	}
+	return q
+}
+
+// Matches tells if b is one of the books selected by f, as the Mongo filter of f
+// would
+func (f Filter) Matches(b *Book) bool {
+	if f.Deleted != (b.DeletedAt != nil) {
+		return false
+	}
+	switch {
+	case f.Title != "":
+		if b.Title != f.Title {
+			return false
+		}
+	case f.TitleContains != "":
+		if !strings.Contains(strings.ToLower(b.Title), strings.ToLower(f.TitleContains)) {
+			return false
+		}
+	}
+	if f.Reference != "" && b.Reference != f.Reference {
+		return false
+	}
+	if f.ISBN != "" && b.ISBN != f.ISBN {
+		return false
+	}
+	return (f.MinPages <= 0 || b.Pages >= f.MinPages) && (f.MaxPages <= 0 || b.Pages <= f.MaxPages)
 }
 
 type Book struct {
 	mgm.DefaultModel `bson:",inline"`
 	Title            string `json:"title" bson:"title" validate:"required,gt=0,bookAlreadyPresent"`
 	Pages            int    `json:"pages" bson:"pages" validate:"required,numeric,gte=1"`
+	// Bibliographic metadata, see metadata.go
+	ISBN         string        `json:"isbn,omitempty" bson:"isbn,omitempty" validate:"omitempty,isbn13"`
+	Contributors []Contributor `json:"contributors,omitempty" bson:"contributors,omitempty" validate:"dive"`
+	Prices       []Price       `json:"prices,omitempty" bson:"prices,omitempty" validate:"dive"`
+	Availability string        `json:"availability,omitempty" bson:"availability,omitempty" validate:"omitempty,oneof=available not_yet_available out_of_stock unavailable"`
+	// Reference identifies the record of the book in a publisher feed, e.g. the
+	// RecordReference of an ONIX product
+	Reference string `json:"reference,omitempty" bson:"reference,omitempty"`
+	// DeletedAt is the tombstone of a deleted book, kept in the trash until it is
+	// purged
+	DeletedAt *time.Time `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	// Revision is the number of the current revision of the book, see revision.go;
+	// zero for a book stored before the revisions were recorded
+	Revision int `json:"revision,omitempty" bson:"revision,omitempty"`
 }
 
 // Returns a new book object
@@ -41,8 +164,13 @@ func NewBook(name string, pages int) *Book {
 	}
 }
 
-// Get book by id
+// Get book by id, unless it is deleted
 func (bs *bookService) ReadById(log *common.Logger, id string) (out *Book, err error) {
+	return bs.read(log, id, notDeleted)
+}
+
+// read the book by id whose deleted_at field matches the condition, if any
+func (bs *bookService) read(log *common.Logger, id string, deletedAt bson.M) (out *Book, err error) {
 	out = &Book{}
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -50,17 +178,102 @@ func (bs *bookService) ReadById(log *common.Logger, id string) (out *Book, err e
 		return nil, err
 	}
 
-	err = db.GoBookStore.FirstWithCtx(mgm.Ctx(), bson.M{"_id": objID}, out)
+	query := bson.M{"_id": objID}
+	if deletedAt != nil {
+		query["deleted_at"] = deletedAt
+	}
+	err = db.GoBookStore.FirstWithCtx(mgm.Ctx(), query, out)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		log.Error(err.Error())
+		return nil, err
+	}
+	return
+}
+
+// Get the books matching the given ids, in no particular order. Invalid and
+// unknown ids, and deleted books, are skipped.
+func (bs *bookService) ReadByIds(log *common.Logger, ids []string) (out []*Book, err error) {
+
+	objIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+			objIDs = append(objIDs, objID)
+		}
+	}
+	if len(objIDs) == 0 {
+		return
+	}
+
+	results := []Book{}
+
+	err = db.GoBookStore.SimpleFindWithCtx(mgm.Ctx(), &results, bson.M{"_id": bson.M{"$in": objIDs}, "deleted_at": notDeleted})
 	if err != nil {
-		return nil, fmt.Errorf("mongo: no documents in result")
+		log.Error(err.Error())
+		return
+	}
+
+	for i := range results {
+		out = append(out, &results[i])
 	}
 	return
 }
 
-// Get all books
+// Get, for each of the given books, a stored book with its ISBN or with its
+// title and page count, nil if none. Unlike the duplicate checks of Create and
+// Batch, the books in the trash are matched as well. The books are looked up with
+// a single query.
+func (bs *bookService) FindDuplicates(log *common.Logger, books []*Book) (out []*Book, err error) {
+
+	out = make([]*Book, len(books))
+	var or bson.A
+	for _, b := range books {
+		if b.ISBN != "" {
+			or = append(or, bson.M{"isbn": b.ISBN})
+		}
+		if b.Title != "" {
+			or = append(or, bson.M{"title": b.Title, "pages": b.Pages})
+		}
+	}
+	if len(or) == 0 {
+		return
+	}
+
+	results := []Book{}
+
+	err = db.GoBookStore.SimpleFindWithCtx(mgm.Ctx(), &results, bson.M{"$or": or})
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	byISBN := map[string]*Book{}
+	byTitle := map[string]*Book{}
+	for i := range results {
+		r := &results[i]
+		if r.ISBN != "" && byISBN[r.ISBN] == nil {
+			byISBN[r.ISBN] = r
+		}
+		if k := titleKey(r); byTitle[k] == nil {
+			byTitle[k] = r
+		}
+	}
+	for i, b := range books {
+		if b.ISBN != "" && byISBN[b.ISBN] != nil {
+			out[i] = byISBN[b.ISBN]
+		} else if b.Title != "" {
+			out[i] = byTitle[titleKey(b)]
+		}
+	}
+	return
+}
+
+// Get all books, except the deleted ones
 func (bs *bookService) ReadAll(log *common.Logger) (out []*Book, err error) {
 
-	filter := bson.M{}
+	filter := bson.M{"deleted_at": notDeleted}
 
 	results := []Book{}
 
@@ -76,46 +289,167 @@ func (bs *bookService) ReadAll(log *common.Logger) (out []*Book, err error) {
 	return
 }
 
+// Get a page of books along with the total number of books
+func (bs *bookService) List(log *common.Logger, opts ListOptions) (out []*Book, total int64, err error) {
+
+	filter := opts.Filter.query()
+
+	total, err = db.GoBookStore.CountDocuments(mgm.Ctx(), filter)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	findOpts := options.Find().SetSort(bson.M{"_id": 1}).SetSkip(opts.Skip)
+	if opts.Limit > 0 {
+		findOpts.SetLimit(opts.Limit)
+	}
+
+	results := []Book{}
+
+	err = db.GoBookStore.SimpleFindWithCtx(mgm.Ctx(), &results, filter, findOpts)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	for i := range results {
+		out = append(out, &results[i])
+	}
+	return
+}
+
+// Call fn for every book matching the filter, ordered by id, reading them from a
+// cursor rather than loading them all at once. Iteration stops at the first error
+// returned by fn, which is returned.
+func (bs *bookService) Each(log *common.Logger, filter Filter, fn func(*Book) error) (err error) {
+
+	cur, err := db.GoBookStore.Find(mgm.Ctx(), filter.query(), options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+	defer cur.Close(mgm.Ctx())
+
+	for cur.Next(mgm.Ctx()) {
+		b := &Book{}
+		if err = cur.Decode(b); err != nil {
+			log.Error(err.Error())
+			return
+		}
+		if err = fn(b); err != nil {
+			return
+		}
+	}
+
+	if err = cur.Err(); err != nil {
+		log.Error(err.Error())
+	}
+	return
+}
+
 // Create a book
-func Create(log *common.Logger, in *Book) (out *Book, err error) {
+func (bs *bookService) Create(ctx context.Context, log *common.Logger, in *Book) (out *Book, err error) {
 
+	revise(nil, in)
 	err = db.GoBookStore.CreateWithCtx(mgm.Ctx(), in)
 	if err != nil {
 		log.Error(err.Error())
 		return
 	}
 	out = in
+	bs.record(log, newRevisions(ctx, nil, out), newAuditRecord(ctx, OpCreate, nil, out))
+	bs.events.Publish(events.Created, out.ID.Hex(), out)
 	return
 }
 
-// Update a book
-func Update(log *common.Logger, id string, data *Book) (out *Book, err error) {
-	bs := NewBookService()
+// Update a book. It fails with ErrConflict if the book was changed by another
+// request meanwhile.
+func (bs *bookService) Update(ctx context.Context, log *common.Logger, id string, data *Book) (out *Book, err error) {
 	out, err = bs.ReadById(log, id)
 	if err != nil {
 		log.Error(err.Error())
 		return nil, err
 	}
 
-	if data.Title != "" {
-		out.Title = data.Title
+	before := *out
+	out.Apply(data)
+	revise(&before, out)
+
+	err = saveRevised(&before, out)
+	if err != nil {
+		log.Error(err.Error())
+		return
 	}
-	if data.Pages != 0 {
-		out.Pages = data.Pages
+	bs.record(log, newRevisions(ctx, &before, out), newAuditRecord(ctx, OpUpdate, &before, out))
+	bs.events.Publish(events.Updated, out.ID.Hex(), out)
+	return
+}
+
+// Delete a book by id, moving it to the trash: it is kept with a tombstone
+// until it is restored or purged
+func (bs *bookService) Delete(ctx context.Context, log *common.Logger, id string) (err error) {
+	out, err := bs.ReadById(log, id)
+	if err != nil {
+		log.Error(err.Error())
+		return err
 	}
 
-	err = db.GoBookStore.UpdateWithCtx(mgm.Ctx(), out)
+	now := time.Now().UTC()
+	res, err := db.GoBookStore.UpdateOne(mgm.Ctx(),
+		bson.M{"_id": out.ID, "deleted_at": notDeleted},
+		bson.M{"$set": bson.M{"deleted_at": now}})
 	if err != nil {
 		log.Error(err.Error())
 		return
 	}
+	// deleted by another request in the meantime
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	before := *out
+	out.DeletedAt = &now
+	bs.record(log, nil, newAuditRecord(ctx, OpDelete, &before, out))
+	bs.events.Publish(events.Deleted, out.ID.Hex(), out)
 	return
 }
 
-// Delete a book by id
-func Delete(log *common.Logger, id string) (err error) {
-	bs := NewBookService()
-	out, err := bs.ReadById(log, id)
+// Restore a deleted book by id from the trash. It fails with ErrDuplicate if
+// another book with the same title and pages was created since.
+func (bs *bookService) Restore(ctx context.Context, log *common.Logger, id string) (out *Book, err error) {
+	out, err = bs.read(log, id, deleted)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+
+	if isBookAlreadyPresent(out.ID, out.Title, out.Pages) {
+		return nil, ErrDuplicate
+	}
+
+	before := *out
+	out.DeletedAt = nil
+	out.UpdatedAt = time.Now().UTC()
+	res, err := db.GoBookStore.UpdateOne(mgm.Ctx(),
+		bson.M{"_id": out.ID, "deleted_at": deleted},
+		bson.M{"$set": bson.M{"updated_at": out.UpdatedAt}, "$unset": bson.M{"deleted_at": ""}})
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+	// restored or purged by another request in the meantime
+	if res.MatchedCount == 0 {
+		return nil, ErrNotFound
+	}
+	bs.record(log, nil, newAuditRecord(ctx, OpRestore, &before, out))
+	// to the subscribers, a restored book is created again
+	bs.events.Publish(events.Created, out.ID.Hex(), out)
+	return
+}
+
+// Purge permanently deletes a book by id, whether it is in the trash or not
+func (bs *bookService) Purge(ctx context.Context, log *common.Logger, id string) (err error) {
+	out, err := bs.read(log, id, nil)
 	if err != nil {
 		log.Error(err.Error())
 		return err
@@ -126,13 +460,74 @@ func Delete(log *common.Logger, id string) (err error) {
 		log.Error(err.Error())
 		return
 	}
+	bs.purgeRevisions(log, out.ID)
+	bs.record(log, nil, newAuditRecord(ctx, OpPurge, out, nil))
+	// the subscribers were told of the deletion of the books in the trash already
+	if out.DeletedAt == nil {
+		bs.events.Publish(events.Deleted, out.ID.Hex(), out)
+	}
 	return
 }
 
-// check if book is already present in the collection
-func isBookAlreadyPresent(title string, pages int) bool {
+// PurgeDeleted permanently deletes the books deleted before the given time, and
+// returns their number
+func (bs *bookService) PurgeDeleted(ctx context.Context, log *common.Logger, before time.Time) (int64, error) {
+
+	// the purged books are read first for the audit log
+	purged := []Book{}
+	err := db.GoBookStore.SimpleFindWithCtx(mgm.Ctx(), &purged, bson.M{"deleted_at": bson.M{"$lt": before}})
+	if err != nil || len(purged) == 0 {
+		if err != nil {
+			log.Error(err.Error())
+		}
+		return 0, err
+	}
+
+	ids := make([]primitive.ObjectID, len(purged))
+	records := make([]*AuditRecord, len(purged))
+	for i := range purged {
+		ids[i] = purged[i].ID
+		records[i] = newAuditRecord(ctx, OpPurge, &purged[i], nil)
+	}
+
+	// books restored in the meantime are kept
+	res, err := db.GoBookStore.DeleteMany(mgm.Ctx(), bson.M{"_id": bson.M{"$in": ids}, "deleted_at": bson.M{"$lt": before}})
+	if err != nil {
+		log.Error(err.Error())
+		return 0, err
+	}
+	if res.DeletedCount < int64(len(purged)) {
+		kept := []Book{}
+		if err = db.GoBookStore.SimpleFindWithCtx(mgm.Ctx(), &kept, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+			log.Error(err.Error())
+		}
+		restored := make(map[primitive.ObjectID]bool, len(kept))
+		for _, b := range kept {
+			restored[b.ID] = true
+		}
+		n := 0
+		for _, r := range records {
+			if !restored[r.BookID] {
+				records[n] = r
+				n++
+			}
+		}
+		records = records[:n]
+	}
+	purgedIDs := make([]primitive.ObjectID, len(records))
+	for i, r := range records {
+		purgedIDs[i] = r.BookID
+	}
+	bs.purgeRevisions(log, purgedIDs...)
+	bs.record(log, nil, records...)
+	return res.DeletedCount, nil
+}
+
+// check if another book with the title and pages is present in the collection,
+// the books in the trash aside
+func isBookAlreadyPresent(id primitive.ObjectID, title string, pages int) bool {
 
-	filter := bson.M{"title": title, "pages": pages}
+	filter := bson.M{"_id": bson.M{"$ne": id}, "title": title, "pages": pages, "deleted_at": notDeleted}
 
 	count, err := db.GoBookStore.CountDocuments(mgm.Ctx(), filter)
 	if err != nil {
@@ -144,5 +539,5 @@ func isBookAlreadyPresent(title string, pages int) bool {
 
 // Validator function to check if book is already present in the database
 func (b *Book) ValidateBookAlreadyPresent(fl validator.FieldLevel) bool {
-	return !isBookAlreadyPresent(b.Title, b.Pages)
+	return !isBookAlreadyPresent(b.ID, b.Title, b.Pages)
 }
@@ -0,0 +1,240 @@
+package book
+
+// Revisions of the books: a full copy of a book is stored each time it is
+// created or updated, to read the book as it was at a given time and to revert
+// bad edits.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kamva/mgm/v3"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/db"
+	"github.com/snehil-sinha/goBookStore/events"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// OpRevert is the operation recorded in the audit log for a revert
+const OpRevert OpType = "revert"
+
+// ErrConflict is returned for an update or a revert of a book changed by another
+// request in the meantime
+var ErrConflict = errors.New("conflict: the book was changed by another request")
+
+// Revision is a version of a book, numbered from 1 for each book. Revisions are
+// only ever inserted, and removed when the book is purged.
+type Revision struct {
+	mgm.IDField `bson:",inline"`
+	BookID      primitive.ObjectID `json:"book_id" bson:"book_id"`
+	Revision    int                `json:"revision" bson:"revision"`
+	// Time is when the revision was made, the updated_at of the book
+	Time      time.Time `json:"time" bson:"time"`
+	Actor     string    `json:"actor,omitempty" bson:"actor,omitempty"`
+	RequestID string    `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	Book      Book      `json:"book" bson:"book"`
+}
+
+// revise sets the revision of a book about to be saved, changed from before
+// (nil for a new book). A book stored before the revisions were recorded is
+// taken as revision 1.
+func revise(before, after *Book) {
+	switch {
+	case before == nil:
+		after.Revision = 1
+	case before.Revision == 0:
+		after.Revision = 2
+	default:
+		after.Revision = before.Revision + 1
+	}
+}
+
+// saveRevised writes a book revised from before, with the hooks of
+// UpdateWithCtx, provided the stored book is still the revision of before. It fails
+// with ErrConflict otherwise, so that two concurrent edits cannot both make the
+// next revision.
+func saveRevised(before, after *Book) error {
+	if err := after.Saving(mgm.Ctx()); err != nil {
+		return err
+	}
+
+	// the whole book is replaced, a $set could not clear the omitted fields
+	res, err := db.GoBookStore.ReplaceOne(mgm.Ctx(), revisedFilter(before), after)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// revisedFilter matches a book if it is still the revision of before, and not
+// deleted
+func revisedFilter(before *Book) bson.M {
+	// a book stored before the revisions were recorded has none
+	revision := any(before.Revision)
+	if before.Revision == 0 {
+		revision = bson.M{"$exists": false}
+	}
+	return bson.M{"_id": before.ID, "deleted_at": notDeleted, "revision": revision}
+}
+
+// newRevisions returns the revisions to store for a book saved by the request
+// of ctx: the one of after, preceded by the one of before if it was stored
+// before the revisions were recorded
+func newRevisions(ctx context.Context, before, after *Book) []*Revision {
+	var out []*Revision
+	if before != nil && before.Revision == 0 {
+		legacy := *before
+		legacy.Revision = 1
+		out = append(out, newRevision(&legacy, "", ""))
+	}
+	id := common.IdentityFrom(ctx)
+	if id.Actor == "" {
+		id.Actor = ActorSystem
+	}
+	return append(out, newRevision(after, id.Actor, id.RequestID))
+}
+
+// newRevision returns the revision of a saved book
+func newRevision(b *Book, actor, requestID string) *Revision {
+	r := &Revision{
+		BookID:    b.ID,
+		Revision:  b.Revision,
+		Time:      b.UpdatedAt,
+		Actor:     actor,
+		RequestID: requestID,
+		Book:      *b,
+	}
+	r.ID = primitive.NewObjectID()
+	r.Book.DeletedAt = nil
+	return r
+}
+
+// saveRevisions stores the revisions, see record
+func (bs *bookService) saveRevisions(revisions ...*Revision) error {
+	if len(revisions) == 0 {
+		return nil
+	}
+	docs := make([]any, len(revisions))
+	for i, r := range revisions {
+		docs[i] = r
+	}
+	if _, err := db.BookRevisions.InsertMany(mgm.Ctx(), docs); err != nil {
+		return fmt.Errorf("error storing the revisions: %w", err)
+	}
+	return nil
+}
+
+// purgeRevisions removes the revisions of the purged books
+func (bs *bookService) purgeRevisions(log *common.Logger, ids ...primitive.ObjectID) {
+	if len(ids) == 0 {
+		return
+	}
+	if _, err := db.BookRevisions.DeleteMany(mgm.Ctx(), bson.M{"book_id": bson.M{"$in": ids}}); err != nil {
+		log.Error("error removing the revisions: " + err.Error())
+	}
+}
+
+// Revision returns a revision of a book by id, including a book in the trash
+func (bs *bookService) Revision(log *common.Logger, id string, rev int) (out *Revision, err error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+	return bs.findRevision(log, bson.M{"book_id": objID, "revision": rev})
+}
+
+// findRevision returns the latest revision matching the query
+func (bs *bookService) findRevision(log *common.Logger, query bson.M) (out *Revision, err error) {
+	out = &Revision{}
+	err = db.BookRevisions.FirstWithCtx(mgm.Ctx(), query, out, options.FindOne().SetSort(bson.M{"revision": -1}))
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		log.Error(err.Error())
+		return nil, err
+	}
+	return
+}
+
+// ReadAsOf returns a book by id as it was at the given time. It fails with
+// ErrNotFound before the book was created, and from the time it was deleted
+// on if it is in the trash.
+func (bs *bookService) ReadAsOf(log *common.Logger, id string, t time.Time) (out *Book, err error) {
+	current, err := bs.read(log, id, nil)
+	if err != nil {
+		return nil, err
+	}
+	if current.DeletedAt != nil && !t.Before(*current.DeletedAt) {
+		return nil, ErrNotFound
+	}
+
+	// a book stored before the revisions were recorded is only known as it is
+	if current.Revision == 0 {
+		if t.Before(current.UpdatedAt) {
+			return nil, ErrNotFound
+		}
+		return current, nil
+	}
+
+	r, err := bs.findRevision(log, bson.M{"book_id": current.ID, "time": bson.M{"$lte": t}})
+	if err != nil {
+		return nil, err
+	}
+	return &r.Book, nil
+}
+
+// Revert sets the fields of a book by id back to the ones of a previous
+// revision, making a new revision. It fails with ErrNotFound if the book is
+// deleted or the revision is unknown, with ErrDuplicate if another book has
+// the title and pages of the revision, and with ErrConflict if the book was
+// changed meanwhile.
+func (bs *bookService) Revert(ctx context.Context, log *common.Logger, id string, rev int) (out *Book, err error) {
+	out, err = bs.ReadById(log, id)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+	r, err := bs.Revision(log, id, rev)
+	if err != nil {
+		return nil, err
+	}
+
+	if isBookAlreadyPresent(out.ID, r.Book.Title, r.Book.Pages) {
+		return nil, ErrDuplicate
+	}
+
+	before := *out
+	out.revert(&r.Book)
+	revise(&before, out)
+
+	err = saveRevised(&before, out)
+	if err != nil {
+		log.Error(err.Error())
+		return nil, err
+	}
+	bs.record(log, newRevisions(ctx, &before, out), newAuditRecord(ctx, OpRevert, &before, out))
+	bs.events.Publish(events.Updated, out.ID.Hex(), out)
+	return
+}
+
+// revert sets the fields of the book, the id and the timestamps aside, to the
+// ones of the revision
+func (b *Book) revert(rev *Book) {
+	b.Title = rev.Title
+	b.Pages = rev.Pages
+	b.ISBN = rev.ISBN
+	b.Contributors = rev.Contributors
+	b.Prices = rev.Prices
+	b.Availability = rev.Availability
+	b.Reference = rev.Reference
+}
@@ -1,8 +1,11 @@
 package book
 
 import (
+	"errors"
 	"fmt"
 
+	"github.com/go-playground/validator/v10"
+
 	"github.com/snehil-sinha/goBookStore/service/validators"
 )
 
@@ -17,8 +20,15 @@ func (b *Book) Validate() (err error) {
 	}
 	err = v.Struct(b)
 	if err != nil {
-		err = fmt.Errorf("book validation failed, err: %s", err.Error())
+		err = fmt.Errorf("book validation failed, err: %w", err)
 		return
 	}
 	return
 }
+
+// isDuplicate reports whether the only error of Validate is a book with the same
+// title and pages
+func isDuplicate(err error) bool {
+	var errs validator.ValidationErrors
+	return errors.As(err, &errs) && len(errs) == 1 && errs[0].Tag() == "bookAlreadyPresent"
+}
@@ -0,0 +1,162 @@
+package seed
+
+// Generator of fake books, realistic enough for demos and load tests.
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/snehil-sinha/goBookStore/models/book"
+)
+
+// Words the titles are made of
+var (
+	adjectives = []string{
+		"Silent", "Broken", "Golden", "Last", "Hidden", "Distant", "Crimson", "Forgotten",
+		"Endless", "Quiet", "Wandering", "Burning", "Hollow", "Northern", "Secret", "Little",
+		"Midnight", "Frozen", "Restless", "Invisible",
+	}
+	nouns = []string{
+		"River", "Garden", "Empire", "Lighthouse", "Orchard", "Machine", "Harbor", "Library",
+		"Mountain", "Station", "Kingdom", "Winter", "Archive", "Compass", "Bridge", "Voyage",
+		"Forest", "Letter", "Island", "Clockmaker",
+	}
+	places = []string{
+		"Lisbon", "the North", "Samarkand", "the Sea", "Avalon", "the Valley", "Kyoto",
+		"the Steppe", "Alexandria", "the Marshes",
+	}
+	firstNames = []string{
+		"Ada", "Bruno", "Chiara", "Dmitri", "Elena", "Farid", "Greta", "Hiro", "Ines", "Jonas",
+		"Kemi", "Lucas", "Maya", "Nikolai", "Olga", "Pablo", "Quinn", "Rosa", "Samir", "Tove",
+	}
+	lastNames = []string{
+		"Almeida", "Becker", "Castillo", "Dubois", "Eriksen", "Fischer", "García", "Hansen",
+		"Ivanova", "Jensen", "Kowalski", "Lindqvist", "Moreau", "Nakamura", "Okafor", "Petrov",
+		"Rossi", "Schmidt", "Tanaka", "Weber",
+	}
+)
+
+// titlePatterns build a title from a random source
+var titlePatterns = []func(r *rand.Rand) string{
+	func(r *rand.Rand) string { return "The " + pick(r, adjectives) + " " + pick(r, nouns) },
+	func(r *rand.Rand) string { return "The " + pick(r, nouns) + " of " + pick(r, places) },
+	func(r *rand.Rand) string { return pick(r, adjectives) + " " + pick(r, nouns) + "s" },
+	func(r *rand.Rand) string { return "A " + pick(r, nouns) + " for " + pick(r, firstNames) },
+	func(r *rand.Rand) string {
+		return "The " + pick(r, nouns) + " and the " + pick(r, nouns)
+	},
+}
+
+// currencies of the prices, with their rate to the first one
+var currencies = []struct {
+	code string
+	rate float64
+}{{"EUR", 1}, {"USD", 1.08}, {"GBP", 0.86}}
+
+// availabilities, weighted by repetition
+var availabilities = []string{
+	book.Available, book.Available, book.Available, book.Available, book.Available, book.Available,
+	book.OutOfStock, book.OutOfStock, book.NotYetAvailable, book.Unavailable,
+}
+
+func pick(r *rand.Rand, list []string) string {
+	return list[r.Intn(len(list))]
+}
+
+// Generator generates fake books: the same ones, in the same order, for the
+// same seed. The title and page count of every book is unique among those of
+// the generator.
+type Generator struct {
+	rng   *rand.Rand
+	taken map[string]bool
+}
+
+// NewGenerator returns a generator of fake books from the seed
+func NewGenerator(seed int64) *Generator {
+	return &Generator{rng: rand.New(rand.NewSource(seed)), taken: map[string]bool{}}
+}
+
+// Fake returns n fake books generated from the seed
+func Fake(n int, seed int64) []*book.Book {
+	g := NewGenerator(seed)
+	out := make([]*book.Book, n)
+	for i := range out {
+		out[i] = g.Book()
+	}
+	return out
+}
+
+// Book returns the next fake book, which has a title and page count, and some
+// of the metadata: an ISBN-13, contributors, prices and an availability
+func (g *Generator) Book() *book.Book {
+	r := g.rng
+	b := book.NewBook(g.title(), 0)
+	// mostly between 200 and 450 pages
+	b.Pages = 48 + int(math.Abs(r.NormFloat64()*120+280))
+	for g.taken[g.key(b)] {
+		b.Pages = 48 + r.Intn(1200)
+	}
+	g.taken[g.key(b)] = true
+
+	if r.Intn(10) < 9 {
+		b.ISBN = isbn13(r)
+	}
+	for i, n := 0, 1+r.Intn(2); i < n; i++ {
+		b.Contributors = append(b.Contributors, book.Contributor{Name: name(r), Role: book.RoleAuthor})
+	}
+	switch r.Intn(6) {
+	case 0:
+		b.Contributors = append(b.Contributors, book.Contributor{Name: name(r), Role: book.RoleTranslator})
+	case 1:
+		b.Contributors = append(b.Contributors, book.Contributor{Name: name(r), Role: book.RoleIllustrator})
+	case 2:
+		b.Contributors = append(b.Contributors, book.Contributor{Name: name(r), Role: book.RoleEditor})
+	}
+	// priced by length, in up to every currency
+	base := 6 + float64(b.Pages)/40 + r.Float64()*8
+	for _, c := range currencies[:1+r.Intn(len(currencies))] {
+		amount := math.Round((math.Ceil(base*c.rate)-0.01)*100) / 100
+		b.Prices = append(b.Prices, book.Price{Amount: amount, Currency: c.code})
+	}
+	b.Availability = pick(r, availabilities)
+	return b
+}
+
+func (g *Generator) key(b *book.Book) string {
+	return b.Title + "\x00" + strconv.Itoa(b.Pages)
+}
+
+// title returns a random title, now and then numbered as a volume
+func (g *Generator) title() string {
+	title := titlePatterns[g.rng.Intn(len(titlePatterns))](g.rng)
+	if g.rng.Intn(8) == 0 {
+		title += ", Volume " + strconv.Itoa(2+g.rng.Intn(4))
+	}
+	return title
+}
+
+// name returns a random personal name
+func name(r *rand.Rand) string {
+	return pick(r, firstNames) + " " + pick(r, lastNames)
+}
+
+// isbn13 returns a random valid ISBN-13 of the 978 prefix
+func isbn13(r *rand.Rand) string {
+	var sb strings.Builder
+	sb.WriteString("978")
+	for i := 0; i < 9; i++ {
+		sb.WriteByte(byte('0' + r.Intn(10)))
+	}
+	sum := 0
+	for i, c := range sb.String() {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(c-'0') * weight
+	}
+	sb.WriteString(strconv.Itoa((10 - sum%10) % 10))
+	return sb.String()
+}
@@ -0,0 +1,161 @@
+// Package seed fills a DB with books: those of fixture files in YAML or JSON,
+// and fake ones generated from a fixed random seed. Seeding is idempotent, the
+// books already stored being left alone, so that developers and tests can
+// start from a known catalog.
+package seed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of the fixture files
+const (
+	JSON = "json"
+	YAML = "yaml"
+)
+
+// Statuses of the seeded books
+const (
+	Created = "created"
+	Present = "present"
+	Invalid = "invalid"
+)
+
+// BatchSize is the number of books written at once
+const BatchSize = 500
+
+// Read decodes the books of a fixture in the format, a list of books with the
+// fields of the API. YAML fixtures are converted to JSON first, so that both
+// share the field names.
+func Read(r io.Reader, format string) ([]*book.Book, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case JSON:
+	case YAML:
+		var v any
+		if err = yaml.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("invalid YAML fixture: %w", err)
+		}
+		if raw, err = json.Marshal(v); err != nil {
+			return nil, fmt.Errorf("invalid YAML fixture: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format %q, must be one of %s, %s", format, JSON, YAML)
+	}
+
+	books := []*book.Book{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(&books); err != nil {
+		return nil, fmt.Errorf("invalid fixture, a list of books is expected: %w", err)
+	}
+	return books, nil
+}
+
+// ReadFile decodes the books of a fixture file, its format given by its
+// extension: .json, .yaml or .yml
+func ReadFile(path string) ([]*book.Book, error) {
+	var format string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		format = JSON
+	case ".yaml", ".yml":
+		format = YAML
+	default:
+		return nil, fmt.Errorf("the format of %s cannot be implied from its extension, must be .json, .yaml or .yml", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	books, err := Read(f, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return books, nil
+}
+
+// BookReport is the outcome of the seeding of a book
+type BookReport struct {
+	// Index of the book in the seeded list, from 0
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	ID     string `json:"id,omitempty"`
+	Title  string `json:"title"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report summarizes a seeding
+type Report struct {
+	Created int          `json:"created"`
+	Present int          `json:"present"`
+	Invalid int          `json:"invalid"`
+	Books   []BookReport `json:"books"`
+}
+
+func (r *Report) add(b BookReport) {
+	switch b.Status {
+	case Created:
+		r.Created++
+	case Present:
+		r.Present++
+	case Invalid:
+		r.Invalid++
+	}
+	r.Books = append(r.Books, b)
+}
+
+// Books stores the books, unless a book with the same title and page count is
+// stored already, in batches of BatchSize. The books are set the stored ID and
+// timestamps. Invalid books are reported without stopping the seeding; the
+// returned error is only set if it could not go on, in which case the report
+// covers the books seeded so far.
+func Books(ctx context.Context, log *common.Logger, svc book.BookService, books []*book.Book) (*Report, error) {
+	report := &Report{Books: []BookReport{}}
+	for start := 0; start < len(books); start += BatchSize {
+		end := start + BatchSize
+		if end > len(books) {
+			end = len(books)
+		}
+		ops := make([]book.Operation, 0, end-start)
+		for _, b := range books[start:end] {
+			ops = append(ops, book.Operation{Op: book.OpCreate, Book: b})
+		}
+
+		results, err := svc.Batch(ctx, log, ops, false)
+		if err != nil {
+			return report, err
+		}
+		for i, r := range results {
+			b := books[start+i]
+			rep := BookReport{Index: start + i, Status: Created, ID: r.ID, Title: b.Title}
+			switch {
+			case errors.Is(r.Err, book.ErrDuplicate):
+				rep.Status, rep.ID = Present, ""
+			case r.Err != nil:
+				rep.Status, rep.ID, rep.Error = Invalid, "", r.Err.Error()
+			case r.Book != nil:
+				*b = *r.Book
+			}
+			report.add(rep)
+		}
+	}
+	return report, nil
+}
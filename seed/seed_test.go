@@ -0,0 +1,165 @@
+package seed_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/models/book/mocks"
+	"github.com/snehil-sinha/goBookStore/seed"
+	"github.com/snehil-sinha/goBookStore/service/validators"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+func TestFake(t *testing.T) {
+	Convey("Given fake books generated from a seed", t, func() {
+		books := seed.Fake(2000, 42)
+
+		Convey("Then the same seed should generate the same books", func() {
+			So(seed.Fake(2000, 42), ShouldResemble, books)
+			So(seed.Fake(10, 42), ShouldResemble, books[:10])
+		})
+
+		Convey("Then another seed should generate other books", func() {
+			So(seed.Fake(10, 43), ShouldNotResemble, books[:10])
+		})
+
+		Convey("Then every book should be valid, with a unique title and page count", func() {
+			v := validators.New()
+			So(v.RegisterValidation("bookAlreadyPresent", func(validator.FieldLevel) bool { return true }), ShouldBeNil)
+			seen := map[string]bool{}
+			for _, b := range books {
+				So(v.Struct(b), ShouldBeNil)
+				key := b.Title + "/" + strconv.Itoa(b.Pages)
+				So(seen[key], ShouldBeFalse)
+				seen[key] = true
+			}
+		})
+
+		Convey("Then the books should have metadata", func() {
+			withISBN := 0
+			for _, b := range books {
+				So(b.Contributors, ShouldNotBeEmpty)
+				So(b.Contributors[0].Role, ShouldEqual, book.RoleAuthor)
+				So(b.Prices, ShouldNotBeEmpty)
+				So(b.Availability, ShouldNotBeEmpty)
+				if b.ISBN != "" {
+					withISBN++
+				}
+			}
+			So(withISBN, ShouldBeBetween, 1700, 1900)
+		})
+	})
+}
+
+func TestRead(t *testing.T) {
+	Convey("Given the same fixture in YAML and JSON", t, func() {
+		fromYAML, err := seed.ReadFile("testdata/books.yaml")
+		So(err, ShouldBeNil)
+		fromJSON, err := seed.ReadFile("testdata/books.json")
+		So(err, ShouldBeNil)
+
+		Convey("Then both should decode to the same books", func() {
+			So(fromYAML, ShouldHaveLength, 3)
+			So(fromYAML, ShouldResemble, fromJSON)
+			So(fromYAML[0].Title, ShouldEqual, "Dune")
+			So(fromYAML[0].ISBN, ShouldEqual, "9780441172719")
+			So(fromYAML[0].Prices, ShouldResemble, []book.Price{{Amount: 10.99, Currency: "USD"}})
+			So(fromYAML[2].Contributors[1], ShouldResemble, book.Contributor{Name: "Joanna Kilmartin", Role: book.RoleTranslator})
+		})
+	})
+
+	Convey("Given invalid fixtures", t, func() {
+
+		Convey("Then an unknown field should be rejected", func() {
+			_, err := seed.Read(strings.NewReader("- title: Dune\n  pagse: 412\n"), seed.YAML)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "pagse")
+		})
+
+		Convey("Then a fixture which is not a list should be rejected", func() {
+			_, err := seed.Read(strings.NewReader(`{"title": "Dune"}`), seed.JSON)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Then an unknown format should be rejected", func() {
+			_, err := seed.ReadFile("fixtures.csv")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "cannot be implied from its extension")
+		})
+	})
+}
+
+func TestBooks(t *testing.T) {
+	Convey("Given books to seed, one of which is stored already and one invalid", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		log := &common.Logger{Logger: zap.NewNop()}
+		m := mocks.NewMockBookService(ctrl)
+		books := seed.Fake(3, 1)
+		id := primitive.NewObjectID()
+
+		m.EXPECT().Batch(gomock.Any(), gomock.Any(), gomock.Len(3), false).DoAndReturn(
+			func(_ context.Context, _ *common.Logger, ops []book.Operation, _ bool) ([]book.Result, error) {
+				stored := *ops[0].Book
+				stored.ID = id
+				return []book.Result{
+					{Op: book.OpCreate, ID: id.Hex(), Book: &stored},
+					{Op: book.OpCreate, Err: book.ErrDuplicate},
+					{Op: book.OpCreate, Err: errors.New("validation error: pages")},
+				}, nil
+			})
+
+		Convey("When seeding them", func() {
+			report, err := seed.Books(context.Background(), log, m, books)
+
+			Convey("Then the outcome of every book should be reported", func() {
+				So(err, ShouldBeNil)
+				So(report.Created, ShouldEqual, 1)
+				So(report.Present, ShouldEqual, 1)
+				So(report.Invalid, ShouldEqual, 1)
+				So(report.Books[0], ShouldResemble, seed.BookReport{Index: 0, Status: seed.Created, ID: id.Hex(), Title: books[0].Title})
+				So(report.Books[1].Status, ShouldEqual, seed.Present)
+				So(report.Books[2].Error, ShouldEqual, "validation error: pages")
+			})
+
+			Convey("Then the created book should be set its ID", func() {
+				So(books[0].ID, ShouldEqual, id)
+			})
+		})
+	})
+
+	Convey("Given more books than a batch holds", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		m := mocks.NewMockBookService(ctrl)
+		created := func(_ context.Context, _ *common.Logger, ops []book.Operation, _ bool) ([]book.Result, error) {
+			results := make([]book.Result, len(ops))
+			for i, op := range ops {
+				results[i] = book.Result{Op: book.OpCreate, Book: op.Book}
+			}
+			return results, nil
+		}
+		gomock.InOrder(
+			m.EXPECT().Batch(gomock.Any(), gomock.Any(), gomock.Len(seed.BatchSize), false).DoAndReturn(created),
+			m.EXPECT().Batch(gomock.Any(), gomock.Any(), gomock.Len(10), false).DoAndReturn(created),
+		)
+
+		Convey("Then they should be written in several batches", func() {
+			report, err := seed.Books(context.Background(), &common.Logger{Logger: zap.NewNop()}, m, seed.Fake(seed.BatchSize+10, 1))
+			So(err, ShouldBeNil)
+			So(report.Created, ShouldEqual, seed.BatchSize+10)
+			So(report.Books[seed.BatchSize].Index, ShouldEqual, seed.BatchSize)
+		})
+	})
+}
@@ -0,0 +1,61 @@
+package service
+
+// Authentication of the /admin endpoints.
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/snehil-sinha/goBookStore/common"
+)
+
+// AdminAuth returns a gin.HandlerFunc (middleware) which only lets through requests
+// carrying the configured admin token as a bearer token. Without a configured
+// token every request is rejected.
+func AdminAuth(s *common.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := s.Config().Admin.Token
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "admin API is disabled",
+			})
+			return
+		}
+
+		if !hasBearerToken(c, token) {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "invalid admin token",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// hasBearerToken tells if the request carries token as a bearer token
+func hasBearerToken(c *gin.Context, token string) bool {
+	given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return ok && isToken(given, token)
+}
+
+// isToken tells if given is the configured token, which is not empty
+func isToken(given, token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// AdminAuthIf returns a gin.HandlerFunc (middleware) applying AdminAuth to the
+// requests for which cond is true, e.g. the hard deletes, and letting the others
+// through
+func AdminAuthIf(s *common.App, cond func(*gin.Context) bool) gin.HandlerFunc {
+	auth := AdminAuth(s)
+	return func(c *gin.Context) {
+		if cond(c) {
+			auth(c)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,116 @@
+package service
+
+// Cross-origin resource sharing driven by the cors config section.
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/snehil-sinha/goBookStore/common"
+)
+
+// originMatcher decides whether an origin may make cross-origin requests
+type originMatcher struct {
+	any       bool
+	exact     map[string]bool
+	wildcards []wildcardOrigin
+	re        *regexp.Regexp
+}
+
+// wildcardOrigin matches any subdomain of a host, e.g. https://*.example.com
+type wildcardOrigin struct {
+	scheme string // "https://"
+	suffix string // ".example.com"
+}
+
+// Compile the allowed origins of the cors config section
+func newOriginMatcher(cfg common.CorsConfig) (*originMatcher, error) {
+	m := &originMatcher{
+		exact: make(map[string]bool),
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.ToLower(origin)
+		switch {
+		case origin == "*":
+			m.any = true
+		case strings.Contains(origin, "://*."):
+			scheme, suffix, _ := strings.Cut(origin, "*")
+			if strings.Contains(suffix, "*") {
+				return nil, fmt.Errorf("invalid cors origin %q: only a single leading wildcard is supported", origin)
+			}
+			m.wildcards = append(m.wildcards, wildcardOrigin{scheme: scheme, suffix: suffix})
+		case strings.Contains(origin, "*"):
+			return nil, fmt.Errorf("invalid cors origin %q: wildcards must prefix the host, e.g. https://*.example.com", origin)
+		default:
+			m.exact[origin] = true
+		}
+	}
+
+	if cfg.AllowedOriginsRegex != "" {
+		re, err := regexp.Compile(cfg.AllowedOriginsRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cors origin regex: %s", err)
+		}
+		m.re = re
+	}
+	return m, nil
+}
+
+func (m *originMatcher) match(origin string) bool {
+	if m.any {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if m.exact[lower] {
+		return true
+	}
+	for _, w := range m.wildcards {
+		sub, ok := strings.CutPrefix(lower, w.scheme)
+		if ok && len(sub) > len(w.suffix) && strings.HasSuffix(sub, w.suffix) &&
+			!strings.ContainsAny(sub[:len(sub)-len(w.suffix)], "/:") {
+			return true
+		}
+	}
+	return m.re != nil && m.re.MatchString(origin)
+}
+
+// Cors returns the CORS middleware for the cors config section. The allowed
+// origins are compiled once here and recompiled when the config is reloaded;
+// invalid origins are reported as an error.
+func Cors(s *common.App) (gin.HandlerFunc, error) {
+	cfg := s.Config().Cors
+
+	m, err := newOriginMatcher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var matcher atomic.Pointer[originMatcher]
+	matcher.Store(m)
+
+	if s.Reloader != nil {
+		s.Reloader.OnReload(func(c *common.Config) {
+			m, err := newOriginMatcher(c.Cors)
+			if err != nil {
+				s.Log.Sugar().Errorf("keeping the previous cors origins: %s", err)
+				return
+			}
+			matcher.Store(m)
+		})
+	}
+
+	return cors.New(cors.Config{
+		AllowOriginFunc: func(origin string) bool {
+			return matcher.Load().match(origin)
+		},
+		AllowMethods:     cfg.AllowMethods,
+		AllowHeaders:     cfg.AllowHeaders,
+		ExposeHeaders:    cfg.ExposeHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           cfg.MaxAge,
+	}), nil
+}
@@ -0,0 +1,101 @@
+package service_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/service"
+)
+
+func newTestApp(cfg *common.Config) *common.App {
+	log, _ := common.NewLogger(&common.Config{Env: "test"})
+	return &common.App{Cfg: cfg, Log: log}
+}
+
+// Send a CORS preflight request from origin
+func preflight(r http.Handler, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/books", nil)
+	req.Host = "api.internal"
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCorsPreflight(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given the CORS middleware with exact, wildcard and regex origins", t, func() {
+		cfg := common.DefaultConfig()
+		cfg.Cors.AllowedOrigins = []string{"https://books.example.org", "https://*.example.com"}
+		cfg.Cors.AllowedOriginsRegex = `^http://localhost:\d+$`
+
+		mw, err := service.Cors(newTestApp(cfg))
+		So(err, ShouldBeNil)
+
+		r := gin.New()
+		r.Use(mw)
+		r.POST("/api/v1/books", func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+		Convey("When the preflight comes from an exact origin", func() {
+			w := preflight(r, "https://books.example.org")
+
+			Convey("Then it should be allowed with the configured headers", func() {
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+				So(w.Header().Get("Access-Control-Allow-Origin"), ShouldEqual, "https://books.example.org")
+				So(w.Header().Get("Access-Control-Allow-Credentials"), ShouldEqual, "true")
+				So(w.Header().Get("Access-Control-Allow-Methods"), ShouldContainSubstring, "POST")
+				So(w.Header().Get("Access-Control-Max-Age"), ShouldEqual, "43200")
+			})
+		})
+
+		Convey("When the preflight comes from a subdomain of a wildcard origin", func() {
+			w := preflight(r, "https://shop.example.com")
+
+			Convey("Then it should be allowed", func() {
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+				So(w.Header().Get("Access-Control-Allow-Origin"), ShouldEqual, "https://shop.example.com")
+			})
+		})
+
+		Convey("When the preflight comes from the bare wildcard domain", func() {
+			w := preflight(r, "https://example.com")
+
+			Convey("Then it should be rejected", func() {
+				So(w.Code, ShouldEqual, http.StatusForbidden)
+			})
+		})
+
+		Convey("When the preflight comes from an origin matching the regex", func() {
+			w := preflight(r, "http://localhost:3000")
+
+			Convey("Then it should be allowed", func() {
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+			})
+		})
+
+		Convey("When the preflight comes from an unknown origin", func() {
+			w := preflight(r, "https://evil.example.net")
+
+			Convey("Then it should be rejected", func() {
+				So(w.Code, ShouldEqual, http.StatusForbidden)
+				So(w.Header().Get("Access-Control-Allow-Origin"), ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given a CORS config with an invalid origin pattern", t, func() {
+		cfg := common.DefaultConfig()
+		cfg.Cors.AllowedOrigins = []string{"https://books.*.com"}
+
+		Convey("Then the middleware fails to build", func() {
+			_, err := service.Cors(newTestApp(cfg))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,35 @@
+package service
+
+// Routing of custom methods, e.g. POST /api/v1/books:batch.
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/snehil-sinha/goBookStore/service/openapi"
+)
+
+// CustomMethods returns a gin.HandlerFunc (middleware) routing the requests for
+// custom methods (/<resource>:<method>), which gin cannot match, to their route
+// registered under openapi.CustomMethod. It must be the first middleware of the
+// engine, so that the others only handle the rerouted request.
+func CustomMethods(r *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.FullPath() != "" {
+			c.Next()
+			return
+		}
+
+		path := c.Request.URL.Path
+		segment := strings.LastIndexByte(path, '/') + 1
+		colon := strings.IndexByte(path[segment:], ':')
+		if colon <= 0 {
+			c.Next()
+			return
+		}
+
+		c.Request.URL.Path = openapi.CustomMethod(path[:segment+colon], path[segment+colon+1:])
+		r.HandleContext(c)
+		c.Abort()
+	}
+}
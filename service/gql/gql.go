@@ -0,0 +1,100 @@
+package gql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+)
+
+// ErrMutationNotAllowed is reported for mutations sent in a GET request
+var ErrMutationNotAllowed = errors.New("mutations are only allowed in POST requests")
+
+// Request is a GraphQL request, as sent in the body of a POST request
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Executor runs GraphQL requests against the schema of the catalog
+type Executor struct {
+	schema graphql.Schema
+	r      *resolver
+	limits Limits
+}
+
+// New returns an executor of the schema returned by NewSchema, rejecting the
+// operations beyond the limits
+func New(svc book.BookService, s *common.App, limits Limits) (*Executor, error) {
+	schema, err := NewSchema(svc, s)
+	if err != nil {
+		return nil, err
+	}
+	return &Executor{
+		schema: schema,
+		r:      &resolver{svc: svc, s: s},
+		limits: limits,
+	}, nil
+}
+
+// Execute parses and validates the request, checks it against the limits and
+// runs it. Without allowMutations, mutations are rejected with
+// ErrMutationNotAllowed. Every failure is reported in the errors of the result.
+func (e *Executor) Execute(ctx context.Context, req Request, allowMutations bool) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	if v := graphql.ValidateDocument(&e.schema, doc, nil); !v.IsValid {
+		return &graphql.Result{Errors: v.Errors}
+	}
+
+	op := operation(doc, req.OperationName)
+	if op == nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(errors.New("unknown operation " + req.OperationName))}
+	}
+	if op.Operation == ast.OperationTypeMutation && !allowMutations {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(ErrMutationNotAllowed)}
+	}
+	if err := e.limits.check(doc, op, req.Variables); err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        e.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       e.r.withLoader(ctx),
+	})
+}
+
+// operation returns the operation to run: the one named, or the only one
+func operation(doc *ast.Document, name string) (op *ast.OperationDefinition) {
+	for _, def := range doc.Definitions {
+		d, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			if op != nil {
+				// several operations, one must be named
+				return nil
+			}
+			op = d
+		} else if d.Name != nil && d.Name.Value == name {
+			return d
+		}
+	}
+	return
+}
@@ -0,0 +1,164 @@
+package gql_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/models/book/mocks"
+	"github.com/snehil-sinha/goBookStore/service/gql"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+func newBook(title string, pages int) *book.Book {
+	b := book.NewBook(title, pages)
+	b.ID = primitive.NewObjectID()
+	return b
+}
+
+func TestExecutor(t *testing.T) {
+	Convey("Given a GraphQL executor of the catalog", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		m := mocks.NewMockBookService(ctrl)
+		s := &common.App{Log: &common.Logger{Logger: zap.NewNop()}}
+		e, err := gql.New(m, s, gql.Limits{MaxDepth: 3, MaxComplexity: 50})
+		So(err, ShouldBeNil)
+
+		run := func(query string, vars map[string]any, allowMutations bool) (map[string]any, []string) {
+			result := e.Execute(context.Background(), gql.Request{Query: query, Variables: vars}, allowMutations)
+			var msgs []string
+			for _, err := range result.Errors {
+				msgs = append(msgs, err.Message)
+			}
+			data, _ := result.Data.(map[string]any)
+			return data, msgs
+		}
+
+		Convey("When several books are requested by id in one query", func() {
+			dune, emma := newBook("Dune", 412), newBook("Emma", 474)
+			var loaded []string
+			m.EXPECT().ReadByIds(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ *common.Logger, ids []string) ([]*book.Book, error) {
+					loaded = ids
+					return []*book.Book{dune, emma}, nil
+				}).Times(1)
+
+			data, errs := run(`query($a: ID!, $b: ID!, $c: ID!) {
+				a: book(id: $a) { title }
+				b: book(id: $b) { title }
+				c: book(id: $c) { title }
+			}`, map[string]any{"a": dune.ID.Hex(), "b": emma.ID.Hex(), "c": primitive.NewObjectID().Hex()}, false)
+
+			Convey("Then they should be loaded with a single query", func() {
+				So(errs, ShouldBeEmpty)
+				So(loaded, ShouldHaveLength, 3)
+				So(data["a"], ShouldResemble, map[string]any{"title": "Dune"})
+				So(data["b"], ShouldResemble, map[string]any{"title": "Emma"})
+				So(data["c"], ShouldBeNil)
+			})
+		})
+
+		Convey("When the books are filtered and paged", func() {
+			m.EXPECT().List(gomock.Any(), book.ListOptions{
+				Skip:   0,
+				Limit:  2,
+				Filter: book.Filter{TitleContains: "du", MinPages: 100},
+			}).Return([]*book.Book{newBook("Dune", 412), newBook("Dune Messiah", 256)}, int64(3), nil)
+
+			data, errs := run(`{
+				books(first: 2, filter: {titleContains: "du", minPages: 100}) {
+					nodes { title }
+					totalCount
+					pageInfo { hasNextPage endCursor }
+				}
+			}`, nil, false)
+
+			Convey("Then the page should report the next one", func() {
+				So(errs, ShouldBeEmpty)
+				books := data["books"].(map[string]any)
+				So(books["nodes"], ShouldHaveLength, 2)
+				So(books["totalCount"], ShouldEqual, 3)
+				So(books["pageInfo"].(map[string]any)["hasNextPage"], ShouldBeTrue)
+				So(books["pageInfo"].(map[string]any)["endCursor"], ShouldNotBeEmpty)
+			})
+		})
+
+		Convey("When a query is nested deeper than the limit", func() {
+			e, err := gql.New(m, s, gql.Limits{MaxDepth: 2})
+			So(err, ShouldBeNil)
+			nested := func(query string) []string {
+				var msgs []string
+				for _, err := range e.Execute(context.Background(), gql.Request{Query: query}, false).Errors {
+					msgs = append(msgs, err.Message)
+				}
+				return msgs
+			}
+
+			Convey("Then it should be rejected, including fields reached through fragments", func() {
+				So(nested(`{ books { nodes { title } } }`), ShouldResemble, []string{"query depth 3 exceeds the maximum of 2"})
+				So(nested(`{ ...F } fragment F on Query { books { ... on BookConnection { nodes { title } } } }`),
+					ShouldResemble, []string{"query depth 3 exceeds the maximum of 2"})
+			})
+
+			Convey("Then introspection should not count", func() {
+				So(nested(`{ __schema { types { fields { type { ofType { name } } } } } }`), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When a query requests too many items", func() {
+			_, errs := run(`{ books(first: 100) { nodes { id title } } }`, nil, false)
+
+			Convey("Then it should be rejected before running", func() {
+				So(errs, ShouldResemble, []string{"query complexity 301 exceeds the maximum of 50"})
+			})
+		})
+
+		Convey("When a query lowers its cost with a negative first", func() {
+			_, errs := run(`{ a: books(first: 100) { nodes { id title } } b: books(first: -1000000) { nodes { id } } }`, nil, false)
+			_, varErrs := run(`query($n: Int) { a: books(first: 100) { nodes { id title } } b: books(first: $n) { nodes { id } } }`,
+				map[string]any{"n": float64(-1000000)}, false)
+
+			Convey("Then the negative first should count as one item", func() {
+				So(errs, ShouldResemble, []string{"query complexity 304 exceeds the maximum of 50"})
+				So(varErrs, ShouldResemble, []string{"query complexity 304 exceeds the maximum of 50"})
+			})
+		})
+
+		Convey("When a mutation is run without being allowed", func() {
+			_, errs := run(`mutation { deleteBook(id: "61733b8e9c483c721f65b21d") }`, nil, false)
+
+			Convey("Then it should be rejected", func() {
+				So(errs, ShouldResemble, []string{gql.ErrMutationNotAllowed.Error()})
+			})
+		})
+
+		Convey("When a book which fails validation is created", func() {
+			m.EXPECT().Create(gomock.Any(), gomock.Any(), book.NewBook("Dune", 412)).
+				Return(nil, fmt.Errorf("%w: book validation failed", book.ErrValidation))
+
+			_, errs := run(`mutation { createBook(input: {title: "Dune", pages: 412}) { id } }`, nil, true)
+
+			Convey("Then the validation error should be reported", func() {
+				So(errs, ShouldResemble, []string{"validation error: book validation failed"})
+			})
+		})
+
+		Convey("When the service fails unexpectedly", func() {
+			m.EXPECT().Delete(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("connection reset"))
+
+			_, errs := run(`mutation { deleteBook(id: "61733b8e9c483c721f65b21d") }`, nil, true)
+
+			Convey("Then the error should not leak to the client", func() {
+				So(errs, ShouldResemble, []string{"server encountered an unknown error"})
+			})
+		})
+	})
+}
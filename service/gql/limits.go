@@ -0,0 +1,119 @@
+package gql
+
+// Depth and complexity limits of the queries.
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Limits bound the cost of an operation; a zero limit is not enforced
+type Limits struct {
+	// MaxDepth is the maximum nesting of the fields
+	MaxDepth int
+	// MaxComplexity is the maximum number of fields resolved, where the fields
+	// of a list count once per requested item
+	MaxComplexity int
+}
+
+// defaultPageSizes holds the page size of the list fields called without first
+var defaultPageSizes = map[string]int{
+	"books": defaultPageSize,
+}
+
+type cost struct {
+	doc       *ast.Document
+	variables map[string]any
+}
+
+// check returns an error if the operation exceeds the limits
+func (l Limits) check(doc *ast.Document, op *ast.OperationDefinition, variables map[string]any) error {
+	c := cost{doc: doc, variables: variables}
+	depth, complexity := c.selections(op.SelectionSet)
+
+	if l.MaxDepth > 0 && depth > l.MaxDepth {
+		return fmt.Errorf("query depth %d exceeds the maximum of %d", depth, l.MaxDepth)
+	}
+	if l.MaxComplexity > 0 && complexity > l.MaxComplexity {
+		return fmt.Errorf("query complexity %d exceeds the maximum of %d", complexity, l.MaxComplexity)
+	}
+	return nil
+}
+
+// selections returns the depth and complexity of a selection set
+func (c cost) selections(set *ast.SelectionSet) (depth, complexity int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	for _, sel := range set.Selections {
+		var d, n int
+		switch sel := sel.(type) {
+		case *ast.Field:
+			// introspection (e.g. by GraphiQL) is nested deeply but cheap
+			if strings.HasPrefix(sel.Name.Value, "__") {
+				continue
+			}
+			d, n = c.selections(sel.SelectionSet)
+			d, n = d+1, 1+c.multiplier(sel)*n
+		case *ast.InlineFragment:
+			d, n = c.selections(sel.SelectionSet)
+		case *ast.FragmentSpread:
+			// fragment cycles are rejected by the validation of the document
+			if frag := c.fragment(sel.Name.Value); frag != nil {
+				d, n = c.selections(frag.SelectionSet)
+			}
+		}
+		if d > depth {
+			depth = d
+		}
+		complexity += n
+	}
+	return
+}
+
+// multiplier returns the number of items requested from a list field, between
+// 1 and maxPageSize: a first out of that range is rejected by the resolver, and
+// must not lower the cost of the other fields
+func (c cost) multiplier(f *ast.Field) int {
+	clamp := func(n int) int {
+		switch {
+		case n < 1:
+			return 1
+		case n > maxPageSize:
+			return maxPageSize
+		}
+		return n
+	}
+	for _, arg := range f.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil {
+				return clamp(n)
+			}
+		case *ast.Variable:
+			if n, ok := c.variables[v.Name.Value].(float64); ok {
+				return clamp(int(n))
+			}
+		}
+	}
+	if n, ok := defaultPageSizes[f.Name.Value]; ok {
+		return n
+	}
+	return 1
+}
+
+func (c cost) fragment(name string) *ast.FragmentDefinition {
+	for _, def := range c.doc.Definitions {
+		if frag, ok := def.(*ast.FragmentDefinition); ok && frag.Name.Value == name {
+			return frag
+		}
+	}
+	return nil
+}
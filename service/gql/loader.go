@@ -0,0 +1,124 @@
+package gql
+
+// Batched loading of the books requested by id within a query.
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+)
+
+type loaderKey struct{}
+
+// bookLoader collects the ids requested while a level of the query is resolved,
+// and loads all of them with a single query once the first result is needed.
+// Loaded books are cached for the rest of the request.
+type bookLoader struct {
+	svc book.BookService
+	log *common.Logger
+
+	mu      sync.Mutex
+	pending []string
+	books   map[string]*book.Book
+	errs    map[string]error
+}
+
+func newBookLoader(svc book.BookService, log *common.Logger) *bookLoader {
+	return &bookLoader{
+		svc:   svc,
+		log:   log,
+		books: make(map[string]*book.Book),
+		errs:  make(map[string]error),
+	}
+}
+
+// withLoader returns a context carrying a loader for the lifetime of a request
+func (r *resolver) withLoader(ctx context.Context) context.Context {
+	return context.WithValue(ctx, loaderKey{}, newBookLoader(r.svc, r.log(ctx)))
+}
+
+// loader returns the loader of the request, or an unshared one outside of a request
+func (r *resolver) loader(ctx context.Context) *bookLoader {
+	if l, ok := ctx.Value(loaderKey{}).(*bookLoader); ok {
+		return l
+	}
+	return newBookLoader(r.svc, r.log(ctx))
+}
+
+// log returns the logger of the request, see common.LoggerFrom
+func (r *resolver) log(ctx context.Context) *common.Logger {
+	return common.LoggerFrom(ctx, r.s.Log)
+}
+
+// Load queues the id and returns a function returning its book, nil if it does not exist
+func (l *bookLoader) Load(id string) func() (*book.Book, error) {
+	l.mu.Lock()
+	if _, done := l.books[id]; !done {
+		l.pending = append(l.pending, id)
+	}
+	l.mu.Unlock()
+
+	return func() (*book.Book, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if _, done := l.books[id]; !done && l.errs[id] == nil {
+			l.flush()
+		}
+		return l.books[id], l.errs[id]
+	}
+}
+
+// Prime caches books loaded by other means
+func (l *bookLoader) Prime(books []*book.Book) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, b := range books {
+		l.books[b.ID.Hex()] = b
+	}
+}
+
+// flush loads the pending ids; the caller holds l.mu
+func (l *bookLoader) flush() {
+	ids := l.pending
+	l.pending = nil
+
+	books, err := l.svc.ReadByIds(l.log, ids)
+	for _, id := range ids {
+		if err != nil {
+			l.errs[id] = errors.New("server encountered an unknown error")
+			continue
+		}
+		// ids without a book resolve to null
+		l.books[id] = nil
+	}
+	for _, b := range books {
+		l.books[b.ID.Hex()] = b
+	}
+}
+
+// cursors are opaque to the clients, they encode the offset of the next page
+func encodeCursor(offset int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(offset, 10)))
+}
+
+func decodeCursor(after any) (int64, error) {
+	cursor, _ := after.(string)
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.ParseInt(string(raw), 10, 64)
+	if err == nil && offset < 0 {
+		err = errors.New("negative offset")
+	}
+	return offset, err
+}
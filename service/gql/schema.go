@@ -0,0 +1,279 @@
+package gql
+
+// GraphQL schema of the catalog, resolved through book.BookService.
+
+import (
+	"errors"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type resolver struct {
+	svc book.BookService
+	s   *common.App
+}
+
+// NewSchema returns the GraphQL schema of the catalog. Types are declared with
+// room for related entities (e.g. authors, inventory) to be added as fields of
+// Book, loaded in batches like the books themselves.
+func NewSchema(svc book.BookService, s *common.App) (graphql.Schema, error) {
+	r := &resolver{svc: svc, s: s}
+
+	bookType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Book",
+		Description: "A book of the catalog",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.ID),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(*book.Book).ID.Hex(), nil
+				},
+			},
+			"title": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(*book.Book).Title, nil
+				},
+			},
+			"pages": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(*book.Book).Pages, nil
+				},
+			},
+			"createdAt": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.DateTime),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(*book.Book).CreatedAt.UTC().Format(time.RFC3339), nil
+				},
+			},
+			"updatedAt": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.DateTime),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(*book.Book).UpdatedAt.UTC().Format(time.RFC3339), nil
+				},
+			},
+		},
+	})
+
+	pageInfoType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"endCursor":   &graphql.Field{Type: graphql.String, Description: "Cursor to pass as after to get the next page"},
+		},
+	})
+
+	bookConnectionType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "BookConnection",
+		Description: "A page of books, ordered by id",
+		Fields: graphql.Fields{
+			"nodes":      &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(bookType)))},
+			"totalCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"pageInfo":   &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+		},
+	})
+
+	bookFilterType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "BookFilter",
+		Description: "Books matching every field which is set",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":         &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Exact title"},
+			"titleContains": &graphql.InputObjectFieldConfig{Type: graphql.String, Description: "Case insensitive part of the title"},
+			"minPages":      &graphql.InputObjectFieldConfig{Type: graphql.Int},
+			"maxPages":      &graphql.InputObjectFieldConfig{Type: graphql.Int},
+		},
+	})
+
+	bookInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "BookInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"pages": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	bookUpdateInputType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "BookUpdateInput",
+		Description: "The fields left out are not changed",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title": &graphql.InputObjectFieldConfig{Type: graphql.String},
+			"pages": &graphql.InputObjectFieldConfig{Type: graphql.Int},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"book": &graphql.Field{
+				Type:        bookType,
+				Description: "A book by id, null if it does not exist",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: r.book,
+			},
+			"books": &graphql.Field{
+				Type:        graphql.NewNonNull(bookConnectionType),
+				Description: "A page of the books matching the filter",
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{Type: bookFilterType},
+					"first": &graphql.ArgumentConfig{
+						Type:         graphql.Int,
+						DefaultValue: defaultPageSize,
+						Description:  "Number of books to return, at most 100",
+					},
+					"after": &graphql.ArgumentConfig{Type: graphql.String, Description: "endCursor of the previous page"},
+				},
+				Resolve: r.books,
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createBook": &graphql.Field{
+				Type: graphql.NewNonNull(bookType),
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(bookInputType)},
+				},
+				Resolve: r.createBook,
+			},
+			"updateBook": &graphql.Field{
+				Type: graphql.NewNonNull(bookType),
+				Args: graphql.FieldConfigArgument{
+					"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(bookUpdateInputType)},
+				},
+				Resolve: r.updateBook,
+			},
+			"deleteBook": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: r.deleteBook,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+// book is batched: the ids requested by sibling fields are loaded with a single query
+func (r *resolver) book(p graphql.ResolveParams) (any, error) {
+	id, _ := p.Args["id"].(string)
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return nil, errors.New("id must be a hex encoded ObjectID")
+	}
+
+	load := r.loader(p.Context).Load(id)
+	return func() (any, error) {
+		b, err := load()
+		if err != nil || b == nil {
+			// a nil *book.Book would not resolve to null
+			return nil, err
+		}
+		return b, nil
+	}, nil
+}
+
+func (r *resolver) books(p graphql.ResolveParams) (any, error) {
+	first, _ := p.Args["first"].(int)
+	if first < 1 || first > maxPageSize {
+		return nil, errors.New("first must be between 1 and 100")
+	}
+
+	offset, err := decodeCursor(p.Args["after"])
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	opts := book.ListOptions{Skip: offset, Limit: int64(first)}
+	if f, ok := p.Args["filter"].(map[string]any); ok {
+		opts.Filter.Title, _ = f["title"].(string)
+		opts.Filter.TitleContains, _ = f["titleContains"].(string)
+		opts.Filter.MinPages, _ = f["minPages"].(int)
+		opts.Filter.MaxPages, _ = f["maxPages"].(int)
+	}
+
+	books, total, err := r.svc.List(r.log(p.Context), opts)
+	if err != nil {
+		return nil, toError(err)
+	}
+
+	// prime the loader, so that the same books requested by id are not queried again
+	r.loader(p.Context).Prime(books)
+
+	pageInfo := map[string]any{"hasNextPage": offset+int64(len(books)) < total}
+	if len(books) > 0 {
+		pageInfo["endCursor"] = encodeCursor(offset + int64(len(books)))
+	}
+	if books == nil {
+		books = []*book.Book{}
+	}
+	return map[string]any{
+		"nodes":      books,
+		"totalCount": total,
+		"pageInfo":   pageInfo,
+	}, nil
+}
+
+// mutations go through the book service, whose Saving hook runs Book.Validate
+
+func (r *resolver) createBook(p graphql.ResolveParams) (any, error) {
+	in, _ := p.Args["input"].(map[string]any)
+	title, _ := in["title"].(string)
+	pages, _ := in["pages"].(int)
+
+	b, err := r.svc.Create(p.Context, r.log(p.Context), book.NewBook(title, pages))
+	if err != nil {
+		return nil, toError(err)
+	}
+	return b, nil
+}
+
+func (r *resolver) updateBook(p graphql.ResolveParams) (any, error) {
+	id, _ := p.Args["id"].(string)
+	in, _ := p.Args["input"].(map[string]any)
+	title, _ := in["title"].(string)
+	pages, _ := in["pages"].(int)
+	if _, set := in["pages"]; set && pages < 1 {
+		return nil, errors.New("pages must be greater than or equal to 1")
+	}
+
+	b, err := r.svc.Update(p.Context, r.log(p.Context), id, book.NewBook(title, pages))
+	if err != nil {
+		return nil, toError(err)
+	}
+	return b, nil
+}
+
+func (r *resolver) deleteBook(p graphql.ResolveParams) (any, error) {
+	id, _ := p.Args["id"].(string)
+	if err := r.svc.Delete(p.Context, r.log(p.Context), id); err != nil {
+		return nil, toError(err)
+	}
+	return true, nil
+}
+
+// toError hides the unexpected errors of the book service from the clients
+func toError(err error) error {
+	switch {
+	case errors.Is(err, book.ErrNotFound):
+		return errors.New("book not found")
+	case errors.Is(err, primitive.ErrInvalidHex), errors.Is(err, book.ErrConflict), errors.Is(err, book.ErrValidation):
+		return err
+	}
+	return errors.New("server encountered an unknown error")
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/snehil-sinha/goBookStore/common"
+)
+
+// LogLevels is the body of the log level endpoints
+type LogLevels struct {
+	Console string `json:"console,omitempty" binding:"omitempty,oneof=debug info warn error"`
+	File    string `json:"file,omitempty" binding:"omitempty,oneof=debug info warn error"`
+}
+
+// GetLogLevelHandler returns the current console and file log levels
+func GetLogLevelHandler(s *common.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, LogLevels{
+			Console: s.Log.Level.String(),
+			File:    s.Log.FileLevel.String(),
+		})
+	}
+}
+
+// SetLogLevelHandler changes the console and/or file log levels until the next
+// restart or config reload
+func SetLogLevelHandler(s *common.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var req LogLevels
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		if err := s.Log.SetLevels(req.Console, req.File); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		s.Log.Sugar().Infof("log levels changed to console=%s file=%s", s.Log.Level, s.Log.FileLevel)
+
+		c.JSON(http.StatusOK, LogLevels{
+			Console: s.Log.Level.String(),
+			File:    s.Log.FileLevel.String(),
+		})
+	}
+}
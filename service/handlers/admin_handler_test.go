@@ -0,0 +1,52 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/service/handlers"
+)
+
+func TestSetLogLevelHandler(t *testing.T) {
+	Convey("Given a SetLogLevelHandler", t, func() {
+		h := handlers.SetLogLevelHandler(s)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		Reset(func() {
+			_ = s.Log.SetLevels("info", "info")
+		})
+
+		Convey("When a valid level is sent for the file sink only", func() {
+			_ = s.Log.SetLevels("info", "info")
+			c.Request = httptest.NewRequest(http.MethodPut, "/admin/loglevel", strings.NewReader(`{"file":"debug"}`))
+			h(c)
+
+			Convey("Then only the file level should change", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				var response handlers.LogLevels
+				So(json.Unmarshal(w.Body.Bytes(), &response), ShouldBeNil)
+				So(response.Console, ShouldEqual, "info")
+				So(response.File, ShouldEqual, "debug")
+				So(s.Log.FileLevel.String(), ShouldEqual, "debug")
+			})
+		})
+
+		Convey("When an unknown level is sent", func() {
+			c.Request = httptest.NewRequest(http.MethodPut, "/admin/loglevel", strings.NewReader(`{"console":"loud"}`))
+			h(c)
+
+			Convey("Then it should return a 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(s.Log.Level.String(), ShouldEqual, "info")
+			})
+		})
+	})
+}
@@ -0,0 +1,101 @@
+package handlers
+
+// Audit log of the changes made to the books.
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BookHistoryHandler fetches the audit records of a book by the specified ID,
+// oldest first
+func BookHistoryHandler(svc book.BookService, s *common.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		records, err := svc.History(requestLog(c, s), c.Param("id"))
+		if err != nil {
+			if errors.Is(err, primitive.ErrInvalidHex) {
+				respond(c, http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			respond(c, http.StatusInternalServerError, gin.H{
+				"error": "server encountered an unknown error",
+			})
+			return
+		}
+		if records == nil {
+			records = []*book.AuditRecord{}
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": records})
+	}
+}
+
+// parseTime returns the RFC 3339 time of a query parameter, zero if it is not set
+func parseTime(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.New(name + " must be an RFC 3339 time, e.g. 2026-01-02T15:04:05Z")
+	}
+	return t, nil
+}
+
+// SearchAuditHandler fetches a page of the audit records, latest first, matching
+// the book_id, actor, request_id, operation and field parameters, and the period
+// from from (inclusive) to to (exclusive)
+func SearchAuditHandler(svc book.BookService, s *common.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		page, perPage, err := parsePage(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter := book.AuditFilter{
+			BookID:    c.Query("book_id"),
+			Actor:     c.Query("actor"),
+			RequestID: c.Query("request_id"),
+			Operation: book.OpType(c.Query("operation")),
+			Field:     c.Query("field"),
+			Skip:      int64(page-1) * int64(perPage),
+			Limit:     int64(perPage),
+		}
+		if filter.From, err = parseTime(c, "from"); err == nil {
+			filter.To, err = parseTime(c, "to")
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		records, total, err := svc.Audit(requestLog(c, s), filter)
+		if err != nil {
+			if errors.Is(err, primitive.ErrInvalidHex) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "book_id: " + err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "server encountered an unknown error"})
+			return
+		}
+		if records == nil {
+			records = []*book.AuditRecord{}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"data": records,
+			"meta": Meta{Total: total, Page: page, PerPage: perPage},
+		})
+	}
+}
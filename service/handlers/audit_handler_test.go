@@ -0,0 +1,126 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/models/book/mocks"
+	"github.com/snehil-sinha/goBookStore/service/handlers"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestAuditHandlers(t *testing.T) {
+	Convey("Given the audit endpoints", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		m := mocks.NewMockBookService(ctrl)
+		r := gin.New()
+		r.GET("/books/:id/history", handlers.BookHistoryHandler(m, s))
+		r.GET("/admin/audit", handlers.SearchAuditHandler(m, s))
+
+		do := func(path string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			return w
+		}
+
+		id := primitive.NewObjectID()
+		update := &book.AuditRecord{
+			BookID:    id,
+			Operation: book.OpUpdate,
+			Actor:     "admin",
+			RequestID: "req-1",
+			Time:      time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+			Changes:   []book.Change{{Field: "pages", Before: 412.0, After: 500.0}},
+		}
+
+		Convey("When the history of a book is fetched", func() {
+			m.EXPECT().History(gomock.Any(), id.Hex()).Return([]*book.AuditRecord{update}, nil)
+			w := do("/books/" + id.Hex() + "/history")
+
+			Convey("Then its records should be returned with their changes", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				var body struct {
+					Data []*book.AuditRecord `json:"data"`
+				}
+				So(json.Unmarshal(w.Body.Bytes(), &body), ShouldBeNil)
+				So(body.Data, ShouldHaveLength, 1)
+				So(body.Data[0].Actor, ShouldEqual, "admin")
+				So(body.Data[0].Changes, ShouldResemble, update.Changes)
+			})
+		})
+
+		Convey("When the history of an invalid id is fetched", func() {
+			m.EXPECT().History(gomock.Any(), "abcd").Return(nil, primitive.ErrInvalidHex)
+
+			Convey("Then it should return a 400 status code", func() {
+				So(do("/books/abcd/history").Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When the audit log is searched", func() {
+			m.EXPECT().Audit(gomock.Any(), book.AuditFilter{
+				Actor:     "admin",
+				Operation: book.OpUpdate,
+				Field:     "pages",
+				From:      time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+				Skip:      10,
+				Limit:     10,
+			}).Return([]*book.AuditRecord{update}, int64(11), nil)
+			w := do("/admin/audit?actor=admin&operation=update&field=pages&from=2026-01-01T00:00:00Z&page=2&per_page=10")
+
+			Convey("Then the page of matching records should be returned", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				var body struct {
+					Data []*book.AuditRecord `json:"data"`
+					Meta handlers.Meta       `json:"meta"`
+				}
+				So(json.Unmarshal(w.Body.Bytes(), &body), ShouldBeNil)
+				So(body.Data, ShouldHaveLength, 1)
+				So(body.Meta, ShouldResemble, handlers.Meta{Total: 11, Page: 2, PerPage: 10})
+			})
+		})
+
+		Convey("When the audit log is searched with an invalid period", func() {
+			w := do("/admin/audit?to=yesterday")
+
+			Convey("Then it should return a 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, "to must be an RFC 3339 time")
+			})
+		})
+	})
+}
+
+func TestDiff(t *testing.T) {
+	Convey("Given a book before and after a change", t, func() {
+		before := book.NewBook("Dune", 412)
+		before.ID = primitive.NewObjectID()
+		after := *before
+		after.Pages = 500
+		after.ISBN = "9780441172719"
+		after.UpdatedAt = time.Now()
+
+		Convey("Then the changed fields should be listed in order, the timestamps aside", func() {
+			So(book.Diff(before, &after), ShouldResemble, []book.Change{
+				{Field: "isbn", Before: nil, After: "9780441172719"},
+				{Field: "pages", Before: 412.0, After: 500.0},
+			})
+		})
+
+		Convey("Then a created book should have every field set", func() {
+			changes := book.Diff(nil, before)
+			So(changes, ShouldHaveLength, 2)
+			So(changes[0], ShouldResemble, book.Change{Field: "pages", After: 412.0})
+			So(changes[1], ShouldResemble, book.Change{Field: "title", After: "Dune"})
+		})
+	})
+}
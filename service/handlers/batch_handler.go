@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Modes of a batch
+const (
+	BatchAtomic     = "atomic"
+	BatchBestEffort = "best_effort"
+)
+
+// BatchRequest is the body of the batch endpoint
+type BatchRequest struct {
+	// Mode is atomic (all or nothing) or best_effort (the default)
+	Mode       string           `json:"mode,omitempty"`
+	Operations []book.Operation `json:"operations"`
+}
+
+// BatchResult is the outcome of an operation of a batch
+type BatchResult struct {
+	Index  int        `json:"index"`
+	Status int        `json:"status"`
+	ID     string     `json:"id,omitempty"`
+	Error  string     `json:"error,omitempty"`
+	Book   *book.Book `json:"book,omitempty"`
+}
+
+// BatchBooksHandler creates, updates and deletes books in a batch. It answers
+// with a 200 if every operation was applied, a 207 otherwise, along with the
+// result of each operation.
+func BatchBooksHandler(svc book.BookService, s *common.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var req BatchRequest
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respond(c, http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		if n, max := len(req.Operations), s.Cfg.Batch.MaxOperations; n == 0 || n > max {
+			respond(c, http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("a batch must have between 1 and %d operations, got %d", max, n),
+			})
+			return
+		}
+		if req.Mode != "" && req.Mode != BatchAtomic && req.Mode != BatchBestEffort {
+			respond(c, http.StatusBadRequest, gin.H{
+				"error": "mode must be one of atomic, best_effort",
+			})
+			return
+		}
+
+		results, err := svc.Batch(c.Request.Context(), requestLog(c, s), req.Operations, req.Mode == BatchAtomic)
+		if err != nil {
+			respond(c, http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		status := http.StatusOK
+		resp := make([]BatchResult, len(results))
+		for i, r := range results {
+			resp[i] = BatchResult{
+				Index:  i,
+				Status: batchStatus(r),
+				ID:     r.ID,
+				Book:   r.Book,
+			}
+			if r.Err != nil {
+				resp[i].Error = r.Err.Error()
+				status = http.StatusMultiStatus
+			}
+		}
+		respond(c, status, gin.H{"data": resp})
+	}
+}
+
+// batchStatus returns the HTTP status of the result of an operation
+func batchStatus(r book.Result) int {
+	switch {
+	case r.Err == nil && r.Op == book.OpCreate:
+		return http.StatusCreated
+	case r.Err == nil:
+		return http.StatusOK
+	case errors.Is(r.Err, book.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(r.Err, book.ErrDuplicate), errors.Is(r.Err, book.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(r.Err, book.ErrNotApplied):
+		return http.StatusFailedDependency
+	case errors.Is(r.Err, primitive.ErrInvalidHex), errors.Is(r.Err, book.ErrValidation):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,95 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/models/book/mocks"
+	"github.com/snehil-sinha/goBookStore/service/handlers"
+)
+
+func TestBatchBooksHandler(t *testing.T) {
+	Convey("Given a BatchBooksHandler", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		m := mocks.NewMockBookService(ctrl)
+		h := handlers.BatchBooksHandler(m, s)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		decode := func() []handlers.BatchResult {
+			var response struct {
+				Data []handlers.BatchResult `json:"data"`
+			}
+			So(json.Unmarshal(w.Body.Bytes(), &response), ShouldBeNil)
+			return response.Data
+		}
+
+		Convey("When some operations of a best effort batch fail", func() {
+			m.EXPECT().Batch(gomock.Any(), gomock.Any(), gomock.Len(5), false).Return([]book.Result{
+				{Op: book.OpCreate, ID: "61733b8e9c483c721f65b21d"},
+				{Op: book.OpCreate, Err: book.ErrDuplicate},
+				{Op: book.OpUpdate, Err: book.ErrNotFound},
+				{Op: book.OpDelete, Err: errors.New("connection reset")},
+				{Op: book.OpUpdate, Err: book.ErrConflict},
+			}, nil)
+
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/books:batch", strings.NewReader(`{"operations": [
+				{"op": "create", "book": {"title": "Dune", "pages": 412}},
+				{"op": "create", "book": {"title": "Dune", "pages": 412}},
+				{"op": "update", "id": "61733b8e9c483c721f65b21e", "book": {"pages": 1}},
+				{"op": "delete", "id": "61733b8e9c483c721f65b21f"},
+				{"op": "update", "id": "61733b8e9c483c721f65b220", "book": {"pages": 2}}
+			]}`))
+			h(c)
+
+			Convey("Then each operation should report its own status", func() {
+				So(w.Code, ShouldEqual, http.StatusMultiStatus)
+				results := decode()
+				So(results, ShouldHaveLength, 5)
+				So(results[0].Status, ShouldEqual, http.StatusCreated)
+				So(results[1].Status, ShouldEqual, http.StatusConflict)
+				So(results[2].Status, ShouldEqual, http.StatusNotFound)
+				So(results[3].Status, ShouldEqual, http.StatusInternalServerError)
+				So(results[3].Index, ShouldEqual, 3)
+				So(results[4].Status, ShouldEqual, http.StatusConflict)
+			})
+		})
+
+		Convey("When an atomic batch is fully applied", func() {
+			m.EXPECT().Batch(gomock.Any(), gomock.Any(), gomock.Len(1), true).Return([]book.Result{
+				{Op: book.OpDelete, ID: "61733b8e9c483c721f65b21d"},
+			}, nil)
+
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/books:batch",
+				strings.NewReader(`{"mode": "atomic", "operations": [{"op": "delete", "id": "61733b8e9c483c721f65b21d"}]}`))
+			h(c)
+
+			Convey("Then it should return a 200 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(decode()[0].Status, ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("When a batch has more operations than allowed", func() {
+			ops := strings.Repeat(`{"op": "delete", "id": "61733b8e9c483c721f65b21d"},`, s.Cfg.Batch.MaxOperations+1)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/books:batch",
+				strings.NewReader(`{"operations": [`+strings.TrimSuffix(ops, ",")+`]}`))
+			h(c)
+
+			Convey("Then it should return a 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
@@ -9,6 +10,16 @@ import (
 	"github.com/snehil-sinha/goBookStore/models/book"
 )
 
+// requestLog returns the logger of the request, tagged with its id and actor; the
+// book service reads them from the context of the request, see
+// common.IdentityFrom
+func requestLog(c *gin.Context, s *common.App) *common.Logger {
+	if c.Request == nil {
+		return s.Log
+	}
+	return common.LoggerFrom(c.Request.Context(), s.Log)
+}
+
 // Used to ping
 func PingHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -24,19 +35,20 @@ func FindBooksHandler(svc book.BookService, s *common.App) gin.HandlerFunc {
 			resp []*book.Book
 		)
 
-		resp, err = svc.ReadAll(s.Log)
+		resp, err = svc.ReadAll(requestLog(c, s))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
+			respond(c, http.StatusInternalServerError, gin.H{
 				"error": "server encountered an unknown error",
 			})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"data": resp})
+		respond(c, http.StatusOK, gin.H{"data": resp})
 	}
 }
 
-// FindBookHandler fetches the book (if present) by the specified ID
+// FindBookHandler fetches the book (if present) by the specified ID, as it was
+// at the as_of time if set
 func FindBookHandler(svc book.BookService, s *common.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
@@ -47,32 +59,43 @@ func FindBookHandler(svc book.BookService, s *common.App) gin.HandlerFunc {
 			resp *book.Book
 		)
 
-		resp, err = svc.ReadById(s.Log, queryParam)
+		asOf, err := parseTime(c, "as_of")
+		if err != nil {
+			respond(c, http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		if asOf.IsZero() {
+			resp, err = svc.ReadById(requestLog(c, s), queryParam)
+		} else {
+			resp, err = svc.ReadAsOf(requestLog(c, s), queryParam, asOf)
+		}
 		if err != nil {
 			if strings.EqualFold("the provided hex string is not a valid ObjectID", err.Error()) {
-				c.JSON(http.StatusBadRequest, gin.H{
+				respond(c, http.StatusBadRequest, gin.H{
 					"error": err.Error(),
 				})
 				return
 			}
 			if strings.EqualFold("mongo: no documents in result", err.Error()) {
-				c.JSON(http.StatusNotFound, gin.H{
+				respond(c, http.StatusNotFound, gin.H{
 					"error": err.Error(),
 				})
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{
+			respond(c, http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"data": resp})
+		respond(c, http.StatusOK, gin.H{"data": resp})
 	}
 }
 
 // CreateBookHandler creates a new book
-func CreateBookHandler(s *common.App) gin.HandlerFunc {
+func CreateBookHandler(svc book.BookService, s *common.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		var (
@@ -81,31 +104,31 @@ func CreateBookHandler(s *common.App) gin.HandlerFunc {
 		)
 
 		if err = c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
+			respond(c, http.StatusBadRequest, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
 
-		book, err := book.Create(s.Log, &req)
+		book, err := svc.Create(c.Request.Context(), requestLog(c, s), &req)
 		if err != nil {
 			if strings.Contains(err.Error(), "validation") {
-				c.JSON(http.StatusBadRequest, gin.H{
+				respond(c, http.StatusBadRequest, gin.H{
 					"error": err.Error(),
 				})
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{
+			respond(c, http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-		c.JSON(http.StatusCreated, book)
+		respond(c, http.StatusCreated, book)
 	}
 }
 
 // UpdateBookHandler updates a book (if present) by the specified ID
-func UpdateBookHandler(s *common.App) gin.HandlerFunc {
+func UpdateBookHandler(svc book.BookService, s *common.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		id := c.Param("id")
@@ -116,62 +139,67 @@ func UpdateBookHandler(s *common.App) gin.HandlerFunc {
 			rsp *book.Book
 		)
 
-		err = c.BindJSON(&req)
+		err = c.ShouldBindJSON(&req)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
+			respond(c, http.StatusBadRequest, gin.H{
 				"error": "error parsing the request body: " + err.Error(),
 			})
 			return
 		}
 
-		rsp, err = book.Update(s.Log, id, &req)
+		rsp, err = svc.Update(c.Request.Context(), requestLog(c, s), id, &req)
 		if err != nil {
 			if strings.EqualFold("the provided hex string is not a valid ObjectID", err.Error()) {
-				c.JSON(http.StatusBadRequest, gin.H{
+				respond(c, http.StatusBadRequest, gin.H{
 					"error": err.Error(),
 				})
 				return
 			}
 			if strings.EqualFold("mongo: no documents in result", err.Error()) {
-				c.JSON(http.StatusNotFound, gin.H{
+				respond(c, http.StatusNotFound, gin.H{
 					"error": err.Error(),
 				})
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{
+			if errors.Is(err, book.ErrConflict) {
+				respond(c, http.StatusConflict, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			respond(c, http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-		c.JSON(http.StatusOK, rsp)
+		respond(c, http.StatusOK, rsp)
 	}
 }
 
-// DeleteBookHandler deletes a book (if present) by its specified id
-func DeleteBookHandler(s *common.App) gin.HandlerFunc {
+// DeleteBookHandler deletes a book (if present) by its specified id, moving it to
+// the trash unless hard is set
+func DeleteBookHandler(svc book.BookService, s *common.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
-		id := c.Param("id")
-
-		if err := book.Delete(s.Log, id); err != nil {
+		if err := deleteBook(c, svc, s); err != nil {
 			if strings.EqualFold("the provided hex string is not a valid ObjectID", err.Error()) {
-				c.JSON(http.StatusBadRequest, gin.H{
+				respond(c, http.StatusBadRequest, gin.H{
 					"error": err.Error(),
 				})
 				return
 			}
 			if strings.EqualFold("mongo: no documents in result", err.Error()) {
-				c.JSON(http.StatusNotFound, gin.H{
+				respond(c, http.StatusNotFound, gin.H{
 					"error": err.Error(),
 				})
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{
+			respond(c, http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
 
-		c.JSON(http.StatusOK, "yes bro.")
+		respond(c, http.StatusOK, "yes bro.")
 	}
 }
@@ -0,0 +1,193 @@
+package handlers
+
+// Book endpoints of the v2 API, answering with an Envelope.
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Page sizes of the v2 book list
+const (
+	DefaultPerPage = 20
+	MaxPerPage     = 100
+)
+
+// bookStatus returns the HTTP status of an error of the book service. A
+// duplicate is a validation error as well, and is matched first.
+func bookStatus(err error) int {
+	switch {
+	case errors.Is(err, book.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, book.ErrDuplicate), errors.Is(err, book.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, primitive.ErrInvalidHex), errors.Is(err, book.ErrValidation):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// collectionPath returns the path of the collection of the route of c, e.g.
+// /api/v2/books for /api/v2/books/:id
+func collectionPath(c *gin.Context) string {
+	return strings.TrimSuffix(c.FullPath(), "/:id")
+}
+
+// bookEnvelope returns the Envelope of a book of the collection
+func bookEnvelope(collection string, b *book.Book) Envelope {
+	return Envelope{
+		Data: b,
+		Links: &Links{
+			Self:       collection + "/" + b.ID.Hex(),
+			Collection: collection,
+		},
+	}
+}
+
+// pageLink returns the link of a page of the collection
+func pageLink(collection string, page, perPage int) string {
+	return fmt.Sprintf("%s?page=%d&per_page=%d", collection, page, perPage)
+}
+
+// parsePage returns the page (from 1) and the page size given by the page and
+// per_page parameters
+func parsePage(c *gin.Context) (page, perPage int, err error) {
+	page, err = strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		return 0, 0, errors.New("page must be a positive integer")
+	}
+	perPage, err = strconv.Atoi(c.DefaultQuery("per_page", strconv.Itoa(DefaultPerPage)))
+	if err != nil || perPage < 1 || perPage > MaxPerPage {
+		return 0, 0, fmt.Errorf("per_page must be between 1 and %d", MaxPerPage)
+	}
+	return page, perPage, nil
+}
+
+// ListBooksV2Handler fetches a page of books, given by the page (from 1) and
+// per_page parameters, with the links of the pages around it
+func ListBooksV2Handler(svc book.BookService, s *common.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		page, perPage, err := parsePage(c)
+		if err != nil {
+			respond(c, http.StatusBadRequest, Envelope{Error: err.Error()})
+			return
+		}
+
+		books, total, err := svc.List(requestLog(c, s), book.ListOptions{
+			Skip:  int64(page-1) * int64(perPage),
+			Limit: int64(perPage),
+		})
+		if err != nil {
+			respond(c, http.StatusInternalServerError, Envelope{Error: "server encountered an unknown error"})
+			return
+		}
+		if books == nil {
+			books = []*book.Book{}
+		}
+
+		collection := collectionPath(c)
+		links := &Links{
+			Self:       pageLink(collection, page, perPage),
+			Collection: collection,
+		}
+		if int64(page)*int64(perPage) < total {
+			links.Next = pageLink(collection, page+1, perPage)
+		}
+		if page > 1 {
+			// pages past the end lead back to the last one
+			last := int((total + int64(perPage) - 1) / int64(perPage))
+			if last < 1 {
+				last = 1
+			}
+			prev := page - 1
+			if prev > last {
+				prev = last
+			}
+			links.Prev = pageLink(collection, prev, perPage)
+		}
+
+		respond(c, http.StatusOK, Envelope{
+			Data:  books,
+			Links: links,
+			Meta:  &Meta{Total: total, Page: page, PerPage: perPage},
+		})
+	}
+}
+
+// FindBookV2Handler fetches the book (if present) by the specified ID
+func FindBookV2Handler(svc book.BookService, s *common.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		b, err := svc.ReadById(requestLog(c, s), c.Param("id"))
+		if err != nil {
+			respond(c, bookStatus(err), Envelope{Error: err.Error()})
+			return
+		}
+		respond(c, http.StatusOK, bookEnvelope(collectionPath(c), b))
+	}
+}
+
+// CreateBookV2Handler creates a new book, answering with a 201 and its
+// location
+func CreateBookV2Handler(svc book.BookService, s *common.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var req book.Book
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respond(c, http.StatusBadRequest, Envelope{Error: err.Error()})
+			return
+		}
+
+		b, err := svc.Create(c.Request.Context(), requestLog(c, s), &req)
+		if err != nil {
+			respond(c, bookStatus(err), Envelope{Error: err.Error()})
+			return
+		}
+
+		env := bookEnvelope(collectionPath(c), b)
+		c.Header("Location", env.Links.Self)
+		respond(c, http.StatusCreated, env)
+	}
+}
+
+// UpdateBookV2Handler updates the fields set in the body of a book (if present)
+// by the specified ID
+func UpdateBookV2Handler(svc book.BookService, s *common.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var req book.Book
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respond(c, http.StatusBadRequest, Envelope{Error: "error parsing the request body: " + err.Error()})
+			return
+		}
+
+		b, err := svc.Update(c.Request.Context(), requestLog(c, s), c.Param("id"), &req)
+		if err != nil {
+			respond(c, bookStatus(err), Envelope{Error: err.Error()})
+			return
+		}
+		respond(c, http.StatusOK, bookEnvelope(collectionPath(c), b))
+	}
+}
+
+// DeleteBookV2Handler deletes a book (if present) by the specified ID, moving it
+// to the trash unless hard is set, and answers with a 204
+func DeleteBookV2Handler(svc book.BookService, s *common.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		if err := deleteBook(c, svc, s); err != nil {
+			respond(c, bookStatus(err), Envelope{Error: err.Error()})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
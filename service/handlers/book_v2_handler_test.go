@@ -0,0 +1,186 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/models/book/mocks"
+	"github.com/snehil-sinha/goBookStore/service/handlers"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBookV2Handlers(t *testing.T) {
+	Convey("Given the v2 book endpoints", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		m := mocks.NewMockBookService(ctrl)
+		r := gin.New()
+		v2 := r.Group("/api/v2")
+		v2.GET("/books", handlers.Negotiate(handlers.V2CollectionFormats...), handlers.ListBooksV2Handler(m, s))
+		v2.GET("/books/:id", handlers.Negotiate(handlers.V2Formats...), handlers.FindBookV2Handler(m, s))
+		v2.POST("/books", handlers.Negotiate(handlers.V2Formats...), handlers.CreateBookV2Handler(m, s))
+		v2.PUT("/books/:id", handlers.Negotiate(handlers.V2Formats...), handlers.UpdateBookV2Handler(m, s))
+		v2.DELETE("/books/:id", handlers.Negotiate(handlers.V2Formats...), handlers.DeleteBookV2Handler(m, s))
+
+		do := func(method, path, accept string, body io.Reader) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(method, path, body)
+			req.Header.Set("Accept", accept)
+			req.Header.Set("Content-Type", "application/json")
+			r.ServeHTTP(w, req)
+			return w
+		}
+		decode := func(w *httptest.ResponseRecorder) map[string]any {
+			var body map[string]any
+			So(json.Unmarshal(w.Body.Bytes(), &body), ShouldBeNil)
+			return body
+		}
+
+		dune := book.NewBook("Dune", 412)
+		dune.ID = primitive.NewObjectID()
+		self := "/api/v2/books/" + dune.ID.Hex()
+
+		Convey("When a page in the middle of the books is fetched", func() {
+			m.EXPECT().List(gomock.Any(), book.ListOptions{Skip: 1, Limit: 1}).Return([]*book.Book{dune}, int64(3), nil)
+			w := do(http.MethodGet, "/api/v2/books?page=2&per_page=1", "", nil)
+
+			Convey("Then it should link to the pages around it", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				var env struct {
+					Data  []*book.Book   `json:"data"`
+					Links handlers.Links `json:"links"`
+					Meta  handlers.Meta  `json:"meta"`
+				}
+				So(json.Unmarshal(w.Body.Bytes(), &env), ShouldBeNil)
+				So(env.Data, ShouldHaveLength, 1)
+				So(env.Links, ShouldResemble, handlers.Links{
+					Self:       "/api/v2/books?page=2&per_page=1",
+					Collection: "/api/v2/books",
+					Next:       "/api/v2/books?page=3&per_page=1",
+					Prev:       "/api/v2/books?page=1&per_page=1",
+				})
+				So(env.Meta, ShouldResemble, handlers.Meta{Total: 3, Page: 2, PerPage: 1})
+			})
+		})
+
+		Convey("When the page size is too large", func() {
+			w := do(http.MethodGet, "/api/v2/books?per_page=1000", "", nil)
+
+			Convey("Then it should return a 400 status code in the envelope", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(decode(w)["error"], ShouldNotBeEmpty)
+			})
+		})
+
+		Convey("When a book is fetched", func() {
+			m.EXPECT().ReadById(gomock.Any(), dune.ID.Hex()).Return(dune, nil)
+			w := do(http.MethodGet, self, "", nil)
+
+			Convey("Then it should be returned with its links", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(decode(w)["links"], ShouldResemble, map[string]any{"self": self, "collection": "/api/v2/books"})
+			})
+		})
+
+		Convey("When a book is created", func() {
+			m.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(dune, nil)
+			w := do(http.MethodPost, "/api/v2/books", "", strings.NewReader(`{"title": "Dune", "pages": 412}`))
+
+			Convey("Then its location should be returned with a 201", func() {
+				So(w.Code, ShouldEqual, http.StatusCreated)
+				So(w.Header().Get("Location"), ShouldEqual, self)
+				So(decode(w)["data"], ShouldNotBeNil)
+			})
+		})
+
+		Convey("When a book already in the catalog is created", func() {
+			m.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, book.ErrDuplicate)
+			w := do(http.MethodPost, "/api/v2/books", "", strings.NewReader(`{"title": "Dune", "pages": 412}`))
+
+			Convey("Then it should return a 409 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusConflict)
+			})
+		})
+
+		Convey("When an invalid book is created", func() {
+			m.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(nil, fmt.Errorf("%w: book validation failed", book.ErrValidation))
+			w := do(http.MethodPost, "/api/v2/books", "", strings.NewReader(`{"title": "Dune"}`))
+
+			Convey("Then it should return a 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When a book is deleted", func() {
+			m.EXPECT().Delete(gomock.Any(), gomock.Any(), dune.ID.Hex()).Return(nil)
+			w := do(http.MethodDelete, self, "", nil)
+
+			Convey("Then it should return a 204 without a body", func() {
+				So(w.Code, ShouldEqual, http.StatusNoContent)
+				So(w.Body.Len(), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When a book changed by another request is updated", func() {
+			m.EXPECT().Update(gomock.Any(), gomock.Any(), dune.ID.Hex(), gomock.Any()).Return(nil, book.ErrConflict)
+			w := do(http.MethodPut, self, "", strings.NewReader(`{"pages": 500}`))
+
+			Convey("Then it should return a 409 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusConflict)
+			})
+		})
+
+		Convey("When the books are fetched in HAL", func() {
+			m.EXPECT().List(gomock.Any(), gomock.Any()).Return([]*book.Book{dune}, int64(1), nil)
+			w := do(http.MethodGet, "/api/v2/books", "application/hal+json", nil)
+
+			Convey("Then they should be embedded with their links", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "application/hal+json")
+				body := decode(w)
+				books := body["_embedded"].(map[string]any)["books"].([]any)
+				So(books, ShouldHaveLength, 1)
+				So(books[0].(map[string]any)["_links"], ShouldResemble, map[string]any{"self": map[string]any{"href": self}})
+				So(body["_links"], ShouldResemble, map[string]any{"self": map[string]any{"href": "/api/v2/books?page=1&per_page=20"}})
+				So(body["total"], ShouldEqual, 1)
+			})
+		})
+
+		Convey("When a book is fetched in JSON:API", func() {
+			m.EXPECT().ReadById(gomock.Any(), dune.ID.Hex()).Return(dune, nil)
+			w := do(http.MethodGet, self, "application/vnd.api+json", nil)
+
+			Convey("Then it should be a resource object", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "application/vnd.api+json")
+				data := decode(w)["data"].(map[string]any)
+				So(data["type"], ShouldEqual, "books")
+				So(data["id"], ShouldEqual, dune.ID.Hex())
+				So(data["attributes"].(map[string]any)["title"], ShouldEqual, "Dune")
+				So(data["attributes"], ShouldNotContainKey, "id")
+			})
+		})
+
+		Convey("When a missing book is fetched in JSON:API", func() {
+			m.EXPECT().ReadById(gomock.Any(), dune.ID.Hex()).Return(nil, book.ErrNotFound)
+			w := do(http.MethodGet, self, "application/vnd.api+json", nil)
+
+			Convey("Then the error should be reported in the errors member", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+				errs := decode(w)["errors"].([]any)
+				So(errs[0].(map[string]any)["status"], ShouldEqual, "404")
+			})
+		})
+	})
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/snehil-sinha/goBookStore/catalog"
+	"github.com/snehil-sinha/goBookStore/catalog/marc"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"go.uber.org/zap"
+)
+
+// Number of books written between two flushes of an export
+const exportFlushEvery = 500
+
+// ExportBooksHandler streams the books matching the title, title_contains,
+// min_pages, max_pages and isbn parameters in the format of the format parameter
+// (csv by default), reading them from a cursor
+func ExportBooksHandler(svc book.BookService, s *common.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		format := c.DefaultQuery("format", catalog.CSV)
+
+		w, contentType, extension, err := newExportWriter(format, c.Writer)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		filter, err := exportFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="books.%s"`, extension))
+		c.Status(http.StatusOK)
+
+		n := 0
+		err = svc.Each(requestLog(c, s), filter, func(b *book.Book) error {
+			if err := w.Write(b); err != nil {
+				return err
+			}
+			if n++; n%exportFlushEvery == 0 {
+				if err := w.Flush(); err != nil {
+					return err
+				}
+				c.Writer.Flush()
+			}
+			return nil
+		})
+		if err == nil {
+			err = w.Close()
+		}
+		if err == nil {
+			return
+		}
+
+		if c.Writer.Written() {
+			// the status is sent already, cut the export short
+			requestLog(c, s).Error("export interrupted", zap.Int("books", n), zap.Error(err))
+			c.Abort()
+			return
+		}
+		c.Header("Content-Type", "")
+		c.Header("Content-Disposition", "")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "server encountered an unknown error",
+		})
+	}
+}
+
+// newExportWriter returns the writer of an export format, with the content
+// type and extension of its files
+func newExportWriter(format string, w io.Writer) (catalog.Writer, string, string, error) {
+	if _, ok := marc.ContentTypes[format]; ok {
+		mw, err := marc.NewWriter(format, w)
+		return mw, marc.ContentTypes[format], marc.Extensions[format], err
+	}
+	cw, err := catalog.NewWriter(format, w)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("unsupported format %q, must be one of %s", format, strings.Join(ExportFormats(), ", "))
+	}
+	return cw, catalog.ContentTypes[format], format, nil
+}
+
+// ExportFormats lists the formats of the exports
+func ExportFormats() []string {
+	return append(append([]string{}, catalog.Formats...), marc.Formats...)
+}
+
+// exportFilter returns the filter of the books to export
+func exportFilter(c *gin.Context) (f book.Filter, err error) {
+	return filterOf(c.Request.URL.Query())
+}
+
+// filterOf returns the filter of the books selected by the title, title_contains,
+// isbn, min_pages and max_pages parameters
+func filterOf(params url.Values) (f book.Filter, err error) {
+	f.Title = params.Get("title")
+	f.TitleContains = params.Get("title_contains")
+	f.ISBN = params.Get("isbn")
+	for name, bound := range map[string]*int{"min_pages": &f.MinPages, "max_pages": &f.MaxPages} {
+		if v := params.Get(name); v != "" {
+			if *bound, err = strconv.Atoi(v); err != nil {
+				return f, fmt.Errorf("%s must be an integer, got %q", name, v)
+			}
+		}
+	}
+	return
+}
+
+// ImportBooksHandler imports a CSV or JSON Lines file, sent as the file field of
+// a multipart form or as the body. The format is taken from the format parameter,
+// or else from the file extension or the content type. See catalog.Import for the
+// key and dry_run parameters; map parameters ("Book Title=title") map the CSV
+// headers to the fields of the books.
+func ImportBooksHandler(svc book.BookService, s *common.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		body, filename, err := upload(c, s)
+		if err != nil {
+			respond(c, http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		defer body.Close()
+
+		format := c.Query("format")
+		if format == "" && filename != "" {
+			format = formatOfExtension(filename)
+		}
+		if format == "" {
+			format = formatOfContentType(c.ContentType())
+		}
+
+		mapping := map[string]string{}
+		for _, m := range c.QueryArray("map") {
+			header, field, ok := strings.Cut(m, "=")
+			if !ok {
+				respond(c, http.StatusBadRequest, gin.H{
+					"error": fmt.Sprintf("map must be of the form header=field, got %q", m),
+				})
+				return
+			}
+			mapping[header] = field
+		}
+
+		r, err := catalog.NewReader(format, body, mapping)
+		if err != nil {
+			respond(c, http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		report, err := catalog.Import(c.Request.Context(), requestLog(c, s), svc, r, catalog.ImportOptions{
+			Key:    c.Query("key"),
+			DryRun: c.Query("dry_run") == "true",
+		})
+		if err != nil {
+			status := http.StatusInternalServerError
+			var tooLarge *http.MaxBytesError
+			if report == nil || errors.As(err, &tooLarge) {
+				status = http.StatusBadRequest
+			}
+			respond(c, status, gin.H{
+				"error": err.Error(),
+				"data":  report,
+			})
+			return
+		}
+		respond(c, http.StatusOK, gin.H{"data": report})
+	}
+}
+
+// upload returns the uploaded file, the file field of a multipart form or else
+// the body, and its name if any. Its size is limited by import.max_size_mb.
+func upload(c *gin.Context, s *common.App) (io.ReadCloser, string, error) {
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(s.Cfg.Import.MaxSizeMB)<<20)
+
+	if c.ContentType() != "multipart/form-data" {
+		return c.Request.Body, "", nil
+	}
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return nil, "", fmt.Errorf("a file field is required: %s", err)
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	return f, fh.Filename, nil
+}
+
+func formatOfExtension(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return catalog.CSV
+	case ".jsonl", ".ndjson":
+		return catalog.JSONL
+	}
+	return ""
+}
+
+func formatOfContentType(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return catalog.CSV
+	case "application/x-ndjson", "application/jsonl":
+		return catalog.JSONL
+	}
+	return ""
+}
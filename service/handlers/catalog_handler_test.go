@@ -0,0 +1,170 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/catalog"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/models/book/mocks"
+	"github.com/snehil-sinha/goBookStore/service/handlers"
+)
+
+func TestExportBooksHandler(t *testing.T) {
+	Convey("Given an ExportBooksHandler", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		m := mocks.NewMockBookService(ctrl)
+		h := handlers.ExportBooksHandler(m, s)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		Convey("When the books are exported as JSON Lines", func() {
+			m.EXPECT().Each(gomock.Any(), book.Filter{}, gomock.Any()).
+				DoAndReturn(func(_ *common.Logger, _ book.Filter, fn func(*book.Book) error) error {
+					for _, b := range []*book.Book{book.NewBook("Dune", 412), book.NewBook("Emma", 474)} {
+						if err := fn(b); err != nil {
+							return err
+						}
+					}
+					return nil
+				})
+
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/books/export?format=jsonl", nil)
+			h(c)
+
+			Convey("Then every book should be written on its own line", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "application/x-ndjson")
+				So(w.Header().Get("Content-Disposition"), ShouldEqual, `attachment; filename="books.jsonl"`)
+				So(strings.Count(w.Body.String(), "\n"), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When the books matching a query are exported as MARCXML", func() {
+			m.EXPECT().Each(gomock.Any(), book.Filter{TitleContains: "dune", MinPages: 100}, gomock.Any()).
+				DoAndReturn(func(_ *common.Logger, _ book.Filter, fn func(*book.Book) error) error {
+					return fn(book.NewBook("Dune", 412))
+				})
+
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/books/export?format=marcxml&title_contains=dune&min_pages=100", nil)
+			h(c)
+
+			Convey("Then a MARCXML collection should be written", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "application/marcxml+xml")
+				So(w.Header().Get("Content-Disposition"), ShouldEqual, `attachment; filename="books.xml"`)
+				So(w.Body.String(), ShouldContainSubstring, `<subfield code="a">Dune</subfield>`)
+				So(w.Body.String(), ShouldEndWith, "</collection>\n")
+			})
+		})
+
+		Convey("When the query is invalid", func() {
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/books/export?min_pages=many", nil)
+			h(c)
+
+			Convey("Then it should return a 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When the books cannot be read", func() {
+			m.EXPECT().Each(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("connection reset"))
+
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/books/export", nil)
+			h(c)
+
+			Convey("Then it should return a 500 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+				So(w.Header().Get("Content-Disposition"), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When the format is not supported", func() {
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/books/export?format=xml", nil)
+			h(c)
+
+			Convey("Then it should return a 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
+
+func TestImportBooksHandler(t *testing.T) {
+	Convey("Given an ImportBooksHandler", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		m := mocks.NewMockBookService(ctrl)
+		h := handlers.ImportBooksHandler(m, s)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		decode := func() *catalog.Report {
+			var response struct {
+				Data *catalog.Report `json:"data"`
+			}
+			So(json.Unmarshal(w.Body.Bytes(), &response), ShouldBeNil)
+			return response.Data
+		}
+
+		Convey("When a CSV file with custom headers is uploaded", func() {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			fw, err := mw.CreateFormFile("file", "books.csv")
+			So(err, ShouldBeNil)
+			_, _ = fw.Write([]byte("Name,Pages\nDune,412\n"))
+			So(mw.Close(), ShouldBeNil)
+
+			m.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, int64(0), nil)
+			m.EXPECT().Create(gomock.Any(), gomock.Any(), book.NewBook("Dune", 412)).Return(book.NewBook("Dune", 412), nil)
+
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/books/import?map=Name=title", &body)
+			c.Request.Header.Set("Content-Type", mw.FormDataContentType())
+			h(c)
+
+			Convey("Then the format should be taken from the file name", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(decode().Created, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When a JSON Lines body is sent for a dry run", func() {
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/books/import?dry_run=true&key=id",
+				strings.NewReader(`{"title": ""}`+"\n"))
+			c.Request.Header.Set("Content-Type", "application/x-ndjson")
+			h(c)
+
+			Convey("Then the invalid records should be reported", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				report := decode()
+				So(report.DryRun, ShouldBeTrue)
+				So(report.Invalid, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When the format cannot be told", func() {
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/books/import", strings.NewReader("Dune"))
+			c.Request.Header.Set("Content-Type", "text/plain")
+			h(c)
+
+			Convey("Then it should return a 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
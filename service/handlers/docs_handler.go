@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/snehil-sinha/goBookStore/service/openapi"
+)
+
+//go:generate curl -sSfL -o static/vendor/redoc.standalone.js https://cdn.redoc.ly/redoc/v2.1.3/bundles/redoc.standalone.js
+
+//go:embed static/docs.html
+var docsTemplate string
+
+//go:embed static/vendor
+var vendor embed.FS
+
+// RedocPath is the path of the Redoc bundle served with the docs
+const RedocPath = "/docs/redoc.standalone.js"
+
+// redocCDN is the Redoc bundle loaded by the docs when it is not vendored, see
+// static/vendor/README.md
+const redocCDN = "https://cdn.redoc.ly/redoc/v2.1.3/bundles/redoc.standalone.js"
+
+// redocBundle returns the vendored Redoc bundle, nil if it is missing
+func redocBundle() []byte {
+	data, _ := vendor.ReadFile("static/vendor/redoc.standalone.js")
+	return data
+}
+
+// OpenAPIHandler serves the OpenAPI document of the service
+func OpenAPIHandler(doc *openapi.Document) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, doc)
+	}
+}
+
+// DocsHandler serves the API reference page rendering the OpenAPI document, with
+// the Redoc bundle served at RedocPath, or from its CDN if it is not vendored
+func DocsHandler() gin.HandlerFunc {
+	src := redocCDN
+	if redocBundle() != nil {
+		src = RedocPath
+	}
+	var page bytes.Buffer
+	_ = template.Must(template.New("docs").Parse(docsTemplate)).Execute(&page, map[string]string{"Redoc": src})
+
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+	}
+}
+
+// RedocHandler serves the vendored Redoc bundle of the docs, or a 404 if it is
+// missing
+func RedocHandler() gin.HandlerFunc {
+	bundle := redocBundle()
+
+	return func(c *gin.Context) {
+		if bundle == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "the Redoc bundle is not vendored, see service/handlers/static/vendor/README.md",
+			})
+			return
+		}
+		c.Header("Cache-Control", "public, max-age=86400")
+		c.Data(http.StatusOK, "text/javascript; charset=utf-8", bundle)
+	}
+}
@@ -0,0 +1,175 @@
+package handlers
+
+// Envelope of the v2 responses, and its HAL and JSON:API renderings.
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/snehil-sinha/goBookStore/models/book"
+)
+
+// Envelope is the body of every v2 response: the data or the error, along with
+// the links of the resource
+type Envelope struct {
+	Data  any    `json:"data,omitempty"`
+	Error string `json:"error,omitempty"`
+	Links *Links `json:"links,omitempty"`
+	Meta  *Meta  `json:"meta,omitempty"`
+}
+
+// Links are the links of a resource, relative to the host of the service
+type Links struct {
+	Self       string `json:"self,omitempty"`
+	Collection string `json:"collection,omitempty"`
+	Next       string `json:"next,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+}
+
+// Meta describes the page of a collection
+type Meta struct {
+	Total   int64 `json:"total"`
+	Page    int   `json:"page"`
+	PerPage int   `json:"per_page"`
+}
+
+// object returns the fields of the JSON encoding of v
+func object(v any) (map[string]any, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]any
+	return out, json.Unmarshal(raw, &out)
+}
+
+// halLinks returns the _links of a HAL resource
+func halLinks(l *Links) map[string]any {
+	out := map[string]any{}
+	for rel, href := range map[string]string{
+		"self":       l.Self,
+		"collection": l.Collection,
+		"next":       l.Next,
+		"prev":       l.Prev,
+	} {
+		if href != "" {
+			out[rel] = map[string]string{"href": href}
+		}
+	}
+	return out
+}
+
+// encodeHAL renders an Envelope in HAL: a book is a resource with its links, a
+// list of books a resource embedding them
+func encodeHAL(body any) ([]byte, error) {
+	env, ok := body.(Envelope)
+	if !ok {
+		return nil, fmt.Errorf("%T cannot be written as HAL", body)
+	}
+	if env.Error != "" {
+		return json.Marshal(map[string]string{"error": env.Error})
+	}
+
+	links := env.Links
+	if links == nil {
+		links = &Links{}
+	}
+
+	switch data := env.Data.(type) {
+	case *book.Book:
+		out, err := object(data)
+		if err != nil {
+			return nil, err
+		}
+		out["_links"] = halLinks(links)
+		return json.Marshal(out)
+
+	case []*book.Book:
+		books := make([]map[string]any, len(data))
+		for i, b := range data {
+			out, err := object(b)
+			if err != nil {
+				return nil, err
+			}
+			out["_links"] = halLinks(&Links{Self: links.Collection + "/" + b.ID.Hex()})
+			books[i] = out
+		}
+		out := map[string]any{
+			"_embedded": map[string]any{"books": books},
+			"_links":    halLinks(&Links{Self: links.Self, Next: links.Next, Prev: links.Prev}),
+		}
+		if env.Meta != nil {
+			out["total"], out["page"], out["per_page"] = env.Meta.Total, env.Meta.Page, env.Meta.PerPage
+		}
+		return json.Marshal(out)
+	}
+	return nil, fmt.Errorf("%T cannot be written as HAL", env.Data)
+}
+
+// jsonAPIResource returns the JSON:API resource object of a book
+func jsonAPIResource(b *book.Book, self string) (map[string]any, error) {
+	attributes, err := object(b)
+	if err != nil {
+		return nil, err
+	}
+	delete(attributes, "id")
+	return map[string]any{
+		"type":       "books",
+		"id":         b.ID.Hex(),
+		"attributes": attributes,
+		"links":      map[string]string{"self": self},
+	}, nil
+}
+
+// encodeJSONAPI renders an Envelope as a JSON:API document, errors being
+// reported with the status of the response
+func encodeJSONAPI(status int, body any) ([]byte, error) {
+	env, ok := body.(Envelope)
+	if !ok {
+		return nil, fmt.Errorf("%T cannot be written as JSON:API", body)
+	}
+	if env.Error != "" {
+		return json.Marshal(map[string]any{
+			"errors": []map[string]string{{
+				"status": strconv.Itoa(status),
+				"title":  http.StatusText(status),
+				"detail": env.Error,
+			}},
+		})
+	}
+
+	links := env.Links
+	if links == nil {
+		links = &Links{}
+	}
+
+	switch data := env.Data.(type) {
+	case *book.Book:
+		resource, err := jsonAPIResource(data, links.Self)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]any{"data": resource, "links": links})
+
+	case []*book.Book:
+		resources := make([]map[string]any, len(data))
+		for i, b := range data {
+			resource, err := jsonAPIResource(b, links.Collection+"/"+b.ID.Hex())
+			if err != nil {
+				return nil, err
+			}
+			resources[i] = resource
+		}
+		out := map[string]any{
+			"data":  resources,
+			"links": &Links{Self: links.Self, Next: links.Next, Prev: links.Prev},
+		}
+		if env.Meta != nil {
+			out["meta"] = env.Meta
+		}
+		return json.Marshal(out)
+	}
+	return nil, fmt.Errorf("%T cannot be written as JSON:API", env.Data)
+}
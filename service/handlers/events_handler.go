@@ -0,0 +1,149 @@
+package handlers
+
+// Feed of the changes made to the books, as server-sent events.
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/events"
+	"github.com/snehil-sinha/goBookStore/models/book"
+)
+
+// LastEventIDHeader carries the ID of the last event received by a client
+// resuming the feed
+const LastEventIDHeader = "Last-Event-ID"
+
+// EventReset is the type of the event telling a resuming client that the events
+// it missed are no longer available, so it should reload the books
+const EventReset = "reset"
+
+// eventsRetry is the delay the clients wait before reconnecting to the feed
+const eventsRetry = 3 * time.Second
+
+// BookEvent is the data of an event of the book feeds
+type BookEvent struct {
+	ID     string      `json:"id"`
+	Type   events.Type `json:"type"`
+	BookID string      `json:"book_id"`
+	Time   time.Time   `json:"time"`
+	Book   *book.Book  `json:"book,omitempty"`
+}
+
+// NewBookEvent returns the data of an event published on the bus of the epoch
+func NewBookEvent(epoch string, e events.Event) BookEvent {
+	b, _ := e.Data.(*book.Book)
+	return BookEvent{
+		ID:     EventID(epoch, e.ID),
+		Type:   e.Type,
+		BookID: e.Subject,
+		Time:   e.Time,
+		Book:   b,
+	}
+}
+
+// EventID returns the ID given to the clients for the event id of a bus epoch
+func EventID(epoch string, id uint64) string {
+	return epoch + "-" + strconv.FormatUint(id, 10)
+}
+
+// ParseEventID returns the event id of an ID returned by EventID, if it belongs
+// to the epoch
+func ParseEventID(epoch, s string) (uint64, bool) {
+	prefix, n, found := strings.Cut(s, "-")
+	if !found || prefix != epoch {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(n, 10, 64)
+	return id, err == nil
+}
+
+// BookEventsHandler streams the changes made to the books as server-sent events,
+// until the client goes away. A client resuming with the Last-Event-ID header,
+// or the last_event_id query parameter, first gets the events it missed, or a
+// reset event if they are no longer available. The events published on another
+// instance, or before a restart, are not part of the feed.
+func BookEventsHandler(s *common.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.Events == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error": "events are not enabled",
+			})
+			return
+		}
+
+		epoch := s.Events.Epoch()
+		lastEventID := c.GetHeader(LastEventIDHeader)
+		if lastEventID == "" {
+			lastEventID = c.Query("last_event_id")
+		}
+
+		var (
+			sub    *events.Subscription
+			missed []events.Event
+			resume bool
+		)
+		if id, ok := ParseEventID(epoch, lastEventID); ok {
+			sub, missed, resume = s.Events.SubscribeSince(id)
+		} else {
+			sub = s.Events.Subscribe()
+		}
+		defer sub.Cancel()
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+
+		// without data, the first message only sets the retry delay and the ID
+		// to resume from
+		c.Writer.Header().Set("Content-Type", sse.ContentType)
+		fmt.Fprintf(c.Writer, "retry:%d\nid:%s\n\n", eventsRetry.Milliseconds(), EventID(epoch, sub.LastID()))
+		if lastEventID != "" && !resume {
+			c.Render(-1, sse.Event{
+				Event: EventReset,
+				Id:    EventID(epoch, sub.LastID()),
+				Data:  gin.H{"error": "the events since " + lastEventID + " are not available"},
+			})
+		}
+		for _, e := range missed {
+			renderBookEvent(c, epoch, e)
+		}
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(s.Config().Events.Heartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case <-heartbeat.C:
+				// a comment, ignored by the clients
+				fmt.Fprint(c.Writer, ":heartbeat\n\n")
+			case e, ok := <-sub.Events():
+				if !ok {
+					// dropped for falling behind, the client reconnects and resumes
+					requestLog(c, s).Warn("the event feed fell behind and was dropped")
+					return
+				}
+				renderBookEvent(c, epoch, e)
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+func renderBookEvent(c *gin.Context, epoch string, e events.Event) {
+	data := NewBookEvent(epoch, e)
+	c.Render(-1, sse.Event{
+		Event: string(data.Type),
+		Id:    data.ID,
+		Data:  data,
+	})
+}
@@ -0,0 +1,142 @@
+package handlers_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/events"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/service/handlers"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// readEvent reads the fields of the next server-sent event of a stream
+func readEvent(r *bufio.Reader) map[string]string {
+	fields := map[string]string{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return fields
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if len(fields) > 0 {
+				return fields
+			}
+			continue
+		}
+		name, value, _ := strings.Cut(line, ":")
+		fields[name] += value
+	}
+}
+
+func TestBookEventsHandler(t *testing.T) {
+	Convey("Given the book event feed", t, func() {
+		app := &common.App{Cfg: s.Cfg, Log: s.Log, Events: events.NewBus()}
+		epoch := app.Events.Epoch()
+
+		r := gin.New()
+		r.GET("/books/events", handlers.BookEventsHandler(app))
+		srv := httptest.NewServer(r)
+		defer srv.Close()
+
+		connect := func(lastEventID string) (*http.Response, *bufio.Reader) {
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+"/books/events", nil)
+			if lastEventID != "" {
+				req.Header.Set(handlers.LastEventIDHeader, lastEventID)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			So(err, ShouldBeNil)
+			return resp, bufio.NewReader(resp.Body)
+		}
+
+		dune := book.NewBook("Dune", 412)
+		dune.ID = primitive.NewObjectID()
+
+		Convey("When a client connects", func() {
+			resp, stream := connect("")
+			defer resp.Body.Close()
+			preamble := readEvent(stream)
+
+			Convey("Then the stream should start with the retry delay and the ID to resume from", func() {
+				So(resp.StatusCode, ShouldEqual, http.StatusOK)
+				So(resp.Header.Get("Content-Type"), ShouldEqual, "text/event-stream")
+				So(preamble["retry"], ShouldEqual, "3000")
+				So(preamble["id"], ShouldEqual, handlers.EventID(epoch, 0))
+			})
+
+			Convey("Then the changes published afterwards should be streamed", func() {
+				app.Events.Publish(events.Created, dune.ID.Hex(), dune)
+				e := readEvent(stream)
+				So(e["event"], ShouldEqual, "created")
+				So(e["id"], ShouldEqual, handlers.EventID(epoch, 1))
+
+				var data handlers.BookEvent
+				So(json.Unmarshal([]byte(e["data"]), &data), ShouldBeNil)
+				So(data.BookID, ShouldEqual, dune.ID.Hex())
+				So(data.Book.Title, ShouldEqual, "Dune")
+			})
+		})
+
+		Convey("When a client resumes after missing events", func() {
+			app.Events.Publish(events.Created, dune.ID.Hex(), dune)
+			app.Events.Publish(events.Updated, dune.ID.Hex(), dune)
+			app.Events.Publish(events.Deleted, dune.ID.Hex(), dune)
+			resp, stream := connect(handlers.EventID(epoch, 1))
+			defer resp.Body.Close()
+			readEvent(stream)
+
+			Convey("Then the missed events should be streamed first", func() {
+				So(readEvent(stream)["event"], ShouldEqual, "updated")
+				e := readEvent(stream)
+				So(e["event"], ShouldEqual, "deleted")
+				So(e["id"], ShouldEqual, handlers.EventID(epoch, 3))
+			})
+		})
+
+		Convey("When a client resumes from another epoch", func() {
+			app.Events.Publish(events.Created, dune.ID.Hex(), dune)
+			resp, stream := connect("0123-42")
+			defer resp.Body.Close()
+			readEvent(stream)
+
+			Convey("Then a reset event should be streamed", func() {
+				e := readEvent(stream)
+				So(e["event"], ShouldEqual, handlers.EventReset)
+				So(e["id"], ShouldEqual, handlers.EventID(epoch, 1))
+			})
+		})
+
+		Convey("When events are not enabled", func() {
+			app.Events = nil
+			resp, _ := connect("")
+			defer resp.Body.Close()
+
+			Convey("Then a 503 should be returned", func() {
+				So(resp.StatusCode, ShouldEqual, http.StatusServiceUnavailable)
+			})
+		})
+	})
+
+	Convey("Given event IDs", t, func() {
+		Convey("Then the IDs of the epoch should be parsed", func() {
+			id, ok := handlers.ParseEventID("abc", handlers.EventID("abc", 7))
+			So(ok, ShouldBeTrue)
+			So(id, ShouldEqual, 7)
+		})
+
+		Convey("Then the IDs of another epoch or malformed should be rejected", func() {
+			for _, s := range []string{"def-7", "abc", "abc-x", ""} {
+				_, ok := handlers.ParseEventID("abc", s)
+				So(ok, ShouldBeFalse)
+			}
+		})
+	})
+}
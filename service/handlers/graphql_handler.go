@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/snehil-sinha/goBookStore/service/gql"
+)
+
+//go:embed static/graphiql.html
+var graphiqlPage []byte
+
+// GraphQLHandler runs the GraphQL request in the JSON body of a POST request, or
+// in the query, operationName and variables parameters of a GET request, which
+// cannot run mutations. With graphiql, a GET request from a browser without a
+// query is served the GraphiQL page.
+func GraphQLHandler(e *gql.Executor, graphiql bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var req gql.Request
+
+		if c.Request.Method == http.MethodGet {
+			req.Query = c.Query("query")
+			if req.Query == "" && graphiql && strings.Contains(c.GetHeader("Accept"), "text/html") {
+				c.Data(http.StatusOK, "text/html; charset=utf-8", graphiqlPage)
+				return
+			}
+			req.OperationName = c.Query("operationName")
+			if vars := c.Query("variables"); vars != "" {
+				if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+					graphQLError(c, http.StatusBadRequest, "variables must be a JSON object")
+					return
+				}
+			}
+		} else if err := c.ShouldBindJSON(&req); err != nil {
+			graphQLError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if req.Query == "" {
+			graphQLError(c, http.StatusBadRequest, "query is required")
+			return
+		}
+
+		result := e.Execute(c.Request.Context(), req, c.Request.Method == http.MethodPost)
+		for _, err := range result.Errors {
+			if err.Message == gql.ErrMutationNotAllowed.Error() {
+				c.Header("Allow", http.MethodPost)
+				c.JSON(http.StatusMethodNotAllowed, result)
+				return
+			}
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// graphQLError answers with a GraphQL result carrying a single error
+func graphQLError(c *gin.Context, status int, msg string) {
+	c.JSON(status, &graphql.Result{Errors: gqlerrors.FormatErrors(errors.New(msg))})
+}
@@ -0,0 +1,62 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/models/book/mocks"
+	"github.com/snehil-sinha/goBookStore/service/gql"
+	"github.com/snehil-sinha/goBookStore/service/handlers"
+)
+
+func TestGraphQLHandler(t *testing.T) {
+	Convey("Given a GraphQLHandler with GraphiQL enabled", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		e, err := gql.New(mocks.NewMockBookService(ctrl), s, gql.Limits{})
+		So(err, ShouldBeNil)
+		h := handlers.GraphQLHandler(e, true)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		Convey("When a browser requests the endpoint without a query", func() {
+			c.Request = httptest.NewRequest(http.MethodGet, "/graphql", nil)
+			c.Request.Header.Set("Accept", "text/html,application/xhtml+xml")
+			h(c)
+
+			Convey("Then it should be served GraphiQL", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldContainSubstring, "graphiql")
+			})
+		})
+
+		Convey("When a mutation is sent in a GET request", func() {
+			q := url.Values{"query": {`mutation { deleteBook(id: "61733b8e9c483c721f65b21d") }`}}
+			c.Request = httptest.NewRequest(http.MethodGet, "/graphql?"+q.Encode(), nil)
+			h(c)
+
+			Convey("Then it should return a 405 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusMethodNotAllowed)
+				So(w.Header().Get("Allow"), ShouldEqual, http.MethodPost)
+			})
+		})
+
+		Convey("When a POST request is sent without a query", func() {
+			c.Request = httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"variables": {}}`))
+			h(c)
+
+			Convey("Then it should return a 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, "query is required")
+			})
+		})
+	})
+}
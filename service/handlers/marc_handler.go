@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/snehil-sinha/goBookStore/catalog"
+	"github.com/snehil-sinha/goBookStore/catalog/marc"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+)
+
+// ImportMARCHandler imports a MARC 21 file in ISO 2709 or MARCXML, sent as the
+// file field of a multipart form or as the body. The format is taken from the
+// format parameter, or else from the file extension or the content type. The
+// records of books already in the catalog are skipped, see marc.Import.
+func ImportMARCHandler(svc book.BookService, s *common.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		body, filename, err := upload(c, s)
+		if err != nil {
+			respond(c, http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		defer body.Close()
+
+		format := c.Query("format")
+		if format == "" && filename != "" {
+			format = marcFormatOfExtension(filename)
+		}
+		if format == "" {
+			format = marcFormatOfContentType(c.ContentType())
+		}
+
+		r, err := marc.NewReader(format, body)
+		if err != nil {
+			respond(c, http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		report, err := marc.Import(c.Request.Context(), requestLog(c, s), svc, r, catalog.ImportOptions{
+			DryRun: c.Query("dry_run") == "true",
+		})
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, marc.ErrInvalidFile) {
+				status = http.StatusBadRequest
+			}
+			respond(c, status, gin.H{
+				"error": err.Error(),
+				"data":  report,
+			})
+			return
+		}
+		respond(c, http.StatusOK, gin.H{"data": report})
+	}
+}
+
+func marcFormatOfExtension(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".mrc", ".marc":
+		return marc.Binary
+	case ".xml":
+		return marc.XML
+	}
+	return ""
+}
+
+func marcFormatOfContentType(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/marc":
+		return marc.Binary
+	case "application/marcxml+xml", "application/xml", "text/xml":
+		return marc.XML
+	}
+	return ""
+}
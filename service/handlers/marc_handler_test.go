@@ -0,0 +1,65 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/catalog"
+	"github.com/snehil-sinha/goBookStore/catalog/marc"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/models/book/mocks"
+	"github.com/snehil-sinha/goBookStore/service/handlers"
+)
+
+func TestImportMARCHandler(t *testing.T) {
+	Convey("Given an ImportMARCHandler", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		m := mocks.NewMockBookService(ctrl)
+		h := handlers.ImportMARCHandler(m, s)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		Convey("When an ISO 2709 file is sent as the body", func() {
+			raw, err := marc.FromBook(book.NewBook("Dune", 412)).Encode()
+			So(err, ShouldBeNil)
+
+			m.EXPECT().FindDuplicates(gomock.Any(), []*book.Book{{Title: "Dune", Pages: 412}}).Return([]*book.Book{nil}, nil)
+			m.EXPECT().Batch(gomock.Any(), gomock.Any(), []book.Operation{{Op: book.OpCreate, Book: &book.Book{Title: "Dune", Pages: 412}}}, false).
+				Return([]book.Result{{Op: book.OpCreate, ID: "64b7f8a2c1d2e3f4a5b6c7d8"}}, nil)
+
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/books/import/marc", bytes.NewReader(raw))
+			c.Request.Header.Set("Content-Type", "application/marc")
+			h(c)
+
+			Convey("Then the report should be returned", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				var response struct {
+					Data catalog.Report `json:"data"`
+				}
+				So(json.Unmarshal(w.Body.Bytes(), &response), ShouldBeNil)
+				So(response.Data.Created, ShouldEqual, 1)
+				So(response.Data.Records[0].ID, ShouldEqual, "64b7f8a2c1d2e3f4a5b6c7d8")
+			})
+		})
+
+		Convey("When a MARCXML file is not well formed", func() {
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/books/import/marc", strings.NewReader("<collection><record>"))
+			c.Request.Header.Set("Content-Type", "application/marcxml+xml")
+			h(c)
+
+			Convey("Then it should return a 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
@@ -0,0 +1,319 @@
+package handlers
+
+// Content negotiation of the responses of the book endpoints.
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/snehil-sinha/goBookStore/catalog"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats of the responses
+const (
+	FormatJSON = "json"
+	FormatXML  = "xml"
+	FormatYAML = "yaml"
+	FormatCSV  = "csv"
+	// HAL and JSON:API are only offered by the v2 endpoints, see Envelope
+	FormatHAL     = "hal"
+	FormatJSONAPI = "jsonapi"
+)
+
+// ResponseFormats lists the formats every negotiated response may be rendered in
+var ResponseFormats = []string{FormatJSON, FormatXML, FormatYAML}
+
+// CollectionFormats lists the formats of the responses holding a list of books,
+// which may also be rendered in CSV
+var CollectionFormats = []string{FormatJSON, FormatXML, FormatYAML, FormatCSV}
+
+// V2Formats lists the formats of the v2 responses
+var V2Formats = []string{FormatJSON, FormatHAL, FormatJSONAPI, FormatXML, FormatYAML}
+
+// V2CollectionFormats lists the formats of the v2 responses holding a list of books
+var V2CollectionFormats = []string{FormatJSON, FormatHAL, FormatJSONAPI, FormatXML, FormatYAML, FormatCSV}
+
+// MediaTypes maps the formats to the media types they are requested with, the
+// first one being the Content-Type of their responses
+var MediaTypes = map[string][]string{
+	FormatJSON:    {"application/json"},
+	FormatXML:     {"application/xml", "text/xml"},
+	FormatYAML:    {"application/yaml", "application/x-yaml", "text/yaml"},
+	FormatCSV:     {catalog.ContentTypes[catalog.CSV]},
+	FormatHAL:     {"application/hal+json"},
+	FormatJSONAPI: {"application/vnd.api+json"},
+}
+
+// key of the negotiated format in the gin context
+const formatKey = "responseFormat"
+
+// Negotiate returns a gin.HandlerFunc (middleware) picking the format of the
+// response among formats from the Accept header, JSON if there is none. Requests
+// accepting none of the formats are answered with a 406 before reaching the
+// handler.
+func Negotiate(formats ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format, ok := negotiate(c.GetHeader("Accept"), formats)
+		if !ok {
+			var types []string
+			for _, f := range formats {
+				types = append(types, MediaTypes[f]...)
+			}
+			c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{
+				"error": "not acceptable, the response can be one of " + strings.Join(types, ", "),
+			})
+			return
+		}
+		c.Set(formatKey, format)
+		c.Next()
+	}
+}
+
+// mediaRange is a media range of an Accept header
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// negotiate returns the format of formats preferred by the Accept header. Formats
+// listed first win the ties.
+func negotiate(accept string, formats []string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return formats[0], true
+	}
+
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		typ, subtype, _ := strings.Cut(mediaType, "/")
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{typ: typ, subtype: subtype, q: q})
+	}
+
+	best, bestQ := "", 0.0
+	for _, f := range formats {
+		for _, mt := range MediaTypes[f] {
+			if q := quality(ranges, mt); q > bestQ {
+				best, bestQ = f, q
+			}
+		}
+	}
+	return best, best != ""
+}
+
+// quality returns the q value of the most specific range matching mediaType
+func quality(ranges []mediaRange, mediaType string) float64 {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, -1
+	for _, r := range ranges {
+		s := -1
+		switch {
+		case r.typ == typ && r.subtype == subtype:
+			s = 2
+		case r.typ == typ && r.subtype == "*":
+			s = 1
+		case r.typ == "*" && r.subtype == "*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
+
+// respond writes body in the format picked by Negotiate, in JSON if the handler
+// is not behind it
+func respond(c *gin.Context, status int, body any) {
+	format := c.GetString(formatKey)
+	if format == "" || format == FormatJSON {
+		c.JSON(status, body)
+		return
+	}
+
+	var (
+		out []byte
+		err error
+	)
+	switch format {
+	case FormatXML:
+		out, err = encodeXML(body)
+	case FormatYAML:
+		out, err = encodeYAML(body)
+	case FormatCSV:
+		out, err = encodeCSV(body)
+	case FormatHAL:
+		out, err = encodeHAL(body)
+	case FormatJSONAPI:
+		out, err = encodeJSONAPI(status, body)
+	default:
+		err = fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "could not render the response: " + err.Error(),
+		})
+		return
+	}
+	contentType := MediaTypes[format][0]
+	if format != FormatHAL && format != FormatJSONAPI {
+		contentType += "; charset=utf-8"
+	}
+	c.Data(status, contentType, out)
+}
+
+// plain returns body as decoded from its JSON encoding, so that every format has
+// the fields of the JSON responses. Numbers are int64 or float64.
+func plain(body any) (any, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return numbers(v), nil
+}
+
+func numbers(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = numbers(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = numbers(e)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
+
+// encodeXML writes body in a response element, objects having an element per
+// field and arrays an item element per value
+func encodeXML(body any) ([]byte, error) {
+	v, err := plain(body)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := xmlElement(enc, "response", v); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+func xmlElement(enc *xml.Encoder, name string, v any) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := xmlElement(enc, k, v[k]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, e := range v {
+			if err := xmlElement(enc, "item", e); err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		if err := enc.EncodeToken(xml.CharData(fmt.Sprint(v))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+func encodeYAML(body any) ([]byte, error) {
+	v, err := plain(body)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(v)
+}
+
+// encodeCSV writes the books of the data of body, a gin.H or an Envelope, with
+// the columns of the CSV exports, or its error in an error column
+func encodeCSV(body any) ([]byte, error) {
+	var data, msg any
+	switch body := body.(type) {
+	case gin.H:
+		data, msg = body["data"], body["error"]
+	case Envelope:
+		data = body.Data
+		if body.Error != "" {
+			msg = body.Error
+		}
+	}
+	var buf bytes.Buffer
+
+	if books, ok := data.([]*book.Book); ok {
+		w, err := catalog.NewWriter(catalog.CSV, &buf)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range books {
+			if err := w.Write(b); err != nil {
+				return nil, err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	if msg, ok := msg.(string); ok {
+		if err := csv.NewWriter(&buf).WriteAll([][]string{{"error"}, {msg}}); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("%T cannot be written as CSV", body)
+}
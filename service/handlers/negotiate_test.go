@@ -0,0 +1,118 @@
+package handlers_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/models/book/mocks"
+	"github.com/snehil-sinha/goBookStore/service/handlers"
+	"gopkg.in/yaml.v3"
+)
+
+func TestNegotiate(t *testing.T) {
+	Convey("Given the book endpoints behind Negotiate", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		m := mocks.NewMockBookService(ctrl)
+		r := gin.New()
+		r.GET("/books", handlers.Negotiate(handlers.CollectionFormats...), handlers.FindBooksHandler(m, s))
+		r.GET("/books/:id", handlers.Negotiate(handlers.ResponseFormats...), handlers.FindBookHandler(m, s))
+
+		get := func(path, accept string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Accept", accept)
+			r.ServeHTTP(w, req)
+			return w
+		}
+		books := []*book.Book{book.NewBook("Dune", 412), book.NewBook("Emma", 474)}
+
+		Convey("When XML is preferred", func() {
+			m.EXPECT().ReadAll(gomock.Any()).Return(books, nil)
+			w := get("/books", "application/json;q=0.5, application/xml")
+
+			Convey("Then the books should be written in XML", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "application/xml; charset=utf-8")
+				So(w.Body.String(), ShouldContainSubstring, "<response>\n  <data>\n    <item>")
+				So(w.Body.String(), ShouldContainSubstring, "<pages>412</pages>")
+				So(w.Body.String(), ShouldContainSubstring, "<title>Emma</title>")
+			})
+		})
+
+		Convey("When YAML is requested", func() {
+			m.EXPECT().ReadAll(gomock.Any()).Return(books, nil)
+			w := get("/books", "application/yaml")
+
+			Convey("Then the books should be written in YAML with the JSON fields", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "application/yaml; charset=utf-8")
+				var body struct {
+					Data []map[string]any `yaml:"data"`
+				}
+				So(yaml.Unmarshal(w.Body.Bytes(), &body), ShouldBeNil)
+				So(body.Data, ShouldHaveLength, 2)
+				So(body.Data[0]["title"], ShouldEqual, "Dune")
+				So(body.Data[0]["pages"], ShouldEqual, 412)
+			})
+		})
+
+		Convey("When CSV is requested for the collection", func() {
+			m.EXPECT().ReadAll(gomock.Any()).Return(books, nil)
+			w := get("/books", "text/csv, application/json;q=0.9")
+
+			Convey("Then the books should be written with the columns of the exports", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "text/csv; charset=utf-8")
+				lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+				So(lines, ShouldHaveLength, 3)
+				So(lines[0], ShouldEqual, "id,title,pages,isbn,contributors,prices,availability,reference,created_at,updated_at")
+			})
+		})
+
+		Convey("When an error is returned in CSV", func() {
+			m.EXPECT().ReadAll(gomock.Any()).Return(nil, errors.New("connection reset"))
+			w := get("/books", "text/csv")
+
+			Convey("Then it should be written in an error column", func() {
+				So(w.Code, ShouldEqual, http.StatusInternalServerError)
+				So(w.Body.String(), ShouldEqual, "error\nserver encountered an unknown error\n")
+			})
+		})
+
+		Convey("When CSV is requested for a single book", func() {
+			w := get("/books/61733b8e9c483c721f65b21d", "text/csv")
+
+			Convey("Then it should return a 406 status code without reading the book", func() {
+				So(w.Code, ShouldEqual, http.StatusNotAcceptable)
+				So(w.Body.String(), ShouldContainSubstring, "application/json")
+			})
+		})
+
+		Convey("When any type is accepted", func() {
+			m.EXPECT().ReadById(gomock.Any(), gomock.Any()).Return(books[0], nil)
+			w := get("/books/61733b8e9c483c721f65b21d", "*/*")
+
+			Convey("Then the book should be written in JSON", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Content-Type"), ShouldEqual, "application/json; charset=utf-8")
+			})
+		})
+
+		Convey("When JSON is refused", func() {
+			w := get("/books", "application/json;q=0, text/html")
+
+			Convey("Then it should return a 406 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusNotAcceptable)
+			})
+		})
+	})
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/snehil-sinha/goBookStore/catalog"
+	"github.com/snehil-sinha/goBookStore/catalog/onix"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+)
+
+// ImportONIXHandler imports an ONIX 3.0 message, sent as the file field of a
+// multipart form or as the body, upserting the books by record reference. See
+// onix.Import for the dry_run parameter and the report.
+func ImportONIXHandler(svc book.BookService, s *common.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		body, _, err := upload(c, s)
+		if err != nil {
+			respond(c, http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		defer body.Close()
+
+		report, err := onix.Import(c.Request.Context(), requestLog(c, s), svc, onix.NewReader(body), catalog.ImportOptions{
+			DryRun: c.Query("dry_run") == "true",
+		})
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, onix.ErrInvalidMessage) {
+				status = http.StatusBadRequest
+			}
+			respond(c, status, gin.H{
+				"error": err.Error(),
+				"data":  report,
+			})
+			return
+		}
+		respond(c, http.StatusOK, gin.H{"data": report})
+	}
+}
@@ -0,0 +1,64 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/catalog"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/models/book/mocks"
+	"github.com/snehil-sinha/goBookStore/service/handlers"
+)
+
+func TestImportONIXHandler(t *testing.T) {
+	Convey("Given an ImportONIXHandler", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		m := mocks.NewMockBookService(ctrl)
+		h := handlers.ImportONIXHandler(m, s)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		Convey("When a message is sent as the body", func() {
+			m.EXPECT().List(gomock.Any(), book.ListOptions{Limit: 2, Filter: book.Filter{Reference: "com.chilton.persuasion"}}).
+				Return(nil, int64(0), nil)
+
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/books/import/onix?dry_run=true", strings.NewReader(
+				`<ONIXMessage release="3.0"><Product>
+					<RecordReference>com.chilton.persuasion</RecordReference>
+					<NotificationType>05</NotificationType>
+				</Product></ONIXMessage>`))
+			c.Request.Header.Set("Content-Type", "application/xml")
+			h(c)
+
+			Convey("Then the report should be returned", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				var response struct {
+					Data catalog.Report `json:"data"`
+				}
+				So(json.Unmarshal(w.Body.Bytes(), &response), ShouldBeNil)
+				So(response.Data.DryRun, ShouldBeTrue)
+				So(response.Data.Unchanged, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When the message is not well formed", func() {
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/books/import/onix",
+				strings.NewReader(`<ONIXMessage release="3.0"><Product>`))
+			c.Request.Header.Set("Content-Type", "application/xml")
+			h(c)
+
+			Convey("Then it should return a 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
@@ -0,0 +1,83 @@
+package handlers
+
+// Revisions of the books, to read them as they were and to revert bad edits.
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RevertRequest is the body of the revert endpoint
+type RevertRequest struct {
+	Revision int `json:"revision" binding:"required,gte=1"`
+}
+
+// revisionStatus returns the status code of an error of the revision endpoints
+func revisionStatus(err error) int {
+	switch {
+	case errors.Is(err, primitive.ErrInvalidHex):
+		return http.StatusBadRequest
+	case errors.Is(err, book.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, book.ErrDuplicate), errors.Is(err, book.ErrConflict):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// BookRevisionHandler fetches a revision of a book by the specified ID and
+// revision number
+func BookRevisionHandler(svc book.BookService, s *common.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		rev, err := strconv.Atoi(c.Param("rev"))
+		if err != nil || rev < 1 {
+			respond(c, http.StatusBadRequest, gin.H{
+				"error": "rev must be a positive integer",
+			})
+			return
+		}
+
+		r, err := svc.Revision(requestLog(c, s), c.Param("id"), rev)
+		if err != nil {
+			respond(c, revisionStatus(err), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": r})
+	}
+}
+
+// RevertBookHandler sets a book by the specified ID back to a previous revision,
+// making a new revision
+func RevertBookHandler(svc book.BookService, s *common.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var req RevertRequest
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respond(c, http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		b, err := svc.Revert(c.Request.Context(), requestLog(c, s), c.Param("id"), req.Revision)
+		if err != nil {
+			respond(c, revisionStatus(err), gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		respond(c, http.StatusOK, b)
+	}
+}
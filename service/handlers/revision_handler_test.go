@@ -0,0 +1,125 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/models/book/mocks"
+	"github.com/snehil-sinha/goBookStore/service/handlers"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRevisionHandlers(t *testing.T) {
+	Convey("Given the revision endpoints", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		m := mocks.NewMockBookService(ctrl)
+		r := gin.New()
+		r.GET("/books/:id", handlers.FindBookHandler(m, s))
+		r.GET("/books/:id/revisions/:rev", handlers.BookRevisionHandler(m, s))
+		r.POST("/books/:id/revert", handlers.RevertBookHandler(m, s))
+
+		do := func(method, path, body string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+			return w
+		}
+
+		id := primitive.NewObjectID()
+		dune := book.NewBook("Dune", 412)
+		dune.ID = id
+		dune.Revision = 1
+
+		Convey("When a revision of a book is fetched", func() {
+			m.EXPECT().Revision(gomock.Any(), id.Hex(), 1).Return(&book.Revision{BookID: id, Revision: 1, Book: *dune}, nil)
+			w := do(http.MethodGet, "/books/"+id.Hex()+"/revisions/1", "")
+
+			Convey("Then the revision should be returned", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				var body struct {
+					Data book.Revision `json:"data"`
+				}
+				So(json.Unmarshal(w.Body.Bytes(), &body), ShouldBeNil)
+				So(body.Data.Revision, ShouldEqual, 1)
+				So(body.Data.Book.Title, ShouldEqual, "Dune")
+			})
+		})
+
+		Convey("When an unknown revision is fetched", func() {
+			m.EXPECT().Revision(gomock.Any(), id.Hex(), 7).Return(nil, book.ErrNotFound)
+
+			Convey("Then it should return a 404 status code", func() {
+				So(do(http.MethodGet, "/books/"+id.Hex()+"/revisions/7", "").Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("When an invalid revision number is fetched", func() {
+			Convey("Then it should return a 400 status code", func() {
+				So(do(http.MethodGet, "/books/"+id.Hex()+"/revisions/0", "").Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When a book is read as of a time", func() {
+			asOf := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+			m.EXPECT().ReadAsOf(gomock.Any(), id.Hex(), asOf).Return(dune, nil)
+			w := do(http.MethodGet, "/books/"+id.Hex()+"?as_of=2026-03-01T00:00:00Z", "")
+
+			Convey("Then the book should be returned as it was then", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldContainSubstring, `"pages":412`)
+			})
+		})
+
+		Convey("When a book is read as of an invalid time", func() {
+			w := do(http.MethodGet, "/books/"+id.Hex()+"?as_of=yesterday", "")
+
+			Convey("Then it should return a 400 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(w.Body.String(), ShouldContainSubstring, "as_of must be an RFC 3339 time")
+			})
+		})
+
+		Convey("When a book is reverted", func() {
+			reverted := *dune
+			reverted.Revision = 3
+			m.EXPECT().Revert(gomock.Any(), gomock.Any(), id.Hex(), 1).Return(&reverted, nil)
+			w := do(http.MethodPost, "/books/"+id.Hex()+"/revert", `{"revision": 1}`)
+
+			Convey("Then the book should be returned with its new revision", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldContainSubstring, `"revision":3`)
+			})
+		})
+
+		Convey("When a book is reverted to a revision taken by another book", func() {
+			m.EXPECT().Revert(gomock.Any(), gomock.Any(), id.Hex(), 1).Return(nil, book.ErrDuplicate)
+
+			Convey("Then it should return a 409 status code", func() {
+				So(do(http.MethodPost, "/books/"+id.Hex()+"/revert", `{"revision": 1}`).Code, ShouldEqual, http.StatusConflict)
+			})
+		})
+
+		Convey("When a book changed by another request is reverted", func() {
+			m.EXPECT().Revert(gomock.Any(), gomock.Any(), id.Hex(), 1).Return(nil, book.ErrConflict)
+
+			Convey("Then it should return a 409 status code", func() {
+				So(do(http.MethodPost, "/books/"+id.Hex()+"/revert", `{"revision": 1}`).Code, ShouldEqual, http.StatusConflict)
+			})
+		})
+
+		Convey("When a revert has no revision", func() {
+			Convey("Then it should return a 400 status code", func() {
+				So(do(http.MethodPost, "/books/"+id.Hex()+"/revert", `{}`).Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+	})
+}
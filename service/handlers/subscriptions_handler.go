@@ -0,0 +1,314 @@
+package handlers
+
+// Subscriptions to the changes made to the books, over WebSocket.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/events"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Topics of the subscriptions: every change, the changes of a book by ID
+// (books/<id>), or the changes of the books matching the parameters of a query
+// (books?title_contains=dune&min_pages=100)
+const (
+	TopicBooks = "books"
+	topicBook  = "books/"
+	topicQuery = "books?"
+)
+
+// Types of the messages of the subscriptions
+const (
+	MessageSubscribe    = "subscribe"    // from the client, to subscribe to the topic
+	MessageUnsubscribe  = "unsubscribe"  // from the client, to unsubscribe from the topic
+	MessageSubscribed   = "subscribed"   // answers subscribe
+	MessageUnsubscribed = "unsubscribed" // answers unsubscribe
+	MessageEvent        = "event"        // a change matching the topics
+	MessageHeartbeat    = "heartbeat"    // sent every events.heartbeat, the clients may send it too
+	MessageError        = "error"        // an invalid message of the client
+)
+
+// SubscriptionMessage is a message of the subscriptions, in either direction
+type SubscriptionMessage struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic,omitempty"`
+	// Topics of the client matching an event
+	Topics []string   `json:"topics,omitempty"`
+	Event  *BookEvent `json:"event,omitempty"`
+	Error  string     `json:"error,omitempty"`
+	Time   *time.Time `json:"time,omitempty"`
+}
+
+const (
+	// maxSubscriptionMessage is the size of the largest message of a client
+	maxSubscriptionMessage = 4096
+	// maxTopics is the number of topics a client may subscribe to
+	maxTopics = 100
+	// maxReplies is the number of replies a client may wait for; beyond, the
+	// client is sending faster than it reads and is disconnected
+	maxReplies = 16
+	// subscriptionWriteTimeout bounds the writes to a client
+	subscriptionWriteTimeout = 10 * time.Second
+)
+
+// queryTopicParams are the parameters of the query topics
+var queryTopicParams = map[string]bool{
+	"title": true, "title_contains": true, "isbn": true, "min_pages": true, "max_pages": true,
+}
+
+// topic selects the events of a subscription
+type topic struct {
+	bookID string
+	filter *book.Filter
+}
+
+// parseTopic returns the topic of a subscribe or unsubscribe message
+func parseTopic(name string) (t topic, err error) {
+	switch {
+	case name == TopicBooks:
+		return t, nil
+	case strings.HasPrefix(name, topicBook):
+		t.bookID = strings.TrimPrefix(name, topicBook)
+		if _, err = primitive.ObjectIDFromHex(t.bookID); err != nil {
+			return t, fmt.Errorf("invalid book id %q", t.bookID)
+		}
+		return t, nil
+	case strings.HasPrefix(name, topicQuery):
+		params, err := url.ParseQuery(strings.TrimPrefix(name, topicQuery))
+		if err != nil {
+			return t, fmt.Errorf("invalid query: %s", err)
+		}
+		for param := range params {
+			if !queryTopicParams[param] {
+				return t, fmt.Errorf("unknown query parameter %q", param)
+			}
+		}
+		f, err := filterOf(params)
+		if err != nil {
+			return t, err
+		}
+		t.filter = &f
+		return t, nil
+	}
+	return t, fmt.Errorf("unknown topic %q, must be %s, %s<id> or %s<query>", name, TopicBooks, topicBook, topicQuery)
+}
+
+// matches tells if an event is part of the topic. The deletions of the books
+// matching a query are part of its topic.
+func (t topic) matches(e events.Event) bool {
+	switch {
+	case t.bookID != "":
+		return e.Subject == t.bookID
+	case t.filter != nil:
+		b, ok := e.Data.(*book.Book)
+		if !ok || b == nil {
+			return false
+		}
+		f := *t.filter
+		f.Deleted = b.DeletedAt != nil
+		return f.Matches(b)
+	}
+	return true
+}
+
+// subscriber serves the subscriptions of a connection: the messages of the
+// client are read on their own goroutine, and every write goes through serve
+type subscriber struct {
+	conn      *websocket.Conn
+	epoch     string
+	heartbeat time.Duration
+
+	mu     sync.Mutex
+	topics map[string]topic
+
+	replies chan SubscriptionMessage
+	// closed once the client is gone or disconnected
+	closed chan struct{}
+}
+
+// BookSubscriptionsHandler upgrades the connection to a WebSocket streaming the
+// changes made to the books matching the topics the client subscribes to, as
+// JSON messages. The requests are authenticated like the other requests of the
+// API. Clients too slow to read the changes are disconnected, rather than
+// holding up the others.
+func BookSubscriptionsHandler(s *common.App) gin.HandlerFunc {
+	upgrader := websocket.Upgrader{
+		// the origins are checked by the CORS middleware
+		CheckOrigin: func(*http.Request) bool { return true },
+		Error: func(w http.ResponseWriter, _ *http.Request, status int, reason error) {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(status)
+			_ = json.NewEncoder(w).Encode(gin.H{"error": reason.Error()})
+		},
+	}
+
+	return func(c *gin.Context) {
+		if s.Events == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error": "events are not enabled",
+			})
+			return
+		}
+
+		// the upgrade writes its status itself, it is set for the logs and the
+		// validation of the responses
+		c.Status(http.StatusSwitchingProtocols)
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		sub := s.Events.Subscribe()
+		defer sub.Cancel()
+
+		sb := &subscriber{
+			conn:      conn,
+			epoch:     s.Events.Epoch(),
+			heartbeat: s.Config().Events.Heartbeat,
+			topics:    make(map[string]topic),
+			replies:   make(chan SubscriptionMessage, maxReplies),
+			closed:    make(chan struct{}),
+		}
+		go sb.read()
+		if reason := sb.serve(c.Request.Context(), sub); reason != "" {
+			requestLog(c, s).Sugar().Infof("closed the subscriptions: %s", reason)
+		}
+	}
+}
+
+// read handles the messages of the client until it goes away
+func (sb *subscriber) read() {
+	defer close(sb.closed)
+
+	// the client is gone if it answers neither the pings nor sends anything
+	// for two heartbeats
+	timeout := 2 * sb.heartbeat
+	sb.conn.SetReadLimit(maxSubscriptionMessage)
+	_ = sb.conn.SetReadDeadline(time.Now().Add(timeout))
+	sb.conn.SetPongHandler(func(string) error {
+		return sb.conn.SetReadDeadline(time.Now().Add(timeout))
+	})
+
+	for {
+		_, data, err := sb.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = sb.conn.SetReadDeadline(time.Now().Add(timeout))
+
+		reply, ok := sb.handle(data)
+		if !ok {
+			continue
+		}
+		select {
+		case sb.replies <- reply:
+		default:
+			sb.close(websocket.ClosePolicyViolation, "too many messages waiting for a reply")
+			return
+		}
+	}
+}
+
+// handle applies a message of the client, and returns the reply if any
+func (sb *subscriber) handle(data []byte) (reply SubscriptionMessage, ok bool) {
+	var msg SubscriptionMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return SubscriptionMessage{Type: MessageError, Error: "invalid message: " + err.Error()}, true
+	}
+
+	switch msg.Type {
+	case MessageSubscribe:
+		t, err := parseTopic(msg.Topic)
+		if err != nil {
+			return SubscriptionMessage{Type: MessageError, Topic: msg.Topic, Error: err.Error()}, true
+		}
+		sb.mu.Lock()
+		defer sb.mu.Unlock()
+		if _, found := sb.topics[msg.Topic]; !found && len(sb.topics) >= maxTopics {
+			return SubscriptionMessage{Type: MessageError, Topic: msg.Topic, Error: fmt.Sprintf("cannot subscribe to more than %d topics", maxTopics)}, true
+		}
+		sb.topics[msg.Topic] = t
+		return SubscriptionMessage{Type: MessageSubscribed, Topic: msg.Topic}, true
+	case MessageUnsubscribe:
+		sb.mu.Lock()
+		defer sb.mu.Unlock()
+		delete(sb.topics, msg.Topic)
+		return SubscriptionMessage{Type: MessageUnsubscribed, Topic: msg.Topic}, true
+	case MessageHeartbeat:
+		return reply, false
+	}
+	return SubscriptionMessage{Type: MessageError, Error: fmt.Sprintf("unknown message type %q", msg.Type)}, true
+}
+
+// match returns the topics of the client an event is part of
+func (sb *subscriber) match(e events.Event) (topics []string) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	for name, t := range sb.topics {
+		if t.matches(e) {
+			topics = append(topics, name)
+		}
+	}
+	return
+}
+
+// serve writes the replies, the events and the heartbeats to the client until it
+// goes away or is disconnected, and returns why it was
+func (sb *subscriber) serve(ctx context.Context, sub *events.Subscription) (reason string) {
+	heartbeat := time.NewTicker(sb.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			sb.close(websocket.CloseGoingAway, "the server is shutting down")
+			return "the server is shutting down"
+		case <-sb.closed:
+			return ""
+		case reply := <-sb.replies:
+			err = sb.write(reply)
+		case e, ok := <-sub.Events():
+			if !ok {
+				sb.close(websocket.CloseTryAgainLater, "the client fell behind")
+				return "the client fell behind"
+			}
+			topics := sb.match(e)
+			if len(topics) == 0 {
+				continue
+			}
+			data := NewBookEvent(sb.epoch, e)
+			err = sb.write(SubscriptionMessage{Type: MessageEvent, Topics: topics, Event: &data})
+		case t := <-heartbeat.C:
+			t = t.UTC()
+			if err = sb.write(SubscriptionMessage{Type: MessageHeartbeat, Time: &t}); err == nil {
+				err = sb.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(subscriptionWriteTimeout))
+			}
+		}
+		if err != nil {
+			return "error writing to the client: " + err.Error()
+		}
+	}
+}
+
+func (sb *subscriber) write(msg SubscriptionMessage) error {
+	_ = sb.conn.SetWriteDeadline(time.Now().Add(subscriptionWriteTimeout))
+	return sb.conn.WriteJSON(msg)
+}
+
+func (sb *subscriber) close(code int, text string) {
+	_ = sb.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(subscriptionWriteTimeout))
+}
@@ -0,0 +1,163 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/events"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/service/handlers"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBookSubscriptionsHandler(t *testing.T) {
+	Convey("Given the book subscriptions", t, func() {
+		cfg := *s.Cfg
+		cfg.Events.Heartbeat = time.Hour
+		app := &common.App{Cfg: &cfg, Log: s.Log, Events: events.NewBus()}
+
+		r := gin.New()
+		r.GET("/books/ws", handlers.BookSubscriptionsHandler(app))
+		srv := httptest.NewServer(r)
+		defer srv.Close()
+		wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/books/ws"
+
+		dial := func() *websocket.Conn {
+			conn, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusSwitchingProtocols)
+			return conn
+		}
+		send := func(conn *websocket.Conn, msg handlers.SubscriptionMessage) {
+			So(conn.WriteJSON(msg), ShouldBeNil)
+		}
+		receive := func(conn *websocket.Conn) (msg handlers.SubscriptionMessage) {
+			_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			So(conn.ReadJSON(&msg), ShouldBeNil)
+			return
+		}
+		subscribe := func(conn *websocket.Conn, topic string) {
+			send(conn, handlers.SubscriptionMessage{Type: handlers.MessageSubscribe, Topic: topic})
+			So(receive(conn), ShouldResemble, handlers.SubscriptionMessage{Type: handlers.MessageSubscribed, Topic: topic})
+		}
+
+		dune := book.NewBook("Dune", 412)
+		dune.ID = primitive.NewObjectID()
+		solaris := book.NewBook("Solaris", 204)
+		solaris.ID = primitive.NewObjectID()
+
+		conn := dial()
+		defer conn.Close()
+
+		Convey("When a client subscribes to a book", func() {
+			subscribe(conn, "books/"+dune.ID.Hex())
+			app.Events.Publish(events.Updated, solaris.ID.Hex(), solaris)
+			app.Events.Publish(events.Updated, dune.ID.Hex(), dune)
+
+			Convey("Then only the changes of the book should be sent", func() {
+				msg := receive(conn)
+				So(msg.Type, ShouldEqual, handlers.MessageEvent)
+				So(msg.Topics, ShouldResemble, []string{"books/" + dune.ID.Hex()})
+				So(msg.Event.BookID, ShouldEqual, dune.ID.Hex())
+				So(msg.Event.Type, ShouldEqual, events.Updated)
+			})
+		})
+
+		Convey("When a client subscribes to a query", func() {
+			subscribe(conn, "books?title_contains=DUNE&min_pages=100")
+			app.Events.Publish(events.Created, solaris.ID.Hex(), solaris)
+			app.Events.Publish(events.Created, dune.ID.Hex(), dune)
+			deleted := *dune
+			now := time.Now()
+			deleted.DeletedAt = &now
+			app.Events.Publish(events.Deleted, dune.ID.Hex(), &deleted)
+
+			Convey("Then the changes of the matching books should be sent, deletions included", func() {
+				msg := receive(conn)
+				So(msg.Event.Book.Title, ShouldEqual, "Dune")
+				So(msg.Event.Type, ShouldEqual, events.Created)
+				So(receive(conn).Event.Type, ShouldEqual, events.Deleted)
+			})
+		})
+
+		Convey("When a client subscribes to every change and unsubscribes", func() {
+			subscribe(conn, handlers.TopicBooks)
+			app.Events.Publish(events.Created, solaris.ID.Hex(), solaris)
+			So(receive(conn).Event.BookID, ShouldEqual, solaris.ID.Hex())
+
+			send(conn, handlers.SubscriptionMessage{Type: handlers.MessageUnsubscribe, Topic: handlers.TopicBooks})
+			So(receive(conn).Type, ShouldEqual, handlers.MessageUnsubscribed)
+			app.Events.Publish(events.Created, dune.ID.Hex(), dune)
+			subscribe(conn, "books/"+solaris.ID.Hex())
+
+			Convey("Then the changes should no longer be sent", func() {
+				app.Events.Publish(events.Deleted, solaris.ID.Hex(), solaris)
+				So(receive(conn).Event.Type, ShouldEqual, events.Deleted)
+			})
+		})
+
+		Convey("When a client subscribes to an invalid topic", func() {
+			for _, topic := range []string{"authors", "books/42", "books?pages=1", "books?min_pages=many"} {
+				send(conn, handlers.SubscriptionMessage{Type: handlers.MessageSubscribe, Topic: topic})
+				msg := receive(conn)
+
+				Convey("Then an error should be sent for "+topic, func() {
+					So(msg.Type, ShouldEqual, handlers.MessageError)
+					So(msg.Topic, ShouldEqual, topic)
+					So(msg.Error, ShouldNotBeEmpty)
+				})
+			}
+		})
+
+		Convey("When a client sends an invalid message", func() {
+			So(conn.WriteMessage(websocket.TextMessage, []byte("{")), ShouldBeNil)
+
+			Convey("Then an error should be sent and the connection kept", func() {
+				So(receive(conn).Type, ShouldEqual, handlers.MessageError)
+				subscribe(conn, handlers.TopicBooks)
+			})
+		})
+	})
+
+	Convey("Given the book subscriptions with a short heartbeat", t, func() {
+		cfg := *s.Cfg
+		cfg.Events.Heartbeat = 20 * time.Millisecond
+		app := &common.App{Cfg: &cfg, Log: s.Log, Events: events.NewBus()}
+
+		r := gin.New()
+		r.GET("/books/ws", handlers.BookSubscriptionsHandler(app))
+		srv := httptest.NewServer(r)
+		defer srv.Close()
+
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/books/ws", nil)
+		So(err, ShouldBeNil)
+		defer conn.Close()
+
+		Convey("Then heartbeats should be sent", func() {
+			var msg handlers.SubscriptionMessage
+			_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			So(conn.ReadJSON(&msg), ShouldBeNil)
+			So(msg.Type, ShouldEqual, handlers.MessageHeartbeat)
+			So(msg.Time, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given a request which is not a WebSocket handshake", t, func() {
+		r := gin.New()
+		r.GET("/books/ws", handlers.BookSubscriptionsHandler(s))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/books/ws", nil))
+
+		Convey("Then it should be rejected with a 400", func() {
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+			So(w.Header().Get("Content-Type"), ShouldStartWith, "application/json")
+		})
+	})
+}
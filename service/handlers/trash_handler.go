@@ -0,0 +1,71 @@
+package handlers
+
+// Trash of the deleted books, which are kept until restored or purged.
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// IsHardDelete tells if a delete request purges the book (hard=true) rather than
+// moving it to the trash
+func IsHardDelete(c *gin.Context) bool {
+	return c.Query("hard") == "true"
+}
+
+// deleteBook moves a book to the trash, or purges it for a hard delete
+func deleteBook(c *gin.Context, svc book.BookService, s *common.App) error {
+	if IsHardDelete(c) {
+		return svc.Purge(c.Request.Context(), requestLog(c, s), c.Param("id"))
+	}
+	return svc.Delete(c.Request.Context(), requestLog(c, s), c.Param("id"))
+}
+
+// TrashBooksHandler fetches the deleted books which were not purged yet
+func TrashBooksHandler(svc book.BookService, s *common.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		books, _, err := svc.List(requestLog(c, s), book.ListOptions{Filter: book.Filter{Deleted: true}})
+		if err != nil {
+			respond(c, http.StatusInternalServerError, gin.H{
+				"error": "server encountered an unknown error",
+			})
+			return
+		}
+		if books == nil {
+			books = []*book.Book{}
+		}
+
+		respond(c, http.StatusOK, gin.H{"data": books})
+	}
+}
+
+// RestoreBookHandler restores a deleted book (if in the trash) by the specified ID
+func RestoreBookHandler(svc book.BookService, s *common.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		b, err := svc.Restore(c.Request.Context(), requestLog(c, s), c.Param("id"))
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, primitive.ErrInvalidHex):
+				status = http.StatusBadRequest
+			case errors.Is(err, book.ErrNotFound):
+				status = http.StatusNotFound
+			case errors.Is(err, book.ErrDuplicate):
+				status = http.StatusConflict
+			}
+			respond(c, status, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		respond(c, http.StatusOK, b)
+	}
+}
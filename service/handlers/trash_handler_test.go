@@ -0,0 +1,110 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/models/book/mocks"
+	"github.com/snehil-sinha/goBookStore/service/handlers"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTrashHandlers(t *testing.T) {
+	Convey("Given the trash endpoints", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		m := mocks.NewMockBookService(ctrl)
+		r := gin.New()
+		r.GET("/books/trash", handlers.TrashBooksHandler(m, s))
+		r.POST("/books/:id/restore", handlers.RestoreBookHandler(m, s))
+		r.DELETE("/books/:id", handlers.DeleteBookHandler(m, s))
+
+		do := func(method, path string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+			return w
+		}
+
+		deletedAt := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+		dune := book.NewBook("Dune", 412)
+		dune.ID = primitive.NewObjectID()
+		id := dune.ID.Hex()
+
+		Convey("When the trash is listed", func() {
+			trashed := *dune
+			trashed.DeletedAt = &deletedAt
+			m.EXPECT().List(gomock.Any(), book.ListOptions{Filter: book.Filter{Deleted: true}}).Return([]*book.Book{&trashed}, int64(1), nil)
+			w := do(http.MethodGet, "/books/trash")
+
+			Convey("Then the deleted books should be returned with their tombstone", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				var body struct {
+					Data []*book.Book `json:"data"`
+				}
+				So(json.Unmarshal(w.Body.Bytes(), &body), ShouldBeNil)
+				So(body.Data, ShouldHaveLength, 1)
+				So(body.Data[0].DeletedAt.Equal(deletedAt), ShouldBeTrue)
+			})
+		})
+
+		Convey("When the trash is empty", func() {
+			m.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, int64(0), nil)
+			w := do(http.MethodGet, "/books/trash")
+
+			Convey("Then an empty list should be returned", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldEqual, `{"data":[]}`)
+			})
+		})
+
+		Convey("When a book in the trash is restored", func() {
+			m.EXPECT().Restore(gomock.Any(), gomock.Any(), id).Return(dune, nil)
+			w := do(http.MethodPost, "/books/"+id+"/restore")
+
+			Convey("Then the restored book should be returned", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldNotContainSubstring, "deleted_at")
+			})
+		})
+
+		Convey("When a book which is not in the trash is restored", func() {
+			m.EXPECT().Restore(gomock.Any(), gomock.Any(), id).Return(nil, book.ErrNotFound)
+
+			Convey("Then it should return a 404 status code", func() {
+				So(do(http.MethodPost, "/books/"+id+"/restore").Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+
+		Convey("When a book taken again since its deletion is restored", func() {
+			m.EXPECT().Restore(gomock.Any(), gomock.Any(), id).Return(nil, book.ErrDuplicate)
+
+			Convey("Then it should return a 409 status code", func() {
+				So(do(http.MethodPost, "/books/"+id+"/restore").Code, ShouldEqual, http.StatusConflict)
+			})
+		})
+
+		Convey("When a book is deleted", func() {
+			m.EXPECT().Delete(gomock.Any(), gomock.Any(), id).Return(nil)
+
+			Convey("Then it should be moved to the trash", func() {
+				So(do(http.MethodDelete, "/books/"+id).Code, ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("When a book is hard deleted", func() {
+			m.EXPECT().Purge(gomock.Any(), gomock.Any(), id).Return(nil)
+
+			Convey("Then it should be purged", func() {
+				So(do(http.MethodDelete, "/books/"+id+"?hard=true").Code, ShouldEqual, http.StatusOK)
+			})
+		})
+	})
+}
@@ -63,6 +63,9 @@ func LoggerWithConfig(logger *zap.Logger, conf *HTTPLogCfg) gin.HandlerFunc {
 					zap.String("user-agent", c.Request.UserAgent()),
 					zap.Duration("latency", latency),
 				}
+				if id := c.Writer.Header().Get(RequestIDHeader); id != "" {
+					fields = append(fields, zap.String("request_id", id))
+				}
 				if conf.TimeFormat != "" {
 					fields = append(fields, zap.String("time", end.Format(conf.TimeFormat)))
 				}
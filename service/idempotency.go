@@ -0,0 +1,260 @@
+package service
+
+// Replay of the responses of the POST requests sent with an Idempotency-Key.
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/snehil-sinha/goBookStore/common"
+)
+
+// Headers of the idempotent requests
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on the responses replayed for a retry
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// MaxIdempotencyKeyLength bounds the length of an Idempotency-Key
+const MaxIdempotencyKeyLength = 255
+
+// replayedHeaders are the headers of the responses replayed along with their
+// body; the others (e.g. CORS) are set for every request
+var replayedHeaders = []string{"Content-Type", "Content-Disposition", "Location"}
+
+// How often expired responses are dropped
+const idempotencySweepInterval = time.Minute
+
+// idempotentRequest is a request run with an Idempotency-Key, in flight until
+// done is closed
+type idempotentRequest struct {
+	fingerprint [sha256.Size]byte
+	done        chan struct{}
+
+	// set before done is closed; a request which was not stored failed and may
+	// be run again
+	stored  bool
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+type idempotencyStore struct {
+	mu        sync.Mutex
+	requests  map[string]*idempotentRequest
+	lastSweep time.Time
+}
+
+func newIdempotencyStore() *idempotencyStore {
+	return &idempotencyStore{
+		requests:  make(map[string]*idempotentRequest),
+		lastSweep: time.Now(),
+	}
+}
+
+// begin returns the request of key. If there is none, or it expired, a request
+// in flight is registered and owner is true: the caller must run it and then
+// call finish.
+func (st *idempotencyStore) begin(key string, fingerprint [sha256.Size]byte, now time.Time) (req *idempotentRequest, owner bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.sweep(now)
+
+	if req, ok := st.requests[key]; ok && (!req.stored || now.Before(req.expires)) {
+		return req, false
+	}
+	req = &idempotentRequest{fingerprint: fingerprint, done: make(chan struct{})}
+	st.requests[key] = req
+	return req, true
+}
+
+// finish stores the response of a request for ttl, or drops the request if
+// status is 0, and wakes up the retries waiting for it
+func (st *idempotencyStore) finish(key string, req *idempotentRequest, status int, header http.Header, body []byte, ttl time.Duration, now time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if status == 0 {
+		delete(st.requests, key)
+	} else {
+		req.stored = true
+		req.status, req.header, req.body = status, header, body
+		req.expires = now.Add(ttl)
+	}
+	close(req.done)
+}
+
+// drop the expired responses
+func (st *idempotencyStore) sweep(now time.Time) {
+	if now.Sub(st.lastSweep) < idempotencySweepInterval {
+		return
+	}
+	st.lastSweep = now
+
+	for key, req := range st.requests {
+		if req.stored && !now.Before(req.expires) {
+			delete(st.requests, key)
+		}
+	}
+}
+
+// idempotencyKey returns the key of the store for the Idempotency-Key of a
+// request: the keys are chosen by the clients, so each actor has its own, and a
+// client cannot be replayed the response of another. The anonymous clients all
+// share an actor, so their keys are scoped to their IP address as well.
+func idempotencyKey(c *gin.Context, key string) string {
+	actor := common.IdentityFrom(c.Request.Context()).Actor
+	if actor == ActorAnonymous {
+		actor += "@" + c.ClientIP()
+	}
+	return actor + "\x00" + key
+}
+
+// fingerprint identifies a request by its method, URI, content type and body
+func fingerprint(r *http.Request, body []byte) [sha256.Size]byte {
+	h := sha256.New()
+	for _, part := range []string{r.Method, r.URL.RequestURI(), r.Header.Get("Content-Type")} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+
+	var out [sha256.Size]byte
+	copy(out[:], h.Sum(nil))
+	return out
+}
+
+// Idempotency returns a gin.HandlerFunc (middleware) making the POST requests
+// sent with an Idempotency-Key safe to retry. The response of the first request
+// with a key is kept for idempotency.ttl and replayed for the retries, which must
+// be identical: a key reused for another request is answered with a 422. A retry
+// arriving while the first request is in flight waits for its response.
+// Responses with a 5xx status are not kept, so that the request may be retried.
+// The keys are scoped to the actor of the request, see RequestContext, or to the
+// client IP for the anonymous requests, and kept in the memory of the instance:
+// the retries must reach the same instance.
+func Idempotency(s *common.App) gin.HandlerFunc {
+	st := newIdempotencyStore()
+
+	return func(c *gin.Context) {
+		cfg := s.Cfg.Idempotency
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if !cfg.Enabled || key == "" || c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+
+		if len(key) > MaxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("the Idempotency-Key must not be longer than %d characters", MaxIdempotencyKeyLength),
+			})
+			return
+		}
+
+		// the uploads are bounded like the imports, which are the largest bodies
+		maxSize := int64(s.Cfg.Import.MaxSizeMB) << 20
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxSize))
+		if err != nil {
+			status := http.StatusBadRequest
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			c.AbortWithStatusJSON(status, gin.H{
+				"error": "error reading the request body: " + err.Error(),
+			})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		fp := fingerprint(c.Request, body)
+		key = idempotencyKey(c, key)
+
+		for {
+			req, owner := st.begin(key, fp, time.Now())
+			if owner {
+				runIdempotent(c, st, key, req, cfg.TTL)
+				return
+			}
+
+			if req.fingerprint != fp {
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+					"error": "the Idempotency-Key was already used for another request",
+				})
+				return
+			}
+
+			select {
+			case <-req.done:
+			case <-c.Request.Context().Done():
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+					"error": "a request with the same Idempotency-Key is in progress",
+				})
+				return
+			}
+
+			if req.stored {
+				for name, values := range req.header {
+					c.Writer.Header()[name] = values
+				}
+				c.Header(IdempotentReplayedHeader, "true")
+				c.Writer.WriteHeader(req.status)
+				_, _ = c.Writer.Write(req.body)
+				c.Abort()
+				return
+			}
+			// the first request failed, run this one in its place
+		}
+	}
+}
+
+// runIdempotent runs the handlers of a request, storing its response unless it
+// failed
+func runIdempotent(c *gin.Context, st *idempotencyStore, key string, req *idempotentRequest, ttl time.Duration) {
+	status := 0
+	rec := &replayRecorder{ResponseWriter: c.Writer}
+	// the request is dropped if a handler panics
+	defer func() {
+		header := http.Header{}
+		for _, name := range replayedHeaders {
+			if values := rec.Header().Values(name); len(values) > 0 {
+				header[name] = values
+			}
+		}
+		st.finish(key, req, status, header, rec.body.Bytes(), ttl, time.Now())
+	}()
+
+	c.Writer = rec
+	c.Next()
+	c.Writer = rec.ResponseWriter
+
+	if rec.Status() < http.StatusInternalServerError {
+		status = rec.Status()
+	}
+}
+
+// replayRecorder keeps a copy of the response body written through it, whatever
+// its content type, to replay it
+type replayRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *replayRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *replayRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
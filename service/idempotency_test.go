@@ -0,0 +1,204 @@
+package service_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/service"
+)
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a POST route behind the Idempotency middleware", t, func() {
+		cfg := common.DefaultConfig()
+		var (
+			calls   int32
+			status  = http.StatusCreated
+			release chan struct{}
+			entered = make(chan struct{}, 1)
+		)
+
+		r := gin.New()
+		// the actor of the request, set by RequestContext in the service
+		r.Use(func(c *gin.Context) {
+			identity := common.Identity{Actor: c.GetHeader("X-Test-Actor")}
+			c.Request = c.Request.WithContext(common.WithIdentity(c.Request.Context(), identity))
+		})
+		r.Use(service.Idempotency(newTestApp(cfg)))
+		r.POST("/books", func(c *gin.Context) {
+			n := atomic.AddInt32(&calls, 1)
+			if release != nil {
+				entered <- struct{}{}
+				<-release
+			}
+			c.Header("Location", "/books/1")
+			c.JSON(status, gin.H{"call": n})
+		})
+
+		post := func(key, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if key != "" {
+				req.Header.Set(service.IdempotencyKeyHeader, key)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w
+		}
+
+		Convey("When a request is retried with the same key", func() {
+			first := post("key-1", `{"title": "Dune"}`)
+			retry := post("key-1", `{"title": "Dune"}`)
+
+			Convey("Then the first response should be replayed", func() {
+				So(atomic.LoadInt32(&calls), ShouldEqual, 1)
+				So(retry.Code, ShouldEqual, http.StatusCreated)
+				So(retry.Body.String(), ShouldEqual, first.Body.String())
+				So(retry.Header().Get("Location"), ShouldEqual, "/books/1")
+				So(retry.Header().Get(service.IdempotentReplayedHeader), ShouldEqual, "true")
+				So(first.Header().Get(service.IdempotentReplayedHeader), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When a request answered in XML is retried with the same key", func() {
+			r.POST("/books/xml", func(c *gin.Context) {
+				n := atomic.AddInt32(&calls, 1)
+				c.XML(http.StatusCreated, gin.H{"call": n})
+			})
+			send := func() *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodPost, "/books/xml", strings.NewReader(`{"title": "Dune"}`))
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set(service.IdempotencyKeyHeader, "key-xml")
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				return w
+			}
+			first, retry := send(), send()
+
+			Convey("Then the XML body should be replayed", func() {
+				So(atomic.LoadInt32(&calls), ShouldEqual, 1)
+				So(first.Body.String(), ShouldContainSubstring, "<call>1</call>")
+				So(retry.Body.String(), ShouldEqual, first.Body.String())
+				So(retry.Header().Get("Content-Type"), ShouldStartWith, "application/xml")
+				So(retry.Header().Get(service.IdempotentReplayedHeader), ShouldEqual, "true")
+			})
+		})
+
+		Convey("When another actor sends a request with the same key", func() {
+			post("key-1", `{"title": "Dune"}`)
+			req := httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(`{"title": "Dune"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(service.IdempotencyKeyHeader, "key-1")
+			req.Header.Set("X-Test-Actor", "apikey:pos")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			Convey("Then it should be run rather than replayed the response of the other actor", func() {
+				So(atomic.LoadInt32(&calls), ShouldEqual, 2)
+				So(w.Body.String(), ShouldContainSubstring, `"call":2`)
+				So(w.Header().Get(service.IdempotentReplayedHeader), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When anonymous clients send requests with the same key", func() {
+			send := func(ip string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(`{"title": "Dune"}`))
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set(service.IdempotencyKeyHeader, "key-1")
+				req.Header.Set("X-Test-Actor", service.ActorAnonymous)
+				req.RemoteAddr = ip + ":1234"
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				return w
+			}
+			send("192.0.2.1")
+			other := send("192.0.2.2")
+			retry := send("192.0.2.1")
+
+			Convey("Then each client should only be replayed its own response", func() {
+				So(atomic.LoadInt32(&calls), ShouldEqual, 2)
+				So(other.Header().Get(service.IdempotentReplayedHeader), ShouldBeEmpty)
+				So(retry.Header().Get(service.IdempotentReplayedHeader), ShouldEqual, "true")
+			})
+		})
+
+		Convey("When the key is reused for another body", func() {
+			post("key-1", `{"title": "Dune"}`)
+			w := post("key-1", `{"title": "Emma"}`)
+
+			Convey("Then it should return a 422 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusUnprocessableEntity)
+				So(atomic.LoadInt32(&calls), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When requests are sent without a key", func() {
+			post("", `{"title": "Dune"}`)
+			post("", `{"title": "Dune"}`)
+
+			Convey("Then each of them should be run", func() {
+				So(atomic.LoadInt32(&calls), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When the first request fails", func() {
+			status = http.StatusInternalServerError
+			post("key-1", `{"title": "Dune"}`)
+			status = http.StatusCreated
+			w := post("key-1", `{"title": "Dune"}`)
+
+			Convey("Then the retry should be run", func() {
+				So(atomic.LoadInt32(&calls), ShouldEqual, 2)
+				So(w.Code, ShouldEqual, http.StatusCreated)
+				So(w.Header().Get(service.IdempotentReplayedHeader), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When the response has expired", func() {
+			cfg.Idempotency.TTL = time.Millisecond
+			post("key-1", `{"title": "Dune"}`)
+			time.Sleep(5 * time.Millisecond)
+			post("key-1", `{"title": "Dune"}`)
+
+			Convey("Then the request should be run again", func() {
+				So(atomic.LoadInt32(&calls), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When a retry arrives while the first request is in flight", func() {
+			release = make(chan struct{})
+			responses := make([]*httptest.ResponseRecorder, 2)
+			var wg sync.WaitGroup
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				responses[0] = post("key-1", `{"title": "Dune"}`)
+			}()
+			<-entered
+			go func() {
+				defer wg.Done()
+				responses[1] = post("key-1", `{"title": "Dune"}`)
+			}()
+			// give the retry the time to wait for the first request
+			time.Sleep(10 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			Convey("Then it should get the response of the first request", func() {
+				So(atomic.LoadInt32(&calls), ShouldEqual, 1)
+				So(responses[1].Code, ShouldEqual, http.StatusCreated)
+				So(responses[1].Body.String(), ShouldEqual, responses[0].Body.String())
+				So(responses[1].Header().Get(service.IdempotentReplayedHeader), ShouldEqual, "true")
+			})
+		})
+	})
+}
@@ -0,0 +1,32 @@
+package service
+
+// Migrations of the DB run at startup.
+
+import (
+	"context"
+
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/db"
+	"github.com/snehil-sinha/goBookStore/migrations"
+	"go.uber.org/zap"
+)
+
+// Migrate runs the pending migrations, unless migrations.auto is off. Another
+// instance running them is waited for up to migrations.lock_timeout.
+func Migrate(ctx context.Context, s *common.App) error {
+	if !s.Cfg.Migrations.Auto {
+		return nil
+	}
+
+	m, err := migrations.New(s.Log, db.Client.Database(), migrations.All)
+	if err != nil {
+		return err
+	}
+	m.LockTimeout = s.Cfg.Migrations.LockTimeout
+
+	ran, err := m.Run(ctx, migrations.Up, 0, false)
+	if len(ran) > 0 {
+		s.Log.Info("migrated the DB", zap.Int("migrations", len(ran)), zap.Int("version", ran[len(ran)-1].Version))
+	}
+	return err
+}
@@ -0,0 +1,166 @@
+package openapi
+
+// A subset of the OpenAPI 3.1 document model, enough to describe the service API.
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Version of the OpenAPI specification the documents conform to
+const Version = "3.1.0"
+
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Servers    []Server              `json:"servers,omitempty"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []SecurityRequirement `json:"security,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps lower case HTTP methods to their operation
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []SecurityRequirement `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path, query or header
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Description string                `json:"description,omitempty"`
+	Required    bool                  `json:"required,omitempty"`
+	Content     map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// SecurityRequirement maps security scheme names to their scopes
+type SecurityRequirement map[string][]string
+
+// New returns an empty document
+func New(title, version string) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:   title,
+			Version: version,
+		},
+		Paths: make(map[string]PathItem),
+		Components: Components{
+			Schemas:         make(map[string]*Schema),
+			SecuritySchemes: make(map[string]*SecurityScheme),
+		},
+	}
+}
+
+// AddOperation documents the operation served at the gin route path, e.g.
+// "/api/v1/books/:id"
+func (d *Document) AddOperation(method, ginPath string, op *Operation) {
+	path := PathFromGin(ginPath)
+	if d.Paths[path] == nil {
+		d.Paths[path] = make(PathItem)
+	}
+	d.Paths[path][strings.ToLower(method)] = op
+}
+
+// Operation returns the operation documented for the gin route path, nil if none
+func (d *Document) Operation(method, ginPath string) *Operation {
+	return d.Paths[PathFromGin(ginPath)][strings.ToLower(method)]
+}
+
+// AddSchema registers a named schema under #/components/schemas and returns a
+// reference to it
+func (d *Document) AddSchema(name string, s *Schema) *Schema {
+	d.Components.Schemas[name] = s
+	return Ref(name)
+}
+
+// Resolve follows a reference to a component schema
+func (d *Document) Resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+var ginParam = regexp.MustCompile(`[:*]([^/]+)`)
+
+// CustomMethodPrefix marks the last segment of a gin route path as the custom
+// method of the resource before it, e.g. /books/@batch for POST /books:batch.
+// Gin reads any ":" in a route path as the start of a parameter, so custom
+// methods cannot be registered under their own path.
+const CustomMethodPrefix = "/@"
+
+// CustomMethod returns the gin route path of a custom method of the resource path
+func CustomMethod(path, method string) string {
+	return path + CustomMethodPrefix + method
+}
+
+// PathFromGin converts a gin route path to an OpenAPI path template,
+// e.g. /books/:id to /books/{id} and /books/@batch to /books:batch
+func PathFromGin(path string) string {
+	path = ginParam.ReplaceAllString(path, "{$1}")
+	if i := strings.LastIndex(path, CustomMethodPrefix); i >= 0 {
+		path = path[:i] + ":" + path[i+len(CustomMethodPrefix):]
+	}
+	return path
+}
+
+// JSON returns a content map of a single application/json media type
+func JSON(s *Schema) map[string]*MediaType {
+	return map[string]*MediaType{"application/json": {Schema: s}}
+}
@@ -0,0 +1,248 @@
+package openapi
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ObjectIDPattern matches the hex representation of a Mongo ObjectID
+const ObjectIDPattern = "^[0-9a-fA-F]{24}$"
+
+type Schema struct {
+	Ref              string             `json:"$ref,omitempty"`
+	Type             string             `json:"type,omitempty"`
+	Format           string             `json:"format,omitempty"`
+	Description      string             `json:"description,omitempty"`
+	Pattern          string             `json:"pattern,omitempty"`
+	Enum             []any              `json:"enum,omitempty"`
+	Minimum          *float64           `json:"minimum,omitempty"`
+	ExclusiveMinimum *float64           `json:"exclusiveMinimum,omitempty"`
+	Maximum          *float64           `json:"maximum,omitempty"`
+	ExclusiveMaximum *float64           `json:"exclusiveMaximum,omitempty"`
+	MinLength        *int               `json:"minLength,omitempty"`
+	MaxLength        *int               `json:"maxLength,omitempty"`
+	MinItems         *int               `json:"minItems,omitempty"`
+	MaxItems         *int               `json:"maxItems,omitempty"`
+	Items            *Schema            `json:"items,omitempty"`
+	Properties       map[string]*Schema `json:"properties,omitempty"`
+	Required         []string           `json:"required,omitempty"`
+	ReadOnly         bool               `json:"readOnly,omitempty"`
+}
+
+// Ref returns a reference to the named component schema
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// String returns a string schema
+func String() *Schema {
+	return &Schema{Type: "string"}
+}
+
+// Integer returns an integer schema
+func Integer() *Schema {
+	return &Schema{Type: "integer"}
+}
+
+// Array returns an array schema of items
+func Array(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+// Object returns an object schema with the given properties, all of them required
+func Object(properties map[string]*Schema) *Schema {
+	s := &Schema{Type: "object", Properties: properties}
+	for name := range properties {
+		s.Required = append(s.Required, name)
+	}
+	sort.Strings(s.Required)
+	return s
+}
+
+// ObjectID returns the schema of a hex encoded Mongo ObjectID
+func ObjectID() *Schema {
+	return &Schema{Type: "string", Pattern: ObjectIDPattern, Description: "hex encoded ObjectID"}
+}
+
+// MarkReadOnly flags the named properties as read only, i.e. set by the server
+func (s *Schema) MarkReadOnly(names ...string) *Schema {
+	for _, name := range names {
+		if p, ok := s.Properties[name]; ok {
+			p.ReadOnly = true
+		}
+	}
+	return s
+}
+
+// Optional returns a copy of the object schema without required properties
+func (s *Schema) Optional() *Schema {
+	out := *s
+	out.Required = nil
+	return &out
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+)
+
+// SchemaOf derives the schema of v from its type and its json and validate
+// struct tags, e.g. validate:"required,gte=1" marks a property as required with
+// a minimum of 1.
+func SchemaOf(v any) *Schema {
+	return schemaOf(reflect.TypeOf(v))
+}
+
+func schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case objectIDType:
+		return ObjectID()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return String()
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Integer()
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return Array(schemaOf(t.Elem()))
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		addProperties(s, t)
+		return s
+	}
+	return &Schema{}
+}
+
+// Add the exported fields of struct type t to the object schema s, flattening
+// embedded structs the way encoding/json does
+func addProperties(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			addProperties(s, ft)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		p := schemaOf(f.Type)
+		if applyValidateTag(p, f.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = p
+	}
+}
+
+// Translate go-playground/validator rules to schema constraints. Returns true if
+// the field is required.
+func applyValidateTag(s *Schema, tag string) (required bool) {
+	if tag == "" || tag == "-" {
+		return false
+	}
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		n, numErr := strconv.ParseFloat(param, 64)
+		hasNum := numErr == nil
+
+		switch name {
+		case "required":
+			required = true
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				s.Enum = append(s.Enum, v)
+			}
+		case "email":
+			s.Format = "email"
+		case "uri", "url":
+			s.Format = "uri"
+		case "gt", "gte", "min", "lt", "lte", "max", "len":
+			if hasNum {
+				applyBound(s, name, n)
+			}
+		}
+	}
+	return
+}
+
+// Apply a numeric validator bound: to the value of numbers, to the length of
+// strings and to the number of items of arrays
+func applyBound(s *Schema, rule string, n float64) {
+	switch s.Type {
+	case "integer", "number":
+		switch rule {
+		case "gt":
+			s.ExclusiveMinimum = &n
+		case "gte", "min":
+			s.Minimum = &n
+		case "lt":
+			s.ExclusiveMaximum = &n
+		case "lte", "max":
+			s.Maximum = &n
+		case "len":
+			s.Minimum, s.Maximum = &n, &n
+		}
+	case "string", "array":
+		l := int(n)
+		var min, max *int
+		switch rule {
+		case "gt":
+			l++
+			min = &l
+		case "gte", "min":
+			min = &l
+		case "lt":
+			l--
+			max = &l
+		case "lte", "max":
+			max = &l
+		case "len":
+			min, max = &l, &l
+		}
+		if s.Type == "string" {
+			if min != nil {
+				s.MinLength = min
+			}
+			if max != nil {
+				s.MaxLength = max
+			}
+		} else {
+			if min != nil {
+				s.MinItems = min
+			}
+			if max != nil {
+				s.MaxItems = max
+			}
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Violation describes a value which does not conform to the document
+type Violation struct {
+	In      string `json:"in"` // path, query, header, body or response
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	if v.Field == "" {
+		return fmt.Sprintf("%s: %s", v.In, v.Message)
+	}
+	return fmt.Sprintf("%s %s: %s", v.In, v.Field, v.Message)
+}
+
+// compiled patterns, shared by all documents
+var patterns sync.Map
+
+func compilePattern(p string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(p); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(p)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(p, re)
+	return re, nil
+}
+
+// ValidateParam checks the raw value of a path, query or header parameter
+func (d *Document) ValidateParam(p *Parameter, raw string, present bool) []Violation {
+	if !present {
+		if p.Required {
+			return []Violation{{In: p.In, Field: p.Name, Message: "is required"}}
+		}
+		return nil
+	}
+
+	s := d.Resolve(p.Schema)
+	if s == nil {
+		return nil
+	}
+
+	var value any = raw
+	switch s.Type {
+	case "integer", "number":
+		value = json.Number(raw)
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return []Violation{{In: p.In, Field: p.Name, Message: "must be a boolean"}}
+		}
+		value = b
+	}
+	return d.ValidateValue(s, value, p.In, p.Name)
+}
+
+// ValidateValue checks a value decoded from JSON (with json.Decoder.UseNumber)
+// against the schema
+func (d *Document) ValidateValue(s *Schema, value any, in, field string) (violations []Violation) {
+	s = d.Resolve(s)
+	if s == nil {
+		return nil
+	}
+
+	fail := func(format string, args ...any) []Violation {
+		return append(violations, Violation{In: in, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if value == nil {
+		if s.Type == "" {
+			return nil
+		}
+		return fail("must not be null")
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fail("must be one of %v", s.Enum)
+		}
+	}
+
+	switch s.Type {
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fail("must be a string")
+		}
+		return append(violations, d.validateString(s, str, in, field)...)
+
+	case "integer", "number":
+		kind := "a number"
+		if s.Type == "integer" {
+			kind = "an integer"
+		}
+		n, ok := value.(json.Number)
+		if !ok {
+			return fail("must be %s", kind)
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return fail("must be %s", kind)
+		}
+		if _, err := n.Int64(); err != nil && s.Type == "integer" {
+			return fail("must be %s", kind)
+		}
+		switch {
+		case s.Minimum != nil && f < *s.Minimum:
+			return fail("must be greater than or equal to %v", *s.Minimum)
+		case s.ExclusiveMinimum != nil && f <= *s.ExclusiveMinimum:
+			return fail("must be greater than %v", *s.ExclusiveMinimum)
+		case s.Maximum != nil && f > *s.Maximum:
+			return fail("must be less than or equal to %v", *s.Maximum)
+		case s.ExclusiveMaximum != nil && f >= *s.ExclusiveMaximum:
+			return fail("must be less than %v", *s.ExclusiveMaximum)
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fail("must be a boolean")
+		}
+
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return fail("must be an array")
+		}
+		if s.MinItems != nil && len(items) < *s.MinItems {
+			return fail("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(items) > *s.MaxItems {
+			return fail("must have at most %d items", *s.MaxItems)
+		}
+		for i, item := range items {
+			violations = append(violations, d.ValidateValue(s.Items, item, in, fmt.Sprintf("%s[%d]", field, i))...)
+		}
+
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fail("must be an object")
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				violations = append(violations, Violation{In: in, Field: join(field, name), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if p, ok := s.Properties[name]; ok {
+				violations = append(violations, d.ValidateValue(p, obj[name], in, join(field, name))...)
+			}
+		}
+	}
+	return violations
+}
+
+func (d *Document) validateString(s *Schema, str, in, field string) []Violation {
+	fail := func(format string, args ...any) []Violation {
+		return []Violation{{In: in, Field: field, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	n := utf8.RuneCountInString(str)
+	if s.MinLength != nil && n < *s.MinLength {
+		return fail("must be at least %d characters long", *s.MinLength)
+	}
+	if s.MaxLength != nil && n > *s.MaxLength {
+		return fail("must be at most %d characters long", *s.MaxLength)
+	}
+	if s.Pattern != "" {
+		re, err := compilePattern(s.Pattern)
+		if err == nil && !re.MatchString(str) {
+			if s.Description != "" {
+				return fail("must be a %s", s.Description)
+			}
+			return fail("must match the pattern %s", s.Pattern)
+		}
+	}
+	if s.Format == "date-time" {
+		if _, err := time.Parse(time.RFC3339, str); err != nil {
+			return fail("must be an RFC 3339 date-time")
+		}
+	}
+	return nil
+}
+
+func join(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// MediaTypes lists the media types of a content map
+func MediaTypes(content map[string]*MediaType) []string {
+	out := make([]string, 0, len(content))
+	for mt := range content {
+		out = append(out, mt)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// IsJSON reports whether a media type carries JSON
+func IsJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,99 @@
+package service
+
+// Per client request rate limiting.
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/snehil-sinha/goBookStore/common"
+)
+
+// How often idle buckets are dropped
+const rateLimitSweepInterval = time.Minute
+
+// bucket is a token bucket refilled continuously at the configured rate
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow takes a token from the bucket of key. When the bucket is empty it returns
+// false and the time until the next token is available.
+func (rl *rateLimiter) allow(key string, rps float64, burst int, now time.Time) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.sweep(rps, burst, now)
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		rl.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rps)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / rps * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// drop the buckets which have been idle long enough to be full again
+func (rl *rateLimiter) sweep(rps float64, burst int, now time.Time) {
+	if now.Sub(rl.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	rl.lastSweep = now
+
+	refill := time.Duration(float64(burst) / rps * float64(time.Second))
+	for key, b := range rl.buckets {
+		if now.Sub(b.last) > refill {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// RateLimit returns a gin.HandlerFunc (middleware) limiting the request rate per client IP.
+// The limits are read from the live config, so they follow config reloads.
+func RateLimit(s *common.App) gin.HandlerFunc {
+	rl := newRateLimiter()
+
+	return func(c *gin.Context) {
+		cfg := s.Config().RateLimit
+		if !cfg.Enabled {
+			c.Next()
+			return
+		}
+
+		ok, retryAfter := rl.allow(c.ClientIP(), cfg.RequestsPerSecond, cfg.Burst, time.Now())
+		if !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "rate limit exceeded",
+			})
+			return
+		}
+		c.Next()
+	}
+}
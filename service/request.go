@@ -0,0 +1,187 @@
+package service
+
+// Identification of the requests and of their actors.
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/apikey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDHeader carries the id of a request, given by the client or generated
+const RequestIDHeader = "X-Request-ID"
+
+// APIKeyHeader carries the API key of a client
+const APIKeyHeader = "X-API-Key"
+
+// Actors of the requests; the clients sending an API key are identified as
+// ActorAPIKey followed by the name of the key
+const (
+	ActorAdmin     = "admin"
+	ActorAnonymous = "anonymous"
+	ActorAPIKey    = "apikey:"
+)
+
+// errInvalidAPIKey is returned for an unknown or revoked API key, or a missing
+// one when they are required
+var errInvalidAPIKey = errors.New("missing or invalid API key")
+
+// requestIDPattern restricts the request ids given by the clients, which are
+// logged and stored in the audit log
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// newRequestID returns a random request id
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// actorOf returns the actor of a request carrying the bearer token and the API
+// key, either of which may be empty. It fails with errInvalidAPIKey for an
+// unknown or revoked key, or a missing one if they are required for the path.
+func actorOf(s *common.App, keys apikey.APIKeyService, path, bearer, key string) (string, error) {
+	if isToken(bearer, s.Config().Admin.Token) {
+		return ActorAdmin, nil
+	}
+	if key != "" && keys != nil {
+		k, err := keys.Authenticate(s.Log, key)
+		if errors.Is(err, apikey.ErrNotFound) {
+			return "", errInvalidAPIKey
+		}
+		if err != nil {
+			return "", err
+		}
+		return ActorAPIKey + k.Name, nil
+	}
+	if s.Config().Auth.RequireAPIKey && requiresAPIKey(path) {
+		return "", errInvalidAPIKey
+	}
+	return ActorAnonymous, nil
+}
+
+// requiresAPIKey tells if the API keys are required for a path, when
+// auth.require_api_key is set: the health check and the docs are left open
+func requiresAPIKey(path string) bool {
+	return strings.HasPrefix(path, "/api/") || path == "/graphql" || strings.HasPrefix(path, "/book.v1.")
+}
+
+// RequestContext returns a gin.HandlerFunc (middleware) identifying every request
+// by the X-Request-ID header, generated unless the client sent a valid one, and
+// by its actor: admin for the requests carrying the admin token, the API key of
+// the X-API-Key header (keys may be nil to skip them), or anonymous. Requests
+// with an invalid key, or without one if they are required, are rejected with
+// a 401. The request id is echoed in the response, and both are passed to the
+// services through the request context, see common.IdentityFrom, along with the
+// logger tagged with them.
+func RequestContext(s *common.App, keys apikey.APIKeyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+
+		bearer, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		path := c.Request.URL.Path
+		// the CORS preflights carry no credentials
+		if c.Request.Method == http.MethodOptions {
+			path = ""
+		}
+		actor, err := actorOf(s, keys, path, bearer, c.GetHeader(APIKeyHeader))
+		if err != nil {
+			if errors.Is(err, errInvalidAPIKey) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "server encountered an unknown error"})
+			return
+		}
+
+		c.Request = c.Request.WithContext(requestContext(c.Request.Context(), s, id, actor))
+		c.Next()
+	}
+}
+
+// RequestInterceptor returns a grpc.UnaryServerInterceptor doing for the gRPC
+// calls what RequestContext does for the HTTP requests, reading the request id,
+// the bearer token and the API key from the x-request-id, authorization and
+// x-api-key metadata
+func RequestInterceptor(s *common.App, keys apikey.APIKeyService) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := grpcRequestContext(ctx, s, keys, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamRequestInterceptor returns a grpc.StreamServerInterceptor doing for the
+// streaming calls what RequestInterceptor does for the unary ones
+func StreamRequestInterceptor(s *common.App, keys apikey.APIKeyService) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := grpcRequestContext(ss.Context(), s, keys, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &requestStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// requestStream is a server stream with the context of its request
+type requestStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (rs *requestStream) Context() context.Context { return rs.ctx }
+
+// grpcRequestContext returns the context of a gRPC call, carrying its request id
+// and actor and the logger tagged with them, or a status error rejecting the call
+func grpcRequestContext(ctx context.Context, s *common.App, keys apikey.APIKeyService, method string) (context.Context, error) {
+	idKey, apiKey := strings.ToLower(RequestIDHeader), strings.ToLower(APIKeyHeader)
+	md, _ := metadata.FromIncomingContext(ctx)
+	get := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	id := get(idKey)
+	if !requestIDPattern.MatchString(id) {
+		id = newRequestID()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(idKey, id))
+
+	bearer, _ := strings.CutPrefix(get("authorization"), "Bearer ")
+	actor, err := actorOf(s, keys, method, bearer, get(apiKey))
+	if err != nil {
+		if errors.Is(err, errInvalidAPIKey) {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return nil, status.Error(codes.Internal, "server encountered an unknown error")
+	}
+	return requestContext(ctx, s, id, actor), nil
+}
+
+// requestContext returns ctx carrying the identity of a request, which the
+// services record along with the changes made by the request, and the logger
+// tagged with it
+func requestContext(ctx context.Context, s *common.App, id, actor string) context.Context {
+	identity := common.Identity{RequestID: id, Actor: actor}
+	return common.WithLogger(common.WithIdentity(ctx, identity), s.Log.ForRequest(identity))
+}
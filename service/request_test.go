@@ -0,0 +1,163 @@
+package service_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/apikey"
+	"github.com/snehil-sinha/goBookStore/models/apikey/mocks"
+	"github.com/snehil-sinha/goBookStore/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestRequestContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a route behind the RequestContext middleware", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		cfg := common.DefaultConfig()
+		cfg.Admin.Token = "s3cret"
+		keys := mocks.NewMockAPIKeyService(ctrl)
+
+		var identity common.Identity
+		r := gin.New()
+		r.Use(service.RequestContext(newTestApp(cfg), keys))
+		r.GET("/api/v1/books", func(c *gin.Context) {
+			identity = common.IdentityFrom(c.Request.Context())
+			c.Status(http.StatusOK)
+		})
+
+		do := func(header map[string]string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/books", nil)
+			for name, value := range header {
+				req.Header.Set(name, value)
+			}
+			r.ServeHTTP(w, req)
+			return w
+		}
+
+		Convey("When a request has no id", func() {
+			w := do(nil)
+
+			Convey("Then an anonymous request id should be generated and echoed", func() {
+				id := w.Header().Get(service.RequestIDHeader)
+				So(id, ShouldHaveLength, 32)
+				So(identity.RequestID, ShouldEqual, id)
+				So(identity.Actor, ShouldEqual, service.ActorAnonymous)
+			})
+		})
+
+		Convey("When an admin request has an id", func() {
+			w := do(map[string]string{
+				service.RequestIDHeader: "checkout-42",
+				"Authorization":         "Bearer s3cret",
+			})
+
+			Convey("Then the id should be kept and the actor be admin", func() {
+				So(w.Header().Get(service.RequestIDHeader), ShouldEqual, "checkout-42")
+				So(identity.RequestID, ShouldEqual, "checkout-42")
+				So(identity.Actor, ShouldEqual, service.ActorAdmin)
+			})
+		})
+
+		Convey("When a request has a valid API key", func() {
+			keys.EXPECT().Authenticate(gomock.Any(), "gbs_pos").Return(&apikey.APIKey{Name: "pos"}, nil)
+			w := do(map[string]string{service.APIKeyHeader: "gbs_pos"})
+
+			Convey("Then the actor should be the key", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(identity.Actor, ShouldEqual, "apikey:pos")
+			})
+		})
+
+		Convey("When a request has a revoked API key", func() {
+			keys.EXPECT().Authenticate(gomock.Any(), "gbs_old").Return(nil, apikey.ErrNotFound)
+
+			Convey("Then it should return a 401 status code", func() {
+				So(do(map[string]string{service.APIKeyHeader: "gbs_old"}).Code, ShouldEqual, http.StatusUnauthorized)
+			})
+		})
+
+		Convey("When API keys are required and a request has none", func() {
+			cfg.Auth.RequireAPIKey = true
+
+			Convey("Then it should return a 401 status code", func() {
+				So(do(nil).Code, ShouldEqual, http.StatusUnauthorized)
+			})
+
+			Convey("Then an admin request should be let through", func() {
+				So(do(map[string]string{"Authorization": "Bearer s3cret"}).Code, ShouldEqual, http.StatusOK)
+			})
+		})
+
+		Convey("When a request has an invalid id", func() {
+			w := do(map[string]string{service.RequestIDHeader: "<script>"})
+
+			Convey("Then another id should be generated", func() {
+				So(w.Header().Get(service.RequestIDHeader), ShouldHaveLength, 32)
+			})
+		})
+	})
+}
+
+// testStream is a server stream with only a context
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (ts *testStream) Context() context.Context { return ts.ctx }
+
+func TestStreamRequestInterceptor(t *testing.T) {
+	Convey("Given a streaming call behind the StreamRequestInterceptor", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		cfg := common.DefaultConfig()
+		keys := mocks.NewMockAPIKeyService(ctrl)
+		interceptor := service.StreamRequestInterceptor(newTestApp(cfg), keys)
+		info := &grpc.StreamServerInfo{FullMethod: "/book.v1.BookService/WatchBooks", IsServerStream: true}
+
+		var identity common.Identity
+		call := func(md metadata.MD) error {
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+			return interceptor(nil, &testStream{ctx: ctx}, info, func(srv any, ss grpc.ServerStream) error {
+				identity = common.IdentityFrom(ss.Context())
+				return nil
+			})
+		}
+
+		Convey("When the call has a valid API key", func() {
+			keys.EXPECT().Authenticate(gomock.Any(), "gbs_pos").Return(&apikey.APIKey{Name: "pos"}, nil)
+			err := call(metadata.Pairs("x-api-key", "gbs_pos"))
+
+			Convey("Then the stream should carry the identity of the key", func() {
+				So(err, ShouldBeNil)
+				So(identity.Actor, ShouldEqual, "apikey:pos")
+				So(identity.RequestID, ShouldHaveLength, 32)
+			})
+		})
+
+		Convey("When API keys are required and the call has none", func() {
+			cfg.Auth.RequireAPIKey = true
+			err := call(metadata.MD{})
+
+			Convey("Then it should be rejected as unauthenticated", func() {
+				So(status.Code(err), ShouldEqual, codes.Unauthenticated)
+				So(identity, ShouldResemble, common.Identity{})
+			})
+		})
+	})
+}
@@ -0,0 +1,211 @@
+package rpc
+
+// gRPC implementation of the book catalog, backed by book.BookService.
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/events"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	bookv1 "github.com/snehil-sinha/goBookStore/proto/book/v1"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 1000
+)
+
+// BookServer serves bookv1.BookService
+type BookServer struct {
+	bookv1.UnimplementedBookServiceServer
+
+	svc book.BookService
+	s   *common.App
+}
+
+// NewBookServer returns the gRPC server of the book catalog
+func NewBookServer(svc book.BookService, s *common.App) *BookServer {
+	return &BookServer{svc: svc, s: s}
+}
+
+// log returns the logger of the call, see common.LoggerFrom
+func (bs *BookServer) log(ctx context.Context) *common.Logger {
+	return common.LoggerFrom(ctx, bs.s.Log)
+}
+
+// GetBook fetches a book by id
+func (bs *BookServer) GetBook(ctx context.Context, req *bookv1.GetBookRequest) (*bookv1.Book, error) {
+	b, err := bs.svc.ReadById(bs.log(ctx), req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(b), nil
+}
+
+// ListBooks fetches a page of books, ordered by id
+func (bs *BookServer) ListBooks(ctx context.Context, req *bookv1.ListBooksRequest) (*bookv1.ListBooksResponse, error) {
+	size := int64(req.GetPageSize())
+	switch {
+	case size < 0:
+		return nil, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	case size == 0:
+		size = defaultPageSize
+	case size > maxPageSize:
+		size = maxPageSize
+	}
+
+	offset, err := decodePageToken(req.GetPageToken())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+
+	books, total, err := bs.svc.List(bs.log(ctx), book.ListOptions{Skip: offset, Limit: size})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &bookv1.ListBooksResponse{
+		Books:     make([]*bookv1.Book, len(books)),
+		TotalSize: total,
+	}
+	for i, b := range books {
+		resp.Books[i] = toProto(b)
+	}
+	if next := offset + int64(len(books)); len(books) > 0 && next < total {
+		resp.NextPageToken = encodePageToken(next)
+	}
+	return resp, nil
+}
+
+// CreateBook creates a book
+func (bs *BookServer) CreateBook(ctx context.Context, req *bookv1.CreateBookRequest) (*bookv1.Book, error) {
+	if req.GetBook() == nil {
+		return nil, status.Error(codes.InvalidArgument, "book is required")
+	}
+
+	b, err := bs.svc.Create(ctx, bs.log(ctx), book.NewBook(req.GetBook().GetTitle(), int(req.GetBook().GetPages())))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(b), nil
+}
+
+// UpdateBook updates the fields of a book which are set in the request
+func (bs *BookServer) UpdateBook(ctx context.Context, req *bookv1.UpdateBookRequest) (*bookv1.Book, error) {
+	if req.GetBook() == nil {
+		return nil, status.Error(codes.InvalidArgument, "book is required")
+	}
+	if req.GetBook().GetPages() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "pages must be greater than or equal to 1")
+	}
+
+	b, err := bs.svc.Update(ctx, bs.log(ctx), req.GetId(), book.NewBook(req.GetBook().GetTitle(), int(req.GetBook().GetPages())))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(b), nil
+}
+
+// DeleteBook deletes a book by id
+func (bs *BookServer) DeleteBook(ctx context.Context, req *bookv1.DeleteBookRequest) (*bookv1.DeleteBookResponse, error) {
+	if err := bs.svc.Delete(ctx, bs.log(ctx), req.GetId()); err != nil {
+		return nil, toStatus(err)
+	}
+	return &bookv1.DeleteBookResponse{}, nil
+}
+
+// WatchBooks streams the changes made to the catalog until the client goes away
+func (bs *BookServer) WatchBooks(req *bookv1.WatchBooksRequest, stream bookv1.BookService_WatchBooksServer) error {
+	if bs.s.Events == nil {
+		return status.Error(codes.Unavailable, "events are not enabled")
+	}
+
+	sub := bs.s.Events.Subscribe()
+	defer sub.Cancel()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e, ok := <-sub.Events():
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "the watcher fell behind and was dropped")
+			}
+			if err := stream.Send(toProtoEvent(e)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// toStatus maps the errors of the book service to gRPC status codes
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, book.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, book.ErrDuplicate):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, book.ErrConflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, primitive.ErrInvalidHex), errors.Is(err, book.ErrValidation):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Internal, "server encountered an unknown error")
+}
+
+func toProto(b *book.Book) *bookv1.Book {
+	return &bookv1.Book{
+		Id:        b.ID.Hex(),
+		Title:     b.Title,
+		Pages:     int32(b.Pages),
+		CreatedAt: timestamppb.New(b.CreatedAt),
+		UpdatedAt: timestamppb.New(b.UpdatedAt),
+	}
+}
+
+var eventTypes = map[events.Type]bookv1.BookEvent_Type{
+	events.Created: bookv1.BookEvent_TYPE_CREATED,
+	events.Updated: bookv1.BookEvent_TYPE_UPDATED,
+	events.Deleted: bookv1.BookEvent_TYPE_DELETED,
+}
+
+func toProtoEvent(e events.Event) *bookv1.BookEvent {
+	out := &bookv1.BookEvent{
+		Id:     e.ID,
+		Type:   eventTypes[e.Type],
+		BookId: e.Subject,
+		Time:   timestamppb.New(e.Time),
+	}
+	if b, ok := e.Data.(*book.Book); ok {
+		out.Book = toProto(b)
+	}
+	return out
+}
+
+// page tokens are opaque to the clients, they encode the offset of the page
+func encodePageToken(offset int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(offset, 10)))
+}
+
+func decodePageToken(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.ParseInt(string(raw), 10, 64)
+	if err == nil && offset < 0 {
+		err = errors.New("negative offset")
+	}
+	return offset, err
+}
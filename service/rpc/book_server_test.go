@@ -0,0 +1,190 @@
+package rpc_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/events"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/models/book/mocks"
+	bookv1 "github.com/snehil-sinha/goBookStore/proto/book/v1"
+	"github.com/snehil-sinha/goBookStore/service/rpc"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dial serves the book catalog over an in-memory connection
+func dial(t *testing.T, svc book.BookService, s *common.App) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	srv := rpc.NewServer(svc, s)
+	go srv.Serve(lis)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		srv.Stop()
+	})
+	return conn
+}
+
+func newBook(title string, pages int) *book.Book {
+	b := book.NewBook(title, pages)
+	b.ID = primitive.NewObjectID()
+	return b
+}
+
+func TestBookServer(t *testing.T) {
+	Convey("Given a gRPC client of the book catalog", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		m := mocks.NewMockBookService(ctrl)
+		s := &common.App{Log: &common.Logger{Logger: zap.NewNop()}, Events: events.NewBus()}
+		conn := dial(t, m, s)
+		client := bookv1.NewBookServiceClient(conn)
+		ctx := context.Background()
+
+		Convey("When an existing book is requested", func() {
+			b := newBook("Dune", 412)
+			m.EXPECT().ReadById(gomock.Any(), b.ID.Hex()).Return(b, nil)
+
+			resp, err := client.GetBook(ctx, &bookv1.GetBookRequest{Id: b.ID.Hex()})
+
+			Convey("Then the book should be returned", func() {
+				So(err, ShouldBeNil)
+				So(resp.GetTitle(), ShouldEqual, "Dune")
+				So(resp.GetPages(), ShouldEqual, 412)
+			})
+		})
+
+		Convey("When a missing book is requested", func() {
+			m.EXPECT().ReadById(gomock.Any(), gomock.Any()).Return(nil, book.ErrNotFound)
+
+			_, err := client.GetBook(ctx, &bookv1.GetBookRequest{Id: primitive.NewObjectID().Hex()})
+
+			Convey("Then it should fail with NotFound", func() {
+				So(status.Code(err), ShouldEqual, codes.NotFound)
+			})
+		})
+
+		Convey("When a book is requested with an invalid id", func() {
+			m.EXPECT().ReadById(gomock.Any(), "abcd").Return(nil, primitive.ErrInvalidHex)
+
+			_, err := client.GetBook(ctx, &bookv1.GetBookRequest{Id: "abcd"})
+
+			Convey("Then it should fail with InvalidArgument", func() {
+				So(status.Code(err), ShouldEqual, codes.InvalidArgument)
+			})
+		})
+
+		Convey("When the books are listed page by page", func() {
+			m.EXPECT().List(gomock.Any(), book.ListOptions{Skip: 0, Limit: 2}).
+				Return([]*book.Book{newBook("A", 1), newBook("B", 2)}, int64(3), nil)
+			m.EXPECT().List(gomock.Any(), book.ListOptions{Skip: 2, Limit: 2}).
+				Return([]*book.Book{newBook("C", 3)}, int64(3), nil)
+
+			first, err := client.ListBooks(ctx, &bookv1.ListBooksRequest{PageSize: 2})
+			So(err, ShouldBeNil)
+			last, err := client.ListBooks(ctx, &bookv1.ListBooksRequest{PageSize: 2, PageToken: first.GetNextPageToken()})
+			So(err, ShouldBeNil)
+
+			Convey("Then every page should link to the next one until the last", func() {
+				So(first.GetBooks(), ShouldHaveLength, 2)
+				So(first.GetTotalSize(), ShouldEqual, 3)
+				So(first.GetNextPageToken(), ShouldNotBeEmpty)
+				So(last.GetBooks()[0].GetTitle(), ShouldEqual, "C")
+				So(last.GetNextPageToken(), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When the books are listed with an invalid page token", func() {
+			_, err := client.ListBooks(ctx, &bookv1.ListBooksRequest{PageToken: "!"})
+
+			Convey("Then it should fail with InvalidArgument", func() {
+				So(status.Code(err), ShouldEqual, codes.InvalidArgument)
+			})
+		})
+
+		Convey("When a book is created", func() {
+			m.EXPECT().Create(gomock.Any(), gomock.Any(), book.NewBook("Dune", 412)).Return(newBook("Dune", 412), nil)
+
+			resp, err := client.CreateBook(ctx, &bookv1.CreateBookRequest{Book: &bookv1.Book{Title: "Dune", Pages: 412}})
+
+			Convey("Then the created book should be returned with its id", func() {
+				So(err, ShouldBeNil)
+				So(resp.GetId(), ShouldHaveLength, 24)
+			})
+		})
+
+		Convey("When a book already in the catalog is created", func() {
+			m.EXPECT().Create(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, book.ErrDuplicate)
+
+			_, err := client.CreateBook(ctx, &bookv1.CreateBookRequest{Book: &bookv1.Book{Title: "Dune", Pages: 412}})
+
+			Convey("Then it should fail with AlreadyExists", func() {
+				So(status.Code(err), ShouldEqual, codes.AlreadyExists)
+			})
+		})
+
+		Convey("When a book is deleted", func() {
+			id := primitive.NewObjectID().Hex()
+			m.EXPECT().Delete(gomock.Any(), gomock.Any(), id).Return(nil)
+
+			_, err := client.DeleteBook(ctx, &bookv1.DeleteBookRequest{Id: id})
+
+			Convey("Then it should succeed", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("When the catalog is watched", func() {
+			wctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			stream, err := client.WatchBooks(wctx, &bookv1.WatchBooksRequest{})
+			So(err, ShouldBeNil)
+
+			// wait for the server to subscribe before publishing
+			b := newBook("Dune", 412)
+			go func() {
+				for i := 0; i < 50; i++ {
+					s.Events.Publish(events.Created, b.ID.Hex(), b)
+					time.Sleep(20 * time.Millisecond)
+				}
+			}()
+			e, err := stream.Recv()
+
+			Convey("Then the changes should be streamed", func() {
+				So(err, ShouldBeNil)
+				So(e.GetType(), ShouldEqual, bookv1.BookEvent_TYPE_CREATED)
+				So(e.GetBookId(), ShouldEqual, b.ID.Hex())
+				So(e.GetBook().GetTitle(), ShouldEqual, "Dune")
+			})
+		})
+
+		Convey("When the health of the service is checked", func() {
+			resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: "book.v1.BookService"})
+
+			Convey("Then it should be serving", func() {
+				So(err, ShouldBeNil)
+				So(resp.GetStatus(), ShouldEqual, healthpb.HealthCheckResponse_SERVING)
+			})
+		})
+	})
+}
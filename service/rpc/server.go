@@ -0,0 +1,27 @@
+package rpc
+
+import (
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	bookv1 "github.com/snehil-sinha/goBookStore/proto/book/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// NewServer returns a gRPC server exposing the book catalog, the standard health
+// checking service and server reflection
+func NewServer(svc book.BookService, s *common.App, opts ...grpc.ServerOption) *grpc.Server {
+	srv := grpc.NewServer(opts...)
+
+	bookv1.RegisterBookServiceServer(srv, NewBookServer(svc, s))
+
+	hs := health.NewServer()
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus(bookv1.BookService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+
+	reflection.Register(srv)
+	return srv
+}
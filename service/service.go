@@ -2,22 +2,31 @@ package service
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	"regexp"
 	"syscall"
 	"time"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/snehil-sinha/goBookStore/common"
 	"github.com/snehil-sinha/goBookStore/db"
+	"github.com/snehil-sinha/goBookStore/models/apikey"
 	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/service/gql"
 	"github.com/snehil-sinha/goBookStore/service/handlers"
+	"github.com/snehil-sinha/goBookStore/service/openapi"
+	"github.com/snehil-sinha/goBookStore/service/rpc"
+	"google.golang.org/grpc"
 )
 
-func setGinMode(env string) {
+func setGinMode(env, mode string) {
+	// an explicitly configured gin mode takes precedence over the env default
+	if mode != "" {
+		gin.SetMode(mode)
+		return
+	}
 	switch env {
 	case "development":
 		gin.SetMode(gin.DebugMode)
@@ -28,26 +37,15 @@ func setGinMode(env string) {
 	}
 }
 
-// Used to start the service
-func Start(s *common.App) *http.Server {
-
-	var err error
-
-	// Flush the buffered logs (if any) after successfully starting the service
-	defer s.Log.Core().Sync()
+// NewRouter returns the gin engine serving every route of the service.
+// Routes registered here must be documented in APISpec.
+func NewRouter(s *common.App) (*gin.Engine, error) {
 
-	err = db.New(s.Log, s.Cfg.GoBookStore.DB, s.Cfg.GoBookStore.URI)
-	if err != nil {
-		s.Log.Fatal(err.Error())
-	} else {
-		s.Log.Info("successfully initialized the GoBookStore")
-	}
-
-	s.Log.Sugar().Infof("starting HTTP listeners [%s:%s]", s.Cfg.Bind, s.Cfg.Port)
+	r := gin.New()
 
-	setGinMode(s.Cfg.Env)
+	r.Use(CustomMethods(r))
 
-	r := gin.New()
+	r.Use(RequestContext(s, apikey.NewAPIKeyService()))
 
 	if gin.Mode() != gin.TestMode {
 		logger := s.Log.Logger
@@ -59,36 +57,127 @@ func Start(s *common.App) *http.Server {
 		r.Use(gin.Recovery())
 	}
 
-	r.Use(cors.New(cors.Config{
-		AllowOriginFunc: func(origin string) bool {
-			return regexp.MustCompile(common.GetAllowedOriginsRegex()).MatchString(origin)
-		},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "HEAD"},
-		AllowHeaders:     []string{"Authorization", "Content-Type", "X-Requested-With"},
-		ExposeHeaders:    []string{"Authorization"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
+	corsMiddleware, err := Cors(s)
+	if err != nil {
+		return nil, err
+	}
+	r.Use(corsMiddleware)
+
+	r.Use(RateLimit(s))
+
+	r.Use(Idempotency(s))
+
+	// responses are only validated outside of production
+	doc := APISpec()
+	validateResponses := s.Cfg.Validation.Responses && s.Cfg.Env != "production"
+	r.Use(ValidateRequests(doc, s.Log, validateResponses))
 
 	r.GET("/health", handlers.PingHandler()) // health check
 
-	bs := book.NewBookService()
+	r.GET("/openapi.json", handlers.OpenAPIHandler(doc))
+	r.GET("/docs", handlers.DocsHandler())
+	r.GET(handlers.RedocPath, handlers.RedocHandler())
+
+	bs := book.NewBookService(s.Events)
 
-	v1 := r.Group("/api/v1")
+	admin := r.Group("/admin", AdminAuth(s))
 	{
-		v1.GET("/ping", handlers.PingHandler())
-		v1.GET("/books", handlers.FindBooksHandler(bs, s))
-		v1.GET("/books/:id", handlers.FindBookHandler(bs, s))
-		v1.POST("/books", handlers.CreateBookHandler(s))
-		v1.PUT("/books/:id", handlers.UpdateBookHandler(s))
-		v1.DELETE("/books/:id", handlers.DeleteBookHandler(s))
+		admin.GET("/loglevel", handlers.GetLogLevelHandler(s))
+		admin.PUT("/loglevel", handlers.SetLogLevelHandler(s))
+		admin.GET("/audit", handlers.SearchAuditHandler(bs, s))
+	}
 
+	graphQL, err := gql.New(bs, s, gql.Limits{
+		MaxDepth:      s.Cfg.GraphQL.MaxDepth,
+		MaxComplexity: s.Cfg.GraphQL.MaxComplexity,
+	})
+	if err != nil {
+		return nil, err
 	}
+	// GraphiQL is only served in development
+	graphQLHandler := handlers.GraphQLHandler(graphQL, s.Cfg.Env == "development")
+	r.GET("/graphql", graphQLHandler)
+	r.POST("/graphql", graphQLHandler)
+
+	// the book endpoints answer in the format negotiated from the Accept header,
+	// except the exports which have their own format parameter
+	negotiate := handlers.Negotiate(handlers.ResponseFormats...)
+	negotiateCollection := handlers.Negotiate(handlers.CollectionFormats...)
+	// deleted books go to the trash, only admins may purge them at once
+	hardDeleteAuth := AdminAuthIf(s, handlers.IsHardDelete)
+
+	v1 := NewVersion(V1, nil)
+	v1.Handle(http.MethodGet, "/ping", handlers.PingHandler())
+	v1.Handle(http.MethodGet, "/books", negotiateCollection, handlers.FindBooksHandler(bs, s))
+	v1.Handle(http.MethodGet, "/books/:id", negotiate, handlers.FindBookHandler(bs, s))
+	v1.Handle(http.MethodGet, "/books/export", handlers.ExportBooksHandler(bs, s))
+	v1.Handle(http.MethodGet, "/books/events", handlers.BookEventsHandler(s))
+	v1.Handle(http.MethodGet, "/books/ws", handlers.BookSubscriptionsHandler(s))
+	v1.Handle(http.MethodPost, "/books/import", negotiate, handlers.ImportBooksHandler(bs, s))
+	v1.Handle(http.MethodPost, "/books/import/onix", negotiate, handlers.ImportONIXHandler(bs, s))
+	v1.Handle(http.MethodPost, "/books/import/marc", negotiate, handlers.ImportMARCHandler(bs, s))
+	v1.Handle(http.MethodPost, "/books", negotiate, handlers.CreateBookHandler(bs, s))
+	v1.Handle(http.MethodPut, "/books/:id", negotiate, handlers.UpdateBookHandler(bs, s))
+	v1.Handle(http.MethodDelete, "/books/:id", hardDeleteAuth, negotiate, handlers.DeleteBookHandler(bs, s))
+	v1.Handle(http.MethodGet, "/books/trash", negotiateCollection, handlers.TrashBooksHandler(bs, s))
+	v1.Handle(http.MethodPost, "/books/:id/restore", negotiate, handlers.RestoreBookHandler(bs, s))
+	v1.Handle(http.MethodGet, "/books/:id/history", negotiate, handlers.BookHistoryHandler(bs, s))
+	v1.Handle(http.MethodGet, "/books/:id/revisions/:rev", negotiate, handlers.BookRevisionHandler(bs, s))
+	v1.Handle(http.MethodPost, "/books/:id/revert", negotiate, handlers.RevertBookHandler(bs, s))
+	v1.Handle(http.MethodPost, openapi.CustomMethod("/books", "batch"), negotiate, handlers.BatchBooksHandler(bs, s))
+
+	// v2 answers with an envelope holding the links of the resources, see
+	// handlers.Envelope, and serves the other routes of v1 as they are
+	negotiateV2 := handlers.Negotiate(handlers.V2Formats...)
+	v2 := NewVersion(V2, v1)
+	v2.Handle(http.MethodGet, "/books", handlers.Negotiate(handlers.V2CollectionFormats...), handlers.ListBooksV2Handler(bs, s))
+	v2.Handle(http.MethodGet, "/books/:id", negotiateV2, handlers.FindBookV2Handler(bs, s))
+	v2.Handle(http.MethodPost, "/books", negotiateV2, handlers.CreateBookV2Handler(bs, s))
+	v2.Handle(http.MethodPut, "/books/:id", negotiateV2, handlers.UpdateBookV2Handler(bs, s))
+	v2.Handle(http.MethodDelete, "/books/:id", hardDeleteAuth, negotiateV2, handlers.DeleteBookV2Handler(bs, s))
 
+	RegisterVersions(r, s, v1, v2)
+
+	return r, nil
+}
+
+// Used to start the service
+func Start(s *common.App) *http.Server {
+
+	var err error
+
+	// Flush the buffered logs (if any) after successfully starting the service
+	defer s.Log.Core().Sync()
+
+	err = db.New(s.Log, s.Cfg.GoBookStore.DB, s.Cfg.GoBookStore.URI)
+	if err != nil {
+		s.Log.Fatal(err.Error())
+	} else {
+		s.Log.Info("successfully initialized the GoBookStore")
+	}
+
+	if err = Migrate(context.Background(), s); err != nil {
+		s.Log.Fatal("error migrating the DB: " + err.Error())
+	}
+
+	s.Log.Sugar().Infof("starting HTTP listeners [%s:%s]", s.Cfg.Bind, s.Cfg.Port)
+
+	setGinMode(s.Cfg.Env, s.Cfg.GinMode)
+
+	r, err := NewRouter(s)
+	if err != nil {
+		s.Log.Fatal(err.Error())
+	}
+
+	// the event feeds never finish on their own, so their requests are cancelled
+	// once the server shuts down
+	base, cancel := context.WithCancel(context.Background())
 	server := &http.Server{
-		Addr:    s.Cfg.Bind + ":" + s.Cfg.Port,
-		Handler: r,
+		Addr:        s.Cfg.Bind + ":" + s.Cfg.Port,
+		Handler:     SelectVersion(r, Versions...),
+		BaseContext: func(net.Listener) context.Context { return base },
 	}
+	server.RegisterOnShutdown(cancel)
 
 	go func() {
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -98,6 +187,33 @@ func Start(s *common.App) *http.Server {
 	return server
 }
 
+// StartGRPC starts the gRPC server of the book catalog, once the DB is initialized
+// by Start. It returns nil if gRPC is disabled.
+func StartGRPC(s *common.App) *grpc.Server {
+
+	if !s.Cfg.GRPC.Enabled {
+		return nil
+	}
+
+	addr := s.Cfg.Bind + ":" + s.Cfg.GRPC.Port
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		s.Log.Sugar().Fatalf("Could not listen on %s %v", addr, err)
+	}
+
+	s.Log.Sugar().Infof("starting gRPC listeners [%s]", addr)
+
+	keys := apikey.NewAPIKeyService()
+	server := rpc.NewServer(book.NewBookService(s.Events), s,
+		grpc.UnaryInterceptor(RequestInterceptor(s, keys)), grpc.StreamInterceptor(StreamRequestInterceptor(s, keys)))
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			s.Log.Sugar().Fatalf("Could not serve gRPC on %s %v", addr, err)
+		}
+	}()
+	return server
+}
+
 func WaitForShutdown() {
 	// Wait for a signal to shutdown
 	quit := make(chan os.Signal, 1)
@@ -105,7 +221,9 @@ func WaitForShutdown() {
 	<-quit
 }
 
-func GracefullyShutDownServer(log *common.Logger, server *http.Server) {
+// GracefullyShutDownServer stops the HTTP server and the gRPC server (if not nil),
+// waiting for the active requests to finish
+func GracefullyShutDownServer(log *common.Logger, server *http.Server, grpcServer *grpc.Server) {
 
 	log.Sugar().Info("Server shutting down...")
 
@@ -117,4 +235,22 @@ func GracefullyShutDownServer(log *common.Logger, server *http.Server) {
 	} else {
 		log.Sugar().Info("Server stopped")
 	}
+
+	if grpcServer == nil {
+		return
+	}
+
+	// open streams never finish on their own, so cut them off after the same deadline
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		log.Sugar().Info("gRPC server stopped")
+	case <-ctx.Done():
+		grpcServer.Stop()
+		log.Sugar().Warn("gRPC server stopped, cancelling the open streams")
+	}
 }
@@ -0,0 +1,756 @@
+package service
+
+// OpenAPI description of every route registered in NewRouter.
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-contrib/sse"
+	"github.com/snehil-sinha/goBookStore/catalog"
+	"github.com/snehil-sinha/goBookStore/catalog/marc"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/service/handlers"
+	"github.com/snehil-sinha/goBookStore/service/openapi"
+)
+
+// APIVersion is the version of the API described by the OpenAPI document
+const APIVersion = "1.0.0"
+
+// APISpec returns the OpenAPI document of the service
+func APISpec() *openapi.Document {
+	doc := openapi.New("GoBookStore API", APIVersion)
+	doc.Info.Description = "RESTful CRUD API for managing the books of a bookstore."
+
+	doc.Components.SecuritySchemes["adminToken"] = &openapi.SecurityScheme{
+		Type:        "http",
+		Scheme:      "bearer",
+		Description: "The admin.token of the service configuration",
+	}
+	adminOnly := []openapi.SecurityRequirement{{"adminToken": {}}}
+	doc.Components.SecuritySchemes["apiKey"] = &openapi.SecurityScheme{
+		Type:        "apiKey",
+		In:          "header",
+		Name:        APIKeyHeader,
+		Description: "An API key created with the apikey command, identifying the client in the audit log; required if auth.require_api_key is set",
+	}
+	// the keys are optional unless auth.require_api_key is set
+	doc.Security = []openapi.SecurityRequirement{{}, {"apiKey": {}}, {"adminToken": {}}}
+
+	bookSchema := doc.AddSchema("Book", openapi.SchemaOf(book.Book{}).MarkReadOnly("id", "created_at", "updated_at", "deleted_at", "revision"))
+	bookUpdateSchema := openapi.SchemaOf(book.Book{}).MarkReadOnly("id", "created_at", "updated_at", "deleted_at", "revision").Optional()
+	bookUpdateSchema.Description = "The fields to change; the fields left out, null or empty keep their stored value, so they cannot be cleared"
+	bookUpdate := doc.AddSchema("BookUpdate", bookUpdateSchema)
+	errSchema := doc.AddSchema("Error", openapi.Object(map[string]*openapi.Schema{
+		"error": openapi.String(),
+	}))
+	logLevels := doc.AddSchema("LogLevels", openapi.SchemaOf(handlers.LogLevels{}))
+
+	text := func(description string) *openapi.Response {
+		return &openapi.Response{
+			Description: description,
+			Content:     map[string]*openapi.MediaType{"text/plain": {Schema: openapi.String()}},
+		}
+	}
+	json := func(description string, s *openapi.Schema) *openapi.Response {
+		return &openapi.Response{Description: description, Content: openapi.JSON(s)}
+	}
+	data := func(s *openapi.Schema) *openapi.Schema {
+		return openapi.Object(map[string]*openapi.Schema{"data": s})
+	}
+	badRequest := json("Invalid request", errSchema)
+	notFound := json("Book not found", errSchema)
+	serverError := json("Unexpected server error", errSchema)
+	unauthorized := json("Missing or invalid admin token", errSchema)
+	forbidden := json("Admin API disabled", errSchema)
+
+	bookID := &openapi.Parameter{
+		Name:        "id",
+		In:          "path",
+		Description: "ID of the book",
+		Required:    true,
+		Schema:      openapi.ObjectID(),
+	}
+	hardDelete := &openapi.Parameter{
+		Name:        "hard",
+		In:          "query",
+		Description: "Purge the book at once rather than moving it to the trash, for admins only",
+		Schema:      &openapi.Schema{Type: "boolean"},
+	}
+
+	doc.AddOperation(http.MethodGet, "/health", &openapi.Operation{
+		OperationID: "health",
+		Summary:     "Health check",
+		Tags:        []string{"health"},
+		Responses:   map[string]*openapi.Response{"200": text("Service is up")},
+	})
+	doc.AddOperation(http.MethodGet, "/openapi.json", &openapi.Operation{
+		OperationID: "getOpenAPI",
+		Summary:     "This OpenAPI document",
+		Tags:        []string{"docs"},
+		Responses:   map[string]*openapi.Response{"200": json("OpenAPI document", &openapi.Schema{Type: "object"})},
+	})
+	doc.AddOperation(http.MethodGet, "/docs", &openapi.Operation{
+		OperationID: "getDocs",
+		Summary:     "API reference rendered from the OpenAPI document",
+		Tags:        []string{"docs"},
+		Responses: map[string]*openapi.Response{"200": {
+			Description: "HTML page",
+			Content:     map[string]*openapi.MediaType{"text/html": {Schema: openapi.String()}},
+		}},
+	})
+	doc.AddOperation(http.MethodGet, handlers.RedocPath, &openapi.Operation{
+		OperationID: "getRedoc",
+		Summary:     "Redoc bundle of the API reference, served with the service",
+		Tags:        []string{"docs"},
+		Responses: map[string]*openapi.Response{
+			"200": {
+				Description: "JavaScript bundle",
+				Content:     map[string]*openapi.MediaType{"text/javascript": {Schema: openapi.String()}},
+			},
+			"404": json("The bundle is not vendored in this build", errSchema),
+		},
+	})
+
+	doc.AddOperation(http.MethodGet, "/admin/loglevel", &openapi.Operation{
+		OperationID: "getLogLevel",
+		Summary:     "Get the console and file log levels",
+		Tags:        []string{"admin"},
+		Security:    adminOnly,
+		Responses: map[string]*openapi.Response{
+			"200": json("Current log levels", logLevels),
+			"401": unauthorized,
+			"403": forbidden,
+		},
+	})
+	doc.AddOperation(http.MethodPut, "/admin/loglevel", &openapi.Operation{
+		OperationID: "setLogLevel",
+		Summary:     "Change the console and/or file log levels",
+		Tags:        []string{"admin"},
+		Security:    adminOnly,
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(logLevels)},
+		Responses: map[string]*openapi.Response{
+			"200": json("New log levels", logLevels),
+			"400": badRequest,
+			"401": unauthorized,
+			"403": forbidden,
+		},
+	})
+
+	// the audit records are documented along with the book history
+	auditPage := openapi.Object(map[string]*openapi.Schema{
+		"data": openapi.Array(openapi.Ref("AuditRecord")),
+		"meta": openapi.SchemaOf(handlers.Meta{}),
+	})
+	dateTime := &openapi.Schema{Type: "string", Format: "date-time"}
+	one, maxPerPage := 1.0, float64(handlers.MaxPerPage)
+	doc.AddOperation(http.MethodGet, "/admin/audit", &openapi.Operation{
+		OperationID: "searchAudit",
+		Summary:     "Search the audit log of the books",
+		Description: "The records matching every parameter, latest first.",
+		Tags:        []string{"admin", "audit"},
+		Security:    adminOnly,
+		Parameters: []*openapi.Parameter{
+			{Name: "book_id", In: "query", Description: "ID of the changed book", Schema: openapi.ObjectID()},
+			{Name: "actor", In: "query", Description: "Actor of the change, e.g. admin, anonymous or system", Schema: openapi.String()},
+			{Name: "request_id", In: "query", Description: "X-Request-ID of the request making the change", Schema: openapi.String()},
+			{Name: "operation", In: "query", Schema: &openapi.Schema{Type: "string", Enum: []any{
+				string(book.OpCreate), string(book.OpUpdate), string(book.OpDelete), string(book.OpRestore), string(book.OpPurge), string(book.OpRevert),
+			}}},
+			{Name: "field", In: "query", Description: "Field changed, e.g. pages", Schema: openapi.String()},
+			{Name: "from", In: "query", Description: "Start of the period, inclusive", Schema: dateTime},
+			{Name: "to", In: "query", Description: "End of the period, exclusive", Schema: dateTime},
+			{Name: "page", In: "query", Description: "Page number, from 1", Schema: &openapi.Schema{Type: "integer", Minimum: &one}},
+			{Name: "per_page", In: "query", Description: "Number of records of a page", Schema: &openapi.Schema{Type: "integer", Minimum: &one, Maximum: &maxPerPage}},
+		},
+		Responses: map[string]*openapi.Response{
+			"200": json("A page of audit records", auditPage),
+			"400": badRequest,
+			"401": unauthorized,
+			"403": forbidden,
+			"500": serverError,
+		},
+	})
+
+	graphQLRequest := doc.AddSchema("GraphQLRequest", &openapi.Schema{
+		Type:     "object",
+		Required: []string{"query"},
+		Properties: map[string]*openapi.Schema{
+			"query":         openapi.String(),
+			"operationName": openapi.String(),
+			"variables":     {Type: "object"},
+		},
+	})
+	graphQLResult := doc.AddSchema("GraphQLResult", &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"data":   {Description: "Result of the operation, null if it could not run"},
+			"errors": openapi.Array(&openapi.Schema{Type: "object"}),
+		},
+	})
+	doc.AddOperation(http.MethodGet, "/graphql", &openapi.Operation{
+		OperationID: "graphQLQuery",
+		Summary:     "Run a GraphQL query",
+		Description: "Mutations are only allowed in POST requests. In development, browsers requesting the page without a query get GraphiQL.",
+		Tags:        []string{"graphql"},
+		Parameters: []*openapi.Parameter{
+			{Name: "query", In: "query", Description: "GraphQL document", Schema: openapi.String()},
+			{Name: "operationName", In: "query", Description: "Operation of the document to run", Schema: openapi.String()},
+			{Name: "variables", In: "query", Description: "JSON object of the variables", Schema: openapi.String()},
+		},
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "Result of the operation, or GraphiQL", Content: map[string]*openapi.MediaType{
+				"application/json": {Schema: graphQLResult},
+				"text/html":        {Schema: openapi.String()},
+			}},
+			"400": json("Missing query or invalid variables", graphQLResult),
+			"405": json("Mutation in a GET request", graphQLResult),
+		},
+	})
+	doc.AddOperation(http.MethodPost, "/graphql", &openapi.Operation{
+		OperationID: "graphQL",
+		Summary:     "Run a GraphQL query or mutation",
+		Tags:        []string{"graphql"},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(graphQLRequest)},
+		Responses: map[string]*openapi.Response{
+			"200": json("Result of the operation", graphQLResult),
+			"400": json("Invalid request", graphQLResult),
+		},
+	})
+
+	doc.AddOperation(http.MethodGet, "/api/v1/ping", &openapi.Operation{
+		OperationID: "ping",
+		Summary:     "Ping",
+		Tags:        []string{"health"},
+		Responses:   map[string]*openapi.Response{"200": text("Pong!")},
+	})
+	doc.AddOperation(http.MethodGet, "/api/v1/books", &openapi.Operation{
+		OperationID: "listBooks",
+		Summary:     "Get all books",
+		Tags:        []string{"books"},
+		Responses: map[string]*openapi.Response{
+			"200": json("All books", data(openapi.Array(bookSchema))),
+			"500": serverError,
+		},
+	})
+	doc.AddOperation(http.MethodGet, "/api/v1/books/:id", &openapi.Operation{
+		OperationID: "getBook",
+		Summary:     "Get a book by ID",
+		Tags:        []string{"books"},
+		Parameters: []*openapi.Parameter{bookID,
+			{Name: "as_of", In: "query", Description: "RFC 3339 time to read the book as it was at, from its revisions", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+		},
+		Responses: map[string]*openapi.Response{
+			"200": json("The book", data(bookSchema)),
+			"400": badRequest,
+			"404": notFound,
+			"500": serverError,
+		},
+	})
+	doc.AddOperation(http.MethodPost, "/api/v1/books", &openapi.Operation{
+		OperationID: "createBook",
+		Summary:     "Create a book",
+		Description: "A book with the same title and page count must not exist already.",
+		Tags:        []string{"books"},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(bookSchema)},
+		Responses: map[string]*openapi.Response{
+			"201": json("The created book", bookSchema),
+			"400": badRequest,
+			"500": serverError,
+		},
+	})
+	doc.AddOperation(http.MethodPut, "/api/v1/books/:id", &openapi.Operation{
+		OperationID: "updateBook",
+		Summary:     "Update a book by ID",
+		Description: "Only the fields present in the body are changed.",
+		Tags:        []string{"books"},
+		Parameters:  []*openapi.Parameter{bookID},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(bookUpdate)},
+		Responses: map[string]*openapi.Response{
+			"200": json("The updated book", bookSchema),
+			"400": badRequest,
+			"404": notFound,
+			"409": json("The book was changed by another request, read it and retry", errSchema),
+			"500": serverError,
+		},
+	})
+	doc.AddOperation(http.MethodDelete, "/api/v1/books/:id", &openapi.Operation{
+		OperationID: "deleteBook",
+		Summary:     "Delete a book by ID",
+		Description: "The book is moved to the trash, from which it can be restored until it is purged.",
+		Tags:        []string{"books"},
+		Parameters:  []*openapi.Parameter{bookID, hardDelete},
+		Responses: map[string]*openapi.Response{
+			"200": json("Book deleted", openapi.String()),
+			"400": badRequest,
+			"401": unauthorized,
+			"403": forbidden,
+			"404": notFound,
+			"500": serverError,
+		},
+	})
+	auditRecord := doc.AddSchema("AuditRecord", openapi.SchemaOf(book.AuditRecord{}))
+	doc.AddOperation(http.MethodGet, "/api/v1/books/:id/history", &openapi.Operation{
+		OperationID: "getBookHistory",
+		Summary:     "Get the audit records of a book by ID",
+		Description: "Every change made to the book, oldest first, with the fields it changed. The history of a purged book is kept.",
+		Tags:        []string{"books", "audit"},
+		Parameters:  []*openapi.Parameter{bookID},
+		Responses: map[string]*openapi.Response{
+			"200": json("The audit records of the book", data(openapi.Array(auditRecord))),
+			"400": badRequest,
+			"500": serverError,
+		},
+	})
+	revisionSchema := doc.AddSchema("Revision", openapi.SchemaOf(book.Revision{}))
+	doc.AddOperation(http.MethodGet, "/api/v1/books/:id/revisions/:rev", &openapi.Operation{
+		OperationID: "getBookRevision",
+		Summary:     "Get a revision of a book by ID",
+		Description: "A revision is stored each time the book is created, updated or reverted, numbered from 1. " +
+			"The revision field of a book is its current revision.",
+		Tags: []string{"books"},
+		Parameters: []*openapi.Parameter{bookID,
+			{Name: "rev", In: "path", Required: true, Description: "Revision number", Schema: &openapi.Schema{Type: "integer", Minimum: &one}},
+		},
+		Responses: map[string]*openapi.Response{
+			"200": json("The revision", data(revisionSchema)),
+			"400": badRequest,
+			"404": notFound,
+			"500": serverError,
+		},
+	})
+	// the body is bound by gin, whose binding tags SchemaOf does not read
+	revertRequest := openapi.SchemaOf(handlers.RevertRequest{})
+	revertRequest.Required = []string{"revision"}
+	revertRequest.Properties["revision"].Minimum = &one
+	doc.AddOperation(http.MethodPost, "/api/v1/books/:id/revert", &openapi.Operation{
+		OperationID: "revertBook",
+		Summary:     "Revert a book by ID to a previous revision",
+		Description: "The fields of the book are set back to the ones of the revision, making a new revision.",
+		Tags:        []string{"books"},
+		Parameters:  []*openapi.Parameter{bookID},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(revertRequest)},
+		Responses: map[string]*openapi.Response{
+			"200": json("The reverted book", bookSchema),
+			"400": badRequest,
+			"404": json("Book or revision not found", errSchema),
+			"409": json("Another book has the title and page count of the revision, or the book was changed by another request", errSchema),
+			"500": serverError,
+		},
+	})
+	doc.AddOperation(http.MethodGet, "/api/v1/books/trash", &openapi.Operation{
+		OperationID: "listTrash",
+		Summary:     "Get the deleted books",
+		Description: "Deleted books are kept in the trash until they are purged, after trash.retention.",
+		Tags:        []string{"books"},
+		Responses: map[string]*openapi.Response{
+			"200": json("The deleted books", data(openapi.Array(bookSchema))),
+			"500": serverError,
+		},
+	})
+	doc.AddOperation(http.MethodPost, "/api/v1/books/:id/restore", &openapi.Operation{
+		OperationID: "restoreBook",
+		Summary:     "Restore a deleted book by ID",
+		Tags:        []string{"books"},
+		Parameters:  []*openapi.Parameter{bookID},
+		Responses: map[string]*openapi.Response{
+			"200": json("The restored book", bookSchema),
+			"400": badRequest,
+			"404": json("Book not in the trash", errSchema),
+			"409": json("A book with the same title and page count was created since", errSchema),
+			"500": serverError,
+		},
+	})
+
+	enum := func(values []string) []any {
+		out := make([]any, len(values))
+		for i, v := range values {
+			out[i] = v
+		}
+		return out
+	}
+	formats := enum(catalog.Formats)
+	doc.AddOperation(http.MethodGet, "/api/v1/books/export", &openapi.Operation{
+		OperationID: "exportBooks",
+		Summary:     "Export the books",
+		Description: "The books matching the parameters are streamed, ordered by id.",
+		Tags:        []string{"books", "catalog"},
+		Parameters: []*openapi.Parameter{
+			{Name: "format", In: "query", Description: "Format of the file, csv by default", Schema: &openapi.Schema{Type: "string", Enum: enum(handlers.ExportFormats())}},
+			{Name: "title", In: "query", Description: "Exact title", Schema: openapi.String()},
+			{Name: "title_contains", In: "query", Description: "Case insensitive part of the title", Schema: openapi.String()},
+			{Name: "min_pages", In: "query", Schema: openapi.Integer()},
+			{Name: "max_pages", In: "query", Schema: openapi.Integer()},
+			{Name: "isbn", In: "query", Description: "ISBN-13", Schema: openapi.String()},
+		},
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "The books", Content: map[string]*openapi.MediaType{
+				catalog.ContentTypes[catalog.CSV]:   {Schema: openapi.String()},
+				catalog.ContentTypes[catalog.JSONL]: {Schema: openapi.String()},
+				marc.ContentTypes[marc.Binary]:      {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+				marc.ContentTypes[marc.XML]:         {Schema: openapi.String()},
+			}},
+			"400": badRequest,
+			"500": serverError,
+		},
+	})
+
+	doc.AddOperation(http.MethodGet, "/api/v1/books/events", &openapi.Operation{
+		OperationID: "getBookEvents",
+		Summary:     "Stream the changes made to the books",
+		Description: "Server-sent events named created, updated or deleted, whose data is a BookEvent, " +
+			"with a heartbeat comment every events.heartbeat. A client resuming with the ID of the last event it received " +
+			"first gets the events it missed, or a reset event if they are no longer available. " +
+			"Only the changes made through this instance since it started are streamed.",
+		Tags: []string{"books", "events"},
+		Parameters: []*openapi.Parameter{
+			{Name: handlers.LastEventIDHeader, In: "header", Description: "ID of the last event received, to resume the feed", Schema: openapi.String()},
+			{Name: "last_event_id", In: "query", Description: "Same as the Last-Event-ID header, for the clients which cannot set it", Schema: openapi.String()},
+		},
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "The events", Content: map[string]*openapi.MediaType{
+				sse.ContentType: {Schema: openapi.String()},
+			}},
+			"503": json("Events are not enabled", errSchema),
+		},
+	})
+	doc.AddSchema("BookEvent", openapi.SchemaOf(handlers.BookEvent{}))
+	doc.AddOperation(http.MethodGet, "/api/v1/books/ws", &openapi.Operation{
+		OperationID: "subscribeBooks",
+		Summary:     "Subscribe to the changes made to the books over WebSocket",
+		Description: "The client sends subscribe and unsubscribe SubscriptionMessages for the topics books (every change), " +
+			"books/<id> (a book) or books?<query> (the books matching the title, title_contains, isbn, min_pages and max_pages parameters), " +
+			"and receives an event message for every change matching its topics, and a heartbeat every events.heartbeat. " +
+			"Clients falling behind are disconnected. Only the changes made through this instance are sent.",
+		Tags: []string{"books", "events"},
+		Responses: map[string]*openapi.Response{
+			"101": {Description: "Switched to the WebSocket protocol"},
+			"400": json("Not a WebSocket handshake", errSchema),
+			"503": json("Events are not enabled", errSchema),
+		},
+	})
+	doc.AddSchema("SubscriptionMessage", openapi.SchemaOf(handlers.SubscriptionMessage{}))
+
+	recordReport := openapi.SchemaOf(catalog.RecordReport{})
+	recordReport.Properties["invalid"] = openapi.Array(openapi.SchemaOf(catalog.FieldError{}))
+	importReport := openapi.SchemaOf(catalog.Report{})
+	importReport.Properties["records"] = openapi.Array(recordReport)
+	importReportSchema := doc.AddSchema("ImportReport", importReport)
+	upload := &openapi.Schema{Type: "string", Format: "binary"}
+	doc.AddOperation(http.MethodPost, "/api/v1/books/import", &openapi.Operation{
+		OperationID: "importBooks",
+		Summary:     "Import a CSV or JSON Lines file",
+		Description: "Records matching a stored book by key update the fields they set, the others create a book. " +
+			"Invalid records are reported and skipped. The size of the file is limited by import.max_size_mb.",
+		Tags: []string{"books", "catalog"},
+		Parameters: []*openapi.Parameter{
+			{Name: "format", In: "query", Description: "Format of the file, by default taken from its extension or the content type", Schema: &openapi.Schema{Type: "string", Enum: formats}},
+			{Name: "key", In: "query", Description: "Field matching the records to the stored books, title by default", Schema: &openapi.Schema{Type: "string", Enum: []any{catalog.KeyID, catalog.KeyTitle}}},
+			{Name: "dry_run", In: "query", Description: "Validate the records without writing anything", Schema: &openapi.Schema{Type: "boolean"}},
+			{Name: "map", In: "query", Description: "Maps a CSV header to a field, e.g. Book Title=title; may be repeated", Schema: openapi.String()},
+		},
+		RequestBody: &openapi.RequestBody{Required: true, Content: map[string]*openapi.MediaType{
+			"multipart/form-data": {Schema: &openapi.Schema{
+				Type:       "object",
+				Required:   []string{"file"},
+				Properties: map[string]*openapi.Schema{"file": upload},
+			}},
+			catalog.ContentTypes[catalog.CSV]:   {Schema: upload},
+			catalog.ContentTypes[catalog.JSONL]: {Schema: upload},
+			"application/jsonl":                 {Schema: upload},
+		}},
+		Responses: map[string]*openapi.Response{
+			"200": json("Report of the import", data(importReportSchema)),
+			"400": badRequest,
+			"500": serverError,
+		},
+	})
+
+	doc.AddOperation(http.MethodPost, "/api/v1/books/import/onix", &openapi.Operation{
+		OperationID: "importONIX",
+		Summary:     "Import an ONIX 3.0 message",
+		Description: "The products are upserted by record reference, so importing a message again changes nothing, " +
+			"and products notified as deleted delete their book. The unmapped and invalid fields of every product are reported. " +
+			"The size of the message is limited by import.max_size_mb.",
+		Tags: []string{"books", "catalog"},
+		Parameters: []*openapi.Parameter{
+			{Name: "dry_run", In: "query", Description: "Map and validate the products without writing anything", Schema: &openapi.Schema{Type: "boolean"}},
+		},
+		RequestBody: &openapi.RequestBody{Required: true, Content: map[string]*openapi.MediaType{
+			"multipart/form-data": {Schema: &openapi.Schema{
+				Type:       "object",
+				Required:   []string{"file"},
+				Properties: map[string]*openapi.Schema{"file": upload},
+			}},
+			"application/xml": {Schema: upload},
+			"text/xml":        {Schema: upload},
+		}},
+		Responses: map[string]*openapi.Response{
+			"200": json("Report of the import", data(importReportSchema)),
+			"400": badRequest,
+			"500": serverError,
+		},
+	})
+
+	doc.AddOperation(http.MethodPost, "/api/v1/books/import/marc", &openapi.Operation{
+		OperationID: "importMARC",
+		Summary:     "Import a MARC 21 file, in ISO 2709 or MARCXML",
+		Description: "Records of books already in the catalog, with the same ISBN or the same title and page count, are skipped, " +
+			"as well as the duplicates within the file. The size of the file is limited by import.max_size_mb.",
+		Tags: []string{"books", "catalog"},
+		Parameters: []*openapi.Parameter{
+			{Name: "format", In: "query", Description: "Format of the file, by default taken from its extension or the content type", Schema: &openapi.Schema{Type: "string", Enum: enum(marc.Formats)}},
+			{Name: "dry_run", In: "query", Description: "Validate the records without writing anything", Schema: &openapi.Schema{Type: "boolean"}},
+		},
+		RequestBody: &openapi.RequestBody{Required: true, Content: map[string]*openapi.MediaType{
+			"multipart/form-data": {Schema: &openapi.Schema{
+				Type:       "object",
+				Required:   []string{"file"},
+				Properties: map[string]*openapi.Schema{"file": upload},
+			}},
+			marc.ContentTypes[marc.Binary]: {Schema: upload},
+			marc.ContentTypes[marc.XML]:    {Schema: upload},
+			"application/xml":              {Schema: upload},
+			"text/xml":                     {Schema: upload},
+		}},
+		Responses: map[string]*openapi.Response{
+			"200": json("Report of the import", data(importReportSchema)),
+			"400": badRequest,
+			"500": serverError,
+		},
+	})
+
+	minOperations := 1
+	batchRequest := doc.AddSchema("BatchRequest", &openapi.Schema{
+		Type:     "object",
+		Required: []string{"operations"},
+		Properties: map[string]*openapi.Schema{
+			"mode": {
+				Type:        "string",
+				Enum:        []any{handlers.BatchAtomic, handlers.BatchBestEffort},
+				Description: "atomic applies all the operations or none, best_effort (the default) applies the valid ones",
+			},
+			"operations": {
+				Type:     "array",
+				MinItems: &minOperations,
+				Items: &openapi.Schema{
+					Type:     "object",
+					Required: []string{"op"},
+					Properties: map[string]*openapi.Schema{
+						"op":   {Type: "string", Enum: []any{string(book.OpCreate), string(book.OpUpdate), string(book.OpDelete)}},
+						"id":   {Type: "string", Description: "ID of the book to update or delete"},
+						"book": bookUpdate,
+					},
+				},
+			},
+		},
+	})
+	batchResult := doc.AddSchema("BatchResult", &openapi.Schema{
+		Type:     "object",
+		Required: []string{"index", "status"},
+		Properties: map[string]*openapi.Schema{
+			"index":  openapi.Integer(),
+			"status": {Type: "integer", Description: "HTTP status of the operation"},
+			"id":     openapi.String(),
+			"error":  openapi.String(),
+			"book":   bookSchema,
+		},
+	})
+	doc.AddOperation(http.MethodPost, openapi.CustomMethod("/api/v1/books", "batch"), &openapi.Operation{
+		OperationID: "batchBooks",
+		Summary:     "Create, update and delete books in a batch",
+		Description: "Duplicates are checked against the stored books and between the operations of the batch. " +
+			"The size of a batch is limited by batch.max_operations.",
+		Tags:        []string{"books"},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(batchRequest)},
+		Responses: map[string]*openapi.Response{
+			"200": json("Every operation was applied", data(openapi.Array(batchResult))),
+			"207": json("Some operations failed, or none was applied in atomic mode", data(openapi.Array(batchResult))),
+			"400": badRequest,
+			"500": serverError,
+		},
+	})
+
+	minPage := 1.0
+	links := doc.AddSchema("Links", openapi.SchemaOf(handlers.Links{}))
+	bookEnvelope := doc.AddSchema("BookEnvelope", openapi.Object(map[string]*openapi.Schema{
+		"data":  bookSchema,
+		"links": links,
+	}))
+	bookPage := doc.AddSchema("BookPage", openapi.Object(map[string]*openapi.Schema{
+		"data":  openapi.Array(bookSchema),
+		"links": links,
+		"meta":  openapi.SchemaOf(handlers.Meta{}),
+	}))
+	doc.AddOperation(http.MethodGet, "/api/v2/books", &openapi.Operation{
+		OperationID: "listBooksV2",
+		Summary:     "Get a page of books",
+		Description: "The books are ordered by id. The links lead to the next and previous pages.",
+		Tags:        []string{"books", "v2"},
+		Parameters: []*openapi.Parameter{
+			{Name: "page", In: "query", Description: "Page number, from 1", Schema: &openapi.Schema{Type: "integer", Minimum: &minPage}},
+			{Name: "per_page", In: "query", Description: "Number of books of a page", Schema: &openapi.Schema{Type: "integer", Minimum: &one, Maximum: &maxPerPage}},
+		},
+		Responses: map[string]*openapi.Response{
+			"200": json("A page of books", bookPage),
+			"400": badRequest,
+			"500": serverError,
+		},
+	})
+	doc.AddOperation(http.MethodGet, "/api/v2/books/:id", &openapi.Operation{
+		OperationID: "getBookV2",
+		Summary:     "Get a book by ID",
+		Tags:        []string{"books", "v2"},
+		Parameters:  []*openapi.Parameter{bookID},
+		Responses: map[string]*openapi.Response{
+			"200": json("The book", bookEnvelope),
+			"400": badRequest,
+			"404": notFound,
+			"500": serverError,
+		},
+	})
+	created := json("The created book", bookEnvelope)
+	created.Headers = map[string]*openapi.Header{
+		"Location": {Description: "Path of the created book", Schema: openapi.String()},
+	}
+	doc.AddOperation(http.MethodPost, "/api/v2/books", &openapi.Operation{
+		OperationID: "createBookV2",
+		Summary:     "Create a book",
+		Description: "A book with the same title and page count must not exist already.",
+		Tags:        []string{"books", "v2"},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(bookSchema)},
+		Responses: map[string]*openapi.Response{
+			"201": created,
+			"400": badRequest,
+			"409": json("A book with the same title and page count exists already", errSchema),
+			"500": serverError,
+		},
+	})
+	doc.AddOperation(http.MethodPut, "/api/v2/books/:id", &openapi.Operation{
+		OperationID: "updateBookV2",
+		Summary:     "Update a book by ID",
+		Description: "Only the fields present in the body are changed.",
+		Tags:        []string{"books", "v2"},
+		Parameters:  []*openapi.Parameter{bookID},
+		RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(bookUpdate)},
+		Responses: map[string]*openapi.Response{
+			"200": json("The updated book", bookEnvelope),
+			"400": badRequest,
+			"404": notFound,
+			"409": json("Another book has the new title and page count, or the book was changed by another request, read it and retry", errSchema),
+			"500": serverError,
+		},
+	})
+	doc.AddOperation(http.MethodDelete, "/api/v2/books/:id", &openapi.Operation{
+		OperationID: "deleteBookV2",
+		Summary:     "Delete a book by ID",
+		Description: "The book is moved to the trash, from which it can be restored until it is purged.",
+		Tags:        []string{"books", "v2"},
+		Parameters:  []*openapi.Parameter{bookID, hardDelete},
+		Responses: map[string]*openapi.Response{
+			"204": {Description: "Book deleted"},
+			"400": badRequest,
+			"401": unauthorized,
+			"403": forbidden,
+			"404": notFound,
+			"500": serverError,
+		},
+	})
+
+	// the book endpoints answer in the format negotiated from the Accept header
+	negotiated := []struct {
+		method, path string
+		formats      []string
+	}{
+		{http.MethodGet, "/api/v1/books", handlers.CollectionFormats},
+		{http.MethodGet, "/api/v1/books/:id", handlers.ResponseFormats},
+		{http.MethodPost, "/api/v1/books", handlers.ResponseFormats},
+		{http.MethodPut, "/api/v1/books/:id", handlers.ResponseFormats},
+		{http.MethodDelete, "/api/v1/books/:id", handlers.ResponseFormats},
+		{http.MethodGet, "/api/v1/books/trash", handlers.CollectionFormats},
+		{http.MethodPost, "/api/v1/books/:id/restore", handlers.ResponseFormats},
+		{http.MethodGet, "/api/v1/books/:id/history", handlers.ResponseFormats},
+		{http.MethodGet, "/api/v1/books/:id/revisions/:rev", handlers.ResponseFormats},
+		{http.MethodPost, "/api/v1/books/:id/revert", handlers.ResponseFormats},
+		{http.MethodPost, "/api/v1/books/import", handlers.ResponseFormats},
+		{http.MethodPost, "/api/v1/books/import/onix", handlers.ResponseFormats},
+		{http.MethodPost, "/api/v1/books/import/marc", handlers.ResponseFormats},
+		{http.MethodPost, openapi.CustomMethod("/api/v1/books", "batch"), handlers.ResponseFormats},
+		{http.MethodGet, "/api/v2/books", handlers.V2CollectionFormats},
+		{http.MethodGet, "/api/v2/books/:id", handlers.V2Formats},
+		{http.MethodPost, "/api/v2/books", handlers.V2Formats},
+		{http.MethodPut, "/api/v2/books/:id", handlers.V2Formats},
+		{http.MethodDelete, "/api/v2/books/:id", handlers.V2Formats},
+	}
+	for _, n := range negotiated {
+		op := doc.Operation(n.method, n.path)
+		for status, resp := range op.Responses {
+			media, ok := resp.Content["application/json"]
+			if !ok {
+				continue
+			}
+			content := map[string]*openapi.MediaType{}
+			for _, f := range n.formats {
+				for _, mt := range handlers.MediaTypes[f] {
+					switch f {
+					case handlers.FormatCSV:
+						content[mt] = &openapi.MediaType{Schema: openapi.String()}
+					case handlers.FormatHAL, handlers.FormatJSONAPI:
+						// the resources are rendered differently, see handlers.Envelope
+						content[mt] = &openapi.MediaType{Schema: &openapi.Schema{Type: "object"}}
+					default:
+						content[mt] = media
+					}
+				}
+			}
+			// the responses are shared between the operations, so they are copied
+			op.Responses[status] = &openapi.Response{Description: resp.Description, Headers: resp.Headers, Content: content}
+		}
+		op.Responses["406"] = json("None of the accepted media types can be returned", errSchema)
+	}
+
+	// the POST requests may be retried with an Idempotency-Key, see Idempotency
+	maxKeyLength := MaxIdempotencyKeyLength
+	idempotencyKey := &openapi.Parameter{
+		Name:        IdempotencyKeyHeader,
+		In:          "header",
+		Description: "Key of the request, whose response is replayed for the retries with the same key",
+		Schema:      &openapi.Schema{Type: "string", MaxLength: &maxKeyLength},
+	}
+	for _, item := range doc.Paths {
+		op, ok := item["post"]
+		if !ok {
+			continue
+		}
+		op.Parameters = append(append([]*openapi.Parameter{}, op.Parameters...), idempotencyKey)
+		inProgress := "A request with the same Idempotency-Key is in progress"
+		if conflict, ok := op.Responses["409"]; ok {
+			inProgress = conflict.Description + ", or a request with the same Idempotency-Key is in progress"
+		}
+		op.Responses["409"] = json(inProgress, errSchema)
+		op.Responses["422"] = json("The Idempotency-Key was used for another request", errSchema)
+	}
+
+	// v2 serves the operations of v1 it does not redefine
+	for path, item := range doc.Paths {
+		rest, ok := strings.CutPrefix(path, "/api/v1/")
+		if !ok {
+			continue
+		}
+		v2Path := "/api/v2/" + rest
+		for method, op := range item {
+			if doc.Paths[v2Path][method] != nil {
+				continue
+			}
+			if doc.Paths[v2Path] == nil {
+				doc.Paths[v2Path] = make(openapi.PathItem)
+			}
+			inherited := *op
+			inherited.OperationID += "V2"
+			inherited.Tags = append(append([]string{}, op.Tags...), "v2")
+			doc.Paths[v2Path][method] = &inherited
+		}
+	}
+
+	return doc
+}
@@ -0,0 +1,114 @@
+package service_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/service"
+	"github.com/snehil-sinha/goBookStore/service/handlers"
+	"github.com/snehil-sinha/goBookStore/service/openapi"
+)
+
+func TestAPISpecCoversRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given the router and the OpenAPI document of the service", t, func() {
+		r, err := service.NewRouter(newTestApp(common.DefaultConfig()))
+		So(err, ShouldBeNil)
+		doc := service.APISpec()
+
+		Convey("Then every registered route should be documented", func() {
+			for _, route := range r.Routes() {
+				So(doc.Operation(route.Method, route.Path), ShouldNotBeNil)
+			}
+		})
+
+		Convey("Then every documented operation should be a registered route", func() {
+			routes := map[string]bool{}
+			for _, route := range r.Routes() {
+				routes[strings.ToLower(route.Method)+" "+openapi.PathFromGin(route.Path)] = true
+			}
+			for path, item := range doc.Paths {
+				for method := range item {
+					So(routes, ShouldContainKey, method+" "+path)
+				}
+			}
+		})
+	})
+}
+
+func TestAPISpecBookSchema(t *testing.T) {
+	Convey("Given the Book schema of the OpenAPI document", t, func() {
+		book := service.APISpec().Components.Schemas["Book"]
+		So(book, ShouldNotBeNil)
+
+		Convey("Then the validate constraints should be part of the schema", func() {
+			So(book.Required, ShouldResemble, []string{"title", "pages"})
+			So(*book.Properties["pages"].Minimum, ShouldEqual, 1)
+			So(*book.Properties["title"].MinLength, ShouldEqual, 1)
+		})
+
+		Convey("Then the fields managed by the server should be read only", func() {
+			So(book.Properties["id"].ReadOnly, ShouldBeTrue)
+			So(book.Properties["id"].Pattern, ShouldEqual, openapi.ObjectIDPattern)
+			So(book.Properties["created_at"].Format, ShouldEqual, "date-time")
+		})
+	})
+}
+
+func TestOpenAPIEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given the router of the service", t, func() {
+		r, err := service.NewRouter(newTestApp(common.DefaultConfig()))
+		So(err, ShouldBeNil)
+
+		Convey("When GET /openapi.json is requested", func() {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+			Convey("Then it should return the OpenAPI 3.1 document", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+
+				var doc openapi.Document
+				So(json.Unmarshal(w.Body.Bytes(), &doc), ShouldBeNil)
+				So(doc.OpenAPI, ShouldEqual, "3.1.0")
+				So(doc.Paths, ShouldContainKey, "/api/v1/books/{id}")
+			})
+		})
+
+		Convey("When GET /docs is requested", func() {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+
+			Convey("Then it should return the HTML page", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Content-Type"), ShouldStartWith, "text/html")
+				So(w.Body.String(), ShouldContainSubstring, "/openapi.json")
+				So(w.Body.String(), ShouldContainSubstring, "redoc.standalone.js")
+			})
+		})
+
+		Convey("When the Redoc bundle is requested", func() {
+			page := httptest.NewRecorder()
+			r.ServeHTTP(page, httptest.NewRequest(http.MethodGet, "/docs", nil))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, handlers.RedocPath, nil))
+
+			Convey("Then it should be served with the service if it is vendored, and loaded by the docs", func() {
+				if strings.Contains(page.Body.String(), `src="`+handlers.RedocPath+`"`) {
+					So(w.Code, ShouldEqual, http.StatusOK)
+					So(w.Header().Get("Content-Type"), ShouldStartWith, "text/javascript")
+				} else {
+					So(w.Code, ShouldEqual, http.StatusNotFound)
+				}
+			})
+		})
+	})
+}
@@ -0,0 +1,42 @@
+package service
+
+// Purge of the books kept in the trash past their retention.
+
+import (
+	"context"
+	"time"
+
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"go.uber.org/zap"
+)
+
+// PurgeTrash permanently deletes the books deleted more than trash.retention ago,
+// at start and then every trash.purge_interval, until ctx is done. It returns at
+// once if the retention is 0, the deleted books then being kept until they are
+// purged by hand.
+func PurgeTrash(ctx context.Context, s *common.App, svc book.BookService) {
+	cfg := s.Cfg.Trash
+	if cfg.Retention <= 0 || cfg.PurgeInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(cfg.PurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		before := time.Now().Add(-cfg.Retention)
+		n, err := svc.PurgeDeleted(ctx, s.Log, before)
+		if err != nil {
+			s.Log.Error("error purging the trash", zap.Error(err))
+		} else if n > 0 {
+			s.Log.Info("purged the trash", zap.Int64("books", n), zap.Time("deleted_before", before))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
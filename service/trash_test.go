@@ -0,0 +1,82 @@
+package service_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/models/book/mocks"
+	"github.com/snehil-sinha/goBookStore/service"
+)
+
+func TestPurgeTrash(t *testing.T) {
+	Convey("Given a trash retention of a day", t, func() {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		cfg := common.DefaultConfig()
+		cfg.Trash.Retention = 24 * time.Hour
+		cfg.Trash.PurgeInterval = 10 * time.Millisecond
+		m := mocks.NewMockBookService(ctrl)
+
+		Convey("When the purge job runs", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var cutoffs []time.Time
+			m.EXPECT().PurgeDeleted(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, _ *common.Logger, before time.Time) (int64, error) {
+				cutoffs = append(cutoffs, before)
+				if len(cutoffs) == 2 {
+					cancel()
+				}
+				return 1, nil
+			}).Times(2)
+
+			start := time.Now()
+			service.PurgeTrash(ctx, newTestApp(cfg), m)
+
+			Convey("Then the books deleted before the retention should be purged on every tick", func() {
+				So(cutoffs, ShouldHaveLength, 2)
+				for _, before := range cutoffs {
+					So(before, ShouldHappenOnOrBetween, start.Add(-24*time.Hour), time.Now().Add(-24*time.Hour))
+				}
+			})
+		})
+
+		Convey("When the retention is 0", func() {
+			cfg.Trash.Retention = 0
+
+			Convey("Then the purge job should return at once", func() {
+				service.PurgeTrash(context.Background(), newTestApp(cfg), m)
+			})
+		})
+	})
+}
+
+func TestHardDeleteRequiresAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given the router with an admin token", t, func() {
+		cfg := common.DefaultConfig()
+		cfg.Admin.Token = "s3cret"
+		r, err := service.NewRouter(newTestApp(cfg))
+		So(err, ShouldBeNil)
+
+		Convey("When a book is hard deleted without the token", func() {
+			for _, path := range []string{"/api/v1/books/61733b8e9c483c721f65b21d", "/api/v2/books/61733b8e9c483c721f65b21d"} {
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, path+"?hard=true", nil))
+
+				Convey("Then "+path+" should return a 401 status code", func() {
+					So(w.Code, ShouldEqual, http.StatusUnauthorized)
+				})
+			}
+		})
+	})
+}
@@ -0,0 +1,210 @@
+package service
+
+// Validation of requests and responses against the OpenAPI document.
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/service/openapi"
+	"go.uber.org/zap"
+)
+
+// ValidationError is the body of a response to a request rejected by the
+// validation middleware
+type ValidationError struct {
+	Error      string              `json:"error"`
+	Violations []openapi.Violation `json:"violations"`
+}
+
+// ValidateRequests returns a gin.HandlerFunc (middleware) rejecting requests which do
+// not conform to their operation in doc: path, query and header parameters, the
+// content type and the body. Violations are answered with a 400, or a 415 for an
+// unsupported content type. With validateResponses, the responses are validated as
+// well and their violations logged.
+func ValidateRequests(doc *openapi.Document, log *common.Logger, validateResponses bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		op := doc.Operation(c.Request.Method, c.FullPath())
+		if op == nil {
+			c.Next()
+			return
+		}
+
+		status, violations := validateRequest(doc, op, c)
+		if len(violations) > 0 {
+			abortWithViolations(c, status, violations)
+			return
+		}
+
+		if !validateResponses {
+			c.Next()
+			return
+		}
+
+		rec := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+		c.Next()
+
+		for _, v := range validateResponse(doc, op, rec) {
+			log.Error("response does not conform to the OpenAPI document",
+				zap.String("method", c.Request.Method),
+				zap.String("route", c.FullPath()),
+				zap.Int("status", rec.Status()),
+				zap.String("field", v.Field),
+				zap.String("violation", v.Message),
+			)
+		}
+	}
+}
+
+func abortWithViolations(c *gin.Context, status int, violations []openapi.Violation) {
+	msgs := make([]string, len(violations))
+	for i, v := range violations {
+		msgs[i] = v.String()
+	}
+	c.AbortWithStatusJSON(status, ValidationError{
+		Error:      "invalid request: " + strings.Join(msgs, "; "),
+		Violations: violations,
+	})
+}
+
+func validateRequest(doc *openapi.Document, op *openapi.Operation, c *gin.Context) (int, []openapi.Violation) {
+	var violations []openapi.Violation
+
+	for _, p := range op.Parameters {
+		var (
+			raw     string
+			present bool
+		)
+		switch p.In {
+		case "path":
+			raw = c.Param(p.Name)
+			present = raw != ""
+		case "query":
+			raw, present = c.GetQuery(p.Name)
+		case "header":
+			raw = c.GetHeader(p.Name)
+			present = raw != ""
+		}
+		violations = append(violations, doc.ValidateParam(p, raw, present)...)
+	}
+
+	if op.RequestBody == nil {
+		return http.StatusBadRequest, violations
+	}
+
+	if c.Request.ContentLength == 0 {
+		return http.StatusBadRequest, append(violations, missingBody(op)...)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+	media, ok := op.RequestBody.Content[mediaType]
+	if !ok {
+		return http.StatusUnsupportedMediaType, append(violations, openapi.Violation{
+			In:      "header",
+			Field:   "Content-Type",
+			Message: "must be one of " + strings.Join(openapi.MediaTypes(op.RequestBody.Content), ", "),
+		})
+	}
+
+	// other bodies (e.g. uploads) are left to the handlers, without reading them
+	if !openapi.IsJSON(mediaType) || media.Schema == nil {
+		return http.StatusBadRequest, violations
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return http.StatusBadRequest, append(violations, openapi.Violation{In: "body", Message: "could not be read"})
+	}
+	// let the handlers read the body again
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	if len(body) == 0 {
+		return http.StatusBadRequest, append(violations, missingBody(op)...)
+	}
+
+	value, err := decodeJSON(body)
+	if err != nil {
+		return http.StatusBadRequest, append(violations, openapi.Violation{In: "body", Message: "must be valid JSON: " + err.Error()})
+	}
+	return http.StatusBadRequest, append(violations, doc.ValidateValue(media.Schema, value, "body", "")...)
+}
+
+func missingBody(op *openapi.Operation) []openapi.Violation {
+	if op.RequestBody.Required {
+		return []openapi.Violation{{In: "body", Message: "is required"}}
+	}
+	return nil
+}
+
+func validateResponse(doc *openapi.Document, op *openapi.Operation, rec *bodyRecorder) []openapi.Violation {
+	resp, ok := op.Responses[strconv.Itoa(rec.Status())]
+	if !ok {
+		if resp, ok = op.Responses["default"]; !ok {
+			return []openapi.Violation{{In: "response", Message: "undocumented status " + strconv.Itoa(rec.Status())}}
+		}
+	}
+
+	if rec.Size() <= 0 || len(resp.Content) == 0 {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	media, ok := resp.Content[mediaType]
+	if !ok {
+		return []openapi.Violation{{
+			In:      "response",
+			Field:   "Content-Type",
+			Message: "must be one of " + strings.Join(openapi.MediaTypes(resp.Content), ", "),
+		}}
+	}
+	if !openapi.IsJSON(mediaType) || media.Schema == nil {
+		return nil
+	}
+
+	value, err := decodeJSON(rec.body.Bytes())
+	if err != nil {
+		return []openapi.Violation{{In: "response", Message: "must be valid JSON: " + err.Error()}}
+	}
+	return doc.ValidateValue(media.Schema, value, "response", "")
+}
+
+func decodeJSON(data []byte) (value any, err error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	err = dec.Decode(&value)
+	return
+}
+
+// bodyRecorder keeps a copy of the JSON response body written through it. The
+// other bodies are not validated, and may be long streams such as the events.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	if w.recording() {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	if w.recording() {
+		w.body.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *bodyRecorder) recording() bool {
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	return openapi.IsJSON(mediaType)
+}
@@ -0,0 +1,173 @@
+package service_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/service"
+	"github.com/snehil-sinha/goBookStore/service/openapi"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func serve(r http.Handler, method, path, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func decodeValidationError(w *httptest.ResponseRecorder) service.ValidationError {
+	var resp service.ValidationError
+	So(json.Unmarshal(w.Body.Bytes(), &resp), ShouldBeNil)
+	return resp
+}
+
+func TestValidateRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given the router of the service", t, func() {
+		r, err := service.NewRouter(newTestApp(common.DefaultConfig()))
+		So(err, ShouldBeNil)
+
+		Convey("When a book is requested with an ID which is not an ObjectID", func() {
+			w := serve(r, http.MethodGet, "/api/v1/books/abcd", "", "")
+
+			Convey("Then it should be rejected with a path violation", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				resp := decodeValidationError(w)
+				So(resp.Violations, ShouldResemble, []openapi.Violation{
+					{In: "path", Field: "id", Message: "must be a hex encoded ObjectID"},
+				})
+			})
+		})
+
+		Convey("When a book is created with a negative page count and no title", func() {
+			w := serve(r, http.MethodPost, "/api/v1/books", "application/json", `{"pages": -1}`)
+
+			Convey("Then every body violation should be reported", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				resp := decodeValidationError(w)
+				So(resp.Violations, ShouldResemble, []openapi.Violation{
+					{In: "body", Field: "title", Message: "is required"},
+					{In: "body", Field: "pages", Message: "must be greater than or equal to 1"},
+				})
+				So(resp.Error, ShouldStartWith, "invalid request: body title: is required")
+			})
+		})
+
+		Convey("When a book is created with a page count of the wrong type", func() {
+			w := serve(r, http.MethodPost, "/api/v1/books", "application/json", `{"title": "Dune", "pages": "many"}`)
+
+			Convey("Then it should be rejected", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(decodeValidationError(w).Violations[0].Field, ShouldEqual, "pages")
+			})
+		})
+
+		Convey("When a book is created with an unsupported content type", func() {
+			w := serve(r, http.MethodPost, "/api/v1/books", "text/plain", `title=Dune`)
+
+			Convey("Then it should be rejected with a 415", func() {
+				So(w.Code, ShouldEqual, http.StatusUnsupportedMediaType)
+				So(decodeValidationError(w).Violations[0].Field, ShouldEqual, "Content-Type")
+			})
+		})
+
+		Convey("When a book is updated without a body", func() {
+			w := serve(r, http.MethodPut, "/api/v1/books/61733b8e9c483c721f65b21d", "application/json", "")
+
+			Convey("Then it should be rejected", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(decodeValidationError(w).Violations[0].In, ShouldEqual, "body")
+			})
+		})
+	})
+}
+
+func TestValidateResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given a handler answering with a body which does not match the document", t, func() {
+		doc := openapi.New("test", "1")
+		doc.AddOperation(http.MethodGet, "/books/:id", &openapi.Operation{
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "ok", Content: openapi.JSON(openapi.Object(map[string]*openapi.Schema{
+					"pages": openapi.Integer(),
+				}))},
+			},
+		})
+
+		core, logs := observer.New(zap.ErrorLevel)
+		log := &common.Logger{Logger: zap.New(core)}
+
+		r := gin.New()
+		r.Use(service.ValidateRequests(doc, log, true))
+		r.GET("/books/:id", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"pages": "many"})
+		})
+
+		Convey("When the route is requested", func() {
+			w := serve(r, http.MethodGet, "/books/1", "", "")
+
+			Convey("Then the response should be sent unchanged", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldEqual, `{"pages":"many"}`)
+			})
+
+			Convey("Then the violation should be logged", func() {
+				So(logs.Len(), ShouldEqual, 1)
+				So(logs.All()[0].ContextMap()["field"], ShouldEqual, "pages")
+			})
+		})
+
+		Convey("When the handler answers with an undocumented content type", func() {
+			r.GET("/books/:id/text", func(c *gin.Context) {
+				c.String(http.StatusOK, "many")
+			})
+			doc.AddOperation(http.MethodGet, "/books/:id/text", doc.Operation(http.MethodGet, "/books/:id"))
+			w := serve(r, http.MethodGet, "/books/1/text", "", "")
+
+			Convey("Then the content type should be reported although the body is not kept", func() {
+				So(w.Body.String(), ShouldEqual, "many")
+				So(logs.Len(), ShouldEqual, 1)
+				So(logs.All()[0].ContextMap()["field"], ShouldEqual, "Content-Type")
+			})
+		})
+	})
+}
+
+func TestCustomMethods(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given the router of the service", t, func() {
+		r, err := service.NewRouter(newTestApp(common.DefaultConfig()))
+		So(err, ShouldBeNil)
+
+		Convey("When a batch without operations is posted to the custom method", func() {
+			w := serve(r, http.MethodPost, "/api/v1/books:batch", "application/json", `{"operations": []}`)
+
+			Convey("Then it should be routed and validated against its operation", func() {
+				So(w.Code, ShouldEqual, http.StatusBadRequest)
+				So(decodeValidationError(w).Violations[0].Field, ShouldEqual, "operations")
+			})
+		})
+
+		Convey("When an unknown custom method is requested", func() {
+			w := serve(r, http.MethodPost, "/api/v1/books:merge", "application/json", `{}`)
+
+			Convey("Then it should not be found", func() {
+				So(w.Code, ShouldEqual, http.StatusNotFound)
+			})
+		})
+	})
+}
@@ -0,0 +1,183 @@
+package service
+
+// Versions of the REST API, served under /api/<version>.
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/snehil-sinha/goBookStore/common"
+	"go.uber.org/zap"
+)
+
+// Names of the API versions
+const (
+	V1 = "v1"
+	V2 = "v2"
+)
+
+// Versions lists the API versions, oldest first
+var Versions = []string{V1, V2}
+
+// Version is the route table of a version of the REST API. A version serves the
+// routes of the version it inherits from, unless it registers its own.
+type Version struct {
+	Name     string
+	Inherits *Version
+	routes   []versionRoute
+}
+
+type versionRoute struct {
+	method, path string
+	handlers     []gin.HandlerFunc
+}
+
+// NewVersion returns an empty version, inheriting the routes of parent (which may be nil)
+func NewVersion(name string, parent *Version) *Version {
+	return &Version{Name: name, Inherits: parent}
+}
+
+// Handle registers the handlers of a route of the version, its path being
+// relative to the prefix of the version
+func (v *Version) Handle(method, path string, handlers ...gin.HandlerFunc) {
+	v.routes = append(v.routes, versionRoute{method: method, path: path, handlers: handlers})
+}
+
+// allRoutes returns the routes served by the version, its own ones first
+func (v *Version) allRoutes() []versionRoute {
+	routes := append([]versionRoute{}, v.routes...)
+	if v.Inherits == nil {
+		return routes
+	}
+	own := map[string]bool{}
+	for _, r := range v.routes {
+		own[r.method+" "+r.path] = true
+	}
+	for _, r := range v.Inherits.allRoutes() {
+		if !own[r.method+" "+r.path] {
+			routes = append(routes, r)
+		}
+	}
+	return routes
+}
+
+// Prefix returns the path prefix of the version
+func (v *Version) Prefix() string {
+	return "/api/" + v.Name
+}
+
+// RegisterVersions registers the routes of versions, oldest first, along with
+// the deprecation middleware of the versions with a policy in s.Cfg.Versions
+func RegisterVersions(r *gin.Engine, s *common.App, versions ...*Version) {
+	for i, v := range versions {
+		g := r.Group(v.Prefix())
+		if policy := s.Cfg.Versions[v.Name]; policy.Deprecated() {
+			var successor *Version
+			if i+1 < len(versions) {
+				successor = versions[i+1]
+			}
+			g.Use(Deprecation(s.Log, v, successor, policy))
+		}
+		for _, route := range v.allRoutes() {
+			g.Handle(route.method, route.path, route.handlers...)
+		}
+	}
+}
+
+// Deprecation returns a gin.HandlerFunc (middleware) announcing the deprecation of
+// a version with the Deprecation (RFC 9745) and Sunset (RFC 8594) headers, and a
+// link to the same resource in the successor version (which may be nil). Every
+// request is logged along with its client. Past the sunset, requests are answered
+// with a 410.
+func Deprecation(log *common.Logger, v, successor *Version, policy common.VersionPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !policy.Deprecation.IsZero() {
+			c.Header("Deprecation", "@"+strconv.FormatInt(policy.Deprecation.Unix(), 10))
+		}
+		if !policy.Sunset.IsZero() {
+			c.Header("Sunset", policy.Sunset.UTC().Format(http.TimeFormat))
+		}
+		if successor != nil {
+			link := successor.Prefix() + strings.TrimPrefix(c.Request.URL.Path, v.Prefix())
+			c.Header("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, link))
+		}
+
+		log.Info("deprecated API version used",
+			zap.String("version", v.Name),
+			zap.String("method", c.Request.Method),
+			zap.String("route", c.FullPath()),
+			zap.String("client_ip", c.ClientIP()),
+			zap.String("user_agent", c.Request.UserAgent()),
+		)
+
+		if !policy.Sunset.IsZero() && !time.Now().Before(policy.Sunset) {
+			c.AbortWithStatusJSON(http.StatusGone, gin.H{
+				"error": fmt.Sprintf("API %s was retired on %s", v.Name, policy.Sunset.UTC().Format(time.RFC3339)),
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// SelectVersion returns a handler serving the unversioned paths of the API, e.g.
+// /api/books, with the version given by the version parameter of the Accept
+// header (e.g. application/json; version=2), the last one of versions by default.
+// Other requests are passed to next unchanged.
+func SelectVersion(next http.Handler, versions ...string) http.Handler {
+	known := map[string]bool{}
+	for _, v := range versions {
+		known[v] = true
+	}
+	latest := versions[len(versions)-1]
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rest, ok := strings.CutPrefix(req.URL.Path, "/api/")
+		segment, _, _ := strings.Cut(rest, "/")
+		if !ok || known[segment] {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		version := latest
+		if requested := acceptedVersion(req.Header.Get("Accept")); requested != "" {
+			if !strings.HasPrefix(requested, "v") {
+				requested = "v" + requested
+			}
+			if !known[requested] {
+				body, _ := json.Marshal(gin.H{
+					"error": fmt.Sprintf("unknown API version %q, must be one of %s", requested, strings.Join(versions, ", ")),
+				})
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				w.WriteHeader(http.StatusNotAcceptable)
+				_, _ = w.Write(body)
+				return
+			}
+			version = requested
+		}
+
+		w.Header().Add("Vary", "Accept")
+		r := req.Clone(req.Context())
+		r.URL.Path = "/api/" + version + "/" + rest
+		r.URL.RawPath = ""
+		next.ServeHTTP(w, r)
+	})
+}
+
+// acceptedVersion returns the version parameter of the first media range of an
+// Accept header which has one
+func acceptedVersion(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		_, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && params["version"] != "" {
+			return params["version"]
+		}
+	}
+	return ""
+}
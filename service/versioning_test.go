@@ -0,0 +1,104 @@
+package service_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/snehil-sinha/goBookStore/common"
+	"github.com/snehil-sinha/goBookStore/service"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestVersions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	Convey("Given the router of the service with v1 deprecated", t, func() {
+		cfg := common.DefaultConfig()
+		deprecation := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		sunset := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+		cfg.Versions = map[string]common.VersionPolicy{
+			service.V1: {Deprecation: deprecation, Sunset: sunset},
+		}
+		core, logs := observer.New(zap.InfoLevel)
+		app := &common.App{Cfg: cfg, Log: &common.Logger{Logger: zap.New(core)}}
+
+		r, err := service.NewRouter(app)
+		So(err, ShouldBeNil)
+		h := service.SelectVersion(r, service.Versions...)
+
+		get := func(path, accept string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Accept", accept)
+			req.Header.Set("User-Agent", "legacy-client/1.0")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			return w
+		}
+
+		Convey("When a v1 route is requested", func() {
+			w := get("/api/v1/ping", "")
+
+			Convey("Then the deprecation should be announced", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Deprecation"), ShouldEqual, "@1704067200")
+				So(w.Header().Get("Sunset"), ShouldEqual, sunset.Format(http.TimeFormat))
+				So(w.Header().Get("Link"), ShouldEqual, `</api/v2/ping>; rel="successor-version"`)
+			})
+
+			Convey("Then the use of v1 should be logged with the client", func() {
+				entries := logs.FilterMessage("deprecated API version used").All()
+				So(entries, ShouldHaveLength, 1)
+				So(entries[0].ContextMap()["user_agent"], ShouldEqual, "legacy-client/1.0")
+				So(entries[0].ContextMap()["route"], ShouldEqual, "/api/v1/ping")
+			})
+		})
+
+		Convey("When a v1 route is requested on v2", func() {
+			w := get("/api/v2/ping", "")
+
+			Convey("Then it should be served without a deprecation", func() {
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Body.String(), ShouldEqual, "Pong!")
+				So(w.Header().Get("Deprecation"), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When an unversioned path is requested", func() {
+			Convey("Then the version of the Accept header should be served", func() {
+				w := get("/api/ping", "text/plain; version=1")
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Deprecation"), ShouldNotBeEmpty)
+				So(w.Header().Get("Vary"), ShouldEqual, "Accept")
+			})
+
+			Convey("Then the latest version should be served by default", func() {
+				w := get("/api/ping", "")
+				So(w.Code, ShouldEqual, http.StatusOK)
+				So(w.Header().Get("Deprecation"), ShouldBeEmpty)
+			})
+
+			Convey("Then an unknown version should return a 406 status code", func() {
+				w := get("/api/ping", "text/plain; version=3")
+				So(w.Code, ShouldEqual, http.StatusNotAcceptable)
+				So(w.Body.String(), ShouldContainSubstring, `unknown API version \"v3\"`)
+			})
+		})
+
+		Convey("When v1 is past its sunset", func() {
+			cfg.Versions[service.V1] = common.VersionPolicy{Deprecation: deprecation, Sunset: deprecation.Add(time.Hour)}
+			r, err := service.NewRouter(app)
+			So(err, ShouldBeNil)
+
+			w := serve(r, http.MethodGet, "/api/v1/ping", "", "")
+
+			Convey("Then it should return a 410 status code", func() {
+				So(w.Code, ShouldEqual, http.StatusGone)
+			})
+		})
+	})
+}
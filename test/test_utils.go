@@ -5,23 +5,38 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
+	"runtime"
 	"syscall"
 	"testing"
 
+	"github.com/kamva/mgm/v3"
 	"github.com/snehil-sinha/goBookStore/common"
 	"github.com/snehil-sinha/goBookStore/db"
+	"github.com/snehil-sinha/goBookStore/events"
+	"github.com/snehil-sinha/goBookStore/models/book"
+	"github.com/snehil-sinha/goBookStore/seed"
 	"github.com/snehil-sinha/goBookStore/service"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
 )
 
 var BaseUrl string
 
-const testConfigPath = "/Users/snehil.sinha/Documents/bookstore/config.yaml"
+// testConfigPath returns the config file used by the tests: $GBS_TEST_CONFIG if set,
+// otherwise the config.yaml at the root of the repository
+func testConfigPath() string {
+	if p := os.Getenv("GBS_TEST_CONFIG"); p != "" {
+		return p
+	}
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "config.yaml")
+}
 
 func LoadTestConfig() (cfg *common.Config, err error) {
 	// load config from yaml file
-	cfg, err = common.LoadConfig(testConfigPath)
+	cfg, err = common.LoadConfig(testConfigPath())
 	if err != nil {
 		err = fmt.Errorf("failed to load the test config file: %s", err)
 		return
@@ -34,7 +49,7 @@ func LoadTestConfig() (cfg *common.Config, err error) {
 }
 
 func GetMockTestLogger(cfg *common.Config) (*common.Logger, error) {
-	log, err := common.NewLogger(cfg.Env, cfg.GoBookStore.LOGPATH)
+	log, err := common.NewLogger(cfg)
 	return log, err
 }
 
@@ -48,8 +63,9 @@ func GetMockAppInstance(cfg *common.Config) (s *common.App, err error) {
 
 	// instantiate the App struct
 	s = &common.App{
-		Cfg: cfg,
-		Log: log,
+		Cfg:    cfg,
+		Log:    log,
+		Events: events.NewBus(),
 	}
 	return
 }
@@ -60,7 +76,7 @@ func StartTestSever(s *common.App) (srv *http.Server, err error) {
 
 func SetupServerShutdown(s *common.App, server *http.Server) {
 	service.WaitForShutdown()
-	service.GracefullyShutDownServer(s.Log, server)
+	service.GracefullyShutDownServer(s.Log, server, nil)
 }
 
 func SignalShutDown(t *testing.T) {
@@ -76,13 +92,20 @@ func SignalShutDown(t *testing.T) {
 
 func ClearDB(ctx context.Context) error {
 	filter := bson.D{}
-	_, err := db.GoBookStore.Collection.DeleteMany(ctx, filter)
-	if err != nil {
-		return fmt.Errorf("failed to clear the DB, %s", err)
+	for _, coll := range []*mgm.Collection{db.GoBookStore, db.BookAudit, db.BookRevisions, db.APIKeys} {
+		if _, err := coll.Collection.DeleteMany(ctx, filter); err != nil {
+			return fmt.Errorf("failed to clear the DB, %s", err)
+		}
 	}
 	return nil
 }
 
+// SeedDB stores the books, e.g. those of seed.ReadFile or seed.Fake, for a test
+// to start from; they are removed by ClearDB
+func SeedDB(books []*book.Book) (*seed.Report, error) {
+	return seed.Books(context.Background(), &common.Logger{Logger: zap.NewNop()}, book.NewBookService(nil), books)
+}
+
 func CloseDBConnection(c *mongo.Client, ctx context.Context) error {
 	err := c.Disconnect(ctx)
 	if err != nil {